@@ -0,0 +1,109 @@
+package headscale
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// accessLogWriter records the status of the response, and the machine and
+// namespace the handler worked on, for the access log line of the request.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status    int
+	machine   string
+	namespace string
+}
+
+func (writer *accessLogWriter) WriteHeader(status int) {
+	if writer.status == 0 {
+		writer.status = status
+	}
+	writer.ResponseWriter.WriteHeader(status)
+}
+
+func (writer *accessLogWriter) Write(data []byte) (int, error) {
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+
+	return writer.ResponseWriter.Write(data)
+}
+
+// Flush keeps the long poll sessions working, they flush every map
+// response to the client.
+func (writer *accessLogWriter) Flush() {
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// setAccessLogMachine adds the machine to the access log line of the
+// request, if access logging is enabled.
+func setAccessLogMachine(writer http.ResponseWriter, machine *Machine) {
+	if logWriter, ok := writer.(*accessLogWriter); ok {
+		logWriter.machine = machine.Hostname
+		logWriter.namespace = machine.Namespace.Name
+	}
+}
+
+// setAccessLogNamespace adds the namespace to the access log line of the
+// request, if access logging is enabled.
+func setAccessLogNamespace(writer http.ResponseWriter, namespace string) {
+	if logWriter, ok := writer.(*accessLogWriter); ok {
+		logWriter.namespace = namespace
+	}
+}
+
+// newAccessLogger returns the logger of the access log. It always writes
+// JSON, whatever log.format is, and is not filtered by log.level.
+func newAccessLogger() zerolog.Logger {
+	return zerolog.New(os.Stdout).With().Timestamp().Logger()
+}
+
+// withAccessLog wraps the handler to emit one JSON line per request when
+// log.access_log is enabled. The handler is returned as is otherwise.
+func (h *Headscale) withAccessLog(
+	name string,
+	handler http.HandlerFunc,
+) http.HandlerFunc {
+	if !h.cfg.Log.AccessLog {
+		return handler
+	}
+
+	return func(writer http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		logWriter := &accessLogWriter{ResponseWriter: writer}
+
+		handler(logWriter, req)
+
+		status := logWriter.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		outcome := "success"
+		if status >= http.StatusBadRequest {
+			outcome = "failure"
+		}
+
+		// the query is left out, the OIDC callback carries the
+		// authorization code in it
+		h.accessLogger.Log().
+			Str("type", "access").
+			Str("handler", name).
+			Str("method", req.Method).
+			Str("path", req.URL.Path).
+			Str("remote_addr", req.RemoteAddr).
+			Str("machine", logWriter.machine).
+			Str("namespace", logWriter.namespace).
+			Int("status", status).
+			Str("outcome", outcome).
+			Dur("duration", time.Since(start)).
+			Send()
+	}
+}
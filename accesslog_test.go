@@ -0,0 +1,53 @@
+package headscale
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestAccessLog(c *check.C) {
+	var buf bytes.Buffer
+	app.accessLogger = zerolog.New(&buf)
+
+	handler := func(writer http.ResponseWriter, req *http.Request) {
+		setAccessLogMachine(writer, &Machine{
+			Hostname:  "testmachine",
+			Namespace: Namespace{Name: "test"},
+		})
+		http.Error(writer, "", http.StatusUnauthorized)
+		writer.(http.Flusher).Flush()
+	}
+
+	// disabled by default
+	app.withAccessLog("PollNetMap", handler)(
+		httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodPost, "/machine/foo/map", nil),
+	)
+	c.Assert(buf.Len(), check.Equals, 0)
+
+	app.cfg.Log.AccessLog = true
+	recorder := httptest.NewRecorder()
+	app.withAccessLog("PollNetMap", handler)(
+		recorder,
+		httptest.NewRequest(http.MethodPost, "/machine/foo/map?bar=baz", nil),
+	)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+	c.Assert(recorder.Flushed, check.Equals, true)
+
+	var line map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &line), check.IsNil)
+	c.Assert(line["type"], check.Equals, "access")
+	c.Assert(line["handler"], check.Equals, "PollNetMap")
+	c.Assert(line["method"], check.Equals, http.MethodPost)
+	c.Assert(line["path"], check.Equals, "/machine/foo/map")
+	c.Assert(line["machine"], check.Equals, "testmachine")
+	c.Assert(line["namespace"], check.Equals, "test")
+	c.Assert(line["status"], check.Equals, float64(http.StatusUnauthorized))
+	c.Assert(line["outcome"], check.Equals, "failure")
+	c.Assert(line["duration"], check.NotNil)
+}
@@ -1,6 +1,7 @@
 package headscale
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,22 +9,88 @@ import (
 	"net/netip"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/rs/zerolog/log"
 	"github.com/tailscale/hujson"
+	"go4.org/netipx"
 	"gopkg.in/yaml.v3"
 	"tailscale.com/tailcfg"
 )
 
+// internetPrefixes are the public ranges autogroup:internet expands to: all
+// the addresses but the tailnet, private and link-local ones, so that the
+// rule does not grant access to the peers of the tailnet.
+var internetPrefixes = func() []string {
+	var builder netipx.IPSetBuilder
+	builder.AddPrefix(ExitRouteV4)
+	builder.AddPrefix(ExitRouteV6)
+	// the ranges of the ip_prefixes, fc00::/7 includes fd7a:115c:a1e0::/48
+	for _, prefix := range privateIPPrefixes {
+		builder.RemovePrefix(prefix)
+	}
+	builder.RemovePrefix(netip.MustParsePrefix("169.254.0.0/16"))
+	builder.RemovePrefix(netip.MustParsePrefix("fe80::/10"))
+
+	ipSet, err := builder.IPSet()
+	if err != nil {
+		panic(err)
+	}
+
+	return ipPrefixToString(ipSet.Prefixes())
+}()
+
+const (
+	// autogroupInternet grants access to the internet through exit nodes.
+	autogroupInternet = "autogroup:internet"
+	// autogroupMembers matches every machine of every namespace.
+	autogroupMembers = "autogroup:members"
+	// autogroupSelf, only allowed as destination, matches the machines of
+	// the namespace of each source.
+	autogroupSelf = "autogroup:self"
+	// autogroupNonRoot, only allowed as SSH user, matches every local user
+	// but root.
+	autogroupNonRoot = "autogroup:nonroot"
+)
+
+const (
+	// groupPatternPrefix marks a group member matching the namespaces with
+	// a regular expression, e.g. pattern:team-.*
+	groupPatternPrefix = "pattern:"
+	// maxGroupPatternLength bounds the length of the group patterns.
+	maxGroupPatternLength = 256
+)
+
 const (
 	errEmptyPolicy       = Error("empty policy")
 	errInvalidAction     = Error("invalid action")
 	errInvalidGroup      = Error("invalid group")
 	errInvalidTag        = Error("invalid tag")
+	errInvalidHost       = Error("invalid host")
 	errInvalidPortFormat = Error("invalid port format")
 	errWildcardIsNeeded  = Error("wildcard as port is required for the protocol")
+	errEmptyAlias        = Error("alias does not match any address")
+	errInvalidAutogroup  = Error("unsupported autogroup")
+	errInvalidProtocol   = Error("invalid protocol")
+	errInvalidSSHUser    = Error("invalid SSH user")
+
+	errSSHCheckNotSupported = Error("the check action of SSH rules is not supported")
+
+	errInvalidAutoApprover = Error("invalid autoApprovers")
+
+	ErrInvalidNamespaceACLPolicy = Error("invalid namespace ACL policy")
+	errNamespaceACLPolicyFields  = Error("a namespace ACL policy can only contain acls")
+	errNamespaceACLPolicyScope   = Error("alias is not the namespace or one of its tags")
+
+	ErrInvalidACLPolicy       = Error("invalid ACL policy")
+	errACLPolicyNotInDatabase = Error("the ACL policy can only be set with acl_policy_mode: database")
+	errACLPolicyNotSet        = Error("no ACL policy is set")
 )
 
 const (
@@ -52,8 +119,70 @@ const (
 	protocolIPv6ICMP = 58  // ICMP for IPv6
 	protocolSCTP     = 132 // Stream Control Transmission Protocol
 	ProtocolFC       = 133 // Fibre Channel
+
+	maxProtocolNumber = 255
 )
 
+// ACLError reports the ACL, and the field of this ACL, making a policy
+// invalid.
+type ACLError struct {
+	Index int
+	Field string
+	Err   error
+}
+
+func (e ACLError) Error() string {
+	return fmt.Sprintf("ACL %d, %s: %s", e.Index, e.Field, e.Err)
+}
+
+func (e ACLError) Unwrap() error {
+	return e.Err
+}
+
+// SSHRuleError reports the SSH rule, and the field of this rule, making a
+// policy invalid.
+type SSHRuleError struct {
+	Index int
+	Field string
+	Err   error
+}
+
+func (e SSHRuleError) Error() string {
+	return fmt.Sprintf("SSH rule %d, %s: %s", e.Index, e.Field, e.Err)
+}
+
+func (e SSHRuleError) Unwrap() error {
+	return e.Err
+}
+
+// TagOwnersError lists every problem found in the tagOwners of a policy,
+// so that they can all be fixed at once.
+type TagOwnersError struct {
+	Problems []string
+}
+
+func (e TagOwnersError) Error() string {
+	return fmt.Sprintf("invalid tagOwners: %s", strings.Join(e.Problems, "; "))
+}
+
+func (e TagOwnersError) Unwrap() error {
+	return errInvalidTag
+}
+
+// GroupsError lists every problem found in the members of the groups of
+// a policy, so that they can all be fixed at once.
+type GroupsError struct {
+	Problems []string
+}
+
+func (e GroupsError) Error() string {
+	return fmt.Sprintf("invalid groups: %s", strings.Join(e.Problems, "; "))
+}
+
+func (e GroupsError) Unwrap() error {
+	return errInvalidGroup
+}
+
 // LoadACLPolicy loads the ACL policy from the specify path, and generates the ACL rules.
 func (h *Headscale) LoadACLPolicy(path string) error {
 	log.Debug().
@@ -67,124 +196,1607 @@ func (h *Headscale) LoadACLPolicy(path string) error {
 	}
 	defer policyFile.Close()
 
-	var policy ACLPolicy
 	policyBytes, err := io.ReadAll(policyFile)
 	if err != nil {
 		return err
 	}
 
-	switch filepath.Ext(path) {
-	case ".yml", ".yaml":
-		log.Debug().
+	warnings, err := h.applyACLPolicy("LoadACLPolicy", policyBytes, filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		log.Warn().
+			Str("func", "LoadACLPolicy").
 			Str("path", path).
-			Bytes("file", policyBytes).
-			Msg("Loading ACLs from YAML")
+			Msg(warning)
+	}
+
+	return nil
+}
+
+// LoadACLPolicyFromDB loads the ACL policy stored in the database, and
+// generates the ACL rules. Nothing is loaded when no policy is stored yet.
+func (h *Headscale) LoadACLPolicyFromDB() error {
+	_, err := h.loadDatabaseACLPolicy(true)
+
+	return err
+}
+
+// loadDatabaseACLPolicy applies the ACL policy stored in the database.
+// Unless force is set, the policy is only applied when it differs from the
+// one last loaded from the database. It reports whether it was applied.
+func (h *Headscale) loadDatabaseACLPolicy(force bool) (bool, error) {
+	h.databaseACLPolicyMutex.Lock()
+	defer h.databaseACLPolicyMutex.Unlock()
+
+	value, err := h.getValue(aclPolicyKey)
+	if errors.Is(err, errValueNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !force && value == h.databaseACLPolicy {
+		return false, nil
+	}
+	// recorded even if the policy cannot be applied, it is only reported
+	// once
+	h.databaseACLPolicy = value
+
+	warnings, err := h.applyACLPolicy("LoadACLPolicyFromDB", []byte(value), "")
+	if err != nil {
+		return false, err
+	}
+	for _, warning := range warnings {
+		log.Warn().
+			Str("func", "LoadACLPolicyFromDB").
+			Msg(warning)
+	}
+
+	return true, nil
+}
+
+// applyACLPolicy parses the policy, generates its rules and replaces the
+// current policy with it. It returns the warnings of the policy.
+func (h *Headscale) applyACLPolicy(
+	caller string,
+	policyBytes []byte,
+	extension string,
+) ([]string, error) {
+	policy, err := parseACLPolicy(policyBytes, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.IsZero() {
+		return nil, errEmptyPolicy
+	}
+
+	groupsWarnings, err := h.validateGroupMembers(&policy)
+	if err != nil {
+		return nil, err
+	}
+
+	h.aclCompileMutex.Lock()
+	defer h.aclCompileMutex.Unlock()
+
+	mergedPolicy := h.mergeNamespaceACLPolicies(&policy)
+	rules, ruleIndexes, warnings, err := h.compileACLPolicy(mergedPolicy, h.cfg.ACL.EmptyAlias)
+	if err != nil {
+		return nil, err
+	}
+	log.Trace().Interface("ACL", rules).Msg("ACL rules generated")
+
+	previousRules := h.setACL(&policy, mergedPolicy, rules, ruleIndexes)
+	logACLRulesChange(caller, previousRules, rules)
+
+	return append(groupsWarnings, warnings...), nil
+}
+
+// SetACLPolicy checks the policy and stores it in the database, replacing
+// the current one. The other headscale instances sharing the database
+// load it on their next watchDatabaseACLPolicy tick. It returns the warnings
+// of the policy.
+func (h *Headscale) SetACLPolicy(policyBytes []byte) ([]string, error) {
+	if h.cfg.ACL.PolicyMode != ACLPolicyModeDatabase {
+		return nil, errACLPolicyNotInDatabase
+	}
+
+	_, warnings, err := h.CheckACLPolicy(policyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidACLPolicy, err)
+	}
+
+	if err := h.setValue(aclPolicyKey, string(policyBytes)); err != nil {
+		return nil, err
+	}
+
+	if err := h.LoadACLPolicyFromDB(); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Msg("ACL policy successfully updated, notifying nodes of change")
+
+	h.setLastStateChangeToNow()
+
+	return warnings, nil
+}
 
-		err := yaml.Unmarshal(policyBytes, &policy)
+// GetACLPolicy returns the ACL policy as it was written, read from the
+// database or from acl_policy_path depending on acl_policy_mode.
+func (h *Headscale) GetACLPolicy() ([]byte, error) {
+	if h.cfg.ACL.PolicyMode == ACLPolicyModeDatabase {
+		value, err := h.getValue(aclPolicyKey)
+		if errors.Is(err, errValueNotFound) {
+			return nil, errACLPolicyNotSet
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		log.Trace().
-			Interface("policy", policy).
-			Msg("Loaded policy from YAML")
+		return []byte(value), nil
+	}
+
+	if h.cfg.ACL.PolicyPath == "" {
+		return nil, errACLPolicyNotSet
+	}
 
-	default:
-		ast, err := hujson.Parse(policyBytes)
+	return os.ReadFile(AbsolutePathFromConfigPath(h.cfg.ACL.PolicyPath))
+}
+
+// watchDatabaseACLPolicy loads the ACL policy stored in the database
+// whenever another headscale sharing the database updates it.
+func (h *Headscale) watchDatabaseACLPolicy(milliSeconds int64) {
+	ticker := time.NewTicker(time.Duration(milliSeconds) * time.Millisecond)
+	for range ticker.C {
+		loaded, err := h.loadDatabaseACLPolicy(false)
 		if err != nil {
-			return err
+			log.Error().
+				Err(err).
+				Msg("Failed to load the ACL policy from the database, keeping the current one")
+
+			continue
+		}
+
+		if loaded {
+			log.Info().
+				Msg("ACL policy changed in the database, notifying nodes of change")
+
+			h.setLastStateChangeToNow()
+
+			continue
 		}
 
-		ast.Standardize()
-		policyBytes = ast.Pack()
-		err = json.Unmarshal(policyBytes, &policy)
+		changed, err := h.namespaceACLPoliciesChanged()
 		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed to load the ACL policies of the namespaces from the database")
+
+			continue
+		}
+		if changed {
+			h.resetNamespaceACLPolicies()
+			if err := h.UpdateACLRules(); err != nil {
+				log.Error().
+					Err(err).
+					Msg("Failed to apply the ACL policies of the namespaces changed in the database")
+
+				continue
+			}
+
+			log.Info().
+				Msg("ACL policies of the namespaces changed in the database, notifying nodes of change")
+
+			h.setLastStateChangeToNow()
+		}
+	}
+}
+
+// reloadACLPolicy loads again the ACL policy from the configured path, or
+// from the database, and notifies the connected machines so they fetch the
+// new filter.
+// If the new policy cannot be loaded, the current one is kept.
+func (h *Headscale) reloadACLPolicy() error {
+	if h.cfg.ACL.PolicyMode == ACLPolicyModeDatabase {
+		if err := h.LoadACLPolicyFromDB(); err != nil {
 			return err
 		}
+
+		log.Info().
+			Msg("ACL policy successfully reloaded from the database, notifying nodes of change")
+
+		h.setLastStateChangeToNow()
+
+		return nil
 	}
 
-	if policy.IsZero() {
-		return errEmptyPolicy
+	if h.cfg.ACL.PolicyPath == "" {
+		return nil
 	}
 
-	h.aclPolicy = &policy
+	aclPath := AbsolutePathFromConfigPath(h.cfg.ACL.PolicyPath)
+	if err := h.LoadACLPolicy(aclPath); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("path", aclPath).
+		Msg("ACL policy successfully reloaded, notifying nodes of change")
+
+	h.setLastStateChangeToNow()
 
-	return h.UpdateACLRules()
+	return nil
 }
 
-func (h *Headscale) UpdateACLRules() error {
-	rules, err := h.generateACLRules()
+// getACL returns the current policy along with the rules generated from it,
+// and the index of the ACL each rule comes from.
+// They are only ever replaced, never modified in place, so the returned
+// values can be used without holding the lock.
+func (h *Headscale) getACL() (*ACLPolicy, []tailcfg.FilterRule, []int) {
+	h.aclMutex.RLock()
+	defer h.aclMutex.RUnlock()
+
+	return h.aclPolicy, h.aclRules, h.aclRuleIndexes
+}
+
+// getMergedACL returns the policy merged with the namespace policies, its
+// rules and the index in the merged policy of the ACL each rule comes from.
+func (h *Headscale) getMergedACL() (*ACLPolicy, []tailcfg.FilterRule, []int) {
+	h.aclMutex.RLock()
+	defer h.aclMutex.RUnlock()
+
+	return h.aclMergedPolicy, h.aclRules, h.aclRuleIndexes
+}
+
+func (h *Headscale) getACLPolicy() *ACLPolicy {
+	policy, _, _ := h.getACL()
+
+	return policy
+}
+
+func (h *Headscale) getACLRules() []tailcfg.FilterRule {
+	_, rules, _ := h.getACL()
+
+	return rules
+}
+
+// previewMachineRules returns the filter rules having the machine among
+// their sources, along with the index of the ACL each one comes from.
+func (h *Headscale) previewMachineRules(
+	machine *Machine,
+) ([]tailcfg.FilterRule, []int, error) {
+	_, rules, ruleIndexes := h.getACL()
+
+	machineRules := []tailcfg.FilterRule{}
+	machineRuleIndexes := []int{}
+	for index, rule := range rules {
+		srcIPs, err := parseFilterIPs(rule.SrcIPs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, addr := range machine.IPAddresses {
+			if !srcIPs.Contains(addr) {
+				continue
+			}
+
+			aclIndex := -1
+			if index < len(ruleIndexes) {
+				aclIndex = ruleIndexes[index]
+			}
+			machineRules = append(machineRules, rule)
+			machineRuleIndexes = append(machineRuleIndexes, aclIndex)
+
+			break
+		}
+	}
+
+	return machineRules, machineRuleIndexes, nil
+}
+
+// setACL swaps the policy and its rules at once, so that a map generation
+// never sees the rules of one policy along with another policy.
+// It returns the rules that were replaced.
+func (h *Headscale) setACL(
+	policy *ACLPolicy,
+	mergedPolicy *ACLPolicy,
+	rules []tailcfg.FilterRule,
+	ruleIndexes []int,
+) []tailcfg.FilterRule {
+	h.aclMutex.Lock()
+	defer h.aclMutex.Unlock()
+
+	previousRules := h.aclRules
+	h.aclPolicy = policy
+	h.aclMergedPolicy = mergedPolicy
+	h.aclRules = rules
+	h.aclRuleIndexes = ruleIndexes
+
+	return previousRules
+}
+
+// parseACLPolicy decodes a policy written either in HuJSON or in YAML.
+// The format is picked from the file extension; files without a known
+// extension are read as HuJSON, and only fall back to YAML when they are not
+// valid HuJSON.
+func parseACLPolicy(policyBytes []byte, ext string) (ACLPolicy, error) {
+	var policy ACLPolicy
+
+	switch strings.ToLower(ext) {
+	case ".yml", ".yaml":
+		err := parseACLPolicyYAML(policyBytes, &policy)
+
+		return policy, err
+
+	case ".json", ".hujson":
+		err := parseACLPolicyHuJSON(policyBytes, &policy)
+
+		return policy, err
+
+	default:
+		err := parseACLPolicyHuJSON(policyBytes, &policy)
+		if err == nil {
+			return policy, nil
+		}
+
+		policy = ACLPolicy{}
+		if yamlErr := parseACLPolicyYAML(policyBytes, &policy); yamlErr != nil {
+			// Report the HuJSON error, as it is the default format
+			return ACLPolicy{}, err
+		}
+
+		return policy, nil
+	}
+}
+
+// parseACLPolicyHuJSON decodes a HuJSON policy. The syntax errors already
+// carry their line and column, the decoding errors are given the top-level
+// key being decoded and its position.
+func parseACLPolicyHuJSON(policyBytes []byte, policy *ACLPolicy) error {
+	ast, err := hujson.Parse(policyBytes)
 	if err != nil {
 		return err
 	}
-	log.Trace().Interface("ACL", rules).Msg("ACL rules generated")
-	h.aclRules = rules
+
+	ast.Standardize()
+	standardBytes := ast.Pack()
+
+	err = json.Unmarshal(standardBytes, policy)
+	if err != nil {
+		return huJSONDecodeError(ast, standardBytes, err)
+	}
 
 	return nil
 }
 
-func (h *Headscale) generateACLRules() ([]tailcfg.FilterRule, error) {
-	rules := []tailcfg.FilterRule{}
+// huJSONDecodeError locates the error returned when decoding the standard
+// form of a HuJSON policy. The standard form keeps the byte offsets of the
+// policy, the positions are those of the policy as written.
+func huJSONDecodeError(ast hujson.Value, standardBytes []byte, err error) error {
+	offset := -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = int(syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		// the offset is the end of the value, report its start
+		offset = int(typeErr.Offset)
+		if value, ok := huJSONValueEndingAt(ast, offset); ok {
+			offset = value.StartOffset
+		}
+	}
 
-	if h.aclPolicy == nil {
-		return nil, errEmptyPolicy
+	object, ok := ast.Value.(*hujson.Object)
+	if !ok {
+		return err
 	}
 
-	machines, err := h.ListMachines()
+	for _, member := range object.Members {
+		name, ok := member.Name.Value.(hujson.Literal)
+		if !ok {
+			continue
+		}
+
+		if offset >= 0 {
+			if offset < member.Name.StartOffset || offset > member.Value.EndOffset {
+				continue
+			}
+		} else {
+			// the errors of the custom decoders (e.g. the hosts) have no
+			// offset, the members are decoded one by one to find it
+			memberBytes := []byte("{" + string(name) + ":")
+			memberBytes = append(
+				memberBytes,
+				standardBytes[member.Value.StartOffset:member.Value.EndOffset]...,
+			)
+			if json.Unmarshal(append(memberBytes, '}'), &ACLPolicy{}) == nil {
+				continue
+			}
+			offset = member.Value.StartOffset
+		}
+
+		line, column := policyLineColumn(standardBytes, offset)
+
+		return fmt.Errorf("line %d, column %d: decoding %q: %w", line, column, name.String(), err)
+	}
+
+	return err
+}
+
+// huJSONValueEndingAt returns the innermost value of the policy ending at
+// the offset.
+func huJSONValueEndingAt(value hujson.Value, offset int) (hujson.Value, bool) {
+	if offset < value.StartOffset || offset > value.EndOffset {
+		return value, false
+	}
+
+	switch composite := value.Value.(type) {
+	case *hujson.Object:
+		for _, member := range composite.Members {
+			if inner, ok := huJSONValueEndingAt(member.Value, offset); ok {
+				return inner, true
+			}
+		}
+	case *hujson.Array:
+		for _, element := range composite.Elements {
+			if inner, ok := huJSONValueEndingAt(element, offset); ok {
+				return inner, true
+			}
+		}
+	}
+
+	return value, value.EndOffset == offset
+}
+
+// policyLineColumn translates a byte offset of the policy to its line and
+// column, both starting at 1.
+func policyLineColumn(policyBytes []byte, offset int) (int, int) {
+	if offset > len(policyBytes) {
+		offset = len(policyBytes)
+	}
+	line := 1 + bytes.Count(policyBytes[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(policyBytes[:offset], '\n')
+
+	return line, column
+}
+
+func parseACLPolicyYAML(policyBytes []byte, policy *ACLPolicy) error {
+	err := yaml.Unmarshal(policyBytes, policy)
+	if err != nil {
+		return err
+	}
+
+	log.Trace().
+		Interface("policy", policy).
+		Msg("Loaded policy from YAML")
+
+	return nil
+}
+
+// ValidateACLPolicy generates the rules of the given policy against the
+// current machines, without applying them.
+// Constructs that are legal but most likely a mistake (an empty group, an
+// alias matching no machine, a rule without any source...) are returned as
+// warnings, while invalid policies still return an error.
+func (h *Headscale) ValidateACLPolicy(policy *ACLPolicy) ([]string, error) {
+	_, _, warnings, err := h.compileACLPolicy(policy, h.cfg.ACL.EmptyAlias)
 	if err != nil {
 		return nil, err
 	}
 
-	for index, acl := range h.aclPolicy.ACLs {
-		if acl.Action != "accept" {
-			return nil, errInvalidAction
+	return warnings, nil
+}
+
+// CheckACLPolicy parses and compiles a policy the same way LoadACLPolicy
+// does, without applying it, and returns the number of rules it generates
+// along with its warnings.
+func (h *Headscale) CheckACLPolicy(policyBytes []byte) (int, []string, error) {
+	policy, err := parseACLPolicy(policyBytes, "")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if policy.IsZero() {
+		return 0, nil, errEmptyPolicy
+	}
+
+	groupsWarnings, err := h.validateGroupMembers(&policy)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rules, _, warnings, err := h.compileACLPolicy(&policy, h.cfg.ACL.EmptyAlias)
+	if err != nil {
+		return 0, nil, err
+	}
+	warnings = append(groupsWarnings, warnings...)
+
+	return len(rules), warnings, nil
+}
+
+// UpdateACLRules generates the rules of the current policy again, against
+// the current machines and namespaces. The policy was already checked when
+// it was loaded, so the aliases matching no machine anymore are only
+// warnings here, whatever acl_empty_alias: deleting the last machine of a
+// namespace must not freeze the rules.
+// It holds aclCompileMutex until the new rules are in place.
+func (h *Headscale) UpdateACLRules() error {
+	h.aclCompileMutex.Lock()
+	defer h.aclCompileMutex.Unlock()
+
+	policy := h.getACLPolicy()
+
+	mergedPolicy := h.mergeNamespaceACLPolicies(policy)
+	rules, ruleIndexes, warnings, err := h.compileACLPolicy(mergedPolicy, ACLEmptyAliasWarn)
+	if err != nil {
+		return err
+	}
+	log.Trace().Interface("ACL", rules).Msg("ACL rules generated")
+	if h.cfg.ACL.EmptyAlias == ACLEmptyAliasError && len(warnings) > 0 {
+		log.Warn().
+			Strs("warnings", warnings).
+			Msg("The ACL policy has aliases matching no machine anymore, applying it anyway")
+	} else {
+		log.Trace().Strs("warnings", warnings).Msg("ACL warnings generated")
+	}
+
+	previousRules := h.setACL(policy, mergedPolicy, rules, ruleIndexes)
+
+	logACLRulesChange("UpdateACLRules", previousRules, rules)
+
+	return nil
+}
+
+// logACLRulesChange reports the number of rules generated, and how many were
+// added and removed compared to the previous rules. The changes are logged at
+// info level, with the rules themselves at debug level.
+func logACLRulesChange(caller string, previous, current []tailcfg.FilterRule) {
+	added, removed := diffFilterRules(previous, current)
+	if len(added) == 0 && len(removed) == 0 {
+		log.Debug().
+			Str("func", caller).
+			Int("rules", len(current)).
+			Msg("ACL rules unchanged")
+
+		return
+	}
+
+	log.Info().
+		Str("func", caller).
+		Int("rules", len(current)).
+		Int("added", len(added)).
+		Int("removed", len(removed)).
+		Msg("ACL rules changed")
+	log.Debug().
+		Str("func", caller).
+		Interface("added", added).
+		Interface("removed", removed).
+		Msg("ACL rules diff")
+}
+
+// diffFilterRules returns the rules of current that are not in previous,
+// and the rules of previous that are not in current. Duplicated rules are
+// counted as many times as they appear.
+func diffFilterRules(
+	previous, current []tailcfg.FilterRule,
+) ([]tailcfg.FilterRule, []tailcfg.FilterRule) {
+	matched := make([]bool, len(previous))
+	added := []tailcfg.FilterRule{}
+	for _, rule := range current {
+		found := false
+		for index, previousRule := range previous {
+			if !matched[index] && reflect.DeepEqual(rule, previousRule) {
+				matched[index] = true
+				found = true
+
+				break
+			}
+		}
+		if !found {
+			added = append(added, rule)
+		}
+	}
+
+	removed := []tailcfg.FilterRule{}
+	for index, previousRule := range previous {
+		if !matched[index] {
+			removed = append(removed, previousRule)
+		}
+	}
+
+	return added, removed
+}
+
+// filterRuleToProto converts a generated rule, along with the index of the
+// ACL it comes from (-1 when unknown), for the API.
+func filterRuleToProto(rule tailcfg.FilterRule, aclIndex int) *v1.ACLRule {
+	dstPorts := make([]*v1.ACLDestination, len(rule.DstPorts))
+	for index, dest := range rule.DstPorts {
+		dstPorts[index] = &v1.ACLDestination{
+			Ip:        dest.IP,
+			FirstPort: uint32(dest.Ports.First),
+			LastPort:  uint32(dest.Ports.Last),
+		}
+	}
+
+	ipProto := make([]int32, len(rule.IPProto))
+	for index, proto := range rule.IPProto {
+		ipProto[index] = int32(proto)
+	}
+
+	return &v1.ACLRule{
+		SrcIps:   rule.SrcIPs,
+		DstPorts: dstPorts,
+		IpProto:  ipProto,
+		AclIndex: int32(aclIndex),
+	}
+}
+
+func (h *Headscale) generateACLRules() ([]tailcfg.FilterRule, error) {
+	rules, _, _, err := h.compileACLPolicy(
+		h.mergeNamespaceACLPolicies(h.getACLPolicy()),
+		ACLEmptyAliasWarn,
+	)
+
+	return rules, err
+}
+
+// namespaceACLPolicy is the policy of a namespace as stored in the
+// database, along with its ACLs once checked against the global policy.
+type namespaceACLPolicy struct {
+	namespaceID uint
+	namespace   string
+	policy      string
+	// acls is nil when the policy is not valid against the global policy.
+	acls []ACL
+}
+
+// mergeNamespaceACLPolicies returns the policy with the ACLs of the
+// policies of the namespaces appended, or the policy itself when no
+// namespace has one. The deny ACLs of the global policy also apply to the
+// ACLs of the namespaces.
+// A namespace policy which is no longer valid against the global policy,
+// e.g. the namespace lost the ownership of a tag, is left out.
+// The namespace policies are only read and checked again when the global
+// policy changed since the last merge, or after
+// resetNamespaceACLPolicies.
+func (h *Headscale) mergeNamespaceACLPolicies(policy *ACLPolicy) *ACLPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	h.namespaceACLMutex.Lock()
+	defer h.namespaceACLMutex.Unlock()
+
+	if h.namespaceACLGlobalPolicy != policy {
+		namespacePolicies, err := h.loadNamespaceACLPolicies(policy)
+		if err != nil {
+			log.Error().
+				Caller().
+				Err(err).
+				Msg("Could not load the ACL policies of the namespaces")
+
+			return policy
+		}
+		h.namespaceACLGlobalPolicy = policy
+		h.namespaceACLPolicies = namespacePolicies
+	}
+	if len(h.namespaceACLPolicies) == 0 {
+		return policy
+	}
+
+	merged := *policy
+	merged.ACLs = append([]ACL{}, policy.ACLs...)
+	for _, namespacePolicy := range h.namespaceACLPolicies {
+		merged.ACLs = append(merged.ACLs, namespacePolicy.acls...)
+	}
+
+	return &merged
+}
+
+// loadNamespaceACLPolicies reads the policies of the namespaces from the
+// database, and checks them against the global policy.
+func (h *Headscale) loadNamespaceACLPolicies(
+	policy *ACLPolicy,
+) ([]namespaceACLPolicy, error) {
+	namespaces := []Namespace{}
+	if err := h.db.Where("acl_policy <> ''").Order("id").Find(&namespaces).Error; err != nil {
+		return nil, err
+	}
+
+	namespacePolicies := make([]namespaceACLPolicy, len(namespaces))
+	for index := range namespaces {
+		namespacePolicies[index] = namespaceACLPolicy{
+			namespaceID: namespaces[index].ID,
+			namespace:   namespaces[index].Name,
+			policy:      namespaces[index].ACLPolicy,
+		}
+
+		namespacePolicy, _, err := h.checkNamespaceACLPolicy(
+			policy,
+			&namespaces[index],
+			[]byte(namespaces[index].ACLPolicy),
+			ACLEmptyAliasWarn,
+		)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("namespace", namespaces[index].Name).
+				Msg("Ignoring the ACL policy of the namespace")
+
+			continue
+		}
+		namespacePolicies[index].acls = namespacePolicy.ACLs
+	}
+
+	return namespacePolicies, nil
+}
+
+// resetNamespaceACLPolicies makes the next merge read and check the
+// policies of the namespaces again, after one of them was changed.
+func (h *Headscale) resetNamespaceACLPolicies() {
+	h.namespaceACLMutex.Lock()
+	defer h.namespaceACLMutex.Unlock()
+
+	h.namespaceACLGlobalPolicy = nil
+	h.namespaceACLPolicies = nil
+}
+
+// namespaceACLPoliciesChanged reports whether the policies of the
+// namespaces stored in the database differ from the ones merged, e.g.
+// when another headscale sharing the database updated one of them.
+func (h *Headscale) namespaceACLPoliciesChanged() (bool, error) {
+	namespaces := []Namespace{}
+	if err := h.db.Select("id", "name", "acl_policy").
+		Where("acl_policy <> ''").
+		Order("id").
+		Find(&namespaces).Error; err != nil {
+		return false, err
+	}
+
+	h.namespaceACLMutex.Lock()
+	defer h.namespaceACLMutex.Unlock()
+
+	if h.namespaceACLGlobalPolicy == nil {
+		return false, nil
+	}
+	if len(namespaces) != len(h.namespaceACLPolicies) {
+		return true, nil
+	}
+	for index, namespace := range namespaces {
+		namespacePolicy := h.namespaceACLPolicies[index]
+		if namespace.ID != namespacePolicy.namespaceID ||
+			namespace.Name != namespacePolicy.namespace ||
+			namespace.ACLPolicy != namespacePolicy.policy {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkNamespaceACLPolicy parses the policy of a namespace and checks it
+// against the global policy: it can only contain ACLs, and these ACLs can
+// only reference the namespace itself and the tags it owns.
+// The ACLs are then compiled, with the groups, hosts and tag owners of the
+// global policy and the given acl_empty_alias mode, and the warnings
+// returned.
+func (h *Headscale) checkNamespaceACLPolicy(
+	policy *ACLPolicy,
+	namespace *Namespace,
+	policyBytes []byte,
+	emptyAlias string,
+) (*ACLPolicy, []string, error) {
+	namespacePolicy, err := parseACLPolicy(policyBytes, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidNamespaceACLPolicy, err)
+	}
+
+	if len(namespacePolicy.Groups) > 0 || len(namespacePolicy.Hosts) > 0 ||
+		len(namespacePolicy.TagOwners) > 0 || len(namespacePolicy.Ports) > 0 ||
+		len(namespacePolicy.SSHs) > 0 || len(namespacePolicy.Tests) > 0 ||
+		!namespacePolicy.AutoApprovers.IsZero() {
+		return nil, nil, fmt.Errorf(
+			"%w: %s",
+			ErrInvalidNamespaceACLPolicy,
+			errNamespaceACLPolicyFields,
+		)
+	}
+
+	globalPolicy := ACLPolicy{}
+	if policy != nil {
+		globalPolicy = *policy
+	}
+
+	checkAlias := func(index int, field string, alias string) error {
+		inScope := alias == namespace.Name
+		if strings.HasPrefix(alias, "tag:") {
+			owners, err := expandTagOwners(
+				globalPolicy,
+				alias,
+				[]string{namespace.Name},
+				h.cfg.OIDC.StripEmaildomain,
+			)
+			inScope = err == nil && contains(owners, namespace.Name)
+		}
+		// a namespace without machines expands to the host of the same name
+		if _, isHost := globalPolicy.Hosts[alias]; isHost {
+			inScope = false
+		}
+
+		if !inScope {
+			return fmt.Errorf("%w: %s", ErrInvalidNamespaceACLPolicy, ACLError{
+				Index: index,
+				Field: field,
+				Err:   fmt.Errorf("%w: %s", errNamespaceACLPolicyScope, alias),
+			})
+		}
+
+		return nil
+	}
+
+	for index, acl := range namespacePolicy.ACLs {
+		for _, src := range acl.Sources {
+			if err := checkAlias(index, "src", src); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		for _, dest := range acl.Destinations {
+			alias, _, err := splitACLDestination(dest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %s", ErrInvalidNamespaceACLPolicy, ACLError{
+					Index: index,
+					Field: "dst",
+					Err:   err,
+				})
+			}
+			if err := checkAlias(index, "dst", alias); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	scopedPolicy := ACLPolicy{
+		Groups:    globalPolicy.Groups,
+		Hosts:     globalPolicy.Hosts,
+		TagOwners: globalPolicy.TagOwners,
+		Ports:     globalPolicy.Ports,
+		ACLs:      namespacePolicy.ACLs,
+	}
+	_, _, warnings, err := h.compileACLPolicy(&scopedPolicy, emptyAlias)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidNamespaceACLPolicy, err)
+	}
+
+	return &namespacePolicy, warnings, nil
+}
+
+// compileACLPolicy generates the filter rules of the policy, along with the
+// index of the ACL each rule comes from, and collects the non-fatal warnings
+// found along the way. emptyAlias is the acl_empty_alias mode applied to the
+// aliases matching no machine.
+// The traffic matched by deny ACLs is removed from the accept ACLs, whatever
+// their order in the policy, as filter rules can only express accept rules.
+func (h *Headscale) compileACLPolicy(
+	policy *ACLPolicy,
+	emptyAlias string,
+) ([]tailcfg.FilterRule, []int, []string, error) {
+	rules := []tailcfg.FilterRule{}
+	ruleIndexes := []int{}
+	denyRules := []tailcfg.FilterRule{}
+	warnings := []string{}
+
+	if policy == nil {
+		return nil, nil, nil, errEmptyPolicy
+	}
+
+	machines, err := h.ListMachines()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tagOwnersWarnings, err := h.validateTagOwners(policy, emptyAlias)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	warnings = append(warnings, tagOwnersWarnings...)
+
+	sshWarnings, err := h.validateSSHRules(policy, machines, emptyAlias)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	warnings = append(warnings, sshWarnings...)
+
+	if err := h.validateAutoApprovers(policy, machines); err != nil {
+		return nil, nil, nil, err
+	}
+
+	groups := make([]string, 0, len(policy.Groups))
+	for group := range policy.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		if len(policy.Groups[group]) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s is empty", group))
+		}
+	}
+
+	for index, acl := range policy.ACLs {
+		if acl.Action != "accept" && acl.Action != "deny" {
+			return nil, nil, nil, ACLError{Index: index, Field: "action", Err: errInvalidAction}
+		}
+
+		srcIPs := []string{}
+		for innerIndex, src := range acl.Sources {
+			srcs, err := h.generateACLPolicySrcIP(machines, *policy, src)
+			if err != nil {
+				log.Error().
+					Msgf("Error parsing ACL %d, Source %d", index, innerIndex)
+
+				return nil, nil, nil, ACLError{Index: index, Field: "src", Err: err}
+			}
+			if len(srcs) == 0 {
+				if emptyAlias == ACLEmptyAliasError {
+					return nil, nil, nil, ACLError{
+						Index: index,
+						Field: "src",
+						Err:   fmt.Errorf("%w: %s", errEmptyAlias, src),
+					}
+				}
+				warnings = append(warnings, fmt.Sprintf(
+					"ACL %d: source %s does not match any machine",
+					index,
+					src,
+				))
+			}
+			srcIPs = append(srcIPs, srcs...)
+		}
+
+		protocols, needsWildcard, err := parseProtocol(acl.Protocol)
+		if err != nil {
+			log.Error().
+				Msgf("Error parsing ACL %d. protocol unknown %s", index, acl.Protocol)
+
+			return nil, nil, nil, ACLError{Index: index, Field: "proto", Err: err}
+		}
+
+		destPorts := []tailcfg.NetPortRange{}
+		selfDests := []string{}
+		for innerIndex, dest := range acl.Destinations {
+			if alias, _, err := splitACLDestination(dest); err == nil && alias == autogroupSelf {
+				selfDests = append(selfDests, dest)
+
+				continue
+			}
+
+			dests, err := h.generateACLPolicyDest(
+				machines,
+				*policy,
+				dest,
+				needsWildcard,
+			)
+			if err != nil {
+				log.Error().
+					Msgf("Error parsing ACL %d, Destination %d", index, innerIndex)
+
+				return nil, nil, nil, ACLError{Index: index, Field: "dst", Err: err}
+			}
+			if len(dests) == 0 {
+				if emptyAlias == ACLEmptyAliasError {
+					return nil, nil, nil, ACLError{
+						Index: index,
+						Field: "dst",
+						Err:   fmt.Errorf("%w: %s", errEmptyAlias, dest),
+					}
+				}
+				warnings = append(warnings, fmt.Sprintf(
+					"ACL %d: destination %s does not match any machine",
+					index,
+					dest,
+				))
+			}
+			destPorts = append(destPorts, dests...)
+		}
+
+		aclRules := []tailcfg.FilterRule{}
+		if len(destPorts) > 0 || len(selfDests) == 0 {
+			aclRules = append(aclRules, tailcfg.FilterRule{
+				SrcIPs:   srcIPs,
+				DstPorts: destPorts,
+				IPProto:  protocols,
+			})
+		}
+		if len(selfDests) > 0 {
+			selfRules, err := h.generateACLPolicySelfRules(
+				machines,
+				*policy,
+				srcIPs,
+				selfDests,
+				protocols,
+				needsWildcard,
+			)
+			if err != nil {
+				log.Error().
+					Msgf("Error parsing ACL %d, %s destination", index, autogroupSelf)
+
+				return nil, nil, nil, ACLError{Index: index, Field: "dst", Err: err}
+			}
+			aclRules = append(aclRules, selfRules...)
+		}
+
+		matchesTraffic := false
+		for _, rule := range aclRules {
+			if len(rule.DstPorts) > 0 {
+				matchesTraffic = true
+			}
+		}
+		if len(srcIPs) == 0 || !matchesTraffic {
+			warnings = append(warnings, fmt.Sprintf(
+				"ACL %d does not match any traffic",
+				index,
+			))
+		}
+
+		if acl.Action == "deny" {
+			denyRules = append(denyRules, aclRules...)
+
+			continue
+		}
+
+		for _, rule := range aclRules {
+			rules = append(rules, rule)
+			ruleIndexes = append(ruleIndexes, index)
+		}
+	}
+
+	for _, denyRule := range denyRules {
+		allowedRules := []tailcfg.FilterRule{}
+		allowedIndexes := []int{}
+		for ruleIndex, rule := range rules {
+			remaining, err := subtractFilterRule(rule, denyRule)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			allowedRules = append(allowedRules, remaining...)
+			for range remaining {
+				allowedIndexes = append(allowedIndexes, ruleIndexes[ruleIndex])
+			}
+		}
+		rules, ruleIndexes = allowedRules, allowedIndexes
+	}
+
+	return rules, ruleIndexes, warnings, nil
+}
+
+// validateAutoApprovers checks that the routes of the autoApprovers are
+// prefixes, and that their aliases can be expanded.
+func (h *Headscale) validateAutoApprovers(policy *ACLPolicy, machines []Machine) error {
+	checkAliases := func(aliases []string) error {
+		for _, alias := range aliases {
+			if _, err := expandAlias(machines, *policy, alias, h.cfg.OIDC.StripEmaildomain); err != nil {
+				return fmt.Errorf("%w: %s", errInvalidAutoApprover, err)
+			}
+		}
+
+		return nil
+	}
+
+	for prefix, aliases := range policy.AutoApprovers.Routes {
+		if _, err := netip.ParsePrefix(prefix); err != nil {
+			return fmt.Errorf("%w: %s", errInvalidAutoApprover, err)
+		}
+		if err := checkAliases(aliases); err != nil {
+			return err
+		}
+	}
+
+	return checkAliases(policy.AutoApprovers.ExitNode)
+}
+
+// validateSSHRules checks the ssh section of the policy against the current
+// machines. As for the ACLs, aliases matching no machine are warnings, or
+// errors with acl_empty_alias: error.
+func (h *Headscale) validateSSHRules(
+	policy *ACLPolicy,
+	machines []Machine,
+	emptyAlias string,
+) ([]string, error) {
+	warnings := []string{}
+
+	checkAliases := func(index int, field string, aliases []string) error {
+		for _, alias := range aliases {
+			if field == "dst" && alias == autogroupSelf {
+				continue
+			}
+
+			ips, err := expandAlias(machines, *policy, alias, h.cfg.OIDC.StripEmaildomain)
+			if err != nil {
+				return SSHRuleError{Index: index, Field: field, Err: err}
+			}
+			if len(ips) > 0 {
+				continue
+			}
+
+			if emptyAlias == ACLEmptyAliasError {
+				return SSHRuleError{
+					Index: index,
+					Field: field,
+					Err:   fmt.Errorf("%w: %s", errEmptyAlias, alias),
+				}
+			}
+			warnings = append(warnings, fmt.Sprintf(
+				"SSH rule %d: %s %s does not match any machine",
+				index,
+				field,
+				alias,
+			))
+		}
+
+		return nil
+	}
+
+	for index, sshRule := range policy.SSHs {
+		if _, err := sshRuleAction(sshRule.Action); err != nil {
+			return nil, SSHRuleError{Index: index, Field: "action", Err: err}
+		}
+
+		if _, err := sshRuleUsers(sshRule.Users); err != nil {
+			return nil, SSHRuleError{Index: index, Field: "users", Err: err}
+		}
+
+		if err := checkAliases(index, "src", sshRule.Sources); err != nil {
+			return nil, err
+		}
+
+		if err := checkAliases(index, "dst", sshRule.Destinations); err != nil {
+			return nil, err
+		}
+	}
+
+	return warnings, nil
+}
+
+// generateSSHPolicy returns the SSH rules machine has to enforce, that is
+// the rules of the policy having it among their destinations.
+// The sources of an autogroup:self destination are restricted to the
+// machines of the namespace of machine. It returns nil when no policy is
+// loaded, and the rules are then left to the client.
+func (h *Headscale) generateSSHPolicy(machine *Machine) (*tailcfg.SSHPolicy, error) {
+	policy := h.getACLPolicy()
+	if policy == nil {
+		return nil, nil
+	}
+
+	sshPolicy := &tailcfg.SSHPolicy{Rules: []*tailcfg.SSHRule{}}
+	if len(policy.SSHs) == 0 {
+		return sshPolicy, nil
+	}
+
+	machines, err := h.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	selfMachines := excludeCorrectlyTaggedNodes(
+		*policy,
+		filterMachinesByNamespace(machines, machine.Namespace.Name),
+		machine.Namespace.Name,
+		h.cfg.OIDC.StripEmaildomain,
+	)
+	isSelf := false
+	for _, selfMachine := range selfMachines {
+		if selfMachine.ID == machine.ID {
+			isSelf = true
+		}
+	}
+
+	for index, sshRule := range policy.SSHs {
+		action, err := sshRuleAction(sshRule.Action)
+		if err != nil {
+			return nil, SSHRuleError{Index: index, Field: "action", Err: err}
+		}
+
+		users, err := sshRuleUsers(sshRule.Users)
+		if err != nil {
+			return nil, SSHRuleError{Index: index, Field: "users", Err: err}
+		}
+
+		reachesMachine := false
+		reachesSelf := false
+		for _, dest := range sshRule.Destinations {
+			if dest == autogroupSelf {
+				reachesSelf = reachesSelf || isSelf
+
+				continue
+			}
+
+			dests, err := expandAlias(machines, *policy, dest, h.cfg.OIDC.StripEmaildomain)
+			if err != nil {
+				return nil, SSHRuleError{Index: index, Field: "dst", Err: err}
+			}
+			destSet, err := parseFilterIPs(dests)
+			if err != nil {
+				return nil, SSHRuleError{Index: index, Field: "dst", Err: err}
+			}
+			for _, addr := range machine.IPAddresses {
+				if destSet.Contains(addr) {
+					reachesMachine = true
+				}
+			}
+		}
+		if !reachesMachine && !reachesSelf {
+			continue
+		}
+
+		srcIPs := []string{}
+		for _, src := range sshRule.Sources {
+			srcs, err := expandAlias(machines, *policy, src, h.cfg.OIDC.StripEmaildomain)
+			if err != nil {
+				return nil, SSHRuleError{Index: index, Field: "src", Err: err}
+			}
+			srcIPs = append(srcIPs, srcs...)
+		}
+
+		candidates := machines
+		if !reachesMachine {
+			candidates = selfMachines
+		}
+		principals, err := sshRulePrincipals(srcIPs, candidates, reachesMachine)
+		if err != nil {
+			return nil, SSHRuleError{Index: index, Field: "src", Err: err}
+		}
+		if len(principals) == 0 {
+			continue
+		}
+
+		sshPolicy.Rules = append(sshPolicy.Rules, &tailcfg.SSHRule{
+			Principals: principals,
+			SSHUsers:   users,
+			Action:     action,
+		})
+	}
+
+	return sshPolicy, nil
+}
+
+// sshRuleAction returns the action of an SSH rule. Only accept is
+// supported, check would need headscale to hold the connections until the
+// user authenticates again.
+func sshRuleAction(action string) (*tailcfg.SSHAction, error) {
+	switch action {
+	case "accept":
+		return &tailcfg.SSHAction{
+			Accept:                   true,
+			AllowAgentForwarding:     true,
+			AllowLocalPortForwarding: true,
+		}, nil
+
+	case "check":
+		return nil, errSSHCheckNotSupported
+
+	default:
+		return nil, errInvalidAction
+	}
+}
+
+// sshRuleUsers maps the users of an SSH rule to the local users they log
+// in as, in the form expected by tailcfg.SSHRule.SSHUsers.
+// autogroup:nonroot lets in every user but root, unless root is also listed.
+func sshRuleUsers(users []string) (map[string]string, error) {
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%w: no users", errInvalidSSHUser)
+	}
+
+	sshUsers := make(map[string]string, len(users))
+	for _, user := range users {
+		switch {
+		case user == autogroupNonRoot:
+			sshUsers["*"] = "="
+			if _, ok := sshUsers["root"]; !ok {
+				sshUsers["root"] = ""
+			}
+
+		case strings.HasPrefix(user, "autogroup:"):
+			return nil, fmt.Errorf("%w: %s", errInvalidAutogroup, user)
+
+		case user == "" || user == "*" || strings.ContainsAny(user, " \t:="):
+			return nil, fmt.Errorf("%w: %q", errInvalidSSHUser, user)
+
+		default:
+			sshUsers[user] = "="
+		}
+	}
+
+	return sshUsers, nil
+}
+
+// sshRulePrincipals returns a principal for every address of the machines
+// matching the sources of an SSH rule. A wildcard source matches any
+// connection, unless the machines are restricted (to a namespace).
+func sshRulePrincipals(
+	srcIPs []string,
+	machines []Machine,
+	unrestricted bool,
+) ([]*tailcfg.SSHPrincipal, error) {
+	if unrestricted && contains(srcIPs, "*") {
+		return []*tailcfg.SSHPrincipal{{Any: true}}, nil
+	}
+
+	sources, err := parseFilterIPs(srcIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	principals := []*tailcfg.SSHPrincipal{}
+	for _, machine := range machines {
+		for _, addr := range machine.IPAddresses {
+			if sources.Contains(addr) {
+				principals = append(principals, &tailcfg.SSHPrincipal{
+					NodeIP: addr.String(),
+				})
+			}
+		}
+	}
+
+	return principals, nil
+}
+
+// subtractFilterRule returns the rules allowing the traffic of rule that is
+// not matched by deny.
+// The rule is returned unchanged when they do not overlap. Otherwise it is
+// split into up to three rules: one for the protocols deny does not cover,
+// one for the sources deny does not cover, and one for the sources and
+// protocols in common, restricted to the destinations and ports left over.
+func subtractFilterRule(
+	rule tailcfg.FilterRule,
+	deny tailcfg.FilterRule,
+) ([]tailcfg.FilterRule, error) {
+	keptProtocols := []int{}
+	deniedProtocols := []int{}
+	for _, protocol := range rule.IPProto {
+		if containsProtocol(deny.IPProto, protocol) {
+			deniedProtocols = append(deniedProtocols, protocol)
+		} else {
+			keptProtocols = append(keptProtocols, protocol)
+		}
+	}
+	if len(deniedProtocols) == 0 {
+		return []tailcfg.FilterRule{rule}, nil
+	}
+
+	denySrcs, err := parseFilterIPs(deny.SrcIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	keptSrcs := []string{}
+	var deniedSrcsBuilder netipx.IPSetBuilder
+	for _, src := range rule.SrcIPs {
+		srcs, err := parseFilterIPs([]string{src})
+		if err != nil {
+			return nil, err
+		}
+		if !srcs.Overlaps(denySrcs) {
+			keptSrcs = append(keptSrcs, src)
+
+			continue
+		}
+
+		var builder netipx.IPSetBuilder
+		builder.AddSet(srcs)
+		builder.RemoveSet(denySrcs)
+		remaining, _ := builder.IPSet()
+		keptSrcs = append(keptSrcs, filterIPStrings(remaining)...)
+
+		builder = netipx.IPSetBuilder{}
+		builder.AddSet(srcs)
+		builder.Intersect(denySrcs)
+		common, _ := builder.IPSet()
+		deniedSrcsBuilder.AddSet(common)
+	}
+	deniedSrcs, _ := deniedSrcsBuilder.IPSet()
+	if len(deniedSrcs.Prefixes()) == 0 {
+		return []tailcfg.FilterRule{rule}, nil
+	}
+
+	keptDsts := []tailcfg.NetPortRange{}
+	changed := false
+	for _, dst := range rule.DstPorts {
+		remaining, err := subtractNetPortRange(dst, deny.DstPorts)
+		if err != nil {
+			return nil, err
+		}
+		if remaining == nil {
+			keptDsts = append(keptDsts, dst)
+
+			continue
+		}
+		changed = true
+		keptDsts = append(keptDsts, remaining...)
+	}
+	if !changed {
+		return []tailcfg.FilterRule{rule}, nil
+	}
+
+	rules := []tailcfg.FilterRule{}
+	if len(keptProtocols) > 0 {
+		rules = append(rules, tailcfg.FilterRule{
+			SrcIPs:   rule.SrcIPs,
+			DstPorts: rule.DstPorts,
+			IPProto:  keptProtocols,
+		})
+	}
+	if len(keptSrcs) > 0 {
+		rules = append(rules, tailcfg.FilterRule{
+			SrcIPs:   keptSrcs,
+			DstPorts: rule.DstPorts,
+			IPProto:  deniedProtocols,
+		})
+	}
+	if len(keptDsts) > 0 {
+		rules = append(rules, tailcfg.FilterRule{
+			SrcIPs:   filterIPStrings(deniedSrcs),
+			DstPorts: keptDsts,
+			IPProto:  deniedProtocols,
+		})
+	}
+
+	return rules, nil
+}
+
+// subtractNetPortRange removes the addresses and ports of the deny
+// destinations from dst. It returns nil when no deny destination overlaps
+// dst, and an empty slice when dst is entirely denied.
+func subtractNetPortRange(
+	dst tailcfg.NetPortRange,
+	denyDsts []tailcfg.NetPortRange,
+) ([]tailcfg.NetPortRange, error) {
+	type piece struct {
+		ips   *netipx.IPSet
+		ports tailcfg.PortRange
+	}
+
+	ips, err := parseFilterIPs([]string{dst.IP})
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := []piece{{ips: ips, ports: dst.Ports}}
+	changed := false
+	for _, denyDst := range denyDsts {
+		denyIPs, err := parseFilterIPs([]string{denyDst.IP})
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := []piece{}
+		for _, current := range pieces {
+			if current.ports.First > denyDst.Ports.Last ||
+				current.ports.Last < denyDst.Ports.First ||
+				!current.ips.Overlaps(denyIPs) {
+				remaining = append(remaining, current)
+
+				continue
+			}
+			changed = true
+
+			// the addresses outside of the deny destination keep all the ports
+			var builder netipx.IPSetBuilder
+			builder.AddSet(current.ips)
+			builder.RemoveSet(denyIPs)
+			outside, _ := builder.IPSet()
+			if len(outside.Prefixes()) > 0 {
+				remaining = append(remaining, piece{ips: outside, ports: current.ports})
+			}
+
+			// the addresses inside of it keep the ports around the denied range
+			builder = netipx.IPSetBuilder{}
+			builder.AddSet(current.ips)
+			builder.Intersect(denyIPs)
+			inside, _ := builder.IPSet()
+			if current.ports.First < denyDst.Ports.First {
+				remaining = append(remaining, piece{
+					ips: inside,
+					ports: tailcfg.PortRange{
+						First: current.ports.First,
+						Last:  denyDst.Ports.First - 1,
+					},
+				})
+			}
+			if current.ports.Last > denyDst.Ports.Last {
+				remaining = append(remaining, piece{
+					ips: inside,
+					ports: tailcfg.PortRange{
+						First: denyDst.Ports.Last + 1,
+						Last:  current.ports.Last,
+					},
+				})
+			}
+		}
+		pieces = remaining
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	dsts := []tailcfg.NetPortRange{}
+	for _, current := range pieces {
+		for _, ip := range filterIPStrings(current.ips) {
+			dsts = append(dsts, tailcfg.NetPortRange{
+				IP:    ip,
+				Ports: current.ports,
+			})
 		}
+	}
 
-		srcIPs := []string{}
-		for innerIndex, src := range acl.Sources {
-			srcs, err := h.generateACLPolicySrcIP(machines, *h.aclPolicy, src)
+	return dsts, nil
+}
+
+// parseFilterIPs returns the set of addresses matched by the IPs of a
+// filter rule, which can be "*", addresses or prefixes.
+func parseFilterIPs(ips []string) (*netipx.IPSet, error) {
+	var builder netipx.IPSetBuilder
+	for _, ip := range ips {
+		switch {
+		case ip == "*":
+			builder.AddPrefix(netip.MustParsePrefix("0.0.0.0/0"))
+			builder.AddPrefix(netip.MustParsePrefix("::/0"))
+
+		case strings.Contains(ip, "/"):
+			prefix, err := netip.ParsePrefix(ip)
 			if err != nil {
-				log.Error().
-					Msgf("Error parsing ACL %d, Source %d", index, innerIndex)
+				return nil, err
+			}
+			builder.AddPrefix(prefix)
 
+		default:
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
 				return nil, err
 			}
-			srcIPs = append(srcIPs, srcs...)
+			builder.Add(addr)
 		}
+	}
 
-		protocols, needsWildcard, err := parseProtocol(acl.Protocol)
-		if err != nil {
-			log.Error().
-				Msgf("Error parsing ACL %d. protocol unknown %s", index, acl.Protocol)
+	return builder.IPSet()
+}
 
-			return nil, err
+// filterIPStrings returns the IPs of a filter rule matching the set,
+// as "*" when it covers every address.
+func filterIPStrings(ips *netipx.IPSet) []string {
+	prefixes := ips.Prefixes()
+	if len(prefixes) == 2 && prefixes[0].Bits() == 0 && prefixes[1].Bits() == 0 {
+		return []string{"*"}
+	}
+
+	strs := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if prefix.IsSingleIP() {
+			strs = append(strs, prefix.Addr().String())
+		} else {
+			strs = append(strs, prefix.String())
 		}
+	}
 
-		destPorts := []tailcfg.NetPortRange{}
-		for innerIndex, dest := range acl.Destinations {
-			dests, err := h.generateACLPolicyDest(
-				machines,
-				*h.aclPolicy,
-				dest,
-				needsWildcard,
-			)
-			if err != nil {
-				log.Error().
-					Msgf("Error parsing ACL %d, Destination %d", index, innerIndex)
+	return strs
+}
 
-				return nil, err
-			}
-			destPorts = append(destPorts, dests...)
+func containsProtocol(protocols []int, protocol int) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
 		}
-
-		rules = append(rules, tailcfg.FilterRule{
-			SrcIPs:   srcIPs,
-			DstPorts: destPorts,
-			IPProto:  protocols,
-		})
 	}
 
-	return rules, nil
+	return false
 }
 
 func (h *Headscale) generateACLPolicySrcIP(
@@ -201,22 +1813,9 @@ func (h *Headscale) generateACLPolicyDest(
 	dest string,
 	needsWildcard bool,
 ) ([]tailcfg.NetPortRange, error) {
-	tokens := strings.Split(dest, ":")
-	if len(tokens) < expectedTokenItems || len(tokens) > 3 {
-		return nil, errInvalidPortFormat
-	}
-
-	var alias string
-	// We can have here stuff like:
-	// git-server:*
-	// 192.168.1.0/24:22
-	// tag:montreal-webserver:80,443
-	// tag:api-server:443
-	// example-host-1:*
-	if len(tokens) == expectedTokenItems {
-		alias = tokens[0]
-	} else {
-		alias = fmt.Sprintf("%s:%s", tokens[0], tokens[1])
+	alias, portsStr, err := splitACLDestination(dest)
+	if err != nil {
+		return nil, err
 	}
 
 	expanded, err := expandAlias(
@@ -228,7 +1827,7 @@ func (h *Headscale) generateACLPolicyDest(
 	if err != nil {
 		return nil, err
 	}
-	ports, err := expandPorts(tokens[len(tokens)-1], needsWildcard)
+	ports, err := expandPorts(portsStr, needsWildcard, aclPolicy.Ports)
 	if err != nil {
 		return nil, err
 	}
@@ -247,6 +1846,116 @@ func (h *Headscale) generateACLPolicyDest(
 	return dests, nil
 }
 
+// generateACLPolicySelfRules returns, for each namespace, a rule allowing
+// the sources of an ACL that are machines of the namespace to reach the
+// autogroup:self destinations on the machines of that same namespace.
+func (h *Headscale) generateACLPolicySelfRules(
+	machines []Machine,
+	aclPolicy ACLPolicy,
+	srcIPs []string,
+	selfDests []string,
+	protocols []int,
+	needsWildcard bool,
+) ([]tailcfg.FilterRule, error) {
+	sources, err := parseFilterIPs(srcIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []tailcfg.PortRange{}
+	for _, dest := range selfDests {
+		_, portsStr, err := splitACLDestination(dest)
+		if err != nil {
+			return nil, err
+		}
+		destPorts, err := expandPorts(portsStr, needsWildcard, aclPolicy.Ports)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, *destPorts...)
+	}
+
+	namespaces := []string{}
+	for _, machine := range machines {
+		if !contains(namespaces, machine.Namespace.Name) {
+			namespaces = append(namespaces, machine.Namespace.Name)
+		}
+	}
+	sort.Strings(namespaces)
+
+	rules := []tailcfg.FilterRule{}
+	for _, namespace := range namespaces {
+		nodes := filterMachinesByNamespace(machines, namespace)
+		nodes = excludeCorrectlyTaggedNodes(
+			aclPolicy,
+			nodes,
+			namespace,
+			h.cfg.OIDC.StripEmaildomain,
+		)
+
+		srcs := []string{}
+		dests := []tailcfg.NetPortRange{}
+		for _, node := range nodes {
+			for _, addr := range node.IPAddresses {
+				if sources.Contains(addr) {
+					srcs = append(srcs, addr.String())
+				}
+				for _, port := range ports {
+					dests = append(dests, tailcfg.NetPortRange{
+						IP:    addr.String(),
+						Ports: port,
+					})
+				}
+			}
+		}
+		if len(srcs) == 0 || len(dests) == 0 {
+			continue
+		}
+
+		rules = append(rules, tailcfg.FilterRule{
+			SrcIPs:   srcs,
+			DstPorts: dests,
+			IPProto:  protocols,
+		})
+	}
+
+	return rules, nil
+}
+
+// splitACLDestination splits an ACL destination into its alias and ports.
+// We can have here stuff like:
+// git-server:*
+// 192.168.1.0/24:22
+// fd7a:115c:a1e0::/48:22
+// [fd7a:115c:a1e0::1]:22
+// tag:montreal-webserver:80,443
+// tag:api-server:443
+// example-host-1:*
+// As the ports come after the last colon, IPv6 addresses must be enclosed
+// in brackets, unless they are written as a prefix.
+func splitACLDestination(dest string) (string, string, error) {
+	separator := strings.LastIndex(dest, ":")
+	if separator == -1 {
+		return "", "", errInvalidPortFormat
+	}
+	alias, ports := dest[:separator], dest[separator+1:]
+
+	if strings.HasPrefix(alias, "[") && strings.HasSuffix(alias, "]") {
+		return alias[1 : len(alias)-1], ports, nil
+	}
+
+	if _, err := netip.ParsePrefix(alias); err == nil {
+		return alias, ports, nil
+	}
+
+	// only tags have a colon in their name
+	if strings.Count(alias, ":") > 1 {
+		return "", "", errInvalidPortFormat
+	}
+
+	return alias, ports, nil
+}
+
 // parseProtocol reads the proto field of the ACL and generates a list of
 // protocols that will be allowed, following the IANA IP protocol number
 // https://www.iana.org/assignments/protocol-numbers/protocol-numbers.xhtml
@@ -258,7 +1967,7 @@ func (h *Headscale) generateACLPolicyDest(
 // requires all the destinations to use wildcard as port number (only TCP,
 // UDP and SCTP support specifying ports).
 func parseProtocol(protocol string) ([]int, bool, error) {
-	switch protocol {
+	switch strings.ToLower(protocol) {
 	case "":
 		return []int{
 			protocolICMP,
@@ -291,8 +2000,13 @@ func parseProtocol(protocol string) ([]int, bool, error) {
 
 	default:
 		protocolNumber, err := strconv.Atoi(protocol)
-		if err != nil {
-			return nil, false, err
+		if err != nil || protocolNumber < 0 || protocolNumber > maxProtocolNumber {
+			return nil, false, fmt.Errorf(
+				"%w: %q is neither a known protocol name nor a protocol number between 0 and %d",
+				errInvalidProtocol,
+				protocol,
+				maxProtocolNumber,
+			)
 		}
 		needsWildcard := protocolNumber != protocolTCP &&
 			protocolNumber != protocolUDP &&
@@ -306,6 +2020,7 @@ func parseProtocol(protocol string) ([]int, bool, error) {
 // - a namespace
 // - a group
 // - a tag
+// - an autogroup
 // and transform these in IPAddresses.
 func expandAlias(
 	machines []Machine,
@@ -318,12 +2033,58 @@ func expandAlias(
 		return []string{"*"}, nil
 	}
 
+	if alias == autogroupInternet {
+		return append([]string{}, internetPrefixes...), nil
+	}
+
+	if alias == autogroupMembers {
+		namespaces := []string{}
+		for _, machine := range machines {
+			if !contains(namespaces, machine.Namespace.Name) {
+				namespaces = append(namespaces, machine.Namespace.Name)
+			}
+		}
+		for _, namespace := range namespaces {
+			nodes := filterMachinesByNamespace(machines, namespace)
+			nodes = excludeCorrectlyTaggedNodes(
+				aclPolicy,
+				nodes,
+				namespace,
+				stripEmailDomain,
+			)
+			for _, node := range nodes {
+				ips = append(ips, node.IPAddresses.ToStringSlice()...)
+			}
+		}
+
+		logAliasExpansion(alias, ips)
+
+		return ips, nil
+	}
+
+	if alias == autogroupSelf {
+		return ips, fmt.Errorf(
+			"%w: %s can only be used as a destination",
+			errInvalidAutogroup,
+			alias,
+		)
+	}
+
+	if strings.HasPrefix(alias, "autogroup:") {
+		return ips, fmt.Errorf("%w: %s", errInvalidAutogroup, alias)
+	}
+
 	log.Debug().
 		Str("alias", alias).
 		Msg("Expanding")
 
 	if strings.HasPrefix(alias, "group:") {
-		namespaces, err := expandGroup(aclPolicy, alias, stripEmailDomain)
+		namespaces, err := expandGroup(
+			aclPolicy,
+			alias,
+			machinesNamespaces(machines),
+			stripEmailDomain,
+		)
 		if err != nil {
 			return ips, err
 		}
@@ -334,6 +2095,8 @@ func expandAlias(
 			}
 		}
 
+		logAliasExpansion(alias, ips)
+
 		return ips, nil
 	}
 
@@ -341,12 +2104,22 @@ func expandAlias(
 		// check for forced tags
 		for _, machine := range machines {
 			if contains(machine.ForcedTags, alias) {
+				log.Trace().
+					Str("alias", alias).
+					Str("machine", machine.Hostname).
+					Strs("forced_tags", machine.ForcedTags).
+					Msg("Machine matches tag through forced tags")
 				ips = append(ips, machine.IPAddresses.ToStringSlice()...)
 			}
 		}
 
 		// find tag owners
-		owners, err := expandTagOwners(aclPolicy, alias, stripEmailDomain)
+		owners, err := expandTagOwners(
+			aclPolicy,
+			alias,
+			machinesNamespaces(machines),
+			stripEmailDomain,
+		)
 		if err != nil {
 			if errors.Is(err, errInvalidTag) {
 				if len(ips) == 0 {
@@ -357,6 +2130,8 @@ func expandAlias(
 					)
 				}
 
+				logAliasExpansion(alias, ips)
+
 				return ips, nil
 			} else {
 				return ips, err
@@ -369,14 +2144,36 @@ func expandAlias(
 			for _, machine := range machines {
 				hi := machine.GetHostInfo()
 				if contains(hi.RequestTags, alias) {
+					log.Trace().
+						Str("alias", alias).
+						Str("machine", machine.Hostname).
+						Str("owner", namespace).
+						Strs("request_tags", hi.RequestTags).
+						Msg("Machine matches tag through requested tags")
+					ips = append(ips, machine.IPAddresses.ToStringSlice()...)
+				} else if contains(machine.Namespace.DefaultTags, alias) {
+					log.Trace().
+						Str("alias", alias).
+						Str("machine", machine.Hostname).
+						Str("owner", namespace).
+						Strs("default_tags", machine.Namespace.DefaultTags).
+						Msg("Machine matches tag through the default tags of its namespace")
 					ips = append(ips, machine.IPAddresses.ToStringSlice()...)
 				}
 			}
 		}
 
+		logAliasExpansion(alias, ips)
+
 		return ips, nil
 	}
 
+	// IP and CIDR literals are parsed before any name lookup, a source
+	// like 10.0.0.0/8 covers the whole range
+	if expanded, ok := expandIPOrPrefix(alias); ok {
+		return expanded, nil
+	}
+
 	// if alias is a namespace
 	nodes := filterMachinesByNamespace(machines, alias)
 	nodes = excludeCorrectlyTaggedNodes(aclPolicy, nodes, alias, stripEmailDomain)
@@ -393,21 +2190,38 @@ func expandAlias(
 		return []string{h.String()}, nil
 	}
 
-	// if alias is an IP
-	ip, err := netip.ParseAddr(alias)
-	if err == nil {
-		return []string{ip.String()}, nil
+	log.Warn().Msgf("No IPs found with the alias %v", alias)
+
+	return ips, nil
+}
+
+// expandIPOrPrefix returns the address or the CIDR written as alias, v4 or
+// v6. A CIDR is kept as written in the policy.
+func expandIPOrPrefix(alias string) ([]string, bool) {
+	if ip, err := netip.ParseAddr(alias); err == nil {
+		return []string{ip.String()}, true
 	}
 
-	// if alias is an CIDR
-	cidr, err := netip.ParsePrefix(alias)
-	if err == nil {
-		return []string{cidr.String()}, nil
+	if _, err := netip.ParsePrefix(alias); err == nil {
+		return []string{alias}, true
 	}
 
-	log.Warn().Msgf("No IPs found with the alias %v", alias)
+	return nil, false
+}
 
-	return ips, nil
+// logAliasExpansion reports the result of expanding a group or tag alias.
+// Only the number of addresses is logged at debug level, the addresses
+// themselves are only logged at trace level.
+func logAliasExpansion(alias string, ips []string) {
+	log.Debug().
+		Str("alias", alias).
+		Int("ips", len(ips)).
+		Msg("Expanded alias")
+
+	log.Trace().
+		Str("alias", alias).
+		Strs("ips", ips).
+		Msg("Expanded alias addresses")
 }
 
 // excludeCorrectlyTaggedNodes will remove from the list of input nodes the ones
@@ -422,7 +2236,7 @@ func excludeCorrectlyTaggedNodes(
 	out := []Machine{}
 	tags := []string{}
 	for tag := range aclPolicy.TagOwners {
-		owners, _ := expandTagOwners(aclPolicy, namespace, stripEmailDomain)
+		owners, _ := expandTagOwners(aclPolicy, namespace, []string{namespace}, stripEmailDomain)
 		ns := append(owners, namespace)
 		if contains(ns, namespace) {
 			tags = append(tags, tag)
@@ -432,8 +2246,11 @@ func excludeCorrectlyTaggedNodes(
 	for _, machine := range nodes {
 		hi := machine.GetHostInfo()
 
+		machineTags := append([]string{}, hi.RequestTags...)
+		machineTags = append(machineTags, machine.Namespace.DefaultTags...)
+
 		found := false
-		for _, t := range hi.RequestTags {
+		for _, t := range machineTags {
 			if contains(tags, t) {
 				found = true
 
@@ -451,7 +2268,16 @@ func excludeCorrectlyTaggedNodes(
 	return out
 }
 
-func expandPorts(portsStr string, needsWildcard bool) (*[]tailcfg.PortRange, error) {
+func expandPorts(
+	portsStr string,
+	needsWildcard bool,
+	namedPorts Ports,
+) (*[]tailcfg.PortRange, error) {
+	portsStr, err := resolveNamedPorts(portsStr, namedPorts)
+	if err != nil {
+		return nil, err
+	}
+
 	if portsStr == "*" {
 		return &[]tailcfg.PortRange{
 			{First: portRangeBegin, Last: portRangeEnd},
@@ -498,6 +2324,47 @@ func expandPorts(portsStr string, needsWildcard bool) (*[]tailcfg.PortRange, err
 	return &ports, nil
 }
 
+// resolveNamedPorts replaces every token of portsStr that is defined in the
+// ports section of the policy with its value. Tokens that are neither a
+// number nor a defined name are rejected so typos do not go unnoticed.
+func resolveNamedPorts(portsStr string, namedPorts Ports) (string, error) {
+	if portsStr == "*" {
+		return portsStr, nil
+	}
+
+	tokens := strings.Split(portsStr, ",")
+	for index, token := range tokens {
+		if value, ok := namedPorts[token]; ok {
+			tokens[index] = value
+
+			continue
+		}
+
+		if token != "" && (token[0] < '0' || token[0] > '9') {
+			return "", fmt.Errorf(
+				"%w: port %q is not defined in ports",
+				errInvalidPortFormat,
+				token,
+			)
+		}
+	}
+
+	return strings.Join(tokens, ","), nil
+}
+
+// machinesNamespaces returns the names of the namespaces of the machines,
+// the pattern members of the groups are matched against them.
+func machinesNamespaces(machines []Machine) []string {
+	namespaces := []string{}
+	for _, machine := range machines {
+		if !contains(namespaces, machine.Namespace.Name) {
+			namespaces = append(namespaces, machine.Namespace.Name)
+		}
+	}
+
+	return namespaces
+}
+
 func filterMachinesByNamespace(machines []Machine, namespace string) []Machine {
 	out := []Machine{}
 	for _, machine := range machines {
@@ -509,11 +2376,173 @@ func filterMachinesByNamespace(machines []Machine, namespace string) []Machine {
 	return out
 }
 
+// validateTagOwners checks the tagOwners of the policy, even the ones no ACL
+// refers to: the tags must be well formed, and the groups owning them must
+// be defined. An owner which is not an existing namespace is a warning, or
+// an error with acl.empty_alias set to error, like the aliases matching
+// no machine.
+func (h *Headscale) validateTagOwners(
+	policy *ACLPolicy,
+	emptyAlias string,
+) ([]string, error) {
+	namespaces, err := h.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	namespaceNames := make([]string, len(namespaces))
+	for index, namespace := range namespaces {
+		namespaceNames[index] = namespace.Name
+	}
+
+	tags := make([]string, 0, len(policy.TagOwners))
+	for tag := range policy.TagOwners {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	problems := []string{}
+	warnings := []string{}
+	for _, tag := range tags {
+		if err := validateTag(tag); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", tag, err))
+		}
+
+		for _, owner := range policy.TagOwners[tag] {
+			if strings.HasPrefix(owner, "group:") {
+				if _, ok := policy.Groups[owner]; !ok {
+					problems = append(problems, fmt.Sprintf(
+						"%s: group %s is not defined",
+						tag,
+						owner,
+					))
+				}
+
+				continue
+			}
+
+			namespace, err := NormalizeToFQDNRules(owner, h.cfg.OIDC.StripEmaildomain)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf(
+					"%s: owner %s is not a valid namespace name",
+					tag,
+					owner,
+				))
+
+				continue
+			}
+
+			if !contains(namespaceNames, namespace) {
+				problem := fmt.Sprintf("%s: owner %s is not an existing namespace", tag, owner)
+				if emptyAlias == ACLEmptyAliasError {
+					problems = append(problems, problem)
+				} else {
+					warnings = append(warnings, problem)
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, TagOwnersError{Problems: problems}
+	}
+
+	return warnings, nil
+}
+
+// validateGroupMembers checks, when the policy is loaded, that the members
+// of the groups are existing namespaces, to catch the groups still listing
+// a deleted namespace. Unknown members are only warnings when acl_strict is
+// disabled, to pre-declare groups before their namespaces exist.
+// Unlike the other checks, it is not run when the rules are regenerated, so
+// that deleting a namespace does not prevent the rules from being updated.
+func (h *Headscale) validateGroupMembers(policy *ACLPolicy) ([]string, error) {
+	namespaces, err := h.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	namespaceNames := make([]string, len(namespaces))
+	for index, namespace := range namespaces {
+		namespaceNames[index] = namespace.Name
+	}
+
+	groups := make([]string, 0, len(policy.Groups))
+	for group := range policy.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	problems := []string{}
+	warnings := []string{}
+	for _, group := range groups {
+		for _, member := range policy.Groups[group] {
+			if strings.HasPrefix(member, "group:") {
+				problems = append(problems, fmt.Sprintf(
+					"%s: member %s %s",
+					group,
+					member,
+					groupMemberProblem(*policy, group, member),
+				))
+
+				continue
+			}
+
+			if strings.HasPrefix(member, groupPatternPrefix) {
+				matches, err := expandGroup(
+					ACLPolicy{Groups: Groups{group: {member}}},
+					group,
+					namespaceNames,
+					h.cfg.OIDC.StripEmaildomain,
+				)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s", group, err))
+				} else if len(matches) == 0 {
+					problem := fmt.Sprintf("%s: member %s matches no existing namespace", group, member)
+					if h.cfg.ACL.Strict {
+						problems = append(problems, problem)
+					} else {
+						warnings = append(warnings, problem)
+					}
+				}
+
+				continue
+			}
+
+			namespace, err := NormalizeToFQDNRules(member, h.cfg.OIDC.StripEmaildomain)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf(
+					"%s: member %s is not a valid namespace name",
+					group,
+					member,
+				))
+
+				continue
+			}
+
+			if !contains(namespaceNames, namespace) {
+				problem := fmt.Sprintf("%s: member %s is not an existing namespace", group, member)
+				if h.cfg.ACL.Strict {
+					problems = append(problems, problem)
+				} else {
+					warnings = append(warnings, problem)
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, GroupsError{Problems: problems}
+	}
+
+	return warnings, nil
+}
+
 // expandTagOwners will return a list of namespace. An owner can be either a namespace or a group
-// a group cannot be composed of groups.
+// a group cannot be composed of groups. The pattern members of the groups
+// are matched against the given namespaces.
 func expandTagOwners(
 	aclPolicy ACLPolicy,
 	tag string,
+	namespaces []string,
 	stripEmailDomain bool,
 ) ([]string, error) {
 	var owners []string
@@ -527,7 +2556,7 @@ func expandTagOwners(
 	}
 	for _, owner := range ows {
 		if strings.HasPrefix(owner, "group:") {
-			gs, err := expandGroup(aclPolicy, owner, stripEmailDomain)
+			gs, err := expandGroup(aclPolicy, owner, namespaces, stripEmailDomain)
 			if err != nil {
 				return []string{}, err
 			}
@@ -537,14 +2566,21 @@ func expandTagOwners(
 		}
 	}
 
+	log.Debug().
+		Str("tag", tag).
+		Strs("owners", owners).
+		Msg("Expanded tag owners")
+
 	return owners, nil
 }
 
 // expandGroup will return the list of namespace inside the group
-// after some validation.
+// after some validation. A pattern: member expands to the given namespaces
+// it matches.
 func expandGroup(
 	aclPolicy ACLPolicy,
 	group string,
+	namespaces []string,
 	stripEmailDomain bool,
 ) ([]string, error) {
 	outGroups := []string{}
@@ -556,23 +2592,115 @@ func expandGroup(
 			errInvalidGroup,
 		)
 	}
-	for _, group := range aclGroups {
-		if strings.HasPrefix(group, "group:") {
+	for _, member := range aclGroups {
+		if strings.HasPrefix(member, "group:") {
 			return []string{}, fmt.Errorf(
-				"%w. A group cannot be composed of groups. https://tailscale.com/kb/1018/acls/#groups",
+				"%w: %s: member %s %s. https://tailscale.com/kb/1018/acls/#groups",
 				errInvalidGroup,
+				group,
+				member,
+				groupMemberProblem(aclPolicy, group, member),
 			)
 		}
-		grp, err := NormalizeToFQDNRules(group, stripEmailDomain)
+		if strings.HasPrefix(member, groupPatternPrefix) {
+			pattern, err := compileGroupPattern(member)
+			if err != nil {
+				return []string{}, err
+			}
+			for _, namespace := range namespaces {
+				if pattern.MatchString(namespace) && !contains(outGroups, namespace) {
+					outGroups = append(outGroups, namespace)
+				}
+			}
+
+			continue
+		}
+		grp, err := NormalizeToFQDNRules(member, stripEmailDomain)
 		if err != nil {
 			return []string{}, fmt.Errorf(
 				"failed to normalize group %q, err: %w",
-				group,
+				member,
 				errInvalidGroup,
 			)
 		}
 		outGroups = append(outGroups, grp)
 	}
 
+	log.Debug().
+		Str("group", group).
+		Strs("namespaces", outGroups).
+		Msg("Expanded group")
+
 	return outGroups, nil
 }
+
+// groupMemberProblem explains why a group: member of the group is refused.
+// Groups cannot be nested, and as a namespace name cannot contain ':', the
+// member cannot be a namespace either.
+func groupMemberProblem(aclPolicy ACLPolicy, group string, member string) string {
+	if member == group {
+		return "is the group itself, groups cannot be nested"
+	}
+
+	if _, ok := aclPolicy.Groups[member]; !ok {
+		return "is not a defined group, and a namespace name cannot contain ':'"
+	}
+
+	if cycle := groupCycle(aclPolicy, member, group, []string{group, member}); cycle != nil {
+		return fmt.Sprintf(
+			"is a group including %s back (%s), groups cannot be nested",
+			group,
+			strings.Join(cycle, " -> "),
+		)
+	}
+
+	return "is a group, groups cannot be nested"
+}
+
+// groupCycle returns the path from the group to the target group through
+// the group: members of the groups, or nil if the target is not reached.
+func groupCycle(aclPolicy ACLPolicy, group string, target string, path []string) []string {
+	for _, member := range aclPolicy.Groups[group] {
+		if !strings.HasPrefix(member, "group:") {
+			continue
+		}
+
+		memberPath := append(append([]string{}, path...), member)
+		if member == target {
+			return memberPath
+		}
+
+		if _, ok := aclPolicy.Groups[member]; !ok || contains(path, member) {
+			continue
+		}
+
+		if cycle := groupCycle(aclPolicy, member, target, memberPath); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// compileGroupPattern compiles the regular expression of a pattern: group
+// member, which has to match the whole namespace name. Go regular
+// expressions run in linear time, a pattern cannot make the generation of
+// the rules hang, and their length is bounded.
+func compileGroupPattern(member string) (*regexp.Regexp, error) {
+	expression := strings.TrimPrefix(member, groupPatternPrefix)
+	if expression == "" || len(expression) > maxGroupPatternLength {
+		return nil, fmt.Errorf(
+			"%w: pattern %q must be 1 to %d characters long",
+			errInvalidGroup,
+			member,
+			maxGroupPatternLength,
+		)
+	}
+
+	pattern, err := regexp.Compile("^(?:" + expression + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("%w: pattern %q: %s", errInvalidGroup, member, err)
+	}
+
+	return pattern, nil
+}
@@ -2,12 +2,17 @@ package headscale
 
 import (
 	"errors"
+	"fmt"
 	"net/netip"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"gopkg.in/check.v1"
 	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
 )
 
 func (s *Suite) TestWrongPath(c *check.C) {
@@ -37,6 +42,20 @@ func (s *Suite) TestParseHosts(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *Suite) TestParseIPv6Hosts(c *check.C) {
+	var hosts Hosts
+	err := hosts.UnmarshalJSON(
+		[]byte(
+			`{"example-host-1": "fd7a:115c:a1e0::1","example-host-2": "fd7a:115c:a1e0::/48"}`,
+		),
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(hosts, check.DeepEquals, Hosts{
+		"example-host-1": netip.MustParsePrefix("fd7a:115c:a1e0::1/128"),
+		"example-host-2": netip.MustParsePrefix("fd7a:115c:a1e0::/48"),
+	})
+}
+
 func (s *Suite) TestParseInvalidCIDR(c *check.C) {
 	var hosts Hosts
 	err := hosts.UnmarshalJSON([]byte(`{"example-host-1": "100.100.100.100/42"}`))
@@ -44,6 +63,68 @@ func (s *Suite) TestParseInvalidCIDR(c *check.C) {
 	c.Assert(err, check.NotNil)
 }
 
+func (s *Suite) TestParseInvalidHost(c *check.C) {
+	_, err := parseACLPolicy([]byte(`{
+		"hosts": {"office": "10.1.0.0/16", "printer": "10.1.0.300"},
+		"acls": [{"action": "accept", "src": ["*"], "dst": ["office:*"]}],
+	}`), "")
+	c.Assert(errors.Is(err, errInvalidHost), check.Equals, true)
+	c.Assert(
+		err.Error(),
+		check.Equals,
+		`line 2, column 12: decoding "hosts": `+
+			`invalid host: printer: "10.1.0.300" is neither an IP address nor a CIDR`,
+	)
+
+	_, err = parseACLPolicy([]byte("hosts:\n  office: 10.1.0.0/33\n"), ".yaml")
+	c.Assert(errors.Is(err, errInvalidHost), check.Equals, true)
+}
+
+func (s *Suite) TestHuJSONPolicyErrors(c *check.C) {
+	_, err := parseACLPolicy([]byte(`{
+  // a comment
+  "acls": [
+    {"action": "accept" "src": ["*"], "dst": ["*:*"]},
+  ],
+}`), ".hujson")
+	c.Assert(err, check.ErrorMatches, "hujson: line 4, column 25: .*")
+
+	_, err = parseACLPolicy([]byte(`{
+  // a comment
+  "groups": {"group:a": ["alice"],},
+  "acls": [
+    {"action": "accept", "src": "*", "dst": ["*:*"]},
+  ],
+}`), ".hujson")
+	c.Assert(err, check.ErrorMatches, `line 5, column 33: decoding "acls": json: cannot unmarshal string .*`)
+
+	_, err = parseACLPolicy([]byte(`{
+  "acls": [],
+  "hosts": {
+    "printer": "10.1.0.300",
+  },
+}`), ".hujson")
+	c.Assert(errors.Is(err, errInvalidHost), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, `line 3, column 12: decoding "hosts": invalid host: printer: .*`)
+}
+
+func (s *Suite) TestCIDRHostRules(c *check.C) {
+	policy, err := parseACLPolicy([]byte(`{
+		"hosts": {"office": "10.1.0.0/16", "printer": "10.1.0.10"},
+		"acls": [{"action": "accept", "src": ["office"], "dst": ["printer:631"]}],
+	}`), "")
+	c.Assert(err, check.IsNil)
+	app.aclPolicy = &policy
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	_, rules, _ := app.getACL()
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].SrcIPs, check.DeepEquals, []string{"10.1.0.0/16"})
+	c.Assert(rules[0].DstPorts, check.DeepEquals, []tailcfg.NetPortRange{
+		{IP: "10.1.0.10/32", Ports: tailcfg.PortRange{First: 631, Last: 631}},
+	})
+}
+
 func (s *Suite) TestRuleInvalidGeneration(c *check.C) {
 	err := app.LoadACLPolicy("./tests/acls/acl_policy_invalid.hujson")
 	c.Assert(err, check.NotNil)
@@ -58,6 +139,439 @@ func (s *Suite) TestBasicRule(c *check.C) {
 	c.Assert(rules, check.NotNil)
 }
 
+func (s *Suite) TestReloadACLPolicy(c *check.C) {
+	namespace, err := app.CreateNamespace("reload")
+	c.Assert(err, check.IsNil)
+
+	app.cfg.ACL.PolicyPath = "./tests/acls/acl_policy_basic_1.hujson"
+	err = app.reloadACLPolicy()
+	c.Assert(err, check.IsNil)
+
+	policy, rules, _ := app.getACL()
+	c.Assert(policy, check.NotNil)
+	c.Assert(rules, check.NotNil)
+	lastChange := app.getLastStateChange(namespace.Name)
+	c.Assert(lastChange.IsZero(), check.Equals, false)
+
+	// a broken policy keeps the current rules and doesn't notify the nodes
+	app.cfg.ACL.PolicyPath = "./tests/acls/broken.hujson"
+	err = app.reloadACLPolicy()
+	c.Assert(err, check.NotNil)
+
+	newPolicy, newRules, _ := app.getACL()
+	c.Assert(newPolicy, check.Equals, policy)
+	c.Assert(newRules, check.DeepEquals, rules)
+	c.Assert(app.getLastStateChange(namespace.Name), check.Equals, lastChange)
+}
+
+func (s *Suite) TestValidateACLPolicyWarnings(c *check.C) {
+	namespace, err := app.CreateNamespace("testnamespace")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:             0,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		IPAddresses:    MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	}
+	app.db.Save(&machine)
+
+	policy := &ACLPolicy{
+		Groups: Groups{
+			"group:contractors": []string{},
+			"group:admins":      []string{"testnamespace"},
+		},
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"group:admins"},
+				Destinations: []string{"*:*"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"group:contractors"},
+				Destinations: []string{"testnamespace:22"},
+			},
+		},
+	}
+
+	warnings, err := app.ValidateACLPolicy(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings, check.DeepEquals, []string{
+		"group:contractors is empty",
+		"ACL 1: source group:contractors does not match any machine",
+		"ACL 1 does not match any traffic",
+	})
+
+	// Validating a policy must not apply it.
+	c.Assert(app.aclPolicy, check.IsNil)
+	c.Assert(app.aclRules, check.IsNil)
+}
+
+func (s *Suite) TestEmptyAliasBehavior(c *check.C) {
+	_, err := app.CreateNamespace("emptynamespace")
+	c.Assert(err, check.IsNil)
+
+	emptyNamespacePolicy := &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"emptynamespace:*"},
+			},
+		},
+	}
+	emptyGroupPolicy := &ACLPolicy{
+		Groups: Groups{
+			"group:empty": []string{},
+		},
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"group:empty"},
+				Destinations: []string{"*:*"},
+			},
+		},
+	}
+
+	defer func() { app.cfg.ACL.EmptyAlias = "" }()
+
+	app.cfg.ACL.EmptyAlias = ACLEmptyAliasWarn
+
+	warnings, err := app.ValidateACLPolicy(emptyNamespacePolicy)
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings, check.DeepEquals, []string{
+		"ACL 0: destination emptynamespace:* does not match any machine",
+		"ACL 0 does not match any traffic",
+	})
+
+	warnings, err = app.ValidateACLPolicy(emptyGroupPolicy)
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings, check.DeepEquals, []string{
+		"group:empty is empty",
+		"ACL 0: source group:empty does not match any machine",
+		"ACL 0 does not match any traffic",
+	})
+
+	app.cfg.ACL.EmptyAlias = ACLEmptyAliasError
+
+	_, err = app.ValidateACLPolicy(emptyNamespacePolicy)
+	c.Assert(errors.Is(err, errEmptyAlias), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, "ACL 0, dst: .*: emptynamespace:\\*")
+
+	_, err = app.ValidateACLPolicy(emptyGroupPolicy)
+	c.Assert(errors.Is(err, errEmptyAlias), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, "ACL 0, src: .*: group:empty")
+}
+
+func (s *Suite) TestEmptyAliasAfterPolicyLoad(c *check.C) {
+	namespace, err := app.CreateNamespace("shrinking")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:          0,
+		MachineKey:  "foo",
+		NodeKey:     "bar",
+		DiscoKey:    "faa",
+		Hostname:    "lastmachine",
+		IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		NamespaceID: namespace.ID,
+	}
+	app.db.Save(&machine)
+
+	defer func() { app.cfg.ACL.EmptyAlias = "" }()
+	app.cfg.ACL.EmptyAlias = ACLEmptyAliasError
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"shrinking"},
+				Destinations: []string{"*:*"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"*:22"},
+			},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 2)
+
+	// the namespace losing its last machine does not freeze the rules
+	c.Assert(app.db.Unscoped().Delete(&machine).Error, check.IsNil)
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 2)
+	c.Assert(app.aclRules[0].SrcIPs, check.HasLen, 0)
+
+	// while loading the policy still refuses it
+	_, err = app.ValidateACLPolicy(app.aclPolicy)
+	c.Assert(errors.Is(err, errEmptyAlias), check.Equals, true)
+}
+
+func (s *Suite) TestValidateTagOwners(c *check.C) {
+	_, err := app.CreateNamespace("owner")
+	c.Assert(err, check.IsNil)
+
+	// no ACL refers to the tags, they are validated anyway
+	policy := &ACLPolicy{
+		Groups: Groups{"group:admins": []string{"owner"}},
+		TagOwners: TagOwners{
+			"tag:valid":       []string{"owner", "group:admins"},
+			"server":          []string{"owner"},
+			"tag:Typo":        []string{"group:admin"},
+			"tag:unknown-ns":  []string{"nobody"},
+			"tag:invalid-own": []string{"not a namespace!"},
+		},
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"*:*"},
+			},
+		},
+	}
+
+	defer func() { app.cfg.ACL.EmptyAlias = "" }()
+
+	_, err = app.ValidateACLPolicy(policy)
+	c.Assert(errors.Is(err, errInvalidTag), check.Equals, true)
+	var tagOwnersErr TagOwnersError
+	c.Assert(errors.As(err, &tagOwnersErr), check.Equals, true)
+	c.Assert(tagOwnersErr.Problems, check.DeepEquals, []string{
+		"server: tag must start with the string 'tag:'",
+		"tag:Typo: tag should be lowercase",
+		"tag:Typo: group group:admin is not defined",
+	})
+
+	delete(policy.TagOwners, "server")
+	delete(policy.TagOwners, "tag:Typo")
+
+	// owners which are not existing namespaces follow acl.empty_alias
+	warnings, err := app.ValidateACLPolicy(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings, check.DeepEquals, []string{
+		"tag:invalid-own: owner not a namespace! is not an existing namespace",
+		"tag:unknown-ns: owner nobody is not an existing namespace",
+	})
+
+	app.cfg.ACL.EmptyAlias = ACLEmptyAliasError
+	_, err = app.ValidateACLPolicy(policy)
+	c.Assert(errors.As(err, &tagOwnersErr), check.Equals, true)
+	c.Assert(tagOwnersErr.Problems, check.HasLen, 2)
+}
+
+func (s *Suite) TestValidateGroupMembers(c *check.C) {
+	_, err := app.CreateNamespace("alice")
+	c.Assert(err, check.IsNil)
+
+	policy := []byte(`{
+		"groups": {
+			"group:admins": ["alice", "deleted"],
+			"group:nested": ["group:admins"],
+		},
+		"acls": [
+			{"action": "accept", "src": ["group:admins"], "dst": ["*:*"]},
+		],
+	}`)
+
+	defer func() { app.cfg.ACL.Strict = false }()
+
+	app.cfg.ACL.Strict = true
+	_, _, err = app.CheckACLPolicy(policy)
+	c.Assert(errors.Is(err, errInvalidGroup), check.Equals, true)
+	var groupsErr GroupsError
+	c.Assert(errors.As(err, &groupsErr), check.Equals, true)
+	c.Assert(groupsErr.Problems, check.DeepEquals, []string{
+		"group:admins: member deleted is not an existing namespace",
+		"group:nested: member group:admins is a group, groups cannot be nested",
+	})
+
+	policy = []byte(`{
+		"groups": {
+			"group:admins": ["alice", "deleted"],
+		},
+		"acls": [
+			{"action": "accept", "src": ["group:admins"], "dst": ["*:*"]},
+		],
+	}`)
+
+	_, _, err = app.CheckACLPolicy(policy)
+	c.Assert(errors.As(err, &groupsErr), check.Equals, true)
+	c.Assert(groupsErr.Problems, check.HasLen, 1)
+
+	// without strict mode, the groups can be declared before the namespaces
+	app.cfg.ACL.Strict = false
+	_, warnings, err := app.CheckACLPolicy(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings[0], check.Equals, "group:admins: member deleted is not an existing namespace")
+}
+
+func (s *Suite) TestNestedGroupMembers(c *check.C) {
+	_, _, err := app.CheckACLPolicy([]byte(`{
+		"groups": {
+			"group:self": ["group:self"],
+			"group:a": ["alice", "group:b"],
+			"group:b": ["group:c"],
+			"group:c": ["group:a"],
+			"group:ops": ["group:admins"],
+			"group:admins": ["alice"],
+			"group:typo": ["group:foo"],
+		},
+		"acls": [
+			{"action": "accept", "src": ["*"], "dst": ["*:*"]},
+		],
+	}`))
+	var groupsErr GroupsError
+	c.Assert(errors.As(err, &groupsErr), check.Equals, true)
+	c.Assert(groupsErr.Problems, check.DeepEquals, []string{
+		"group:a: member group:b is a group including group:a back " +
+			"(group:a -> group:b -> group:c -> group:a), groups cannot be nested",
+		"group:b: member group:c is a group including group:b back " +
+			"(group:b -> group:c -> group:a -> group:b), groups cannot be nested",
+		"group:c: member group:a is a group including group:c back " +
+			"(group:c -> group:a -> group:b -> group:c), groups cannot be nested",
+		"group:ops: member group:admins is a group, groups cannot be nested",
+		"group:self: member group:self is the group itself, groups cannot be nested",
+		"group:typo: member group:foo is not a defined group, and a namespace name cannot contain ':'",
+	})
+
+	_, err = expandGroup(
+		ACLPolicy{Groups: Groups{"group:typo": []string{"alice", "group:foo"}}},
+		"group:typo",
+		nil,
+		true,
+	)
+	c.Assert(errors.Is(err, errInvalidGroup), check.Equals, true)
+	c.Assert(
+		err.Error(),
+		check.Equals,
+		"invalid group: group:typo: member group:foo is not a defined group, "+
+			"and a namespace name cannot contain ':'. https://tailscale.com/kb/1018/acls/#groups",
+	)
+}
+
+func (s *Suite) TestGroupPatternMembers(c *check.C) {
+	for index, name := range []string{"team-a", "ops", "team-b", "myteam-c", "team-c"} {
+		namespace, err := app.CreateNamespace(name)
+		c.Assert(err, check.IsNil)
+
+		machine := Machine{
+			ID:          uint64(index + 1),
+			MachineKey:  fmt.Sprintf("foo%d", index),
+			NodeKey:     fmt.Sprintf("bar%d", index),
+			DiscoKey:    fmt.Sprintf("faa%d", index),
+			Hostname:    fmt.Sprintf("machine%d", index),
+			NamespaceID: namespace.ID,
+			IPAddresses: MachineAddresses{
+				netip.MustParseAddr(fmt.Sprintf("100.64.0.%d", index+1)),
+			},
+		}
+		app.db.Save(&machine)
+	}
+
+	policy, err := parseACLPolicy([]byte(`{
+		"groups": {"group:teams": ["pattern:team-.*"]},
+		"acls": [
+			{"action": "accept", "src": ["group:teams"], "dst": ["ops:*"]},
+		],
+	}`), "")
+	c.Assert(err, check.IsNil)
+	app.aclPolicy = &policy
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	_, rules, _ := app.getACL()
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].SrcIPs, check.DeepEquals, []string{"100.64.0.1", "100.64.0.3", "100.64.0.5"})
+
+	_, warnings, err := app.CheckACLPolicy([]byte(`{
+		"groups": {"group:teams": ["pattern:qa-.*"]},
+		"acls": [
+			{"action": "accept", "src": ["ops"], "dst": ["ops:*"]},
+		],
+	}`))
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings, check.DeepEquals, []string{
+		"group:teams: member pattern:qa-.* matches no existing namespace",
+	})
+
+	_, _, err = app.CheckACLPolicy([]byte(`{
+		"groups": {"group:teams": ["pattern:team-(a"]},
+		"acls": [
+			{"action": "accept", "src": ["group:teams"], "dst": ["ops:*"]},
+		],
+	}`))
+	c.Assert(errors.Is(err, errInvalidGroup), check.Equals, true)
+}
+
+func (s *Suite) TestCheckACLPolicy(c *check.C) {
+	ruleCount, warnings, err := app.CheckACLPolicy([]byte(`{
+		// a comment, as allowed by HuJSON
+		"acls": [
+			{"action": "accept", "src": ["*"], "dst": ["*:*"]},
+			{"action": "accept", "src": ["10.0.0.1"], "dst": ["10.0.0.2:22"]},
+		],
+	}`))
+	c.Assert(err, check.IsNil)
+	c.Assert(ruleCount, check.Equals, 2)
+	c.Assert(warnings, check.HasLen, 0)
+	// the live rules are untouched
+	c.Assert(app.getACLRules(), check.IsNil)
+
+	_, _, err = app.CheckACLPolicy([]byte(`{
+		"acls": [
+			{"action": "accept", "src": ["*"], "dst": ["*:*"]},
+			{"action": "accept", "proto": "gre", "src": ["*"], "dst": ["10.0.0.2:22"]},
+		],
+	}`))
+	var aclErr ACLError
+	c.Assert(errors.As(err, &aclErr), check.Equals, true)
+	c.Assert(aclErr.Index, check.Equals, 1)
+	c.Assert(aclErr.Field, check.Equals, "dst")
+	c.Assert(errors.Is(err, errWildcardIsNeeded), check.Equals, true)
+
+	_, _, err = app.CheckACLPolicy([]byte(`{"acls": [`))
+	c.Assert(err, check.NotNil)
+	c.Assert(errors.As(err, &aclErr), check.Equals, false)
+}
+
+func (s *Suite) TestValidateACLPolicyNoWarnings(c *check.C) {
+	policy := &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"*:*"},
+			},
+		},
+	}
+
+	warnings, err := app.ValidateACLPolicy(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(warnings, check.HasLen, 0)
+}
+
+func (s *Suite) TestValidateACLPolicyErrorsAreFatal(c *check.C) {
+	policy := &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"tag:unowned"},
+				Destinations: []string{"*:*"},
+			},
+		},
+	}
+
+	warnings, err := app.ValidateACLPolicy(policy)
+	c.Assert(errors.Is(err, errInvalidTag), check.Equals, true)
+	c.Assert(warnings, check.IsNil)
+}
+
 // TODO(kradalby): Make tests values safe, independent and descriptive.
 func (s *Suite) TestInvalidAction(c *check.C) {
 	app.aclPolicy = &ACLPolicy{
@@ -73,6 +587,525 @@ func (s *Suite) TestInvalidAction(c *check.C) {
 	c.Assert(errors.Is(err, errInvalidAction), check.Equals, true)
 }
 
+func (s *Suite) TestDualStackRules(c *check.C) {
+	for index, name := range []string{"user1", "user2"} {
+		namespace, err := app.CreateNamespace(name)
+		c.Assert(err, check.IsNil)
+
+		machine := Machine{
+			ID:          uint64(index + 1),
+			MachineKey:  "foo" + name,
+			NodeKey:     "bar" + name,
+			DiscoKey:    "faa" + name,
+			Hostname:    "machine-" + name,
+			NamespaceID: namespace.ID,
+			IPAddresses: MachineAddresses{
+				netip.MustParseAddr(fmt.Sprintf("100.64.0.%d", index+1)),
+				netip.MustParseAddr(fmt.Sprintf("fd7a:115c:a1e0::%d", index+1)),
+			},
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+		app.db.Save(&machine)
+	}
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"user1"},
+				Destinations: []string{"user2:22"},
+			},
+			{
+				Action:  "accept",
+				Sources: []string{"fd7a:115c:a1e0::1"},
+				Destinations: []string{
+					"[fd7a:115c:a1e0::2]:80",
+					"fd7a:115c:a1e0:0::/48:443",
+				},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.aclRules, check.HasLen, 2)
+	c.Assert(
+		app.aclRules[0].SrcIPs,
+		check.DeepEquals,
+		[]string{"100.64.0.1", "fd7a:115c:a1e0::1"},
+	)
+	c.Assert(app.aclRules[0].DstPorts, check.DeepEquals, []tailcfg.NetPortRange{
+		{IP: "100.64.0.2", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+		{IP: "fd7a:115c:a1e0::2", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+	})
+	c.Assert(app.aclRules[1].SrcIPs, check.DeepEquals, []string{"fd7a:115c:a1e0::1"})
+	c.Assert(app.aclRules[1].DstPorts, check.DeepEquals, []tailcfg.NetPortRange{
+		{IP: "fd7a:115c:a1e0::2", Ports: tailcfg.PortRange{First: 80, Last: 80}},
+		{IP: "fd7a:115c:a1e0:0::/48", Ports: tailcfg.PortRange{First: 443, Last: 443}},
+	})
+}
+
+func (s *Suite) TestInvalidIPv6Destination(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"fd7a:115c:a1e0::2:80"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(errors.Is(err, errInvalidPortFormat), check.Equals, true)
+}
+
+func (s *Suite) TestProtocolRule(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Protocol:     "tcp",
+				Sources:      []string{"100.64.0.1"},
+				Destinations: []string{"100.64.0.2:22"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"100.64.0.1"},
+				Destinations: []string{"100.64.0.3:*"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.aclRules, check.DeepEquals, []tailcfg.FilterRule{
+		{
+			SrcIPs: []string{"100.64.0.1"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.2", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+			},
+			IPProto: []int{protocolTCP},
+		},
+		{
+			SrcIPs: []string{"100.64.0.1"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.3", Ports: tailcfg.PortRangeAny},
+			},
+			IPProto: []int{protocolICMP, protocolIPv6ICMP, protocolTCP, protocolUDP},
+		},
+	})
+
+	app.aclPolicy.ACLs[0].Protocol = "tcpx"
+	err = app.UpdateACLRules()
+	var aclErr ACLError
+	c.Assert(errors.As(err, &aclErr), check.Equals, true)
+	c.Assert(aclErr.Field, check.Equals, "proto")
+	c.Assert(errors.Is(err, errInvalidProtocol), check.Equals, true)
+}
+
+func (s *Suite) TestNamedPortRule(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		Ports: Ports{"web": "80,443"},
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"100.64.0.1"},
+				Destinations: []string{"100.64.0.2:web"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.aclRules, check.DeepEquals, []tailcfg.FilterRule{
+		{
+			SrcIPs: []string{"100.64.0.1"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.2", Ports: tailcfg.PortRange{First: 80, Last: 80}},
+				{IP: "100.64.0.2", Ports: tailcfg.PortRange{First: 443, Last: 443}},
+			},
+			IPProto: []int{protocolICMP, protocolIPv6ICMP, protocolTCP, protocolUDP},
+		},
+	})
+
+	app.aclPolicy.ACLs[0].Destinations = []string{"100.64.0.2:wbe"}
+	err = app.UpdateACLRules()
+	var aclErr ACLError
+	c.Assert(errors.As(err, &aclErr), check.Equals, true)
+	c.Assert(aclErr.Field, check.Equals, "dst")
+	c.Assert(errors.Is(err, errInvalidPortFormat), check.Equals, true)
+}
+
+func (s *Suite) TestDenyAction(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Protocol:     "tcp",
+				Sources:      []string{"100.64.0.1", "100.64.0.2"},
+				Destinations: []string{"100.64.0.3:1000-2000"},
+			},
+			{
+				Action:       "deny",
+				Protocol:     "tcp",
+				Sources:      []string{"100.64.0.1"},
+				Destinations: []string{"100.64.0.3:1200-1300"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.aclRules, check.DeepEquals, []tailcfg.FilterRule{
+		{
+			SrcIPs: []string{"100.64.0.2"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.3", Ports: tailcfg.PortRange{First: 1000, Last: 2000}},
+			},
+			IPProto: []int{protocolTCP},
+		},
+		{
+			SrcIPs: []string{"100.64.0.1"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.3", Ports: tailcfg.PortRange{First: 1000, Last: 1199}},
+				{IP: "100.64.0.3", Ports: tailcfg.PortRange{First: 1301, Last: 2000}},
+			},
+			IPProto: []int{protocolTCP},
+		},
+	})
+	// both rules come from the accept ACL
+	c.Assert(app.aclRuleIndexes, check.DeepEquals, []int{0, 0})
+}
+
+func (s *Suite) TestAutogroupSelf(c *check.C) {
+	for index, name := range []string{"user1", "user1", "user2"} {
+		namespace, err := app.GetNamespace(name)
+		if err != nil {
+			namespace, err = app.CreateNamespace(name)
+			c.Assert(err, check.IsNil)
+		}
+
+		machine := Machine{
+			ID:             uint64(index + 1),
+			MachineKey:     fmt.Sprintf("foo%d", index),
+			NodeKey:        fmt.Sprintf("bar%d", index),
+			DiscoKey:       fmt.Sprintf("faa%d", index),
+			Hostname:       fmt.Sprintf("machine-%d", index),
+			NamespaceID:    namespace.ID,
+			IPAddresses:    MachineAddresses{netip.MustParseAddr(fmt.Sprintf("100.64.0.%d", index+1))},
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+		app.db.Save(&machine)
+	}
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"autogroup:members"},
+				Destinations: []string{"autogroup:self:22"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.aclRules, check.DeepEquals, []tailcfg.FilterRule{
+		{
+			SrcIPs: []string{"100.64.0.1", "100.64.0.2"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.1", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+				{IP: "100.64.0.2", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+			},
+			IPProto: []int{protocolICMP, protocolIPv6ICMP, protocolTCP, protocolUDP},
+		},
+		{
+			SrcIPs: []string{"100.64.0.3"},
+			DstPorts: []tailcfg.NetPortRange{
+				{IP: "100.64.0.3", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+			},
+			IPProto: []int{protocolICMP, protocolIPv6ICMP, protocolTCP, protocolUDP},
+		},
+	})
+	c.Assert(app.aclRuleIndexes, check.DeepEquals, []int{0, 0})
+}
+
+func (s *Suite) TestSSHRules(c *check.C) {
+	for index, name := range []string{"user1", "user1", "user2"} {
+		namespace, err := app.GetNamespace(name)
+		if err != nil {
+			namespace, err = app.CreateNamespace(name)
+			c.Assert(err, check.IsNil)
+		}
+
+		machine := Machine{
+			ID:             uint64(index + 1),
+			MachineKey:     fmt.Sprintf("foo%d", index),
+			NodeKey:        fmt.Sprintf("bar%d", index),
+			DiscoKey:       fmt.Sprintf("faa%d", index),
+			Hostname:       fmt.Sprintf("machine-%d", index),
+			NamespaceID:    namespace.ID,
+			IPAddresses:    MachineAddresses{netip.MustParseAddr(fmt.Sprintf("100.64.0.%d", index+1))},
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+		app.db.Save(&machine)
+	}
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"*:22"},
+			},
+		},
+		SSHs: []SSH{
+			{
+				Action:       "accept",
+				Sources:      []string{"user2"},
+				Destinations: []string{"user1"},
+				Users:        []string{"root"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"autogroup:members"},
+				Destinations: []string{"autogroup:self"},
+				Users:        []string{"autogroup:nonroot"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	accept := &tailcfg.SSHAction{
+		Accept:                   true,
+		AllowAgentForwarding:     true,
+		AllowLocalPortForwarding: true,
+	}
+
+	machine1, err := app.GetMachineByID(1)
+	c.Assert(err, check.IsNil)
+	sshPolicy, err := app.generateSSHPolicy(machine1)
+	c.Assert(err, check.IsNil)
+	c.Assert(sshPolicy.Rules, check.DeepEquals, []*tailcfg.SSHRule{
+		{
+			Principals: []*tailcfg.SSHPrincipal{{NodeIP: "100.64.0.3"}},
+			SSHUsers:   map[string]string{"root": "="},
+			Action:     accept,
+		},
+		{
+			Principals: []*tailcfg.SSHPrincipal{
+				{NodeIP: "100.64.0.1"},
+				{NodeIP: "100.64.0.2"},
+			},
+			SSHUsers: map[string]string{"*": "=", "root": ""},
+			Action:   accept,
+		},
+	})
+
+	machine3, err := app.GetMachineByID(3)
+	c.Assert(err, check.IsNil)
+	sshPolicy, err = app.generateSSHPolicy(machine3)
+	c.Assert(err, check.IsNil)
+	c.Assert(sshPolicy.Rules, check.DeepEquals, []*tailcfg.SSHRule{
+		{
+			Principals: []*tailcfg.SSHPrincipal{{NodeIP: "100.64.0.3"}},
+			SSHUsers:   map[string]string{"*": "=", "root": ""},
+			Action:     accept,
+		},
+	})
+
+	app.aclPolicy.SSHs[0].Action = "check"
+	err = app.UpdateACLRules()
+	var sshErr SSHRuleError
+	c.Assert(errors.As(err, &sshErr), check.Equals, true)
+	c.Assert(sshErr.Index, check.Equals, 0)
+	c.Assert(sshErr.Field, check.Equals, "action")
+	c.Assert(errors.Is(err, errSSHCheckNotSupported), check.Equals, true)
+
+	app.aclPolicy.SSHs[0].Action = "accept"
+	app.aclPolicy.SSHs[1].Users = nil
+	err = app.UpdateACLRules()
+	c.Assert(errors.As(err, &sshErr), check.Equals, true)
+	c.Assert(sshErr.Index, check.Equals, 1)
+	c.Assert(sshErr.Field, check.Equals, "users")
+	c.Assert(errors.Is(err, errInvalidSSHUser), check.Equals, true)
+}
+
+func Test_diffFilterRules(t *testing.T) {
+	ssh := tailcfg.FilterRule{
+		SrcIPs: []string{"100.64.0.1"},
+		DstPorts: []tailcfg.NetPortRange{
+			{IP: "100.64.0.2", Ports: tailcfg.PortRange{First: 22, Last: 22}},
+		},
+	}
+	web := tailcfg.FilterRule{
+		SrcIPs: []string{"*"},
+		DstPorts: []tailcfg.NetPortRange{
+			{IP: "100.64.0.3", Ports: tailcfg.PortRange{First: 80, Last: 80}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		previous    []tailcfg.FilterRule
+		current     []tailcfg.FilterRule
+		wantAdded   []tailcfg.FilterRule
+		wantRemoved []tailcfg.FilterRule
+	}{
+		{
+			name:        "unchanged",
+			previous:    []tailcfg.FilterRule{ssh, web},
+			current:     []tailcfg.FilterRule{web, ssh},
+			wantAdded:   []tailcfg.FilterRule{},
+			wantRemoved: []tailcfg.FilterRule{},
+		},
+		{
+			name:        "added and removed",
+			previous:    []tailcfg.FilterRule{ssh},
+			current:     []tailcfg.FilterRule{web},
+			wantAdded:   []tailcfg.FilterRule{web},
+			wantRemoved: []tailcfg.FilterRule{ssh},
+		},
+		{
+			name:        "duplicated rule removed",
+			previous:    []tailcfg.FilterRule{ssh, ssh},
+			current:     []tailcfg.FilterRule{ssh},
+			wantAdded:   []tailcfg.FilterRule{},
+			wantRemoved: []tailcfg.FilterRule{ssh},
+		},
+		{
+			name:        "first policy",
+			previous:    nil,
+			current:     []tailcfg.FilterRule{ssh},
+			wantAdded:   []tailcfg.FilterRule{ssh},
+			wantRemoved: []tailcfg.FilterRule{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			added, removed := diffFilterRules(test.previous, test.current)
+			if !reflect.DeepEqual(added, test.wantAdded) {
+				t.Errorf("diffFilterRules() added = %v, want %v", added, test.wantAdded)
+			}
+			if !reflect.DeepEqual(removed, test.wantRemoved) {
+				t.Errorf("diffFilterRules() removed = %v, want %v", removed, test.wantRemoved)
+			}
+		})
+	}
+}
+
+func Test_sshRuleUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		users   []string
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:  "named users",
+			users: []string{"root", "ubuntu"},
+			want:  map[string]string{"root": "=", "ubuntu": "="},
+		},
+		{
+			name:  "nonroot",
+			users: []string{"autogroup:nonroot"},
+			want:  map[string]string{"*": "=", "root": ""},
+		},
+		{
+			name:  "nonroot and root",
+			users: []string{"autogroup:nonroot", "root"},
+			want:  map[string]string{"*": "=", "root": "="},
+		},
+		{
+			name:    "no users",
+			users:   []string{},
+			wantErr: errInvalidSSHUser,
+		},
+		{
+			name:    "wildcard",
+			users:   []string{"*"},
+			wantErr: errInvalidSSHUser,
+		},
+		{
+			name:    "unknown autogroup",
+			users:   []string{"autogroup:admins"},
+			wantErr: errInvalidAutogroup,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := sshRuleUsers(test.users)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("sshRuleUsers() error = %v, wantErr %v", err, test.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("sshRuleUsers() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func (s *Suite) TestAutogroupSelfAsSource(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"autogroup:self"},
+				Destinations: []string{"*:*"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(errors.Is(err, errInvalidAutogroup), check.Equals, true)
+}
+
+func (s *Suite) TestAutogroupInternet(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"*"},
+				Destinations: []string{"autogroup:internet:*"},
+			},
+		},
+	}
+
+	rules, err := app.generateACLRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+
+	matches := func(address string) bool {
+		ip := netip.MustParseAddr(address)
+		for _, dst := range rules[0].DstPorts {
+			if netip.MustParsePrefix(dst.IP).Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// the peers of the tailnet are not reachable through the rule
+	for _, address := range []string{
+		"100.64.0.1",
+		"fd7a:115c:a1e0::1",
+		"10.1.2.3",
+		"172.16.0.1",
+		"192.168.1.1",
+		"169.254.1.1",
+		"fe80::1",
+	} {
+		c.Assert(matches(address), check.Equals, false, check.Commentf(address))
+	}
+
+	for _, address := range []string{"8.8.8.8", "2001:4860:4860::8888"} {
+		c.Assert(matches(address), check.Equals, true, check.Commentf(address))
+	}
+}
+
 func (s *Suite) TestInvalidGroupInGroup(c *check.C) {
 	// this ACL is wrong because the group in Sources sections doesn't exist
 	app.aclPolicy = &ACLPolicy{
@@ -114,7 +1147,7 @@ func (s *Suite) TestValidExpandTagOwnersInSources(c *check.C) {
 	namespace, err := app.CreateNamespace("user1")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("user1", "testmachine")
@@ -164,7 +1197,7 @@ func (s *Suite) TestValidExpandTagOwnersInDestinations(c *check.C) {
 	namespace, err := app.CreateNamespace("user1")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("user1", "testmachine")
@@ -214,7 +1247,7 @@ func (s *Suite) TestInvalidTagValidNamespace(c *check.C) {
 	namespace, err := app.CreateNamespace("user1")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("user1", "testmachine")
@@ -263,7 +1296,7 @@ func (s *Suite) TestValidTagInvalidNamespace(c *check.C) {
 	namespace, err := app.CreateNamespace("user1")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("user1", "webserver")
@@ -359,43 +1392,187 @@ func (s *Suite) TestProtocolParsing(c *check.C) {
 	c.Assert(rules[2].IPProto[1], check.Equals, protocolIPv6ICMP)
 }
 
-func (s *Suite) TestPortWildcard(c *check.C) {
-	err := app.LoadACLPolicy("./tests/acls/acl_policy_basic_wildcards.hujson")
-	c.Assert(err, check.IsNil)
+func (s *Suite) TestPortWildcard(c *check.C) {
+	err := app.LoadACLPolicy("./tests/acls/acl_policy_basic_wildcards.hujson")
+	c.Assert(err, check.IsNil)
+
+	rules, err := app.generateACLRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.NotNil)
+
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].DstPorts, check.HasLen, 1)
+	c.Assert(rules[0].DstPorts[0].Ports.First, check.Equals, uint16(0))
+	c.Assert(rules[0].DstPorts[0].Ports.Last, check.Equals, uint16(65535))
+	c.Assert(rules[0].SrcIPs, check.HasLen, 1)
+	c.Assert(rules[0].SrcIPs[0], check.Equals, "*")
+}
+
+func (s *Suite) TestCIDRSources(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"10.0.0.0/8", "fd7a:115c:a1e0::/48"},
+				Destinations: []string{"*:22"},
+			},
+		},
+	}
+
+	rules, err := app.generateACLRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].SrcIPs, check.DeepEquals, []string{"10.0.0.0/8", "fd7a:115c:a1e0::/48"})
+}
+
+func (s *Suite) TestPortWildcardYAML(c *check.C) {
+	err := app.LoadACLPolicy("./tests/acls/acl_policy_basic_wildcards.yaml")
+	c.Assert(err, check.IsNil)
+
+	rules, err := app.generateACLRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.NotNil)
+
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].DstPorts, check.HasLen, 1)
+	c.Assert(rules[0].DstPorts[0].Ports.First, check.Equals, uint16(0))
+	c.Assert(rules[0].DstPorts[0].Ports.Last, check.Equals, uint16(65535))
+	c.Assert(rules[0].SrcIPs, check.HasLen, 1)
+	c.Assert(rules[0].SrcIPs[0], check.Equals, "*")
+}
+
+func (s *Suite) TestYAMLPolicyMatchesHuJSON(c *check.C) {
+	for _, name := range []string{
+		"acl_policy_1",
+		"acl_policy_basic_wildcards",
+	} {
+		hujsonBytes, err := os.ReadFile("./tests/acls/" + name + ".hujson")
+		c.Assert(err, check.IsNil)
+		yamlBytes, err := os.ReadFile("./tests/acls/" + name + ".yaml")
+		c.Assert(err, check.IsNil)
+
+		fromHuJSON, err := parseACLPolicy(hujsonBytes, ".hujson")
+		c.Assert(err, check.IsNil)
+		fromYAML, err := parseACLPolicy(yamlBytes, ".yaml")
+		c.Assert(err, check.IsNil)
+
+		c.Assert(fromYAML, check.DeepEquals, fromHuJSON, check.Commentf(name))
+	}
+}
+
+func (s *Suite) TestYAMLPolicyGeneratesSameRules(c *check.C) {
+	err := app.LoadACLPolicy("./tests/acls/acl_policy_basic_wildcards.hujson")
+	c.Assert(err, check.IsNil)
+	fromHuJSON := app.aclRules
+
+	err = app.LoadACLPolicy("./tests/acls/acl_policy_basic_wildcards.yaml")
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.aclRules, check.DeepEquals, fromHuJSON)
+}
+
+func (s *Suite) TestParseACLPolicyDetectsFormat(c *check.C) {
+	hujsonBytes, err := os.ReadFile("./tests/acls/acl_policy_1.hujson")
+	c.Assert(err, check.IsNil)
+	yamlBytes, err := os.ReadFile("./tests/acls/acl_policy_1.yaml")
+	c.Assert(err, check.IsNil)
+
+	fromHuJSON, err := parseACLPolicy(hujsonBytes, "")
+	c.Assert(err, check.IsNil)
+	fromYAML, err := parseACLPolicy(yamlBytes, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(fromYAML, check.DeepEquals, fromHuJSON)
+
+	_, err = parseACLPolicy([]byte("{ \"acls\": ["), "")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *Suite) TestACLReloadDuringMapGeneration(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machines := make([]*Machine, 4)
+	for index := range machines {
+		machine, err := app.RegisterMachine(Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       fmt.Sprintf("testmachine%d", index),
+			GivenName:      fmt.Sprintf("testmachine%d", index),
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		})
+		c.Assert(err, check.IsNil)
+		machines[index] = machine
+	}
+
+	policies := []string{
+		"./tests/acls/acl_policy_basic_wildcards.hujson",
+		"./tests/acls/acl_policy_basic_1.hujson",
+	}
+
+	const generations = 20
+
+	errs := make(chan error, len(machines)*generations+1)
+	done := make(chan struct{})
+	reloaded := make(chan struct{})
+
+	go func() {
+		defer close(reloaded)
+
+		for index := 0; ; index++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if err := app.LoadACLPolicy(policies[index%len(policies)]); err != nil {
+				errs <- err
+
+				return
+			}
+			if err := app.UpdateACLRules(); err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
 
-	rules, err := app.generateACLRules()
-	c.Assert(err, check.IsNil)
-	c.Assert(rules, check.NotNil)
+	var wg sync.WaitGroup
+	for _, machine := range machines {
+		wg.Add(1)
 
-	c.Assert(rules, check.HasLen, 1)
-	c.Assert(rules[0].DstPorts, check.HasLen, 1)
-	c.Assert(rules[0].DstPorts[0].Ports.First, check.Equals, uint16(0))
-	c.Assert(rules[0].DstPorts[0].Ports.Last, check.Equals, uint16(65535))
-	c.Assert(rules[0].SrcIPs, check.HasLen, 1)
-	c.Assert(rules[0].SrcIPs[0], check.Equals, "*")
-}
+		go func(machine *Machine) {
+			defer wg.Done()
 
-func (s *Suite) TestPortWildcardYAML(c *check.C) {
-	err := app.LoadACLPolicy("./tests/acls/acl_policy_basic_wildcards.yaml")
-	c.Assert(err, check.IsNil)
+			mapRequest := tailcfg.MapRequest{
+				Hostinfo: &tailcfg.Hostinfo{Hostname: machine.Hostname},
+			}
+			for i := 0; i < generations; i++ {
+				if _, err := app.generateMapResponse(mapRequest, machine); err != nil {
+					errs <- err
+				}
+			}
+		}(machine)
+	}
 
-	rules, err := app.generateACLRules()
-	c.Assert(err, check.IsNil)
-	c.Assert(rules, check.NotNil)
+	wg.Wait()
+	close(done)
+	<-reloaded
+	close(errs)
 
-	c.Assert(rules, check.HasLen, 1)
-	c.Assert(rules[0].DstPorts, check.HasLen, 1)
-	c.Assert(rules[0].DstPorts[0].Ports.First, check.Equals, uint16(0))
-	c.Assert(rules[0].DstPorts[0].Ports.Last, check.Equals, uint16(65535))
-	c.Assert(rules[0].SrcIPs, check.HasLen, 1)
-	c.Assert(rules[0].SrcIPs[0], check.Equals, "*")
+	for err := range errs {
+		c.Assert(err, check.IsNil)
+	}
 }
 
 func (s *Suite) TestPortNamespace(c *check.C) {
 	namespace, err := app.CreateNamespace("testnamespace")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("testnamespace", "testmachine")
@@ -437,7 +1614,7 @@ func (s *Suite) TestPortGroup(c *check.C) {
 	namespace, err := app.CreateNamespace("testnamespace")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("testnamespace", "testmachine")
@@ -477,6 +1654,7 @@ func Test_expandGroup(t *testing.T) {
 	type args struct {
 		aclPolicy        ACLPolicy
 		group            string
+		namespaces       []string
 		stripEmailDomain bool
 	}
 	tests := []struct {
@@ -549,12 +1727,88 @@ func Test_expandGroup(t *testing.T) {
 			want:    []string{"joe.bar.gmail.com", "john.doe.yahoo.fr"},
 			wantErr: false,
 		},
+		{
+			name: "Expand pattern in group",
+			args: args{
+				aclPolicy: ACLPolicy{
+					Groups: Groups{
+						"group:teams": []string{"pattern:team-.*"},
+					},
+				},
+				group:            "group:teams",
+				namespaces:       []string{"team-a", "ops", "team-b", "myteam-c", "team-c"},
+				stripEmailDomain: true,
+			},
+			want:    []string{"team-a", "team-b", "team-c"},
+			wantErr: false,
+		},
+		{
+			name: "Pattern and literal members",
+			args: args{
+				aclPolicy: ACLPolicy{
+					Groups: Groups{
+						"group:teams": []string{"team-a", "pattern:team-(a|b)", "ops"},
+					},
+				},
+				group:            "group:teams",
+				namespaces:       []string{"team-a", "team-b", "team-c"},
+				stripEmailDomain: true,
+			},
+			want:    []string{"team-a", "team-b", "ops"},
+			wantErr: false,
+		},
+		{
+			name: "Pattern matching the whole name only",
+			args: args{
+				aclPolicy: ACLPolicy{
+					Groups: Groups{
+						"group:backtracking": []string{"pattern:(a+)+b"},
+					},
+				},
+				group:            "group:backtracking",
+				namespaces:       []string{strings.Repeat("a", 64), "aab-c"},
+				stripEmailDomain: true,
+			},
+			want:    []string{},
+			wantErr: false,
+		},
+		{
+			name: "Invalid pattern",
+			args: args{
+				aclPolicy: ACLPolicy{
+					Groups: Groups{
+						"group:teams": []string{"pattern:team-(a"},
+					},
+				},
+				group:            "group:teams",
+				namespaces:       []string{"team-a"},
+				stripEmailDomain: true,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "Pattern too long",
+			args: args{
+				aclPolicy: ACLPolicy{
+					Groups: Groups{
+						"group:teams": []string{"pattern:" + strings.Repeat("a", maxGroupPatternLength+1)},
+					},
+				},
+				group:            "group:teams",
+				namespaces:       []string{"team-a"},
+				stripEmailDomain: true,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			got, err := expandGroup(
 				test.args.aclPolicy,
 				test.args.group,
+				test.args.namespaces,
 				test.args.stripEmailDomain,
 			)
 			if (err != nil) != test.wantErr {
@@ -573,6 +1827,7 @@ func Test_expandTagOwners(t *testing.T) {
 	type args struct {
 		aclPolicy        ACLPolicy
 		tag              string
+		namespaces       []string
 		stripEmailDomain bool
 	}
 	tests := []struct {
@@ -619,6 +1874,20 @@ func Test_expandTagOwners(t *testing.T) {
 			want:    []string{"user1", "user2", "user3"},
 			wantErr: false,
 		},
+		{
+			name: "expand with pattern group",
+			args: args{
+				aclPolicy: ACLPolicy{
+					Groups:    Groups{"group:foo": []string{"pattern:user[12]"}},
+					TagOwners: TagOwners{"tag:test": []string{"group:foo"}},
+				},
+				tag:              "tag:test",
+				namespaces:       []string{"user1", "user2", "user3"},
+				stripEmailDomain: true,
+			},
+			want:    []string{"user1", "user2"},
+			wantErr: false,
+		},
 		{
 			name: "invalid tag",
 			args: args{
@@ -647,115 +1916,406 @@ func Test_expandTagOwners(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got, err := expandTagOwners(
-				test.args.aclPolicy,
-				test.args.tag,
-				test.args.stripEmailDomain,
-			)
-			if (err != nil) != test.wantErr {
-				t.Errorf("expandTagOwners() error = %v, wantErr %v", err, test.wantErr)
-
-				return
+			got, err := expandTagOwners(
+				test.args.aclPolicy,
+				test.args.tag,
+				test.args.namespaces,
+				test.args.stripEmailDomain,
+			)
+			if (err != nil) != test.wantErr {
+				t.Errorf("expandTagOwners() error = %v, wantErr %v", err, test.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expandTagOwners() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_expandPorts(t *testing.T) {
+	type args struct {
+		portsStr      string
+		needsWildcard bool
+		namedPorts    Ports
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *[]tailcfg.PortRange
+		wantErr bool
+	}{
+		{
+			name: "wildcard",
+			args: args{portsStr: "*", needsWildcard: true},
+			want: &[]tailcfg.PortRange{
+				{First: portRangeBegin, Last: portRangeEnd},
+			},
+			wantErr: false,
+		},
+		{
+			name: "needs wildcard but does not require it",
+			args: args{portsStr: "*", needsWildcard: false},
+			want: &[]tailcfg.PortRange{
+				{First: portRangeBegin, Last: portRangeEnd},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "needs wildcard but gets port",
+			args:    args{portsStr: "80,443", needsWildcard: true},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "two Destinations",
+			args: args{portsStr: "80,443", needsWildcard: false},
+			want: &[]tailcfg.PortRange{
+				{First: 80, Last: 80},
+				{First: 443, Last: 443},
+			},
+			wantErr: false,
+		},
+		{
+			name: "a range and a port",
+			args: args{portsStr: "80-1024,443", needsWildcard: false},
+			want: &[]tailcfg.PortRange{
+				{First: 80, Last: 1024},
+				{First: 443, Last: 443},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "out of bounds",
+			args:    args{portsStr: "854038", needsWildcard: false},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "wrong port",
+			args:    args{portsStr: "85a38", needsWildcard: false},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "wrong port in first",
+			args:    args{portsStr: "a-80", needsWildcard: false},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "wrong port in last",
+			args:    args{portsStr: "80-85a38", needsWildcard: false},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "wrong port format",
+			args:    args{portsStr: "80-85a38-3", needsWildcard: false},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "named port",
+			args: args{
+				portsStr:   "web,22",
+				namedPorts: Ports{"web": "80,443"},
+			},
+			want: &[]tailcfg.PortRange{
+				{First: 80, Last: 80},
+				{First: 443, Last: 443},
+				{First: 22, Last: 22},
+			},
+			wantErr: false,
+		},
+		{
+			name: "named port range",
+			args: args{
+				portsStr:   "dev",
+				namedPorts: Ports{"dev": "3000-3010"},
+			},
+			want: &[]tailcfg.PortRange{
+				{First: 3000, Last: 3010},
+			},
+			wantErr: false,
+		},
+		{
+			name: "undefined named port",
+			args: args{
+				portsStr:   "wbe",
+				namedPorts: Ports{"web": "80,443"},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := expandPorts(
+				test.args.portsStr,
+				test.args.needsWildcard,
+				test.args.namedPorts,
+			)
+			if (err != nil) != test.wantErr {
+				t.Errorf("expandPorts() error = %v, wantErr %v", err, test.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expandPorts() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_parseProtocol(t *testing.T) {
+	tests := []struct {
+		name              string
+		protocol          string
+		want              []int
+		wantNeedsWildcard bool
+		wantErr           error
+	}{
+		{
+			name:     "all protocols by default",
+			protocol: "",
+			want:     []int{protocolICMP, protocolIPv6ICMP, protocolTCP, protocolUDP},
+		},
+		{
+			name:     "tcp",
+			protocol: "tcp",
+			want:     []int{protocolTCP},
+		},
+		{
+			name:     "names are case insensitive",
+			protocol: "UDP",
+			want:     []int{protocolUDP},
+		},
+		{
+			name:              "icmp covers icmpv6",
+			protocol:          "icmp",
+			want:              []int{protocolICMP, protocolIPv6ICMP},
+			wantNeedsWildcard: true,
+		},
+		{
+			name:              "igmp",
+			protocol:          "igmp",
+			want:              []int{protocolIGMP},
+			wantNeedsWildcard: true,
+		},
+		{
+			name:     "number with ports",
+			protocol: "6",
+			want:     []int{protocolTCP},
+		},
+		{
+			name:              "number without ports",
+			protocol:          "47",
+			want:              []int{protocolGRE},
+			wantNeedsWildcard: true,
+		},
+		{
+			name:     "unknown name",
+			protocol: "quic",
+			wantErr:  errInvalidProtocol,
+		},
+		{
+			name:     "number out of range",
+			protocol: "256",
+			wantErr:  errInvalidProtocol,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, needsWildcard, err := parseProtocol(test.protocol)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("parseProtocol() error = %v, wantErr %v", err, test.wantErr)
 			}
 			if !reflect.DeepEqual(got, test.want) {
-				t.Errorf("expandTagOwners() = %v, want %v", got, test.want)
+				t.Errorf("parseProtocol() got = %v, want %v", got, test.want)
+			}
+			if needsWildcard != test.wantNeedsWildcard {
+				t.Errorf(
+					"parseProtocol() needsWildcard = %v, want %v",
+					needsWildcard,
+					test.wantNeedsWildcard,
+				)
 			}
 		})
 	}
 }
 
-func Test_expandPorts(t *testing.T) {
-	type args struct {
-		portsStr      string
-		needsWildcard bool
+func Test_subtractFilterRule(t *testing.T) {
+	allProtocols := []int{protocolICMP, protocolIPv6ICMP, protocolTCP, protocolUDP}
+	ports := func(first, last uint16) tailcfg.PortRange {
+		return tailcfg.PortRange{First: first, Last: last}
 	}
+
 	tests := []struct {
 		name    string
-		args    args
-		want    *[]tailcfg.PortRange
+		rule    tailcfg.FilterRule
+		deny    tailcfg.FilterRule
+		want    []tailcfg.FilterRule
 		wantErr bool
 	}{
 		{
-			name: "wildcard",
-			args: args{portsStr: "*", needsWildcard: true},
-			want: &[]tailcfg.PortRange{
-				{First: portRangeBegin, Last: portRangeEnd},
+			name: "deny on other protocols keeps the rule",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(22, 22)}},
+				IPProto:  []int{protocolTCP},
 			},
-			wantErr: false,
-		},
-		{
-			name: "needs wildcard but does not require it",
-			args: args{portsStr: "*", needsWildcard: false},
-			want: &[]tailcfg.PortRange{
-				{First: portRangeBegin, Last: portRangeEnd},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"*"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "*", Ports: tailcfg.PortRangeAny}},
+				IPProto:  []int{protocolUDP},
 			},
-			wantErr: false,
-		},
-		{
-			name:    "needs wildcard but gets port",
-			args:    args{portsStr: "80,443", needsWildcard: true},
-			want:    nil,
-			wantErr: true,
-		},
-		{
-			name: "two Destinations",
-			args: args{portsStr: "80,443", needsWildcard: false},
-			want: &[]tailcfg.PortRange{
-				{First: 80, Last: 80},
-				{First: 443, Last: 443},
+			want: []tailcfg.FilterRule{
+				{
+					SrcIPs:   []string{"100.64.0.1"},
+					DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(22, 22)}},
+					IPProto:  []int{protocolTCP},
+				},
 			},
-			wantErr: false,
 		},
 		{
-			name: "a range and a port",
-			args: args{portsStr: "80-1024,443", needsWildcard: false},
-			want: &[]tailcfg.PortRange{
-				{First: 80, Last: 1024},
-				{First: 443, Last: 443},
+			name: "deny on other ports keeps the rule",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(22, 22)}},
+				IPProto:  allProtocols,
+			},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"*"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(80, 443)}},
+				IPProto:  allProtocols,
+			},
+			want: []tailcfg.FilterRule{
+				{
+					SrcIPs:   []string{"100.64.0.1"},
+					DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(22, 22)}},
+					IPProto:  allProtocols,
+				},
 			},
-			wantErr: false,
 		},
 		{
-			name:    "out of bounds",
-			args:    args{portsStr: "854038", needsWildcard: false},
-			want:    nil,
-			wantErr: true,
+			name: "deny overlapping the start of the port range",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"*"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(5400, 5500)}},
+				IPProto:  allProtocols,
+			},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"*"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(5000, 5449)}},
+				IPProto:  allProtocols,
+			},
+			want: []tailcfg.FilterRule{
+				{
+					SrcIPs:   []string{"*"},
+					DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(5450, 5500)}},
+					IPProto:  allProtocols,
+				},
+			},
 		},
 		{
-			name:    "wrong port",
-			args:    args{portsStr: "85a38", needsWildcard: false},
-			want:    nil,
-			wantErr: true,
+			name: "deny inside the port range of a subnet",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "10.0.0.0/30", Ports: ports(1000, 2000)}},
+				IPProto:  []int{protocolTCP},
+			},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "10.0.0.1", Ports: ports(1500, 1600)}},
+				IPProto:  []int{protocolTCP},
+			},
+			want: []tailcfg.FilterRule{
+				{
+					SrcIPs: []string{"100.64.0.1"},
+					DstPorts: []tailcfg.NetPortRange{
+						{IP: "10.0.0.0", Ports: ports(1000, 2000)},
+						{IP: "10.0.0.2/31", Ports: ports(1000, 2000)},
+						{IP: "10.0.0.1", Ports: ports(1000, 1499)},
+						{IP: "10.0.0.1", Ports: ports(1601, 2000)},
+					},
+					IPProto: []int{protocolTCP},
+				},
+			},
 		},
 		{
-			name:    "wrong port in first",
-			args:    args{portsStr: "a-80", needsWildcard: false},
-			want:    nil,
-			wantErr: true,
+			name: "deny on some sources and protocols splits the rule",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1", "100.64.0.2"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.3", Ports: tailcfg.PortRangeAny}},
+				IPProto:  allProtocols,
+			},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.3", Ports: ports(22, 22)}},
+				IPProto:  []int{protocolTCP},
+			},
+			want: []tailcfg.FilterRule{
+				{
+					SrcIPs:   []string{"100.64.0.1", "100.64.0.2"},
+					DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.3", Ports: tailcfg.PortRangeAny}},
+					IPProto:  []int{protocolICMP, protocolIPv6ICMP, protocolUDP},
+				},
+				{
+					SrcIPs:   []string{"100.64.0.2"},
+					DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.3", Ports: tailcfg.PortRangeAny}},
+					IPProto:  []int{protocolTCP},
+				},
+				{
+					SrcIPs: []string{"100.64.0.1"},
+					DstPorts: []tailcfg.NetPortRange{
+						{IP: "100.64.0.3", Ports: ports(0, 21)},
+						{IP: "100.64.0.3", Ports: ports(23, 65535)},
+					},
+					IPProto: []int{protocolTCP},
+				},
+			},
 		},
 		{
-			name:    "wrong port in last",
-			args:    args{portsStr: "80-85a38", needsWildcard: false},
-			want:    nil,
-			wantErr: true,
+			name: "deny covering the whole rule removes it",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "100.64.0.2", Ports: ports(80, 80)}},
+				IPProto:  []int{protocolTCP},
+			},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"*"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "*", Ports: tailcfg.PortRangeAny}},
+				IPProto:  allProtocols,
+			},
+			want: []tailcfg.FilterRule{},
 		},
 		{
-			name:    "wrong port format",
-			args:    args{portsStr: "80-85a38-3", needsWildcard: false},
-			want:    nil,
+			name: "invalid address",
+			rule: tailcfg.FilterRule{
+				SrcIPs:   []string{"100.64.0.1"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "invalid", Ports: ports(80, 80)}},
+				IPProto:  []int{protocolTCP},
+			},
+			deny: tailcfg.FilterRule{
+				SrcIPs:   []string{"*"},
+				DstPorts: []tailcfg.NetPortRange{{IP: "*", Ports: tailcfg.PortRangeAny}},
+				IPProto:  []int{protocolTCP},
+			},
 			wantErr: true,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got, err := expandPorts(test.args.portsStr, test.args.needsWildcard)
+			got, err := subtractFilterRule(test.rule, test.deny)
 			if (err != nil) != test.wantErr {
-				t.Errorf("expandPorts() error = %v, wantErr %v", err, test.wantErr)
+				t.Errorf("subtractFilterRule() error = %v, wantErr %v", err, test.wantErr)
 
 				return
 			}
-			if !reflect.DeepEqual(got, test.want) {
-				t.Errorf("expandPorts() = %v, want %v", got, test.want)
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("subtractFilterRule() = %v, want %v", got, test.want)
 			}
 		})
 	}
@@ -980,6 +2540,128 @@ func Test_expandAlias(t *testing.T) {
 			want:    []string{"10.0.0.0/16"},
 			wantErr: false,
 		},
+		{
+			name: "simple IPv6 address",
+			args: args{
+				alias:            "fd7a:115c:a1e0::1",
+				machines:         []Machine{},
+				aclPolicy:        ACLPolicy{},
+				stripEmailDomain: true,
+			},
+			want:    []string{"fd7a:115c:a1e0::1"},
+			wantErr: false,
+		},
+		{
+			name: "IPv6 CIDR is kept verbatim",
+			args: args{
+				alias:            "fd7a:115c:a1e0:0::/48",
+				machines:         []Machine{},
+				aclPolicy:        ACLPolicy{},
+				stripEmailDomain: true,
+			},
+			want:    []string{"fd7a:115c:a1e0:0::/48"},
+			wantErr: false,
+		},
+		{
+			name: "IPv6 host",
+			args: args{
+				alias:    "ipv6-host",
+				machines: []Machine{},
+				aclPolicy: ACLPolicy{
+					Hosts: Hosts{
+						"ipv6-host": netip.MustParsePrefix("fd7a:115c:a1e0::2/128"),
+					},
+				},
+				stripEmailDomain: true,
+			},
+			want:    []string{"fd7a:115c:a1e0::2/128"},
+			wantErr: false,
+		},
+		{
+			name: "dual-stack namespace",
+			args: args{
+				alias: "joe",
+				machines: []Machine{
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.1"),
+							netip.MustParseAddr("fd7a:115c:a1e0::1"),
+						},
+						Namespace: Namespace{Name: "joe"},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("fd7a:115c:a1e0::2"),
+						},
+						Namespace: Namespace{Name: "joe"},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.3"),
+							netip.MustParseAddr("fd7a:115c:a1e0::3"),
+						},
+						Namespace: Namespace{Name: "marc"},
+					},
+				},
+				aclPolicy:        ACLPolicy{},
+				stripEmailDomain: true,
+			},
+			want:    []string{"100.64.0.1", "fd7a:115c:a1e0::1", "fd7a:115c:a1e0::2"},
+			wantErr: false,
+		},
+		{
+			name: "autogroup members",
+			args: args{
+				alias: "autogroup:members",
+				machines: []Machine{
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.1"),
+						},
+						Namespace: Namespace{Name: "joe"},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.2"),
+						},
+						Namespace:  Namespace{Name: "joe"},
+						ForcedTags: []string{"tag:server"},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.3"),
+						},
+						Namespace: Namespace{Name: "marc"},
+					},
+				},
+				aclPolicy:        ACLPolicy{},
+				stripEmailDomain: true,
+			},
+			want:    []string{"100.64.0.1", "100.64.0.3"},
+			wantErr: false,
+		},
+		{
+			name: "autogroup self",
+			args: args{
+				alias:            "autogroup:self",
+				machines:         []Machine{},
+				aclPolicy:        ACLPolicy{},
+				stripEmailDomain: true,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name: "unsupported autogroup",
+			args: args{
+				alias:            "autogroup:shared",
+				machines:         []Machine{},
+				aclPolicy:        ACLPolicy{},
+				stripEmailDomain: true,
+			},
+			want:    []string{},
+			wantErr: true,
+		},
 		{
 			name: "simple tag",
 			args: args{
@@ -1028,6 +2710,54 @@ func Test_expandAlias(t *testing.T) {
 			want:    []string{"100.64.0.1", "100.64.0.2"},
 			wantErr: false,
 		},
+		{
+			name: "namespace default tag",
+			args: args{
+				alias: "tag:hr-webserver",
+				machines: []Machine{
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.1"),
+						},
+						Namespace: Namespace{
+							Name:        "joe",
+							DefaultTags: StringList{"tag:hr-webserver"},
+						},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.2"),
+						},
+						Namespace: Namespace{
+							Name:        "joe",
+							DefaultTags: StringList{"tag:hr-webserver"},
+						},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.3"),
+						},
+						// marc does not own the tag
+						Namespace: Namespace{
+							Name:        "marc",
+							DefaultTags: StringList{"tag:hr-webserver"},
+						},
+					},
+					{
+						IPAddresses: MachineAddresses{
+							netip.MustParseAddr("100.64.0.4"),
+						},
+						Namespace: Namespace{Name: "mickael"},
+					},
+				},
+				aclPolicy: ACLPolicy{
+					TagOwners: TagOwners{"tag:hr-webserver": []string{"joe"}},
+				},
+				stripEmailDomain: true,
+			},
+			want:    []string{"100.64.0.1", "100.64.0.2"},
+			wantErr: false,
+		},
 		{
 			name: "No tag defined",
 			args: args{
@@ -1459,3 +3189,46 @@ func Test_excludeCorrectlyTaggedNodes(t *testing.T) {
 		})
 	}
 }
+
+func (s *Suite) TestACLPolicyInDatabase(c *check.C) {
+	policy := []byte(`{"acls": [{"action": "accept", "src": ["*"], "dst": ["*:22"]}]}`)
+
+	_, err := app.SetACLPolicy(policy)
+	c.Assert(err, check.Equals, errACLPolicyNotInDatabase)
+
+	app.cfg.ACL.PolicyMode = ACLPolicyModeDatabase
+
+	_, err = app.GetACLPolicy()
+	c.Assert(err, check.Equals, errACLPolicyNotSet)
+
+	// nothing is loaded until a policy is stored
+	c.Assert(app.LoadACLPolicyFromDB(), check.IsNil)
+	c.Assert(app.getACLPolicy(), check.IsNil)
+
+	_, err = app.SetACLPolicy([]byte(`{"acls": [{"action": "accept", "src": ["*"]}]`))
+	c.Assert(errors.Is(err, ErrInvalidACLPolicy), check.Equals, true)
+
+	_, err = app.SetACLPolicy(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.getACLRules(), check.HasLen, 1)
+
+	stored, err := app.GetACLPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(stored), check.Equals, string(policy))
+
+	loaded, err := app.loadDatabaseACLPolicy(false)
+	c.Assert(err, check.IsNil)
+	c.Assert(loaded, check.Equals, false)
+
+	// another headscale sharing the database updates the policy
+	err = app.setValue(aclPolicyKey, `{"acls": [
+		{"action": "accept", "src": ["*"], "dst": ["*:22"]},
+		{"action": "accept", "src": ["*"], "dst": ["*:443"]},
+	]}`)
+	c.Assert(err, check.IsNil)
+
+	loaded, err = app.loadDatabaseACLPolicy(false)
+	c.Assert(err, check.IsNil)
+	c.Assert(loaded, check.Equals, true)
+	c.Assert(app.getACLRules(), check.HasLen, 2)
+}
@@ -2,6 +2,7 @@ package headscale
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/netip"
 	"strings"
 
@@ -14,8 +15,20 @@ type ACLPolicy struct {
 	Groups    Groups    `json:"groups"    yaml:"groups"`
 	Hosts     Hosts     `json:"hosts"     yaml:"hosts"`
 	TagOwners TagOwners `json:"tagOwners" yaml:"tagOwners"`
+	Ports     Ports     `json:"ports"     yaml:"ports"`
 	ACLs      []ACL     `json:"acls"      yaml:"acls"`
+	SSHs      []SSH     `json:"ssh"       yaml:"ssh"`
 	Tests     []ACLTest `json:"tests"     yaml:"tests"`
+
+	AutoApprovers AutoApprovers `json:"autoApprovers" yaml:"autoApprovers"`
+}
+
+// AutoApprovers lists who can have advertised routes enabled without an
+// admin: Routes maps a prefix to the aliases allowed to advertise it or any
+// route within it, ExitNode the aliases allowed to be exit nodes.
+type AutoApprovers struct {
+	Routes   map[string][]string `json:"routes"   yaml:"routes"`
+	ExitNode []string            `json:"exitNode" yaml:"exitNode"`
 }
 
 // ACL is a basic rule for the ACL Policy.
@@ -26,6 +39,15 @@ type ACL struct {
 	Destinations []string `json:"dst"    yaml:"dst"`
 }
 
+// SSH controls who can connect with Tailscale SSH to which machines, and
+// as which local users.
+type SSH struct {
+	Action       string   `json:"action" yaml:"action"`
+	Sources      []string `json:"src"    yaml:"src"`
+	Destinations []string `json:"dst"    yaml:"dst"`
+	Users        []string `json:"users"  yaml:"users"`
+}
+
 // Groups references a series of alias in the ACL rules.
 type Groups map[string][]string
 
@@ -35,6 +57,9 @@ type Hosts map[string]netip.Prefix
 // TagOwners specify what users (namespaces?) are allow to use certain tags.
 type TagOwners map[string][]string
 
+// Ports are named lists of ports (e.g. "web": "80,443") usable in destinations.
+type Ports map[string]string
+
 // ACLTest is not implemented, but should be use to check if a certain rule is allowed.
 type ACLTest struct {
 	Source string   `json:"src"            yaml:"src"`
@@ -44,7 +69,6 @@ type ACLTest struct {
 
 // UnmarshalJSON allows to parse the Hosts directly into netip objects.
 func (hosts *Hosts) UnmarshalJSON(data []byte) error {
-	newHosts := Hosts{}
 	hostIPPrefixMap := make(map[string]string)
 	ast, err := hujson.Parse(data)
 	if err != nil {
@@ -56,13 +80,45 @@ func (hosts *Hosts) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
+
+	return hosts.fromStrings(hostIPPrefixMap)
+}
+
+// UnmarshalYAML allows to parse the Hosts directly into netip objects.
+func (hosts *Hosts) UnmarshalYAML(value *yaml.Node) error {
+	hostIPPrefixMap := make(map[string]string)
+
+	err := value.Decode(&hostIPPrefixMap)
+	if err != nil {
+		return err
+	}
+
+	return hosts.fromStrings(hostIPPrefixMap)
+}
+
+// fromStrings fills the Hosts from their textual form, so that both
+// policy formats accept the same values: an IP address is a single host,
+// a CIDR (e.g. 10.1.0.0/16) a whole network.
+func (hosts *Hosts) fromStrings(hostIPPrefixMap map[string]string) error {
+	newHosts := Hosts{}
 	for host, prefixStr := range hostIPPrefixMap {
 		if !strings.Contains(prefixStr, "/") {
-			prefixStr += "/32"
+			addr, err := netip.ParseAddr(prefixStr)
+			if err != nil {
+				return fmt.Errorf(
+					"%w: %s: %q is neither an IP address nor a CIDR",
+					errInvalidHost,
+					host,
+					prefixStr,
+				)
+			}
+			newHosts[host] = netip.PrefixFrom(addr, addr.BitLen())
+
+			continue
 		}
 		prefix, err := netip.ParsePrefix(prefixStr)
 		if err != nil {
-			return err
+			return fmt.Errorf("%w: %s: %s", errInvalidHost, host, err)
 		}
 		newHosts[host] = prefix
 	}
@@ -71,30 +127,37 @@ func (hosts *Hosts) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// UnmarshalYAML allows to parse the Hosts directly into netip objects.
-func (hosts *Hosts) UnmarshalYAML(data []byte) error {
-	newHosts := Hosts{}
-	hostIPPrefixMap := make(map[string]string)
+// IsZero reports whether nothing is auto approved.
+func (autoApprovers AutoApprovers) IsZero() bool {
+	return len(autoApprovers.Routes) == 0 && len(autoApprovers.ExitNode) == 0
+}
 
-	err := yaml.Unmarshal(data, &hostIPPrefixMap)
-	if err != nil {
-		return err
+// routeApprovers returns the aliases allowed to have the route enabled
+// automatically.
+func (autoApprovers AutoApprovers) routeApprovers(route netip.Prefix) ([]string, error) {
+	if route.Bits() == 0 {
+		return autoApprovers.ExitNode, nil
 	}
-	for host, prefixStr := range hostIPPrefixMap {
+
+	approvers := []string{}
+	for prefixStr, aliases := range autoApprovers.Routes {
 		prefix, err := netip.ParsePrefix(prefixStr)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if prefix.Bits() <= route.Bits() && prefix.Contains(route.Addr()) {
+			approvers = append(approvers, aliases...)
 		}
-		newHosts[host] = prefix
 	}
-	*hosts = newHosts
 
-	return nil
+	return approvers, nil
 }
 
 // IsZero is perhaps a bit naive here.
 func (policy ACLPolicy) IsZero() bool {
-	if len(policy.Groups) == 0 && len(policy.Hosts) == 0 && len(policy.ACLs) == 0 {
+	if len(policy.Groups) == 0 && len(policy.Hosts) == 0 && len(policy.ACLs) == 0 &&
+		len(policy.SSHs) == 0 && policy.AutoApprovers.IsZero() {
 		return true
 	}
 
@@ -61,6 +61,57 @@ func (h *Headscale) HealthHandler(
 	respond(nil)
 }
 
+// ReadinessHandler reports whether headscale can serve clients: the
+// database answers and, when OIDC is configured, every provider has been
+// initialised. Unlike /health, a failing check answers 503, so load
+// balancers stop sending traffic to the instance.
+// Listens in /readyz.
+func (h *Headscale) ReadinessHandler(
+	writer http.ResponseWriter,
+	req *http.Request,
+) {
+	checks := map[string]string{
+		"database": "pass",
+	}
+	status := "pass"
+
+	if err := h.pingDB(req.Context()); err != nil {
+		log.Error().Caller().Err(err).Msg("readiness check of the database failed")
+		checks["database"] = "fail"
+		status = "fail"
+	}
+
+	if len(h.cfg.OIDCProviders) > 0 {
+		checks["oidc"] = "pass"
+
+		if err := h.checkOIDCInitialized(); err != nil {
+			log.Error().Caller().Err(err).Msg("readiness check of OIDC failed")
+			checks["oidc"] = "fail"
+			status = "fail"
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/health+json; charset=utf-8")
+	if status != "pass" {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	buf, err := json.Marshal(struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}{
+		Status: status,
+		Checks: checks,
+	})
+	if err != nil {
+		log.Error().Caller().Err(err).Msg("marshal failed")
+	}
+	_, err = writer.Write(buf)
+	if err != nil {
+		log.Error().Caller().Err(err).Msg("write failed")
+	}
+}
+
 type registerWebAPITemplateConfig struct {
 	Key string
 }
@@ -13,7 +13,12 @@ func (h *Headscale) generateMapResponse(
 		Str("func", "generateMapResponse").
 		Str("machine", mapRequest.Hostinfo.Hostname).
 		Msg("Creating Map response")
-	node, err := machine.toNode(h.cfg.BaseDomain, h.cfg.DNSConfig, true)
+	node, err := machine.toNode(
+		h.cfg.BaseDomain,
+		h.cfg.DNSConfig,
+		true,
+		h.keepAliveInterval(),
+	)
 	if err != nil {
 		log.Error().
 			Caller().
@@ -24,20 +29,30 @@ func (h *Headscale) generateMapResponse(
 		return nil, err
 	}
 
-	peers, err := h.getValidPeers(machine)
-	if err != nil {
-		log.Error().
-			Caller().
-			Str("func", "generateMapResponse").
-			Err(err).
-			Msg("Cannot fetch peers")
+	// A client sending OmitPeers only wants its own node, the DERP map and
+	// the DNS config, so the peers are not even fetched.
+	peers := Machines{}
+	if !mapRequest.OmitPeers {
+		peers, err = h.getValidPeers(machine)
+		if err != nil {
+			log.Error().
+				Caller().
+				Str("func", "generateMapResponse").
+				Err(err).
+				Msg("Cannot fetch peers")
 
-		return nil, err
+			return nil, err
+		}
 	}
 
 	profiles := getMapResponseUserProfiles(*machine, peers)
 
-	nodePeers, err := peers.toNodes(h.cfg.BaseDomain, h.cfg.DNSConfig, true)
+	nodePeers, err := peers.toNodes(
+		h.cfg.BaseDomain,
+		h.cfg.DNSConfig,
+		true,
+		h.keepAliveInterval(),
+	)
 	if err != nil {
 		log.Error().
 			Caller().
@@ -54,6 +69,37 @@ func (h *Headscale) generateMapResponse(
 		*machine,
 		peers,
 	)
+	if dnsConfig != nil && dnsConfig.Proxied {
+		records, err := h.getMagicDNSRecords(*machine, peers)
+		if err != nil {
+			log.Error().
+				Caller().
+				Str("func", "generateMapResponse").
+				Err(err).
+				Msg("Failed to generate the MagicDNS records")
+
+			return nil, err
+		}
+		// dnsConfig is a copy of the global DNS config when MagicDNS is enabled
+		dnsConfig.ExtraRecords = append(dnsConfig.ExtraRecords, records...)
+	}
+
+	sshPolicy, err := h.generateSSHPolicy(machine)
+	if err != nil {
+		log.Error().
+			Caller().
+			Str("func", "generateMapResponse").
+			Err(err).
+			Msg("Failed to generate the SSH policy")
+
+		return nil, err
+	}
+	if sshPolicy != nil {
+		node.Capabilities = append(node.Capabilities, tailcfg.CapabilitySSH)
+		if len(sshPolicy.Rules) > 0 {
+			node.Capabilities = append(node.Capabilities, tailcfg.CapabilitySSHRuleIn)
+		}
+	}
 
 	resp := tailcfg.MapResponse{
 		KeepAlive:    false,
@@ -61,8 +107,9 @@ func (h *Headscale) generateMapResponse(
 		Peers:        nodePeers,
 		DNSConfig:    dnsConfig,
 		Domain:       h.cfg.BaseDomain,
-		PacketFilter: h.aclRules,
-		DERPMap:      h.DERPMap,
+		PacketFilter: h.getACLRules(),
+		SSHPolicy:    sshPolicy,
+		DERPMap:      h.getDERPMap(),
 		UserProfiles: profiles,
 		Debug: &tailcfg.Debug{
 			DisableLogTail:      !h.cfg.LogTail.Enabled,
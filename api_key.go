@@ -6,6 +6,7 @@ import (
 	"time"
 
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -15,8 +16,91 @@ const (
 	apiKeyLength    = 32
 
 	ErrAPIKeyFailedToParse = Error("Failed to parse ApiKey")
+	ErrAPIKeyInvalidScope  = Error("invalid API key scope")
+
+	// apiKeyLastSeenInterval throttles the updates of the last seen time of
+	// the API keys, so that the database is not written on every request.
+	apiKeyLastSeenInterval = time.Minute
+
+	apiKeyScopeRead  = "read"
+	apiKeyScopeWrite = "write"
 )
 
+// apiKeyScopeResources are the resources an API key can be scoped to.
+// A scope is written resource:action, where action is read or write,
+// write implying read.
+var apiKeyScopeResources = []string{
+	"machines",
+	"routes",
+	"namespaces",
+	"preauthkeys",
+	"apikeys",
+	"dns",
+	"acls",
+	"debug",
+}
+
+// apiKeyUnscopedMethods can be called by every API key, whatever its scopes.
+var apiKeyUnscopedMethods = []string{
+	"GetServerInfo",
+}
+
+// apiKeyMethodScopes maps the gRPC methods to the scope they require.
+// Creating and expiring API keys is left out on purpose: it requires a key
+// without scopes, so a scoped key cannot grant itself more access.
+// Methods missing from this map are denied to scoped keys.
+var apiKeyMethodScopes = map[string]string{
+	"GetNamespace":            "namespaces:read",
+	"ListNamespaces":          "namespaces:read",
+	"CreateNamespace":         "namespaces:write",
+	"RenameNamespace":         "namespaces:write",
+	"SetNamespaceTags":        "namespaces:write",
+	"SetNamespaceQuota":       "namespaces:write",
+	"SetNamespaceACLPolicy":   "acls:write",
+	"DeleteNamespace":         "namespaces:write",
+	"ListPreAuthKeys":         "preauthkeys:read",
+	"CreatePreAuthKey":        "preauthkeys:write",
+	"ExpirePreAuthKey":        "preauthkeys:write",
+	"GetMachine":              "machines:read",
+	"ListMachines":            "machines:read",
+	"WatchMachines":           "machines:read",
+	"GetDevice":               "machines:read",
+	"DebugCreateMachine":      "machines:write",
+	"SetTags":                 "machines:write",
+	"BulkSetNamespaceTags":    "machines:write",
+	"RegisterMachine":         "machines:write",
+	"DeleteMachine":           "machines:write",
+	"RestoreMachine":          "machines:write",
+	"ExpireMachine":           "machines:write",
+	"ExpireNamespaceMachines": "machines:write",
+	"ExtendMachineExpiry":     "machines:write",
+	"SetMachineExpiry":        "machines:write",
+	"RevokeMachineSession":    "machines:write",
+	"RenameMachine":           "machines:write",
+	"MoveMachine":             "machines:write",
+	"ReassignMachineIP":       "machines:write",
+	"RotateMachineKey":        "machines:write",
+	"DeleteDevice":            "machines:write",
+	"GetMachineRoute":         "routes:read",
+	"ListAvailableExitNodes":  "routes:read",
+	"ListMachinesByRoute":     "routes:read",
+	"GetDeviceRoutes":         "routes:read",
+	"EnableMachineRoutes":     "routes:write",
+	"EnableRoutesBatch":       "routes:write",
+	"EnableDeviceRoutes":      "routes:write",
+	"ListApiKeys":             "apikeys:read",
+	"GenerateDNSRecords":      "dns:read",
+	"CheckACLPolicy":          "acls:read",
+	"GetACLRules":             "acls:read",
+	"PreviewMachineRules":     "acls:read",
+	"GetACLPolicy":            "acls:read",
+	"SetACLPolicy":            "acls:write",
+	"DebugNotifierState":      "debug:read",
+	"GetDebugInfo":            "debug:read",
+	"DebugGetMapResponse":     "debug:read",
+	"ForceUpdate":             "debug:write",
+}
+
 // APIKey describes the datamodel for API keys used to remotely authenticate with
 // headscale.
 type APIKey struct {
@@ -27,12 +111,23 @@ type APIKey struct {
 	CreatedAt  *time.Time
 	Expiration *time.Time
 	LastSeen   *time.Time
+
+	// Scopes restricts the methods the key can call, a key without
+	// scopes has full access.
+	Scopes StringList
 }
 
 // CreateAPIKey creates a new ApiKey in a namespace, and returns it.
 func (h *Headscale) CreateAPIKey(
 	expiration *time.Time,
+	scopes []string,
 ) (string, *APIKey, error) {
+	for _, scope := range scopes {
+		if err := validateAPIKeyScope(scope); err != nil {
+			return "", nil, err
+		}
+	}
+
 	prefix, err := GenerateRandomStringURLSafe(apiPrefixLength)
 	if err != nil {
 		return "", nil, err
@@ -55,6 +150,7 @@ func (h *Headscale) CreateAPIKey(
 		Prefix:     prefix,
 		Hash:       hash,
 		Expiration: expiration,
+		Scopes:     scopes,
 	}
 
 	if err := h.db.Save(&key).Error; err != nil {
@@ -114,31 +210,103 @@ func (h *Headscale) ExpireAPIKey(key *APIKey) error {
 }
 
 func (h *Headscale) ValidateAPIKey(keyStr string) (bool, error) {
+	key, err := h.getValidAPIKey(keyStr)
+	if err != nil {
+		return false, err
+	}
+
+	return key != nil, nil
+}
+
+// getValidAPIKey returns the API key matching keyStr, or nil if
+// the key has expired.
+func (h *Headscale) getValidAPIKey(keyStr string) (*APIKey, error) {
 	prefix, hash, found := strings.Cut(keyStr, ".")
 	if !found {
-		return false, ErrAPIKeyFailedToParse
+		return nil, ErrAPIKeyFailedToParse
 	}
 
 	key, err := h.GetAPIKey(prefix)
 	if err != nil {
-		return false, fmt.Errorf("failed to validate api key: %w", err)
+		return nil, fmt.Errorf("failed to validate api key: %w", err)
 	}
 
 	if key.Expiration.Before(time.Now()) {
-		return false, nil
+		return nil, nil
 	}
 
 	if err := bcrypt.CompareHashAndPassword(key.Hash, []byte(hash)); err != nil {
-		return false, err
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// touchAPIKey records that a valid API key has been used.
+func (h *Headscale) touchAPIKey(key *APIKey) {
+	now := time.Now().UTC()
+	if key.Expiration != nil && key.Expiration.Before(now) {
+		return
+	}
+
+	if key.LastSeen != nil && now.Sub(*key.LastSeen) < apiKeyLastSeenInterval {
+		return
+	}
+
+	if err := h.db.Model(key).Update("last_seen", now).Error; err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Str("api_key", key.Prefix).
+			Msg("Failed to update the last seen time of the API key")
+
+		return
+	}
+	key.LastSeen = &now
+}
+
+func validateAPIKeyScope(scope string) error {
+	resource, action, found := strings.Cut(scope, ":")
+	if !found ||
+		!contains(apiKeyScopeResources, resource) ||
+		(action != apiKeyScopeRead && action != apiKeyScopeWrite) {
+		return fmt.Errorf(
+			"%w: %q, expected <resource>:<read|write> with resource one of %s",
+			ErrAPIKeyInvalidScope,
+			scope,
+			strings.Join(apiKeyScopeResources, ", "),
+		)
+	}
+
+	return nil
+}
+
+// allowsMethod reports whether the key can call the gRPC method fullMethod,
+// e.g. /headscale.v1.HeadscaleService/ListMachines.
+func (key *APIKey) allowsMethod(fullMethod string) bool {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if len(key.Scopes) == 0 || contains(apiKeyUnscopedMethods, method) {
+		return true
+	}
+
+	required, ok := apiKeyMethodScopes[method]
+	if !ok {
+		return false
+	}
+
+	resource, action, _ := strings.Cut(required, ":")
+	if contains(key.Scopes, resource+":"+apiKeyScopeWrite) {
+		return true
 	}
 
-	return true, nil
+	return action == apiKeyScopeRead && contains(key.Scopes, required)
 }
 
 func (key *APIKey) toProto() *v1.ApiKey {
 	protoKey := v1.ApiKey{
 		Id:     key.ID,
 		Prefix: key.Prefix,
+		Scopes: key.Scopes,
 	}
 
 	if key.Expiration != nil {
@@ -1,13 +1,16 @@
 package headscale
 
 import (
+	"errors"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gopkg.in/check.v1"
 )
 
 func (*Suite) TestCreateAPIKey(c *check.C) {
-	apiKeyStr, apiKey, err := app.CreateAPIKey(nil)
+	apiKeyStr, apiKey, err := app.CreateAPIKey(nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(apiKey, check.NotNil)
 
@@ -32,7 +35,7 @@ func (*Suite) TestAPIKeyDoesNotExist(c *check.C) {
 
 func (*Suite) TestValidateAPIKeyOk(c *check.C) {
 	nowPlus2 := time.Now().Add(2 * time.Hour)
-	apiKeyStr, apiKey, err := app.CreateAPIKey(&nowPlus2)
+	apiKeyStr, apiKey, err := app.CreateAPIKey(&nowPlus2, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(apiKey, check.NotNil)
 
@@ -43,7 +46,7 @@ func (*Suite) TestValidateAPIKeyOk(c *check.C) {
 
 func (*Suite) TestValidateAPIKeyNotOk(c *check.C) {
 	nowMinus2 := time.Now().Add(time.Duration(-2) * time.Hour)
-	apiKeyStr, apiKey, err := app.CreateAPIKey(&nowMinus2)
+	apiKeyStr, apiKey, err := app.CreateAPIKey(&nowMinus2, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(apiKey, check.NotNil)
 
@@ -52,7 +55,7 @@ func (*Suite) TestValidateAPIKeyNotOk(c *check.C) {
 	c.Assert(valid, check.Equals, false)
 
 	now := time.Now()
-	apiKeyStrNow, apiKey, err := app.CreateAPIKey(&now)
+	apiKeyStrNow, apiKey, err := app.CreateAPIKey(&now, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(apiKey, check.NotNil)
 
@@ -71,7 +74,7 @@ func (*Suite) TestValidateAPIKeyNotOk(c *check.C) {
 
 func (*Suite) TestExpireAPIKey(c *check.C) {
 	nowPlus2 := time.Now().Add(2 * time.Hour)
-	apiKeyStr, apiKey, err := app.CreateAPIKey(&nowPlus2)
+	apiKeyStr, apiKey, err := app.CreateAPIKey(&nowPlus2, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(apiKey, check.NotNil)
 
@@ -87,3 +90,74 @@ func (*Suite) TestExpireAPIKey(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(notValid, check.Equals, false)
 }
+
+func (*Suite) TestAPIKeyScopes(c *check.C) {
+	nowPlus2 := time.Now().Add(2 * time.Hour)
+
+	_, _, err := app.CreateAPIKey(&nowPlus2, []string{"machines:admin"})
+	c.Assert(errors.Is(err, ErrAPIKeyInvalidScope), check.Equals, true)
+
+	_, _, err = app.CreateAPIKey(&nowPlus2, []string{"unknown:read"})
+	c.Assert(errors.Is(err, ErrAPIKeyInvalidScope), check.Equals, true)
+
+	apiKeyStr, apiKey, err := app.CreateAPIKey(
+		&nowPlus2,
+		[]string{"machines:read", "routes:write"},
+	)
+	c.Assert(err, check.IsNil)
+
+	apiKey, err = app.getValidAPIKey(apiKeyStr)
+	c.Assert(err, check.IsNil)
+	c.Assert(apiKey, check.NotNil)
+	c.Assert(apiKey.Scopes, check.DeepEquals, StringList{"machines:read", "routes:write"})
+
+	const service = "/headscale.v1.HeadscaleService/"
+	c.Assert(apiKey.allowsMethod(service+"ListMachines"), check.Equals, true)
+	c.Assert(apiKey.allowsMethod(service+"DeleteMachine"), check.Equals, false)
+	c.Assert(apiKey.allowsMethod(service+"GetMachineRoute"), check.Equals, true)
+	c.Assert(apiKey.allowsMethod(service+"EnableMachineRoutes"), check.Equals, true)
+	c.Assert(apiKey.allowsMethod(service+"ListNamespaces"), check.Equals, false)
+	c.Assert(apiKey.allowsMethod(service+"CreateApiKey"), check.Equals, false)
+	c.Assert(apiKey.allowsMethod(service+"UnknownMethod"), check.Equals, false)
+	c.Assert(apiKey.allowsMethod(service+"GetServerInfo"), check.Equals, true)
+
+	err = checkAPIKeyScopes(apiKey, service+"DeleteMachine")
+	c.Assert(status.Code(err), check.Equals, codes.PermissionDenied)
+
+	_, fullAccessKey, err := app.CreateAPIKey(&nowPlus2, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(fullAccessKey.allowsMethod(service+"CreateApiKey"), check.Equals, true)
+	c.Assert(checkAPIKeyScopes(fullAccessKey, service+"DeleteMachine"), check.IsNil)
+}
+
+func (*Suite) TestTouchAPIKey(c *check.C) {
+	nowPlus2 := time.Now().Add(2 * time.Hour)
+	_, apiKey, err := app.CreateAPIKey(&nowPlus2, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(apiKey.LastSeen, check.IsNil)
+
+	app.touchAPIKey(apiKey)
+	c.Assert(apiKey.LastSeen, check.NotNil)
+	lastSeen := *apiKey.LastSeen
+
+	// a second use within the interval does not write to the database
+	app.touchAPIKey(apiKey)
+	c.Assert(apiKey.LastSeen.Equal(lastSeen), check.Equals, true)
+
+	stored, err := app.GetAPIKey(apiKey.Prefix)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.LastSeen, check.NotNil)
+	c.Assert(stored.LastSeen.Equal(lastSeen), check.Equals, true)
+	c.Assert(stored.toProto().GetLastSeen(), check.NotNil)
+
+	nowMinus2 := time.Now().Add(-2 * time.Hour)
+	_, expiredKey, err := app.CreateAPIKey(&nowMinus2, nil)
+	c.Assert(err, check.IsNil)
+
+	app.touchAPIKey(expiredKey)
+	c.Assert(expiredKey.LastSeen, check.IsNil)
+
+	stored, err = app.GetAPIKey(expiredKey.Prefix)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.LastSeen, check.IsNil)
+}
@@ -2,6 +2,7 @@ package headscale
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -16,7 +17,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gorilla/mux"
 	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -29,7 +29,6 @@ import (
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
-	"golang.org/x/oauth2"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -67,7 +66,14 @@ const (
 	privateKeyFileMode  = 0o600
 
 	registerCacheExpiration = time.Minute * 15
-	registerCacheCleanup    = time.Minute * 20
+	registerCacheCleanup    = time.Minute
+
+	// A pending registration must leave the user enough time to log in,
+	// but not linger in memory for days.
+	minRegistrationCacheExpiration = time.Minute
+	maxRegistrationCacheExpiration = 24 * time.Hour
+
+	defaultRegistrationCacheMaxEntries = 10000
 
 	DisabledClientAuth = "disabled"
 	RelaxedClientAuth  = "relaxed"
@@ -86,18 +92,81 @@ type Headscale struct {
 
 	noiseMux *mux.Router
 
-	DERPMap    *tailcfg.DERPMap
-	DERPServer *DERPServer
-
+	// derpMapMutex guards DERPMap and its sources, as the DERP map is
+	// rebuilt when one of them is updated.
+	derpMapMutex   sync.RWMutex
+	DERPMap        *tailcfg.DERPMap
+	sourcesDERPMap *tailcfg.DERPMap
+	customDERPMap  *tailcfg.DERPMap
+	DERPServer     *DERPServer
+
+	// aclMutex guards aclPolicy, aclMergedPolicy, aclRules and
+	// aclRuleIndexes, which are swapped together on reload while poll
+	// goroutines generate map responses.
+	aclMutex  sync.RWMutex
 	aclPolicy *ACLPolicy
-	aclRules  []tailcfg.FilterRule
+	// aclMergedPolicy is aclPolicy with the ACLs of the namespace policies
+	// appended, the policy aclRules are compiled from.
+	aclMergedPolicy *ACLPolicy
+	aclRules        []tailcfg.FilterRule
+	// aclRuleIndexes holds, for each of aclRules, the index in
+	// aclMergedPolicy of the ACL it was generated from, as deny ACLs can
+	// split an ACL into several rules.
+	aclRuleIndexes []int
+	// aclCompileMutex serialises the compilations of the rules along with
+	// their swap, so that rules compiled against an older snapshot of the
+	// machines never replace newer ones.
+	aclCompileMutex sync.Mutex
+	// namespaceACLMutex guards namespaceACLPolicies, the policies of the
+	// namespaces as checked against namespaceACLGlobalPolicy, so they are
+	// not read and checked again every time the rules are compiled.
+	namespaceACLMutex        sync.Mutex
+	namespaceACLGlobalPolicy *ACLPolicy
+	namespaceACLPolicies     []namespaceACLPolicy
+	// databaseACLPolicyMutex serialises the loads of the ACL policy stored
+	// in the database, and guards databaseACLPolicy, the last one loaded.
+	databaseACLPolicyMutex sync.Mutex
+	databaseACLPolicy      string
 
 	lastStateChange *xsync.MapOf[time.Time]
 
-	oidcProvider *oidc.Provider
-	oauth2Config *oauth2.Config
+	// clientsUpdateChannels holds the update channel of every client with
+	// an open long-poll stream, indexed by machine key.
+	clientsUpdateChannels *xsync.MapOf[chan struct{}]
+	// clientsUpdateChannelsMutex serialises the registrations of the update
+	// channels, so a stream only ever unregisters its own channel.
+	clientsUpdateChannelsMutex sync.Mutex
+	// forcedUpdates holds the machine keys of the clients whose next update
+	// request must be answered with a map, even if they look up to date.
+	forcedUpdates *xsync.MapOf[struct{}]
+	// mapResponseHashes holds the hash of the last map response sent on the
+	// stream of every connected client, by machine key, so that identical
+	// maps are not sent again.
+	mapResponseHashes *xsync.MapOf[[sha256.Size]byte]
+	// ephemeralDeletions holds the pending deletions of the ephemeral
+	// machines which disconnected, by machine key, cancelled when they
+	// reconnect within ephemeral_node_disconnect_grace_period.
+	ephemeralDeletionsMutex sync.Mutex
+	ephemeralDeletions      map[string]*time.Timer
+	// stateChangeChan wakes up the notifier when the state changes.
+	stateChangeChan chan struct{}
+	// machineEvents fans out the machine events to the WatchMachines streams.
+	machineEvents machineEventBroker
+	// webhook delivers the machine events to webhook.url, nil without one.
+	webhook *webhookNotifier
+	// accessLogger writes the access log, when log.access_log is enabled.
+	accessLogger zl.Logger
+
+	// oidcClients holds the configured OIDC providers by name.
+	oidcClients map[string]*oidcClient
 
 	registrationCache *cache.Cache
+	// registrationCacheMutex serialises the insertions in registrationCache,
+	// so that it never grows past registration_cache.max_entries.
+	registrationCacheMutex sync.Mutex
+	// oidcRegisterLimiters holds the rate limiter of /oidc/register of every
+	// client IP seen recently.
+	oidcRegisterLimiters *cache.Cache
 
 	ipAllocationMutex sync.Mutex
 
@@ -169,10 +238,7 @@ func NewHeadscale(cfg *Config) (*Headscale, error) {
 		return nil, errUnsupportedDatabase
 	}
 
-	registrationCache := cache.New(
-		registerCacheExpiration,
-		registerCacheCleanup,
-	)
+	registrationCache := newRegistrationCache(cfg.RegistrationCache)
 
 	app := Headscale{
 		cfg:                cfg,
@@ -183,6 +249,18 @@ func NewHeadscale(cfg *Config) (*Headscale, error) {
 		aclRules:           tailcfg.FilterAllowAll, // default allowall
 		registrationCache:  registrationCache,
 		pollNetMapStreamWG: sync.WaitGroup{},
+
+		lastStateChange:       xsync.NewMapOf[time.Time](),
+		clientsUpdateChannels: xsync.NewMapOf[chan struct{}](),
+		forcedUpdates:         xsync.NewMapOf[struct{}](),
+		mapResponseHashes:     xsync.NewMapOf[[sha256.Size]byte](),
+		stateChangeChan:       make(chan struct{}, 1),
+		oidcRegisterLimiters:  cache.New(oidcRegisterLimiterExpiration, registerCacheCleanup),
+		accessLogger:          newAccessLogger(),
+	}
+
+	if cfg.Webhook.URL != "" {
+		app.webhook = newWebhookNotifier(cfg.Webhook)
 	}
 
 	err = app.initDB()
@@ -190,7 +268,13 @@ func NewHeadscale(cfg *Config) (*Headscale, error) {
 		return nil, err
 	}
 
-	if cfg.OIDC.Issuer != "" {
+	if err := app.restoreLastStateChange(); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Could not restore the last state change, all machines will get a full update")
+	}
+
+	if len(cfg.OIDCProviders) > 0 {
 		err = app.initOIDC()
 		if err != nil {
 			return nil, err
@@ -234,6 +318,15 @@ func (h *Headscale) expireEphemeralNodes(milliSeconds int64) {
 	}
 }
 
+// purgeDeletedMachines hard deletes the soft deleted machines once their
+// h.cfg.MachineDeletionGracePeriod is over.
+func (h *Headscale) purgeDeletedMachines(milliSeconds int64) {
+	ticker := time.NewTicker(time.Duration(milliSeconds) * time.Millisecond)
+	for range ticker.C {
+		h.purgeDeletedMachinesWorker()
+	}
+}
+
 func (h *Headscale) expireEphemeralNodesWorker() {
 	namespaces, err := h.ListNamespaces()
 	if err != nil {
@@ -264,12 +357,14 @@ func (h *Headscale) expireEphemeralNodesWorker() {
 					Str("machine", machine.Hostname).
 					Msg("Ephemeral client removed from database")
 
-				err = h.db.Unscoped().Delete(machine).Error
+				err = h.deleteEphemeralMachine(machine)
 				if err != nil {
 					log.Error().
 						Err(err).
 						Str("machine", machine.Hostname).
 						Msg("🤮 Cannot delete ephemeral machine from the database")
+
+					continue
 				}
 			}
 		}
@@ -280,11 +375,129 @@ func (h *Headscale) expireEphemeralNodesWorker() {
 	}
 }
 
+// deleteEphemeralMachine removes the ephemeral machine from the database,
+// skipping the deletion grace period as it would not come back.
+func (h *Headscale) deleteEphemeralMachine(machine Machine) error {
+	if err := h.db.Unscoped().Delete(&machine).Error; err != nil {
+		return err
+	}
+
+	h.publishMachineEvent(v1.MachineEventType_MACHINE_EVENT_TYPE_DELETED, machine)
+
+	return nil
+}
+
+// isEphemeralMachine reports whether the machine was registered with an
+// ephemeral pre auth key.
+func (h *Headscale) isEphemeralMachine(machine *Machine) bool {
+	if machine.AuthKey != nil {
+		return machine.AuthKey.Ephemeral
+	}
+	if machine.AuthKeyID == 0 {
+		return false
+	}
+
+	var pak PreAuthKey
+	if err := h.db.First(&pak, machine.AuthKeyID).Error; err != nil {
+		return false
+	}
+
+	return pak.Ephemeral
+}
+
+// scheduleEphemeralMachineDeletion deletes the ephemeral machine, which
+// closed its stream, once h.cfg.EphemeralNodeDisconnectGracePeriod is over,
+// unless it reconnects in the meantime.
+func (h *Headscale) scheduleEphemeralMachineDeletion(machine Machine) {
+	if h.cfg.EphemeralNodeDisconnectGracePeriod <= 0 {
+		return
+	}
+
+	h.ephemeralDeletionsMutex.Lock()
+	defer h.ephemeralDeletionsMutex.Unlock()
+
+	if h.ephemeralDeletions == nil {
+		h.ephemeralDeletions = make(map[string]*time.Timer)
+	}
+	if timer, ok := h.ephemeralDeletions[machine.MachineKey]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(h.cfg.EphemeralNodeDisconnectGracePeriod, func() {
+		h.ephemeralDeletionsMutex.Lock()
+		// cancelled or scheduled again since
+		if h.ephemeralDeletions[machine.MachineKey] != timer {
+			h.ephemeralDeletionsMutex.Unlock()
+
+			return
+		}
+		delete(h.ephemeralDeletions, machine.MachineKey)
+		h.ephemeralDeletionsMutex.Unlock()
+
+		if _, connected := h.clientsUpdateChannels.Load(machine.MachineKey); connected {
+			return
+		}
+
+		if err := h.deleteEphemeralMachine(machine); err != nil {
+			log.Error().
+				Err(err).
+				Str("machine", machine.Hostname).
+				Msg("Cannot delete disconnected ephemeral machine from the database")
+
+			return
+		}
+
+		log.Info().
+			Str("machine", machine.Hostname).
+			Msg("Disconnected ephemeral client removed from database")
+
+		h.setLastStateChangeToNow()
+	})
+	h.ephemeralDeletions[machine.MachineKey] = timer
+}
+
+// cancelEphemeralMachineDeletion cancels the pending deletion of the
+// ephemeral machine, as it reconnected. It reports whether one was pending.
+func (h *Headscale) cancelEphemeralMachineDeletion(machineKey string) bool {
+	h.ephemeralDeletionsMutex.Lock()
+	defer h.ephemeralDeletionsMutex.Unlock()
+
+	timer, ok := h.ephemeralDeletions[machineKey]
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	delete(h.ephemeralDeletions, machineKey)
+
+	return true
+}
+
 func (h *Headscale) grpcAuthenticationInterceptor(ctx context.Context,
 	req interface{},
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
+	if err := h.authenticateGRPCRequest(ctx, info.FullMethod); err != nil {
+		return ctx, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (h *Headscale) grpcStreamAuthenticationInterceptor(srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := h.authenticateGRPCRequest(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
+
+func (h *Headscale) authenticateGRPCRequest(ctx context.Context, fullMethod string) error {
 	// Check if the request is coming from the on-server client.
 	// This is not secure, but it is to maintain maintainability
 	// with the "legacy" database-based client
@@ -304,7 +517,7 @@ func (h *Headscale) grpcAuthenticationInterceptor(ctx context.Context,
 			Str("client_address", client.Addr.String()).
 			Msg("Retrieving metadata is failed")
 
-		return ctx, status.Errorf(
+		return status.Errorf(
 			codes.InvalidArgument,
 			"Retrieving metadata is failed",
 		)
@@ -317,7 +530,7 @@ func (h *Headscale) grpcAuthenticationInterceptor(ctx context.Context,
 			Str("client_address", client.Addr.String()).
 			Msg("Authorization token is not supplied")
 
-		return ctx, status.Errorf(
+		return status.Errorf(
 			codes.Unauthenticated,
 			"Authorization token is not supplied",
 		)
@@ -331,13 +544,13 @@ func (h *Headscale) grpcAuthenticationInterceptor(ctx context.Context,
 			Str("client_address", client.Addr.String()).
 			Msg(`missing "Bearer " prefix in "Authorization" header`)
 
-		return ctx, status.Error(
+		return status.Error(
 			codes.Unauthenticated,
 			`missing "Bearer " prefix in "Authorization" header`,
 		)
 	}
 
-	valid, err := h.ValidateAPIKey(strings.TrimPrefix(token, AuthPrefix))
+	apiKey, err := h.getValidAPIKey(strings.TrimPrefix(token, AuthPrefix))
 	if err != nil {
 		log.Error().
 			Caller().
@@ -345,20 +558,92 @@ func (h *Headscale) grpcAuthenticationInterceptor(ctx context.Context,
 			Str("client_address", client.Addr.String()).
 			Msg("failed to validate token")
 
-		return ctx, status.Error(codes.Internal, "failed to validate token")
+		return status.Error(codes.Internal, "failed to validate token")
 	}
 
-	if !valid {
+	if apiKey == nil {
 		log.Info().
 			Str("client_address", client.Addr.String()).
 			Msg("invalid token")
 
-		return ctx, status.Error(codes.Unauthenticated, "invalid token")
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	h.touchAPIKey(apiKey)
+
+	return checkAPIKeyScopes(apiKey, fullMethod)
+}
+
+func (h *Headscale) grpcScopeInterceptor(ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if err := h.authorizeGRPCSocketRequest(ctx, info.FullMethod); err != nil {
+		return nil, err
 	}
 
 	return handler(ctx, req)
 }
 
+func (h *Headscale) grpcStreamScopeInterceptor(srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := h.authorizeGRPCSocketRequest(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
+
+// authorizeGRPCSocketRequest enforces the API key scopes on the unix socket.
+// The socket itself is not authenticated, but grpc-gateway forwards the
+// "Authorization" header of the HTTP requests, which has already been
+// validated by httpAuthenticationMiddleware.
+func (h *Headscale) authorizeGRPCSocketRequest(ctx context.Context, fullMethod string) error {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	authHeader, ok := meta["authorization"]
+	if !ok || !strings.HasPrefix(authHeader[0], AuthPrefix) {
+		return nil
+	}
+
+	prefix, _, _ := strings.Cut(strings.TrimPrefix(authHeader[0], AuthPrefix), ".")
+	apiKey, err := h.GetAPIKey(prefix)
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("failed to get API key")
+
+		return status.Error(codes.Internal, "failed to validate token")
+	}
+
+	return checkAPIKeyScopes(apiKey, fullMethod)
+}
+
+func checkAPIKeyScopes(apiKey *APIKey, fullMethod string) error {
+	if apiKey.allowsMethod(fullMethod) {
+		return nil
+	}
+
+	log.Info().
+		Str("api_key", apiKey.Prefix).
+		Str("method", fullMethod).
+		Msg("API key is not allowed to call method")
+
+	return status.Errorf(
+		codes.PermissionDenied,
+		"API key is not allowed to call %s",
+		fullMethod,
+	)
+}
+
 func (h *Headscale) httpAuthenticationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(
 		writer http.ResponseWriter,
@@ -388,7 +673,7 @@ func (h *Headscale) httpAuthenticationMiddleware(next http.Handler) http.Handler
 			return
 		}
 
-		valid, err := h.ValidateAPIKey(strings.TrimPrefix(authHeader, AuthPrefix))
+		apiKey, err := h.getValidAPIKey(strings.TrimPrefix(authHeader, AuthPrefix))
 		if err != nil {
 			log.Error().
 				Caller().
@@ -408,7 +693,7 @@ func (h *Headscale) httpAuthenticationMiddleware(next http.Handler) http.Handler
 			return
 		}
 
-		if !valid {
+		if apiKey == nil {
 			log.Info().
 				Str("client_address", req.RemoteAddr).
 				Msg("invalid token")
@@ -425,6 +710,8 @@ func (h *Headscale) httpAuthenticationMiddleware(next http.Handler) http.Handler
 			return
 		}
 
+		h.touchAPIKey(apiKey)
+
 		next.ServeHTTP(writer, req)
 	})
 }
@@ -446,12 +733,19 @@ func (h *Headscale) createRouter(grpcMux *runtime.ServeMux) *mux.Router {
 	router.HandleFunc(ts2021UpgradePath, h.NoiseUpgradeHandler).Methods(http.MethodPost)
 
 	router.HandleFunc("/health", h.HealthHandler).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", h.ReadinessHandler).Methods(http.MethodGet)
 	router.HandleFunc("/key", h.KeyHandler).Methods(http.MethodGet)
 	router.HandleFunc("/register/{nkey}", h.RegisterWebAPI).Methods(http.MethodGet)
-	router.HandleFunc("/machine/{mkey}/map", h.PollNetMapHandler).Methods(http.MethodPost)
+	router.HandleFunc("/machine/{mkey}/map", h.withAccessLog("PollNetMap", h.PollNetMapHandler)).
+		Methods(http.MethodPost)
 	router.HandleFunc("/machine/{mkey}", h.RegistrationHandler).Methods(http.MethodPost)
-	router.HandleFunc("/oidc/register/{nkey}", h.RegisterOIDC).Methods(http.MethodGet)
-	router.HandleFunc("/oidc/callback", h.OIDCCallback).Methods(http.MethodGet)
+	router.HandleFunc("/oidc/register/{nkey}", h.withAccessLog("RegisterOIDC", h.RegisterOIDC)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/oidc/register/{nkey}/{provider}", h.withAccessLog("RegisterOIDC", h.RegisterOIDC)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/oidc/callback", h.withAccessLog("OIDCCallback", h.OIDCCallback)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/oidc/confirm", h.OIDCConfirm).Methods(http.MethodPost)
 	router.HandleFunc("/apple", h.AppleConfigMessage).Methods(http.MethodGet)
 	router.HandleFunc("/apple/{platform}", h.ApplePlatformConfig).Methods(http.MethodGet)
 	router.HandleFunc("/windows", h.WindowsConfigMessage).Methods(http.MethodGet)
@@ -483,23 +777,69 @@ func (h *Headscale) createNoiseMux() *mux.Router {
 	return router
 }
 
+// enabledFeatures lists the optional features turned on in the
+// configuration, for GetServerInfo.
+func (h *Headscale) enabledFeatures() []string {
+	features := []string{}
+	if h.cfg.DNSConfig != nil && h.cfg.DNSConfig.Proxied {
+		features = append(features, "magic_dns")
+	}
+
+	h.aclMutex.RLock()
+	if h.aclPolicy != nil {
+		features = append(features, "acl_policy")
+	}
+	h.aclMutex.RUnlock()
+
+	if h.cfg.MachineDeletionGracePeriod > 0 {
+		features = append(features, "machine_restore")
+	}
+	if h.cfg.Webhook.URL != "" {
+		features = append(features, "webhook")
+	}
+	if h.cfg.Log.AccessLog {
+		features = append(features, "access_log")
+	}
+	if h.cfg.LogTail.Enabled {
+		features = append(features, "logtail")
+	}
+	if h.cfg.RandomizeClientPort {
+		features = append(features, "randomize_client_port")
+	}
+
+	return features
+}
+
 // Serve launches a GIN server with the Headscale API.
 func (h *Headscale) Serve() error {
 	var err error
 
-	// Fetch an initial DERP Map before we start serving
-	h.DERPMap = GetDERPMap(h.cfg.DERP)
-
 	if h.cfg.DERP.ServerEnabled {
 		// When embedded DERP is enabled we always need a STUN server
 		if h.cfg.DERP.STUNAddr == "" {
 			return errSTUNAddressNotSet
 		}
 
-		h.DERPMap.Regions[h.DERPServer.region.RegionID] = &h.DERPServer.region
 		go h.ServeSTUN()
 	}
 
+	if h.cfg.DERP.CustomMapPath != "" {
+		customDERPMap, err := loadCustomDERPMap(h.cfg.DERP.CustomMapPath)
+		if err != nil {
+			return fmt.Errorf("failed to load the custom DERP map: %w", err)
+		}
+		h.setCustomDERPMap(customDERPMap)
+
+		watcher, err := h.watchCustomDERPMap()
+		if err != nil {
+			return fmt.Errorf("failed to watch the custom DERP map: %w", err)
+		}
+		defer watcher.Close()
+	}
+
+	// Fetch an initial DERP Map before we start serving
+	h.setSourcesDERPMap(GetDERPMap(h.cfg.DERP))
+
 	if h.cfg.DERP.AutoUpdate {
 		derpMapCancelChannel := make(chan struct{})
 		defer func() { derpMapCancelChannel <- struct{}{} }()
@@ -508,6 +848,24 @@ func (h *Headscale) Serve() error {
 
 	go h.expireEphemeralNodes(updateInterval)
 
+	if h.cfg.MachineDeletionGracePeriod > 0 {
+		go h.purgeDeletedMachines(updateInterval)
+	}
+
+	if h.cfg.ACL.PolicyMode == ACLPolicyModeDatabase {
+		go h.watchDatabaseACLPolicy(updateInterval)
+	}
+
+	notifierCancelChannel := make(chan struct{})
+	defer close(notifierCancelChannel)
+	go h.runNotifier(notifierCancelChannel)
+
+	// the machines sharing an address keep working, only one of them is
+	// reachable at this address
+	if err := h.CheckDuplicateMachineIPs(); err != nil {
+		log.Error().Err(err).Msg("The IP addresses of the machines are not consistent")
+	}
+
 	if zl.GlobalLevel() == zl.TraceLevel {
 		zerolog.RespLog = true
 	} else {
@@ -521,6 +879,10 @@ func (h *Headscale) Serve() error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	if h.webhook != nil {
+		go h.webhook.run(ctx)
+	}
+
 	//
 	//
 	// Set up LOCAL listeners
@@ -562,8 +924,17 @@ func (h *Headscale) Serve() error {
 		return err
 	}
 
-	// Start the local gRPC server without TLS and without authentication
-	grpcSocket := grpc.NewServer(zerolog.UnaryInterceptor())
+	// Start the local gRPC server without TLS and without authentication,
+	// only the scopes of the API keys forwarded by grpc-gateway are checked
+	grpcSocket := grpc.NewServer(
+		grpc.UnaryInterceptor(
+			grpcMiddleware.ChainUnaryServer(
+				h.grpcScopeInterceptor,
+				zerolog.NewUnaryServerInterceptor(),
+			),
+		),
+		grpc.StreamInterceptor(h.grpcStreamScopeInterceptor),
+	)
 
 	v1.RegisterHeadscaleServiceServer(grpcSocket, newHeadscaleV1APIServer(h))
 	reflection.Register(grpcSocket)
@@ -606,6 +977,7 @@ func (h *Headscale) Serve() error {
 					zerolog.NewUnaryServerInterceptor(),
 				),
 			),
+			grpc.StreamInterceptor(h.grpcStreamAuthenticationInterceptor),
 		}
 
 		if tlsConfig != nil {
@@ -702,8 +1074,7 @@ func (h *Headscale) Serve() error {
 		syscall.SIGHUP,
 		syscall.SIGINT,
 		syscall.SIGTERM,
-		syscall.SIGQUIT,
-		syscall.SIGHUP)
+		syscall.SIGQUIT)
 	sigFunc := func(c chan os.Signal) {
 		// Wait for a SIGINT or SIGKILL:
 		for {
@@ -716,17 +1087,10 @@ func (h *Headscale) Serve() error {
 
 				// TODO(kradalby): Reload config on SIGHUP
 
-				if h.cfg.ACL.PolicyPath != "" {
-					aclPath := AbsolutePathFromConfigPath(h.cfg.ACL.PolicyPath)
-					err := h.LoadACLPolicy(aclPath)
-					if err != nil {
-						log.Error().Err(err).Msg("Failed to reload ACL policy")
-					}
-					log.Info().
-						Str("path", aclPath).
-						Msg("ACL policy successfully reloaded, notifying nodes of change")
-
-					h.setLastStateChangeToNow()
+				if err := h.reloadACLPolicy(); err != nil {
+					log.Error().
+						Err(err).
+						Msg("Failed to reload ACL policy, keeping the current one")
 				}
 
 			default:
@@ -734,6 +1098,11 @@ func (h *Headscale) Serve() error {
 					Str("signal", sig.String()).
 					Msg("Received signal to stop, shutting down gracefully")
 
+				log.Info().
+					Int("streams", h.clientsUpdateChannels.Size()).
+					Dur("drain_period", h.cfg.PollShutdownDrainPeriod).
+					Msg("Draining the long-poll streams")
+
 				close(h.shutdownChan)
 				h.pollNetMapStreamWG.Wait()
 
@@ -763,6 +1132,8 @@ func (h *Headscale) Serve() error {
 				// Stop listening (and unlink the socket if unix type):
 				socketListener.Close()
 
+				h.persistLastStateChange(time.Time{})
+
 				// Close db connections
 				db, err := h.db.DB()
 				if err != nil {
@@ -869,6 +1240,60 @@ func (h *Headscale) getTLSSettings() (*tls.Config, error) {
 	}
 }
 
+// newRegistrationCache creates the cache holding in-flight registrations.
+// The cleanup interval makes the cache actively purge expired registrations,
+// instead of only discarding them when they are read.
+func newRegistrationCache(cfg RegistrationCacheConfig) *cache.Cache {
+	registrationCache := cache.New(cfg.Expiration, cfg.CleanupInterval)
+	registrationCache.OnEvicted(func(string, interface{}) {
+		registrationCacheEntries.Set(float64(registrationCache.ItemCount()))
+	})
+
+	return registrationCache
+}
+
+// setRegistrationCache stores an in-flight registration, which expires
+// after the configured registration_cache.expiration. A full cache evicts
+// its oldest registration to make room, as the registrations are only used
+// once, the oldest one is also the least recently used.
+func (h *Headscale) setRegistrationCache(key string, value interface{}) {
+	h.registrationCacheMutex.Lock()
+	defer h.registrationCacheMutex.Unlock()
+
+	maxEntries := h.cfg.RegistrationCache.MaxEntries
+	if _, exists := h.registrationCache.Get(key); !exists && maxEntries > 0 &&
+		h.registrationCache.ItemCount() >= maxEntries {
+		h.evictOldestRegistration()
+	}
+
+	h.registrationCache.Set(key, value, cache.DefaultExpiration)
+	registrationCacheEntries.Set(float64(h.registrationCache.ItemCount()))
+}
+
+// evictOldestRegistration removes the registration closest to its
+// expiration, or the expired ones if there are any left.
+func (h *Headscale) evictOldestRegistration() {
+	oldestKey := ""
+	var oldestExpiration int64
+	for key, item := range h.registrationCache.Items() {
+		if oldestKey == "" || item.Expiration < oldestExpiration {
+			oldestKey = key
+			oldestExpiration = item.Expiration
+		}
+	}
+
+	if oldestKey == "" {
+		h.registrationCache.DeleteExpired()
+
+		return
+	}
+
+	log.Debug().
+		Str("key", oldestKey).
+		Msg("Registration cache is full, evicting the oldest registration")
+	h.registrationCache.Delete(oldestKey)
+}
+
 func (h *Headscale) setLastStateChangeToNow() {
 	var err error
 
@@ -889,6 +1314,41 @@ func (h *Headscale) setLastStateChangeToNow() {
 		}
 		h.lastStateChange.Store(namespace, now)
 	}
+
+	// persisted by the notifier, see persistLastStateChange
+	h.notifyStateChange()
+}
+
+// restoreLastStateChange sets the last state change of every namespace to
+// the one persisted before the restart, so that the machines which got
+// the last state before the restart are not seen as outdated.
+func (h *Headscale) restoreLastStateChange() error {
+	value, err := h.getValue(lastStateChangeKey)
+	if errors.Is(err, errValueNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lastChange, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return fmt.Errorf("failed to parse the persisted last state change: %w", err)
+	}
+
+	namespaces, err := h.ListNamespacesStr()
+	if err != nil {
+		return err
+	}
+
+	if h.lastStateChange == nil {
+		h.lastStateChange = xsync.NewMapOf[time.Time]()
+	}
+	for _, namespace := range namespaces {
+		h.lastStateChange.Store(namespace, lastChange)
+	}
+
+	return nil
 }
 
 func (h *Headscale) getLastStateChange(namespaces ...string) time.Time {
@@ -1,10 +1,17 @@
 package headscale
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"net/netip"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/puzpuzpuz/xsync"
 	"gopkg.in/check.v1"
 )
 
@@ -48,6 +55,10 @@ func (s *Suite) ResetDB(c *check.C) {
 		cfg:      &cfg,
 		dbType:   "sqlite3",
 		dbString: tmpDir + "/headscale_test.db",
+
+		clientsUpdateChannels: xsync.NewMapOf[chan struct{}](),
+		forcedUpdates:         xsync.NewMapOf[struct{}](),
+		mapResponseHashes:     xsync.NewMapOf[[sha256.Size]byte](),
 	}
 	err = app.initDB()
 	if err != nil {
@@ -76,3 +87,93 @@ func (s *Suite) TestAuthModes(c *check.C) {
 		c.Assert(isValid, check.Equals, true)
 	}
 }
+
+func (s *Suite) TestRegistrationCacheIsActivelyPurged(c *check.C) {
+	// the entry is expired as soon as it is stored, the purge the cleanup
+	// interval schedules is run by hand rather than waiting for it
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      time.Nanosecond,
+		CleanupInterval: time.Hour,
+	})
+
+	app.setRegistrationCache("foo", "bar")
+	c.Assert(app.registrationCache.ItemCount(), check.Equals, 1)
+	c.Assert(testutil.ToFloat64(registrationCacheEntries), check.Equals, float64(1))
+
+	app.registrationCache.DeleteExpired()
+
+	// The entry is gone without anyone reading it.
+	c.Assert(app.registrationCache.ItemCount(), check.Equals, 0)
+	c.Assert(testutil.ToFloat64(registrationCacheEntries), check.Equals, float64(0))
+}
+
+func (s *Suite) TestRegistrationCacheEvictsOldestEntry(c *check.C) {
+	app.cfg.RegistrationCache = RegistrationCacheConfig{
+		Expiration:      time.Minute,
+		CleanupInterval: time.Minute,
+		MaxEntries:      2,
+	}
+	app.registrationCache = newRegistrationCache(app.cfg.RegistrationCache)
+
+	for _, key := range []string{"first", "second"} {
+		app.setRegistrationCache(key, key)
+		time.Sleep(time.Millisecond)
+	}
+
+	// replacing an entry does not evict another one
+	app.setRegistrationCache("second", "again")
+	c.Assert(app.registrationCache.ItemCount(), check.Equals, 2)
+
+	app.setRegistrationCache("third", "third")
+	c.Assert(app.registrationCache.ItemCount(), check.Equals, 2)
+
+	_, found := app.registrationCache.Get("first")
+	c.Assert(found, check.Equals, false)
+	_, found = app.registrationCache.Get("third")
+	c.Assert(found, check.Equals, true)
+}
+
+func (s *Suite) TestReadinessHandler(c *check.C) {
+	type readiness struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	ready := func() (int, readiness) {
+		recorder := httptest.NewRecorder()
+		app.ReadinessHandler(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var res readiness
+		c.Assert(json.NewDecoder(recorder.Body).Decode(&res), check.IsNil)
+
+		return recorder.Code, res
+	}
+
+	code, res := ready()
+	c.Assert(code, check.Equals, http.StatusOK)
+	c.Assert(res, check.DeepEquals, readiness{
+		Status: "pass",
+		Checks: map[string]string{"database": "pass"},
+	})
+
+	// a configured provider without a client is not ready
+	app.cfg.OIDCProviders = map[string]OIDCConfig{DefaultOIDCProvider: {}}
+	code, res = ready()
+	c.Assert(code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(res, check.DeepEquals, readiness{
+		Status: "fail",
+		Checks: map[string]string{"database": "pass", "oidc": "fail"},
+	})
+	app.cfg.OIDCProviders = nil
+
+	db, err := app.db.DB()
+	c.Assert(err, check.IsNil)
+	c.Assert(db.Close(), check.IsNil)
+
+	code, res = ready()
+	c.Assert(code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(res, check.DeepEquals, readiness{
+		Status: "fail",
+		Checks: map[string]string{"database": "fail"},
+	})
+}
@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxPort = 65535
+)
+
+func init() {
+	rootCmd.AddCommand(aclsCmd)
+	aclsCmd.AddCommand(checkACLPolicyCmd)
+	aclsCmd.AddCommand(listACLRulesCmd)
+	aclsCmd.AddCommand(getACLPolicyCmd)
+	aclsCmd.AddCommand(setACLPolicyCmd)
+
+	previewMachineRulesCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err := previewMachineRulesCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	aclsCmd.AddCommand(previewMachineRulesCmd)
+}
+
+var aclsCmd = &cobra.Command{
+	Use:     "acls",
+	Short:   "Manage the ACL policy of Headscale",
+	Aliases: []string{"acl", "policy"},
+}
+
+var checkACLPolicyCmd = &cobra.Command{
+	Use:   "check FILE",
+	Short: "Checks an ACL policy against the current machines, without applying it",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		policy, err := os.ReadFile(args[0])
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot read ACL policy: %s", err),
+				output,
+			)
+			os.Exit(1)
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.CheckACLPolicyRequest{Policy: policy}
+
+		response, err := client.CheckACLPolicy(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot check ACL policy: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+			os.Exit(1)
+		}
+
+		if !response.GetValid() {
+			policyError := response.GetError()
+			message := fmt.Sprintf("Invalid ACL policy: %s", policyError.GetMessage())
+			if policyError.GetAclIndex() >= 0 {
+				message = fmt.Sprintf(
+					"Invalid ACL policy: ACL %d, %s: %s",
+					policyError.GetAclIndex(),
+					policyError.GetField(),
+					policyError.GetMessage(),
+				)
+			}
+			SuccessOutput(response, message, output)
+			os.Exit(1)
+		}
+
+		message := fmt.Sprintf("ACL policy is valid, %d rules", response.GetRuleCount())
+		for _, warning := range response.GetWarnings() {
+			message += fmt.Sprintf("\nWarning: %s", warning)
+		}
+		SuccessOutput(response, message, output)
+	},
+}
+
+var getACLPolicyCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Prints the current ACL policy",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.GetACLPolicyRequest{}
+
+		response, err := client.GetACLPolicy(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot get ACL policy: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+			os.Exit(1)
+		}
+
+		SuccessOutput(response, string(response.GetPolicy()), output)
+	},
+}
+
+var setACLPolicyCmd = &cobra.Command{
+	Use:   "set FILE",
+	Short: "Replaces the ACL policy stored in the database (acl_policy_mode: database)",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		policy, err := os.ReadFile(args[0])
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot read ACL policy: %s", err),
+				output,
+			)
+			os.Exit(1)
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.SetACLPolicyRequest{Policy: policy}
+
+		response, err := client.SetACLPolicy(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot set ACL policy: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+			os.Exit(1)
+		}
+
+		message := "ACL policy updated"
+		for _, warning := range response.GetWarnings() {
+			message += fmt.Sprintf("\nWarning: %s", warning)
+		}
+		SuccessOutput(response, message, output)
+	},
+}
+
+var listACLRulesCmd = &cobra.Command{
+	Use:     "rules",
+	Short:   "List the filter rules generated from the current ACL policy",
+	Aliases: []string{"ls", "list"},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.GetACLRulesRequest{}
+
+		response, err := client.GetACLRules(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot get ACL rules: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response.GetRules(), "", output)
+
+			return
+		}
+
+		tableData := aclRulesToPtables(response.GetRules())
+		err = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+var previewMachineRulesCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "List the filter rules having a given node among their sources",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		machineID, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error getting machine id from flag: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.PreviewMachineRulesRequest{
+			MachineId: machineID,
+		}
+
+		response, err := client.PreviewMachineRules(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot preview the ACL rules of the machine: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response, "", output)
+
+			return
+		}
+
+		fmt.Printf("Addresses: %s\n", strings.Join(response.GetIpAddresses(), ", "))
+
+		tableData := aclRulesToPtables(response.GetRules())
+		err = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+// aclRulesToPtables converts the filter rules to a table, with the ACL
+// each one comes from.
+func aclRulesToPtables(rules []*v1.ACLRule) pterm.TableData {
+	tableData := pterm.TableData{
+		{"ACL", "Sources", "Destinations", "Protocols"},
+	}
+	for _, rule := range rules {
+		acl := "-"
+		if rule.GetAclIndex() >= 0 {
+			acl = strconv.Itoa(int(rule.GetAclIndex()))
+		}
+
+		dests := make([]string, len(rule.GetDstPorts()))
+		for index, dest := range rule.GetDstPorts() {
+			dests[index] = formatACLDestination(dest)
+		}
+
+		protocols := make([]string, len(rule.GetIpProto()))
+		for index, protocol := range rule.GetIpProto() {
+			protocols[index] = strconv.Itoa(int(protocol))
+		}
+
+		tableData = append(tableData, []string{
+			acl,
+			strings.Join(rule.GetSrcIps(), ", "),
+			strings.Join(dests, ", "),
+			strings.Join(protocols, ", "),
+		})
+	}
+
+	return tableData
+}
+
+func formatACLDestination(dest *v1.ACLDestination) string {
+	switch {
+	case dest.GetFirstPort() == 0 && dest.GetLastPort() == maxPort:
+		return fmt.Sprintf("%s:*", dest.GetIp())
+	case dest.GetFirstPort() == dest.GetLastPort():
+		return fmt.Sprintf("%s:%d", dest.GetIp(), dest.GetFirstPort())
+	default:
+		return fmt.Sprintf("%s:%d-%d", dest.GetIp(), dest.GetFirstPort(), dest.GetLastPort())
+	}
+}
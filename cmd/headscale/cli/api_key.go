@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/juanfont/headscale"
@@ -25,6 +26,8 @@ func init() {
 
 	createAPIKeyCmd.Flags().
 		StringP("expiration", "e", DefaultAPIKeyExpiry, "Human-readable expiration of the key (e.g. 30m, 24h)")
+	createAPIKeyCmd.Flags().
+		StringSliceP("scopes", "s", []string{}, "Scopes restricting the key (e.g. machines:read,routes:write), full access if empty")
 
 	apiKeysCmd.AddCommand(createAPIKeyCmd)
 
@@ -73,7 +76,7 @@ var listAPIKeys = &cobra.Command{
 		}
 
 		tableData := pterm.TableData{
-			{"ID", "Prefix", "Expiration", "Created"},
+			{"ID", "Prefix", "Expiration", "Created", "Last seen", "Scopes"},
 		}
 		for _, key := range response.ApiKeys {
 			expiration := "-"
@@ -82,11 +85,18 @@ var listAPIKeys = &cobra.Command{
 				expiration = ColourTime(key.Expiration.AsTime())
 			}
 
+			lastSeen := "-"
+			if key.GetLastSeen() != nil {
+				lastSeen = key.GetLastSeen().AsTime().Format(HeadscaleDateTimeFormat)
+			}
+
 			tableData = append(tableData, []string{
 				strconv.FormatUint(key.GetId(), headscale.Base10),
 				key.GetPrefix(),
 				expiration,
 				key.GetCreatedAt().AsTime().Format(HeadscaleDateTimeFormat),
+				lastSeen,
+				strings.Join(key.GetScopes(), ","),
 			})
 
 		}
@@ -140,6 +150,18 @@ If you loose a key, create a new one and revoke (expire) the old one.`,
 
 		request.Expiration = timestamppb.New(expiration)
 
+		scopes, err := cmd.Flags().GetStringSlice("scopes")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error retrieving list of scopes, %v", err),
+				output,
+			)
+
+			return
+		}
+		request.Scopes = scopes
+
 		ctx, client, conn, cancel := getHeadscaleCLIClient()
 		defer cancel()
 		defer conn.Close()
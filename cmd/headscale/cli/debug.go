@@ -2,8 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/juanfont/headscale"
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/pterm/pterm"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc/status"
@@ -41,6 +45,24 @@ func init() {
 		StringSliceP("route", "r", []string{}, "List (or repeated flags) of routes to advertise")
 
 	debugCmd.AddCommand(createNodeCmd)
+
+	debugCmd.AddCommand(notifierStateCmd)
+
+	debugCmd.AddCommand(debugInfoCmd)
+
+	mapResponseCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = mapResponseCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	debugCmd.AddCommand(mapResponseCmd)
+
+	forceUpdateCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = forceUpdateCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	debugCmd.AddCommand(forceUpdateCmd)
 }
 
 var debugCmd = &cobra.Command{
@@ -130,3 +152,278 @@ var createNodeCmd = &cobra.Command{
 		SuccessOutput(response.Machine, "Machine created", output)
 	},
 }
+
+var notifierStateCmd = &cobra.Command{
+	Use:   "notifier",
+	Short: "Show the state of the update notifications sent to connected nodes",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.DebugNotifierStateRequest{}
+
+		response, err := client.DebugNotifierState(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot get notifier state: %s", status.Convert(err).Message()),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response, "", output)
+
+			return
+		}
+
+		//nolint
+		fmt.Printf(
+			"Subscribed clients: %d\nLast state change: %s\n\n",
+			response.GetSubscribedClients(),
+			response.GetLastStateChange().AsTime().Format(HeadscaleDateTimeFormat),
+		)
+
+		err = pterm.DefaultTable.WithHasHeader().
+			WithData(updateLagTable(response.GetClients())).
+			Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+var debugInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the last state change and how far behind it every node is",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.GetDebugInfoRequest{}
+
+		response, err := client.GetDebugInfo(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot get debug info: %s", status.Convert(err).Message()),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response, "", output)
+
+			return
+		}
+
+		//nolint
+		fmt.Printf(
+			"Update channels: %d\nLast state change: %s\n\n",
+			response.GetUpdateChannels(),
+			response.GetLastStateChange().AsTime().Format(HeadscaleDateTimeFormat),
+		)
+
+		err = pterm.DefaultTable.WithHasHeader().
+			WithData(updateLagTable(response.GetMachines())).
+			Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+func updateLagTable(clients []*v1.DebugNotifierClient) pterm.TableData {
+	tableData := pterm.TableData{
+		{"ID", "Name", "Namespace", "Last successful update", "Lag", "Outdated"},
+	}
+	for _, client := range clients {
+		lastUpdate := "-"
+		if client.GetLastSuccessfulUpdate() != nil {
+			lastUpdate = client.GetLastSuccessfulUpdate().
+				AsTime().
+				Format(HeadscaleDateTimeFormat)
+		}
+
+		lag := "-"
+		if client.GetUpdateLag() != nil {
+			lag = client.GetUpdateLag().AsDuration().String()
+		}
+
+		tableData = append(tableData, []string{
+			strconv.FormatUint(client.GetMachineId(), headscale.Base10),
+			client.GetName(),
+			client.GetNamespace(),
+			lastUpdate,
+			lag,
+			strconv.FormatBool(client.GetOutdated()),
+		})
+	}
+
+	return tableData
+}
+
+var mapResponseCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Show the map a node would receive, and why each of its peers is in it",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.DebugGetMapResponseRequest{
+			MachineId: identifier,
+		}
+
+		response, err := client.DebugGetMapResponse(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot get map response: %s", status.Convert(err).Message()),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response, "", output)
+
+			return
+		}
+
+		//nolint
+		fmt.Println(response.GetMapResponse())
+
+		tableData := pterm.TableData{
+			{"ID", "Name", "Namespace", "Reason", "Sources", "Destinations"},
+		}
+		for _, peer := range response.GetPeers() {
+			for _, reason := range peer.GetReasons() {
+				tableData = append(tableData, []string{
+					strconv.FormatUint(peer.GetMachineId(), headscale.Base10),
+					peer.GetName(),
+					peer.GetNamespace(),
+					reason.GetDescription(),
+					strings.Join(reason.GetSources(), ", "),
+					strings.Join(reason.GetDestinations(), ", "),
+				})
+			}
+		}
+		err = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		if len(response.GetTagGrants()) == 0 {
+			return
+		}
+
+		tagTableData := pterm.TableData{
+			{"Tag", "ACLs", "Namespaces"},
+		}
+		for _, grant := range response.GetTagGrants() {
+			aclIndexes := make([]string, len(grant.GetAclIndexes()))
+			for index, aclIndex := range grant.GetAclIndexes() {
+				aclIndexes[index] = strconv.FormatInt(int64(aclIndex), headscale.Base10)
+			}
+
+			tagTableData = append(tagTableData, []string{
+				grant.GetTag(),
+				strings.Join(aclIndexes, ", "),
+				strings.Join(grant.GetNamespaces(), ", "),
+			})
+		}
+		err = pterm.DefaultTable.WithHasHeader().WithData(tagTableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+var forceUpdateCmd = &cobra.Command{
+	Use:   "force-update",
+	Short: "Send a map update to a connected node, even if it looks up to date",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.ForceUpdateRequest{
+			MachineId: identifier,
+		}
+
+		response, err := client.ForceUpdate(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot force the update: %s", status.Convert(err).Message()),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response, "Update requested", output)
+	},
+}
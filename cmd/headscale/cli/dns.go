@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/juanfont/headscale"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	rootCmd.AddCommand(dnsCmd)
+
+	dnsRecordsCmd.Flags().
+		Bool("zone-file", false, "Print the records in the zone file format")
+	dnsCmd.AddCommand(dnsRecordsCmd)
+}
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Inspect the DNS records of the machines",
+}
+
+var dnsRecordsCmd = &cobra.Command{
+	Use:     "records",
+	Short:   "Generate the forward and reverse DNS records of all machines",
+	Aliases: []string{"ls", "list"},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		zoneFile, _ := cmd.Flags().GetBool("zone-file")
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.GenerateDNSRecordsRequest{}
+
+		response, err := client.GenerateDNSRecords(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot generate DNS records: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response.GetRecords(), "", output)
+
+			return
+		}
+
+		if zoneFile {
+			fmt.Print(response.GetZoneFile())
+
+			return
+		}
+
+		tableData := pterm.TableData{
+			{"Name", "Type", "Value", "Machine ID"},
+		}
+		for _, record := range response.GetRecords() {
+			tableData = append(tableData, []string{
+				record.GetName(),
+				record.GetType(),
+				record.GetValue(),
+				strconv.FormatUint(record.GetMachineId(), headscale.Base10),
+			})
+		}
+		err = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
@@ -2,6 +2,9 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	survey "github.com/AlecAivazis/survey/v2"
 	"github.com/juanfont/headscale"
@@ -18,10 +21,24 @@ func init() {
 	namespaceCmd.AddCommand(listNamespacesCmd)
 	namespaceCmd.AddCommand(destroyNamespaceCmd)
 	namespaceCmd.AddCommand(renameNamespaceCmd)
+	tagNamespaceCmd.Flags().
+		StringSliceP("tags", "t", []string{}, "List of tags to apply to the machines of the namespace")
+	tagNamespaceCmd.Flags().
+		StringP("mode", "m", "add", "How to apply the tags: add, remove or replace")
+	namespaceCmd.AddCommand(tagNamespaceCmd)
+	defaultTagsNamespaceCmd.Flags().
+		StringSliceP("tags", "t", []string{}, "List of default tags of the namespace, none to clear them")
+	namespaceCmd.AddCommand(defaultTagsNamespaceCmd)
+	namespaceCmd.AddCommand(expireNamespaceCmd)
+	namespaceCmd.AddCommand(policyNamespaceCmd)
+	quotaNamespaceCmd.Flags().
+		UintP("machines", "m", 0, "Maximum number of machines of the namespace, 0 for no limit")
+	namespaceCmd.AddCommand(quotaNamespaceCmd)
 }
 
 const (
 	errMissingParameter = headscale.Error("missing parameters")
+	errInvalidTagMode   = headscale.Error("invalid tag mode")
 )
 
 var namespaceCmd = &cobra.Command{
@@ -176,14 +193,23 @@ var listNamespacesCmd = &cobra.Command{
 			return
 		}
 
-		tableData := pterm.TableData{{"ID", "Name", "Created"}}
+		tableData := pterm.TableData{
+			{"ID", "Name", "Created", "Default tags", "Machines", "Quota"},
+		}
 		for _, namespace := range response.GetNamespaces() {
+			quota := "-"
+			if namespace.GetMachineQuota() > 0 {
+				quota = strconv.FormatUint(uint64(namespace.GetMachineQuota()), headscale.Base10)
+			}
 			tableData = append(
 				tableData,
 				[]string{
 					namespace.GetId(),
 					namespace.GetName(),
 					namespace.GetCreatedAt().AsTime().Format("2006-01-02 15:04:05"),
+					strings.Join(namespace.GetDefaultTags(), ", "),
+					strconv.FormatUint(namespace.GetMachineCount(), headscale.Base10),
+					quota,
 				},
 			)
 		}
@@ -241,3 +267,310 @@ var renameNamespaceCmd = &cobra.Command{
 		SuccessOutput(response.Namespace, "Namespace renamed", output)
 	},
 }
+
+var tagNamespaceCmd = &cobra.Command{
+	Use:     "tag NAME",
+	Short:   "Adds, removes or replaces the tags of all the machines of a namespace",
+	Aliases: []string{"tags", "t"},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		tags, err := cmd.Flags().GetStringSlice("tags")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error retrieving list of tags, %v", err),
+				output,
+			)
+
+			return
+		}
+
+		modeName, _ := cmd.Flags().GetString("mode")
+		var mode v1.BulkTagMode
+		switch modeName {
+		case "add":
+			mode = v1.BulkTagMode_BULK_TAG_MODE_ADD
+		case "remove":
+			mode = v1.BulkTagMode_BULK_TAG_MODE_REMOVE
+		case "replace":
+			mode = v1.BulkTagMode_BULK_TAG_MODE_REPLACE
+		default:
+			ErrorOutput(
+				errInvalidTagMode,
+				fmt.Sprintf("Invalid tag mode %q, must be add, remove or replace", modeName),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.BulkSetNamespaceTagsRequest{
+			Namespace: args[0],
+			Tags:      tags,
+			Mode:      mode,
+		}
+
+		response, err := client.BulkSetNamespaceTags(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot set the tags of the namespace: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(
+			response,
+			fmt.Sprintf("Tags updated on %d machines", response.GetAffectedMachines()),
+			output,
+		)
+	},
+}
+
+var defaultTagsNamespaceCmd = &cobra.Command{
+	Use:   "defaulttags NAME",
+	Short: "Sets the tags considered present on all the machines of a namespace",
+	Long: "The default tags of a namespace apply to all its machines in the ACLs, " +
+		"as long as the namespace is an owner of the tags.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		tags, err := cmd.Flags().GetStringSlice("tags")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error retrieving list of tags, %v", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.SetNamespaceTagsRequest{
+			Name: args[0],
+			Tags: tags,
+		}
+
+		response, err := client.SetNamespaceTags(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot set the default tags of the namespace: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Namespace, "Default tags of the namespace updated", output)
+	},
+}
+
+var quotaNamespaceCmd = &cobra.Command{
+	Use:   "quota NAME",
+	Short: "Sets the maximum number of machines of a namespace",
+	Long: "New machines cannot register in a namespace which reached its quota, " +
+		"the machines already registered are kept.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		quota, err := cmd.Flags().GetUint("machines")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error retrieving the machine quota, %v", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.SetNamespaceQuotaRequest{
+			Name:         args[0],
+			MachineQuota: uint32(quota),
+		}
+
+		response, err := client.SetNamespaceQuota(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot set the machine quota of the namespace: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Namespace, "Machine quota of the namespace updated", output)
+	},
+}
+
+var policyNamespaceCmd = &cobra.Command{
+	Use:   "policy NAME FILE",
+	Short: "Sets the ACL policy of a namespace, merged into the global ACL policy",
+	Long: "The policy of a namespace can only contain acls, whose sources and destinations " +
+		"are the namespace itself or the tags it owns. An empty file removes the policy.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		expectedArguments := 2
+		if len(args) < expectedArguments {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		policy, err := os.ReadFile(args[1])
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot read ACL policy: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.SetNamespaceACLPolicyRequest{
+			Name:   args[0],
+			Policy: policy,
+		}
+
+		response, err := client.SetNamespaceACLPolicy(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot set the ACL policy of the namespace: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		message := "ACL policy of the namespace updated"
+		for _, warning := range response.GetWarnings() {
+			message += fmt.Sprintf("\nWarning: %s", warning)
+		}
+		SuccessOutput(response, message, output)
+	},
+}
+
+var expireNamespaceCmd = &cobra.Command{
+	Use:   "expire NAME",
+	Short: "Expires (logs out) all the machines of a namespace",
+	Long:  "The machines already expired are left as they are.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		namespaceName := args[0]
+
+		confirm := false
+		force, _ := cmd.Flags().GetBool("force")
+		if !force {
+			prompt := &survey.Confirm{
+				Message: fmt.Sprintf(
+					"Do you want to expire all the machines of the namespace '%s'?",
+					namespaceName,
+				),
+			}
+			err := survey.AskOne(prompt, &confirm)
+			if err != nil {
+				return
+			}
+		}
+
+		if !confirm && !force {
+			SuccessOutput(map[string]string{"Result": "Machines not expired"}, "Machines not expired", output)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.ExpireNamespaceMachinesRequest{
+			Namespace: namespaceName,
+		}
+
+		response, err := client.ExpireNamespaceMachines(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot expire the machines of the namespace: %s",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(
+			response,
+			fmt.Sprintf("%d machines expired", response.GetAffectedMachines()),
+			output,
+		)
+	},
+}
@@ -11,16 +11,24 @@ import (
 	survey "github.com/AlecAivazis/survey/v2"
 	"github.com/juanfont/headscale"
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/common/model"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"tailscale.com/types/key"
 )
 
+const (
+	DefaultExtendDuration = "1h"
+)
+
 func init() {
 	rootCmd.AddCommand(nodeCmd)
 	listNodesCmd.Flags().StringP("namespace", "n", "", "Filter by namespace")
 	listNodesCmd.Flags().BoolP("tags", "t", false, "Show tags")
+	listNodesCmd.Flags().Bool("online", false, "Only list the online nodes")
 	nodeCmd.AddCommand(listNodesCmd)
 
 	registerNodeCmd.Flags().StringP("namespace", "n", "", "Namespace")
@@ -40,8 +48,26 @@ func init() {
 	if err != nil {
 		log.Fatalf(err.Error())
 	}
+	expireNodeCmd.Flags().
+		Bool("revoke-session", false, "Also revoke the OIDC session of the node at the provider")
 	nodeCmd.AddCommand(expireNodeCmd)
 
+	extendNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = extendNodeCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	extendNodeCmd.Flags().
+		StringP("duration", "d", DefaultExtendDuration, "Human-readable duration to extend the expiry by (e.g. 30m, 24h)")
+	nodeCmd.AddCommand(extendNodeCmd)
+
+	setExpiryNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = setExpiryNodeCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	nodeCmd.AddCommand(setExpiryNodeCmd)
+
 	renameNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
 	err = renameNodeCmd.MarkFlagRequired("identifier")
 	if err != nil {
@@ -56,6 +82,13 @@ func init() {
 	}
 	nodeCmd.AddCommand(deleteNodeCmd)
 
+	restoreNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = restoreNodeCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	nodeCmd.AddCommand(restoreNodeCmd)
+
 	moveNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
 
 	err = moveNodeCmd.MarkFlagRequired("identifier")
@@ -71,6 +104,25 @@ func init() {
 	}
 	nodeCmd.AddCommand(moveNodeCmd)
 
+	reassignIPNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = reassignIPNodeCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	nodeCmd.AddCommand(reassignIPNodeCmd)
+
+	rotateKeyNodeCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = rotateKeyNodeCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	rotateKeyNodeCmd.Flags().StringP("key", "k", "", "New machine key of the node")
+	err = rotateKeyNodeCmd.MarkFlagRequired("key")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	nodeCmd.AddCommand(rotateKeyNodeCmd)
+
 	tagCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
 
 	err = tagCmd.MarkFlagRequired("identifier")
@@ -156,13 +208,20 @@ var listNodesCmd = &cobra.Command{
 
 			return
 		}
+		onlineOnly, err := cmd.Flags().GetBool("online")
+		if err != nil {
+			ErrorOutput(err, fmt.Sprintf("Error getting online flag: %s", err), output)
+
+			return
+		}
 
 		ctx, client, conn, cancel := getHeadscaleCLIClient()
 		defer cancel()
 		defer conn.Close()
 
 		request := &v1.ListMachinesRequest{
-			Namespace: namespace,
+			Namespace:  namespace,
+			OnlineOnly: onlineOnly,
 		}
 
 		response, err := client.ListMachines(ctx, request)
@@ -221,10 +280,41 @@ var expireNodeCmd = &cobra.Command{
 			return
 		}
 
+		revokeSession, _ := cmd.Flags().GetBool("revoke-session")
+
 		ctx, client, conn, cancel := getHeadscaleCLIClient()
 		defer cancel()
 		defer conn.Close()
 
+		if revokeSession {
+			request := &v1.RevokeMachineSessionRequest{
+				MachineId: identifier,
+			}
+
+			response, err := client.RevokeMachineSession(ctx, request)
+			if err != nil {
+				ErrorOutput(
+					err,
+					fmt.Sprintf(
+						"Cannot revoke machine session: %s\n",
+						status.Convert(err).Message(),
+					),
+					output,
+				)
+
+				return
+			}
+
+			message := "Machine expired, no OIDC session revoked"
+			if response.SessionRevoked {
+				message = "Machine expired and OIDC session revoked"
+			}
+
+			SuccessOutput(response.Machine, message, output)
+
+			return
+		}
+
 		request := &v1.ExpireMachineRequest{
 			MachineId: identifier,
 		}
@@ -247,6 +337,131 @@ var expireNodeCmd = &cobra.Command{
 	},
 }
 
+var extendNodeCmd = &cobra.Command{
+	Use:   "extend",
+	Short: "Push the expiry of a machine forward",
+	Long:  "Extending a node postpones its expiry, so it does not have to reauthenticate yet.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		durationStr, _ := cmd.Flags().GetString("duration")
+
+		duration, err := model.ParseDuration(durationStr)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Could not parse duration: %s\n", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.ExtendMachineExpiryRequest{
+			MachineId: identifier,
+			Duration:  durationpb.New(time.Duration(duration)),
+		}
+
+		response, err := client.ExtendMachineExpiry(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot extend machine expiry: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Machine, "Machine expiry extended", output)
+	},
+}
+
+var setExpiryNodeCmd = &cobra.Command{
+	Use:   "setexpiry EXPIRY",
+	Short: "Set the expiry of a machine",
+	Long: "Set the expiry of a machine to an RFC 3339 timestamp (e.g. 2006-01-02T15:04:05Z), " +
+		"or to \"never\" so it does not expire. An expiry in the past expires the machine right away.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errMissingParameter
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		request := &v1.SetMachineExpiryRequest{
+			MachineId: identifier,
+		}
+
+		if args[0] != "never" {
+			expiry, err := time.Parse(time.RFC3339, args[0])
+			if err != nil {
+				ErrorOutput(
+					err,
+					fmt.Sprintf("Could not parse expiry: %s\n", err),
+					output,
+				)
+
+				return
+			}
+
+			request.Expiry = timestamppb.New(expiry)
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		response, err := client.SetMachineExpiry(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot set machine expiry: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Machine, "Machine expiry set", output)
+	},
+}
+
 var renameNodeCmd = &cobra.Command{
 	Use:   "rename NEW_NAME",
 	Short: "Renames a machine in your network",
@@ -384,6 +599,152 @@ var deleteNodeCmd = &cobra.Command{
 	},
 }
 
+var restoreNodeCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore a deleted node",
+	Long:  "Restoring a node undoes its deletion, as long as the machine_deletion_grace_period is not over.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.RestoreMachineRequest{
+			MachineId: identifier,
+		}
+
+		response, err := client.RestoreMachine(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot restore machine: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Machine, "Machine restored", output)
+	},
+}
+
+var reassignIPNodeCmd = &cobra.Command{
+	Use:   "reassign-ip",
+	Short: "Give new IP addresses to a node",
+	Long: "The node gets new IP addresses from the ip_prefixes, to resolve an address " +
+		"it shares with another node. The other nodes learn the new addresses with their next update.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.ReassignMachineIPRequest{
+			MachineId: identifier,
+		}
+
+		response, err := client.ReassignMachineIP(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot reassign the IP addresses of the machine: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Machine, "IP addresses of the machine reassigned", output)
+	},
+}
+
+var rotateKeyNodeCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Replace the machine key of a node",
+	Long: "The node keeps its ID, namespace and IP addresses under the new machine key. " +
+		"The other nodes learn the new key with their next update.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		identifier, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error converting ID to integer: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		machineKey, err := cmd.Flags().GetString("key")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error getting machine key from flag: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.RotateMachineKeyRequest{
+			MachineId:  identifier,
+			MachineKey: machineKey,
+		}
+
+		response, err := client.RotateMachineKey(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf(
+					"Cannot rotate the machine key of the machine: %s\n",
+					status.Convert(err).Message(),
+				),
+				output,
+			)
+
+			return
+		}
+
+		SuccessOutput(response.Machine, "Machine key of the machine rotated", output)
+	},
+}
+
 var moveNodeCmd = &cobra.Command{
 	Use:     "move",
 	Short:   "Move node to another namespace",
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
@@ -10,6 +11,7 @@ import (
 	"github.com/pterm/pterm"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -33,6 +35,14 @@ func init() {
 		Bool("ephemeral", false, "Preauthkey for ephemeral nodes")
 	createPreAuthKeyCmd.Flags().
 		StringP("expiration", "e", DefaultPreAuthKeyExpiry, "Human-readable expiration of the key (e.g. 30m, 24h)")
+	createPreAuthKeyCmd.Flags().
+		StringP("label", "l", "", "Free-text description of the purpose of the key")
+	createPreAuthKeyCmd.Flags().
+		Uint32("max-uses", 0, "Number of times the key can be used before it expires (0 for no limit)")
+	createPreAuthKeyCmd.Flags().
+		StringSlice("tags", []string{}, "ACL tags forced on the nodes registered with the key")
+	createPreAuthKeyCmd.Flags().
+		String("machine-expiry", "", "Human-readable expiry of the nodes registered with the key, overriding the server default (0 for never)")
 }
 
 var preauthkeysCmd = &cobra.Command{
@@ -81,7 +91,10 @@ var listPreAuthKeys = &cobra.Command{
 		}
 
 		tableData := pterm.TableData{
-			{"ID", "Key", "Reusable", "Ephemeral", "Used", "Expiration", "Created"},
+			{
+				"ID", "Key", "Reusable", "Ephemeral", "Used", "Uses",
+				"Expiration", "Created", "Label", "Tags", "Machines",
+			},
 		}
 		for _, key := range response.PreAuthKeys {
 			expiration := "-"
@@ -96,14 +109,28 @@ var listPreAuthKeys = &cobra.Command{
 				reusable = fmt.Sprintf("%v", key.GetReusable())
 			}
 
+			machines := make([]string, len(key.GetMachines()))
+			for index, machine := range key.GetMachines() {
+				machines[index] = machine.GetGivenName()
+			}
+
+			uses := fmt.Sprintf("%d", key.GetUseCount())
+			if key.GetMaxUses() > 0 {
+				uses = fmt.Sprintf("%d/%d", key.GetUseCount(), key.GetMaxUses())
+			}
+
 			tableData = append(tableData, []string{
 				key.GetId(),
 				key.GetKey(),
 				reusable,
 				strconv.FormatBool(key.GetEphemeral()),
 				strconv.FormatBool(key.GetUsed()),
+				uses,
 				expiration,
 				key.GetCreatedAt().AsTime().Format("2006-01-02 15:04:05"),
+				key.GetLabel(),
+				strings.Join(key.GetAclTags(), ", "),
+				strings.Join(machines, ", "),
 			})
 
 		}
@@ -136,6 +163,9 @@ var createPreAuthKeyCmd = &cobra.Command{
 
 		reusable, _ := cmd.Flags().GetBool("reusable")
 		ephemeral, _ := cmd.Flags().GetBool("ephemeral")
+		label, _ := cmd.Flags().GetString("label")
+		maxUses, _ := cmd.Flags().GetUint32("max-uses")
+		tags, _ := cmd.Flags().GetStringSlice("tags")
 
 		log.Trace().
 			Bool("reusable", reusable).
@@ -147,6 +177,9 @@ var createPreAuthKeyCmd = &cobra.Command{
 			Namespace: namespace,
 			Reusable:  reusable,
 			Ephemeral: ephemeral,
+			Label:     label,
+			MaxUses:   maxUses,
+			AclTags:   tags,
 		}
 
 		durationStr, _ := cmd.Flags().GetString("expiration")
@@ -170,6 +203,21 @@ var createPreAuthKeyCmd = &cobra.Command{
 
 		request.Expiration = timestamppb.New(expiration)
 
+		if machineExpiryStr, _ := cmd.Flags().GetString("machine-expiry"); machineExpiryStr != "" {
+			machineExpiry, err := model.ParseDuration(machineExpiryStr)
+			if err != nil {
+				ErrorOutput(
+					err,
+					fmt.Sprintf("Could not parse machine expiry: %s\n", err),
+					output,
+				)
+
+				return
+			}
+
+			request.MachineExpiry = durationpb.New(time.Duration(machineExpiry))
+		}
+
 		ctx, client, conn, cancel := getHeadscaleCLIClient()
 		defer cancel()
 		defer conn.Close()
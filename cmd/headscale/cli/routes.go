@@ -5,6 +5,7 @@ import (
 	"log"
 	"strconv"
 
+	"github.com/juanfont/headscale"
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -25,6 +26,8 @@ func init() {
 		StringSliceP("route", "r", []string{}, "List (or repeated flags) of routes to enable")
 	enableRouteCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
 	enableRouteCmd.Flags().BoolP("all", "a", false, "All routes from host")
+	enableRouteCmd.Flags().
+		Bool("exit-node", false, "Also enable the exit routes, approving the node as an exit node")
 
 	err = enableRouteCmd.MarkFlagRequired("identifier")
 	if err != nil {
@@ -33,6 +36,20 @@ func init() {
 
 	routesCmd.AddCommand(enableRouteCmd)
 
+	listExitNodesCmd.Flags().Uint64P("identifier", "i", 0, "Node identifier (ID)")
+	err = listExitNodesCmd.MarkFlagRequired("identifier")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	routesCmd.AddCommand(listExitNodesCmd)
+
+	findRouteMachinesCmd.Flags().StringP("prefix", "p", "", "Prefix (CIDR) to search")
+	err = findRouteMachinesCmd.MarkFlagRequired("prefix")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	routesCmd.AddCommand(findRouteMachinesCmd)
+
 	nodeCmd.AddCommand(routesCmd)
 }
 
@@ -164,9 +181,12 @@ omit the route you do not want to enable.
 			}
 		}
 
+		exitNode, _ := cmd.Flags().GetBool("exit-node")
+
 		request := &v1.EnableMachineRoutesRequest{
 			MachineId: machineID,
 			Routes:    routes,
+			ExitNode:  exitNode,
 		}
 
 		response, err := client.EnableMachineRoutes(ctx, request)
@@ -211,23 +231,177 @@ omit the route you do not want to enable.
 
 // routesToPtables converts the list of routes to a nice table.
 func routesToPtables(routes *v1.Routes) pterm.TableData {
-	tableData := pterm.TableData{{"Route", "Enabled"}}
-
-	for _, route := range routes.GetAdvertisedRoutes() {
-		enabled := isStringInSlice(routes.EnabledRoutes, route)
-
-		tableData = append(tableData, []string{route, strconv.FormatBool(enabled)})
+	tableData := pterm.TableData{{"Route", "Advertised", "Enabled", "Exit"}}
+
+	for _, route := range routes.GetRouteStatuses() {
+		tableData = append(tableData, []string{
+			route.GetPrefix(),
+			strconv.FormatBool(route.GetAdvertised()),
+			strconv.FormatBool(route.GetEnabled()),
+			strconv.FormatBool(route.GetExitRoute()),
+		})
 	}
 
 	return tableData
 }
 
-func isStringInSlice(strs []string, s string) bool {
-	for _, s2 := range strs {
-		if s == s2 {
-			return true
+var listExitNodesCmd = &cobra.Command{
+	Use:     "exit-nodes",
+	Short:   "List the exit nodes a given node is allowed to use",
+	Aliases: []string{"exit"},
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		machineID, err := cmd.Flags().GetUint64("identifier")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error getting machine id from flag: %s", err),
+				output,
+			)
+
+			return
 		}
-	}
 
-	return false
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.ListAvailableExitNodesRequest{
+			MachineId: machineID,
+		}
+
+		response, err := client.ListAvailableExitNodes(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot get available exit nodes: %s", status.Convert(err).Message()),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response.GetMachines(), "", output)
+
+			return
+		}
+
+		tableData := pterm.TableData{{"ID", "Name", "Namespace", "Last seen"}}
+		for _, machine := range response.GetMachines() {
+			lastSeen := "-"
+			if machine.GetLastSeen() != nil {
+				lastSeen = machine.GetLastSeen().AsTime().Format(HeadscaleDateTimeFormat)
+			}
+
+			tableData = append(tableData, []string{
+				strconv.FormatUint(machine.GetId(), headscale.Base10),
+				machine.GetGivenName(),
+				machine.GetNamespace().GetName(),
+				lastSeen,
+			})
+		}
+		err = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+var findRouteMachinesCmd = &cobra.Command{
+	Use:   "find",
+	Short: "List the nodes advertising or serving routes overlapping a given prefix",
+	Long: `This command lists the routes of every node overlapping the prefix,
+either because the route contains the prefix or because the prefix
+contains the route. The exit routes are only listed when an exit route
+is searched.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		prefix, err := cmd.Flags().GetString("prefix")
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Error getting prefix from flag: %s", err),
+				output,
+			)
+
+			return
+		}
+
+		ctx, client, conn, cancel := getHeadscaleCLIClient()
+		defer cancel()
+		defer conn.Close()
+
+		request := &v1.ListMachinesByRouteRequest{
+			Prefix: prefix,
+		}
+
+		response, err := client.ListMachinesByRoute(ctx, request)
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Cannot find nodes by route: %s", status.Convert(err).Message()),
+				output,
+			)
+
+			return
+		}
+
+		if output != "" {
+			SuccessOutput(response.GetMachines(), "", output)
+
+			return
+		}
+
+		tableData := pterm.TableData{
+			{"ID", "Name", "Namespace", "Route", "Advertised", "Enabled", "Match"},
+		}
+		for _, match := range response.GetMachines() {
+			machine := match.GetMachine()
+			for _, route := range match.GetRoutes() {
+				tableData = append(tableData, []string{
+					strconv.FormatUint(machine.GetId(), headscale.Base10),
+					machine.GetGivenName(),
+					machine.GetNamespace().GetName(),
+					route.GetRoute(),
+					strconv.FormatBool(route.GetAdvertised()),
+					strconv.FormatBool(route.GetEnabled()),
+					routeMatchTypeToString(route.GetMatchType()),
+				})
+			}
+		}
+		err = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+		if err != nil {
+			ErrorOutput(
+				err,
+				fmt.Sprintf("Failed to render pterm table: %s", err),
+				output,
+			)
+
+			return
+		}
+	},
+}
+
+// routeMatchTypeToString describes which way the route overlaps the
+// searched prefix.
+func routeMatchTypeToString(matchType v1.RouteMatchType) string {
+	switch matchType {
+	case v1.RouteMatchType_ROUTE_MATCH_TYPE_EQUAL:
+		return "equal"
+	case v1.RouteMatchType_ROUTE_MATCH_TYPE_ROUTE_CONTAINS_PREFIX:
+		return "route contains prefix"
+	case v1.RouteMatchType_ROUTE_MATCH_TYPE_PREFIX_CONTAINS_ROUTE:
+		return "prefix contains route"
+	default:
+		return "-"
+	}
 }
@@ -3,10 +3,20 @@ package cli
 import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().
+		Bool("strict", true, "Refuse an ACL policy whose groups list members which are not existing namespaces (overrides acl_strict)")
+	err := viper.BindPFlag("acl_strict", serveCmd.Flags().Lookup("strict"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	serveCmd.Flags().
+		Bool("validate", false, "Check the configuration, the ACL policy and the machines in the database, then exit")
 }
 
 var serveCmd = &cobra.Command{
@@ -21,6 +31,19 @@ var serveCmd = &cobra.Command{
 			log.Fatal().Caller().Err(err).Msg("Error initializing")
 		}
 
+		validate, _ := cmd.Flags().GetBool("validate")
+		if validate {
+			// the configuration and the ACL policy are checked by
+			// getHeadscaleApp
+			err = app.CheckDuplicateMachineIPs()
+			if err != nil {
+				log.Fatal().Caller().Err(err).Msg("Validation failed")
+			}
+			log.Info().Msg("The configuration, the ACL policy and the machines are valid")
+
+			return
+		}
+
 		err = app.Serve()
 		if err != nil {
 			log.Fatal().Caller().Err(err).Msg("Error starting server")
@@ -30,6 +30,8 @@ func getHeadscaleApp() (*headscale.Headscale, error) {
 		)
 	}
 
+	cfg.Version = Version
+
 	app, err := headscale.NewHeadscale(cfg)
 	if err != nil {
 		return nil, err
@@ -48,6 +50,15 @@ func getHeadscaleApp() (*headscale.Headscale, error) {
 		}
 	}
 
+	if cfg.ACL.PolicyMode == headscale.ACLPolicyModeDatabase {
+		err = app.LoadACLPolicyFromDB()
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Could not load the ACL policy from the database")
+		}
+	}
+
 	return app, nil
 }
 
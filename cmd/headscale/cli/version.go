@@ -1,13 +1,20 @@
 package cli
 
 import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
 )
 
 var Version = "dev"
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().
+		Bool("server", false, "Print the version and the enabled features of the server instead")
 }
 
 var versionCmd = &cobra.Command{
@@ -16,6 +23,41 @@ var versionCmd = &cobra.Command{
 	Long:  "The version of headscale.",
 	Run: func(cmd *cobra.Command, args []string) {
 		output, _ := cmd.Flags().GetString("output")
+
+		server, _ := cmd.Flags().GetBool("server")
+		if server {
+			ctx, client, conn, cancel := getHeadscaleCLIClient()
+			defer cancel()
+			defer conn.Close()
+
+			response, err := client.GetServerInfo(ctx, &v1.GetServerInfoRequest{})
+			if err != nil {
+				ErrorOutput(
+					err,
+					fmt.Sprintf(
+						"Cannot get the server info: %s",
+						status.Convert(err).Message(),
+					),
+					output,
+				)
+
+				return
+			}
+
+			SuccessOutput(
+				response,
+				fmt.Sprintf(
+					"%s (%s), features: %s",
+					response.GetVersion(),
+					response.GetServerUrl(),
+					strings.Join(response.GetFeatures(), ", "),
+				),
+				output,
+			)
+
+			return
+		}
+
 		SuccessOutput(map[string]string{"version": Version}, Version, output)
 	},
 }
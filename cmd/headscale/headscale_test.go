@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/juanfont/headscale"
 	"github.com/spf13/viper"
@@ -204,3 +205,202 @@ tls_letsencrypt_challenge_type: TLS-ALPN-01
 	err = headscale.LoadConfig(tmpDir, false)
 	c.Assert(err, check.IsNil)
 }
+
+func (*Suite) TestPollIntervalsValidation(c *check.C) {
+	tmpDir, err := os.MkdirTemp("", "headscale")
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configYaml := []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+node_update_check_interval: 0s
+node_keepalive_interval: 2m
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.NotNil)
+	tmp := strings.ReplaceAll(err.Error(), "\n", "***")
+	c.Assert(
+		tmp,
+		check.Matches,
+		".*Fatal config error: node_update_check_interval must be a positive duration.*",
+	)
+	c.Assert(
+		tmp,
+		check.Matches,
+		".*Fatal config error: node_keepalive_interval \\(2m\\) is set too high.*",
+	)
+
+	configYaml = []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+node_keepalive_interval: 90s
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(viper.GetDuration("node_keepalive_interval"), check.Equals, 90*time.Second)
+}
+
+func (*Suite) TestRegistrationCacheExpirationValidation(c *check.C) {
+	tmpDir, err := os.MkdirTemp("", "headscale")
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, expiration := range []string{"0s", "30s", "25h"} {
+		configYaml := []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+registration_cache:
+  expiration: ` + expiration + `
+`)
+		writeConfig(c, tmpDir, configYaml)
+
+		err = headscale.LoadConfig(tmpDir, false)
+		c.Assert(err, check.NotNil)
+		c.Assert(
+			strings.ReplaceAll(err.Error(), "\n", "***"),
+			check.Matches,
+			".*Fatal config error: registration_cache.expiration \\("+expiration+"\\) must be between 1m0s and 24h0m0s.*",
+		)
+	}
+
+	configYaml := []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+registration_cache:
+  expiration: 1h
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(headscale.GetRegistrationCacheConfig().Expiration, check.Equals, time.Hour)
+}
+
+func (*Suite) TestACLPolicyModeValidation(c *check.C) {
+	tmpDir, err := os.MkdirTemp("", "headscale")
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configYaml := []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+acl_policy_mode: consul
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.NotNil)
+	c.Assert(
+		strings.ReplaceAll(err.Error(), "\n", "***"),
+		check.Matches,
+		".*Fatal config error: the only supported values for acl_policy_mode are file and database.*",
+	)
+
+	configYaml = []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+acl_policy_mode: database
+acl_policy_path: policy.hujson
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.NotNil)
+	c.Assert(
+		strings.ReplaceAll(err.Error(), "\n", "***"),
+		check.Matches,
+		".*Fatal config error: acl_policy_path cannot be set with acl_policy_mode: database.*",
+	)
+
+	configYaml = []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+acl_policy_mode: database
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(headscale.GetACLConfig().PolicyMode, check.Equals, headscale.ACLPolicyModeDatabase)
+}
+
+func (*Suite) TestIPPrefixesValidation(c *check.C) {
+	tmpDir, err := os.MkdirTemp("", "headscale")
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	configYaml := []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+ip_prefixes:
+  - 2001:db8::/48
+  - 100.64.0.0/10
+  - 8.8.8.0/24
+  - 10.20.30.0/25
+  - 100.64
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.NotNil)
+	errorText := strings.ReplaceAll(err.Error(), "\n", "***")
+	c.Assert(
+		errorText,
+		check.Matches,
+		".*Fatal config error: ip_prefixes\\[0\\] \\(2001:db8::/48\\) must be within 100.64.0.0/10, an RFC 1918 range or fc00::/7.*",
+	)
+	c.Assert(
+		errorText,
+		check.Matches,
+		".*Fatal config error: ip_prefixes\\[2\\] \\(8.8.8.0/24\\) must be within.*",
+	)
+	c.Assert(
+		errorText,
+		check.Matches,
+		".*Fatal config error: ip_prefixes\\[3\\] \\(10.20.30.0/25\\) is too small, the prefix length must be at most /24.*",
+	)
+	c.Assert(
+		errorText,
+		check.Matches,
+		".*Fatal config error: ip_prefixes\\[4\\] \\(100.64\\) is not a valid prefix.*",
+	)
+	c.Assert(strings.Contains(errorText, "ip_prefixes[1]"), check.Equals, false)
+
+	configYaml = []byte(`---
+server_url: http://127.0.0.1:8080
+noise:
+  private_key_path: noise_private.key
+ip_prefixes:
+  - fd00:1234::/64
+  - 172.20.0.0/16
+`)
+	writeConfig(c, tmpDir, configYaml)
+
+	err = headscale.LoadConfig(tmpDir, false)
+	c.Assert(err, check.IsNil)
+
+	config, err := headscale.GetHeadscaleConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(len(config.IPPrefixes), check.Equals, 2)
+}
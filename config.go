@@ -25,8 +25,40 @@ const (
 
 	JSONLogFormat = "json"
 	TextLogFormat = "text"
+
+	ACLEmptyAliasWarn  = "warn"
+	ACLEmptyAliasError = "error"
+
+	ACLPolicyModeFile     = "file"
+	ACLPolicyModeDatabase = "database"
+
+	OIDCPKCEAuto     = "auto"
+	OIDCPKCEEnabled  = "enabled"
+	OIDCPKCEDisabled = "disabled"
+
+	OIDCEmailDomainCollisionWarn   = "warn"
+	OIDCEmailDomainCollisionRefuse = "refuse"
+	OIDCEmailDomainCollisionSuffix = "suffix"
+
+	// DefaultOIDCProvider is the name of the OIDC provider configured
+	// directly under oidc, next to the named oidc.providers.
+	DefaultOIDCProvider = "default"
+
+	// minIPPrefixHostBits leaves at least 256 addresses to allocate from
+	// each of the ip_prefixes.
+	minIPPrefixHostBits = 8
 )
 
+// privateIPPrefixes are the ranges the ip_prefixes must be taken from: the
+// CGNAT range used by Tailscale, the RFC 1918 ranges and the IPv6 ULAs.
+var privateIPPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
 // Config contains the initial Headscale configuration.
 type Config struct {
 	ServerURL                      string
@@ -35,7 +67,14 @@ type Config struct {
 	GRPCAddr                       string
 	GRPCAllowInsecure              bool
 	EphemeralNodeInactivityTimeout time.Duration
+	MachineDeletionGracePeriod     time.Duration
 	NodeUpdateCheckInterval        time.Duration
+	NodeKeepAliveInterval          time.Duration
+	PollShutdownDrainPeriod        time.Duration
+	PollMaxRequestBodySize         int64
+	MaxRoutesPerMachine            int
+	MaxExpiryExtension             time.Duration
+	DefaultMachineExpiry           time.Duration
 	IPPrefixes                     []netip.Prefix
 	PrivateKeyPath                 string
 	NoisePrivateKeyPath            string
@@ -43,6 +82,11 @@ type Config struct {
 	Log                            LogConfig
 	DisableUpdateCheck             bool
 
+	// EphemeralNodeDisconnectGracePeriod is the delay before an ephemeral
+	// machine which closed its stream is deleted, 0 to only rely on
+	// EphemeralNodeInactivityTimeout.
+	EphemeralNodeDisconnectGracePeriod time.Duration
+
 	DERP DERPConfig
 
 	DBtype string
@@ -65,6 +109,12 @@ type Config struct {
 	UnixSocketPermission fs.FileMode
 
 	OIDC OIDCConfig
+	// OIDCProviders holds every configured OIDC provider by name, including
+	// the one configured directly under oidc as DefaultOIDCProvider.
+	OIDCProviders map[string]OIDCConfig
+	// OIDCRegisterRateLimit is the number of /oidc/register requests a
+	// client IP can make per minute, 0 means no limit.
+	OIDCRegisterRateLimit int
 
 	LogTail             LogTailConfig
 	RandomizeClientPort bool
@@ -72,6 +122,14 @@ type Config struct {
 	CLI CLIConfig
 
 	ACL ACLConfig
+
+	RegistrationCache RegistrationCacheConfig
+
+	Webhook WebhookConfig
+
+	// Version is the version of the headscale binary, reported by
+	// GetServerInfo. It is not read from the configuration file.
+	Version string
 }
 
 type TLSConfig struct {
@@ -98,6 +156,43 @@ type OIDCConfig struct {
 	AllowedDomains   []string
 	AllowedUsers     []string
 	StripEmaildomain bool
+
+	// AllowedGroups requires the users to be in one of the groups, on top of
+	// AllowedDomains and AllowedUsers.
+	AllowedGroups []string
+
+	// CaseSensitiveLocalPart makes the local part (before the @) of the
+	// emails in AllowedUsers match case-sensitively. Domains are always
+	// matched case-insensitively.
+	CaseSensitiveLocalPart bool
+
+	// GroupNamespaces maps (lower case) OIDC groups to the namespace the
+	// machines of their members are registered in. When a user is in several
+	// mapped groups, the first one in GroupPriority wins.
+	GroupNamespaces map[string]string
+	GroupPriority   []string
+
+	// PKCE is either OIDCPKCEAuto, OIDCPKCEEnabled or OIDCPKCEDisabled.
+	// In auto mode, PKCE is used when the provider advertises S256.
+	PKCE string
+
+	// UseUserInfo merges the claims of the UserInfo endpoint into the
+	// claims of the ID token, for providers leaving e.g. groups out of it.
+	UseUserInfo bool
+
+	// UseExpiryFromToken expires the machines when the ID token they were
+	// authenticated with expires, instead of when the client asks to.
+	UseExpiryFromToken bool
+
+	// NamespaceClaim is the claim the namespaces of the users are named
+	// after, the email when empty.
+	NamespaceClaim string
+
+	// EmailDomainCollision is either OIDCEmailDomainCollisionWarn,
+	// OIDCEmailDomainCollisionRefuse or OIDCEmailDomainCollisionSuffix. It
+	// applies when StripEmaildomain maps a user to a namespace created for
+	// another email domain.
+	EmailDomainCollision string
 }
 
 type DERPConfig struct {
@@ -108,6 +203,7 @@ type DERPConfig struct {
 	STUNAddr         string
 	URLs             []url.URL
 	Paths            []string
+	CustomMapPath    string
 	AutoUpdate       bool
 	UpdateFrequency  time.Duration
 }
@@ -125,11 +221,42 @@ type CLIConfig struct {
 
 type ACLConfig struct {
 	PolicyPath string
+
+	// PolicyMode is either ACLPolicyModeFile, the policy is read from
+	// PolicyPath, or ACLPolicyModeDatabase, the policy is stored in the
+	// database and shared by every headscale using it.
+	PolicyMode string
+
+	// EmptyAlias is either ACLEmptyAliasWarn or ACLEmptyAliasError, and
+	// defines what happens when an alias of a rule matches no address.
+	EmptyAlias string
+
+	// Strict refuses to load a policy whose groups list members which are
+	// not existing namespaces.
+	Strict bool
+}
+
+type RegistrationCacheConfig struct {
+	Expiration      time.Duration
+	CleanupInterval time.Duration
+	// MaxEntries bounds the number of pending registrations, the oldest
+	// one is evicted to make room for a new one. 0 means no limit.
+	MaxEntries int
+}
+
+// WebhookConfig is the endpoint the machine registrations, deletions and
+// expiries are POSTed to. An empty URL disables the webhook.
+type WebhookConfig struct {
+	URL        string
+	Timeout    time.Duration
+	MaxRetries int
 }
 
 type LogConfig struct {
 	Format string
 	Level  zerolog.Level
+	// AccessLog emits a JSON line for every map poll and OIDC request.
+	AccessLog bool
 }
 
 func LoadConfig(path string, isFile bool) error {
@@ -157,6 +284,7 @@ func LoadConfig(path string, isFile bool) error {
 
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", TextLogFormat)
+	viper.SetDefault("log.access_log", false)
 
 	viper.SetDefault("dns_config", nil)
 
@@ -174,14 +302,46 @@ func LoadConfig(path string, isFile bool) error {
 
 	viper.SetDefault("oidc.scope", []string{oidc.ScopeOpenID, "profile", "email"})
 	viper.SetDefault("oidc.strip_email_domain", true)
+	viper.SetDefault("oidc.case_sensitive_local_part", false)
+	viper.SetDefault("oidc.pkce", OIDCPKCEAuto)
+	viper.SetDefault("oidc.email_domain_collision", OIDCEmailDomainCollisionWarn)
+	viper.SetDefault("oidc.register_rate_limit", defaultOIDCRegisterRateLimit)
+	viper.SetDefault("oidc.use_userinfo", false)
+	viper.SetDefault("oidc.use_token_expiry", false)
 
 	viper.SetDefault("logtail.enabled", false)
 	viper.SetDefault("randomize_client_port", false)
 
 	viper.SetDefault("ephemeral_node_inactivity_timeout", "120s")
+	viper.SetDefault("ephemeral_node_disconnect_grace_period", "30s")
+
+	viper.SetDefault("machine_deletion_grace_period", "0s")
 
 	viper.SetDefault("node_update_check_interval", "10s")
 
+	viper.SetDefault("node_keepalive_interval", defaultKeepAliveInterval)
+
+	viper.SetDefault("poll_max_request_body_size", defaultPollMaxRequestBodySize)
+
+	viper.SetDefault("poll_shutdown_drain_period", "5s")
+
+	viper.SetDefault("max_routes_per_machine", defaultMaxRoutesPerMachine)
+
+	viper.SetDefault("max_expiry_extension", "24h")
+	viper.SetDefault("default_machine_expiry", "0s")
+
+	viper.SetDefault("acl_policy_mode", ACLPolicyModeFile)
+	viper.SetDefault("acl_empty_alias", ACLEmptyAliasWarn)
+	viper.SetDefault("acl_strict", true)
+
+	viper.SetDefault("registration_cache.expiration", registerCacheExpiration)
+	viper.SetDefault("registration_cache.cleanup_interval", registerCacheCleanup)
+	viper.SetDefault("registration_cache.max_entries", defaultRegistrationCacheMaxEntries)
+
+	viper.SetDefault("webhook.url", "")
+	viper.SetDefault("webhook.timeout", "5s")
+	viper.SetDefault("webhook.max_retries", 3)
+
 	if err := viper.ReadInConfig(); err != nil {
 		log.Warn().Err(err).Msg("Failed to read configuration from disk")
 
@@ -241,15 +401,153 @@ func LoadConfig(path string, isFile bool) error {
 		)
 	}
 
+	switch viper.GetString("acl_policy_mode") {
+	case ACLPolicyModeFile:
+	case ACLPolicyModeDatabase:
+		if viper.GetString("acl_policy_path") != "" {
+			errorText += "Fatal config error: acl_policy_path cannot be set with acl_policy_mode: database\n"
+		}
+	default:
+		errorText += "Fatal config error: the only supported values for acl_policy_mode are file and database\n"
+	}
+
+	if (viper.GetString("acl_empty_alias") != ACLEmptyAliasWarn) &&
+		(viper.GetString("acl_empty_alias") != ACLEmptyAliasError) {
+		errorText += "Fatal config error: the only supported values for acl_empty_alias are warn and error\n"
+	}
+
+	if (viper.GetString("oidc.pkce") != OIDCPKCEAuto) &&
+		(viper.GetString("oidc.pkce") != OIDCPKCEEnabled) &&
+		(viper.GetString("oidc.pkce") != OIDCPKCEDisabled) {
+		errorText += "Fatal config error: the only supported values for oidc.pkce are auto, enabled and disabled\n"
+	}
+
+	if !isOIDCEmailDomainCollision(viper.GetString("oidc.email_domain_collision")) {
+		errorText += "Fatal config error: the only supported values for oidc.email_domain_collision are warn, refuse and suffix\n"
+	}
+
+	for name := range viper.GetStringMap("oidc.providers") {
+		if name == DefaultOIDCProvider && viper.GetString("oidc.issuer") != "" {
+			errorText += fmt.Sprintf(
+				"Fatal config error: the OIDC provider name %s is reserved for the provider configured under oidc\n",
+				DefaultOIDCProvider,
+			)
+		}
+
+		pkce := viper.GetString("oidc.providers." + name + ".pkce")
+		if pkce != "" && pkce != OIDCPKCEAuto && pkce != OIDCPKCEEnabled &&
+			pkce != OIDCPKCEDisabled {
+			errorText += fmt.Sprintf(
+				"Fatal config error: the only supported values for oidc.providers.%s.pkce are auto, enabled and disabled\n",
+				name,
+			)
+		}
+
+		collision := viper.GetString("oidc.providers." + name + ".email_domain_collision")
+		if collision != "" && !isOIDCEmailDomainCollision(collision) {
+			errorText += fmt.Sprintf(
+				"Fatal config error: the only supported values for oidc.providers.%s.email_domain_collision are warn, refuse and suffix\n",
+				name,
+			)
+		}
+	}
+
+	registrationExpiration := viper.GetDuration("registration_cache.expiration")
+	if registrationExpiration < minRegistrationCacheExpiration ||
+		registrationExpiration > maxRegistrationCacheExpiration {
+		errorText += fmt.Sprintf(
+			"Fatal config error: registration_cache.expiration (%s) must be between %s and %s\n",
+			viper.GetString("registration_cache.expiration"),
+			minRegistrationCacheExpiration,
+			maxRegistrationCacheExpiration,
+		)
+	}
+
+	if viper.GetDuration("registration_cache.cleanup_interval") <= 0 {
+		errorText += "Fatal config error: registration_cache.cleanup_interval must be a positive duration\n"
+	}
+
+	if viper.GetInt("registration_cache.max_entries") < 0 {
+		errorText += "Fatal config error: registration_cache.max_entries must not be negative\n"
+	}
+
+	if viper.GetInt("oidc.register_rate_limit") < 0 {
+		errorText += "Fatal config error: oidc.register_rate_limit must not be negative\n"
+	}
+
+	if webhookURL := viper.GetString("webhook.url"); webhookURL != "" {
+		if parsed, err := url.Parse(webhookURL); err != nil ||
+			(parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errorText += "Fatal config error: webhook.url must be an http or https URL\n"
+		}
+	}
+
+	if viper.GetDuration("webhook.timeout") <= 0 {
+		errorText += "Fatal config error: webhook.timeout must be a positive duration\n"
+	}
+
+	if viper.GetInt("webhook.max_retries") < 0 {
+		errorText += "Fatal config error: webhook.max_retries must not be negative\n"
+	}
+
 	maxNodeUpdateCheckInterval, _ := time.ParseDuration("60s")
 	if viper.GetDuration("node_update_check_interval") > maxNodeUpdateCheckInterval {
 		errorText += fmt.Sprintf(
-			"Fatal config error: node_update_check_interval (%s) is set too high, must be less than %s",
+			"Fatal config error: node_update_check_interval (%s) is set too high, must be less than %s\n",
 			viper.GetString("node_update_check_interval"),
 			maxNodeUpdateCheckInterval,
 		)
 	}
 
+	if viper.GetDuration("node_update_check_interval") <= 0 {
+		errorText += "Fatal config error: node_update_check_interval must be a positive duration\n"
+	}
+
+	if viper.GetDuration("machine_deletion_grace_period") < 0 {
+		errorText += "Fatal config error: machine_deletion_grace_period must not be negative\n"
+	}
+
+	if viper.GetDuration("ephemeral_node_disconnect_grace_period") < 0 {
+		errorText += "Fatal config error: ephemeral_node_disconnect_grace_period must not be negative\n"
+	}
+
+	if viper.GetDuration("default_machine_expiry") < 0 {
+		errorText += "Fatal config error: default_machine_expiry must not be negative\n"
+	}
+
+	if viper.GetDuration("node_keepalive_interval") <= 0 {
+		errorText += "Fatal config error: node_keepalive_interval must be a positive duration\n"
+	}
+
+	if viper.GetDuration("node_keepalive_interval") >= clientPollTimeout {
+		errorText += fmt.Sprintf(
+			"Fatal config error: node_keepalive_interval (%s) is set too high, must be less than %s, after which the clients drop their connection\n",
+			viper.GetString("node_keepalive_interval"),
+			clientPollTimeout,
+		)
+	}
+
+	if legacyPrefix := viper.GetString("ip_prefix"); legacyPrefix != "" {
+		if problem := ipPrefixProblem(legacyPrefix); problem != "" {
+			errorText += fmt.Sprintf(
+				"Fatal config error: ip_prefix (%s) %s\n",
+				legacyPrefix,
+				problem,
+			)
+		}
+	}
+
+	for index, prefix := range viper.GetStringSlice("ip_prefixes") {
+		if problem := ipPrefixProblem(prefix); problem != "" {
+			errorText += fmt.Sprintf(
+				"Fatal config error: ip_prefixes[%d] (%s) %s\n",
+				index,
+				prefix,
+				problem,
+			)
+		}
+	}
+
 	if errorText != "" {
 		//nolint
 		return errors.New(strings.TrimSuffix(errorText, "\n"))
@@ -310,6 +608,7 @@ func GetDERPConfig() DERPConfig {
 	}
 
 	paths := viper.GetStringSlice("derp.paths")
+	customMapPath := viper.GetString("derp.custom_map_path")
 
 	autoUpdate := viper.GetBool("derp.auto_update_enabled")
 	updateFrequency := viper.GetDuration("derp.update_frequency")
@@ -322,6 +621,7 @@ func GetDERPConfig() DERPConfig {
 		STUNAddr:         stunAddr,
 		URLs:             urls,
 		Paths:            paths,
+		CustomMapPath:    customMapPath,
 		AutoUpdate:       autoUpdate,
 		UpdateFrequency:  updateFrequency,
 	}
@@ -335,11 +635,115 @@ func GetLogTailConfig() LogTailConfig {
 	}
 }
 
+// GetOIDCConfig reads the OIDC provider configured under key. The settings
+// which are not tied to the provider itself, like the scope or PKCE, are
+// inherited from the provider configured directly under oidc when unset.
+func GetOIDCConfig(key string) OIDCConfig {
+	inherited := func(field string) string {
+		if viper.IsSet(key + "." + field) {
+			return key + "." + field
+		}
+
+		return "oidc." + field
+	}
+
+	return OIDCConfig{
+		Issuer:           viper.GetString(key + ".issuer"),
+		ClientID:         viper.GetString(key + ".client_id"),
+		ClientSecret:     viper.GetString(key + ".client_secret"),
+		Scope:            viper.GetStringSlice(inherited("scope")),
+		ExtraParams:      viper.GetStringMapString(inherited("extra_params")),
+		AllowedDomains:   viper.GetStringSlice(key + ".allowed_domains"),
+		AllowedUsers:     viper.GetStringSlice(key + ".allowed_users"),
+		StripEmaildomain: viper.GetBool(inherited("strip_email_domain")),
+		AllowedGroups:    viper.GetStringSlice(key + ".allowed_groups"),
+		CaseSensitiveLocalPart: viper.GetBool(
+			inherited("case_sensitive_local_part"),
+		),
+		GroupNamespaces: viper.GetStringMapString(inherited("group_namespaces")),
+		GroupPriority:   viper.GetStringSlice(inherited("group_priority")),
+		PKCE:            viper.GetString(inherited("pkce")),
+		UseUserInfo:     viper.GetBool(inherited("use_userinfo")),
+		UseExpiryFromToken: viper.GetBool(
+			inherited("use_token_expiry"),
+		),
+		EmailDomainCollision: viper.GetString(inherited("email_domain_collision")),
+		NamespaceClaim:       viper.GetString(inherited("namespace_claim")),
+	}
+}
+
+func isOIDCEmailDomainCollision(collision string) bool {
+	return collision == OIDCEmailDomainCollisionWarn ||
+		collision == OIDCEmailDomainCollisionRefuse ||
+		collision == OIDCEmailDomainCollisionSuffix
+}
+
+// ipPrefixProblem explains why the machines cannot be given addresses from
+// the prefix, or returns an empty string if they can.
+func ipPrefixProblem(prefixString string) string {
+	prefix, err := netip.ParsePrefix(prefixString)
+	if err != nil {
+		return "is not a valid prefix"
+	}
+
+	private := false
+	for _, privatePrefix := range privateIPPrefixes {
+		if privatePrefix.Bits() <= prefix.Bits() && privatePrefix.Contains(prefix.Addr()) {
+			private = true
+
+			break
+		}
+	}
+	if !private {
+		return "must be within 100.64.0.0/10, an RFC 1918 range or fc00::/7"
+	}
+
+	if maxBits := prefix.Addr().BitLen() - minIPPrefixHostBits; prefix.Bits() > maxBits {
+		return fmt.Sprintf("is too small, the prefix length must be at most /%d", maxBits)
+	}
+
+	return ""
+}
+
+// GetOIDCProvidersConfig returns every configured OIDC provider by name.
+func GetOIDCProvidersConfig() map[string]OIDCConfig {
+	providers := make(map[string]OIDCConfig)
+
+	if viper.GetString("oidc.issuer") != "" {
+		providers[DefaultOIDCProvider] = GetOIDCConfig("oidc")
+	}
+
+	for name := range viper.GetStringMap("oidc.providers") {
+		providers[name] = GetOIDCConfig("oidc.providers." + name)
+	}
+
+	return providers
+}
+
 func GetACLConfig() ACLConfig {
 	policyPath := viper.GetString("acl_policy_path")
 
 	return ACLConfig{
 		PolicyPath: policyPath,
+		PolicyMode: viper.GetString("acl_policy_mode"),
+		EmptyAlias: viper.GetString("acl_empty_alias"),
+		Strict:     viper.GetBool("acl_strict"),
+	}
+}
+
+func GetRegistrationCacheConfig() RegistrationCacheConfig {
+	return RegistrationCacheConfig{
+		Expiration:      viper.GetDuration("registration_cache.expiration"),
+		CleanupInterval: viper.GetDuration("registration_cache.cleanup_interval"),
+		MaxEntries:      viper.GetInt("registration_cache.max_entries"),
+	}
+}
+
+func GetWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		URL:        viper.GetString("webhook.url"),
+		Timeout:    viper.GetDuration("webhook.timeout"),
+		MaxRetries: viper.GetInt("webhook.max_retries"),
 	}
 }
 
@@ -366,8 +770,9 @@ func GetLogConfig() LogConfig {
 	}
 
 	return LogConfig{
-		Format: logFormat,
-		Level:  logLevel,
+		Format:    logFormat,
+		Level:     logLevel,
+		AccessLog: viper.GetBool("log.access_log"),
 	}
 }
 
@@ -535,10 +940,27 @@ func GetHeadscaleConfig() (*Config, error) {
 			"ephemeral_node_inactivity_timeout",
 		),
 
+		EphemeralNodeDisconnectGracePeriod: viper.GetDuration(
+			"ephemeral_node_disconnect_grace_period",
+		),
+
+		MachineDeletionGracePeriod: viper.GetDuration("machine_deletion_grace_period"),
+
 		NodeUpdateCheckInterval: viper.GetDuration(
 			"node_update_check_interval",
 		),
 
+		NodeKeepAliveInterval: viper.GetDuration("node_keepalive_interval"),
+
+		PollMaxRequestBodySize: viper.GetInt64("poll_max_request_body_size"),
+
+		PollShutdownDrainPeriod: viper.GetDuration("poll_shutdown_drain_period"),
+
+		MaxRoutesPerMachine: viper.GetInt("max_routes_per_machine"),
+
+		MaxExpiryExtension:   viper.GetDuration("max_expiry_extension"),
+		DefaultMachineExpiry: viper.GetDuration("default_machine_expiry"),
+
 		DBtype: viper.GetString("db_type"),
 		DBpath: AbsolutePathFromConfigPath(viper.GetString("db_path")),
 		DBhost: viper.GetString("db_host"),
@@ -558,16 +980,9 @@ func GetHeadscaleConfig() (*Config, error) {
 		UnixSocket:           viper.GetString("unix_socket"),
 		UnixSocketPermission: GetFileMode("unix_socket_permission"),
 
-		OIDC: OIDCConfig{
-			Issuer:           viper.GetString("oidc.issuer"),
-			ClientID:         viper.GetString("oidc.client_id"),
-			ClientSecret:     viper.GetString("oidc.client_secret"),
-			Scope:            viper.GetStringSlice("oidc.scope"),
-			ExtraParams:      viper.GetStringMapString("oidc.extra_params"),
-			AllowedDomains:   viper.GetStringSlice("oidc.allowed_domains"),
-			AllowedUsers:     viper.GetStringSlice("oidc.allowed_users"),
-			StripEmaildomain: viper.GetBool("oidc.strip_email_domain"),
-		},
+		OIDC:                  GetOIDCConfig("oidc"),
+		OIDCProviders:         GetOIDCProvidersConfig(),
+		OIDCRegisterRateLimit: viper.GetInt("oidc.register_rate_limit"),
 
 		LogTail:             logConfig,
 		RandomizeClientPort: randomizeClientPort,
@@ -581,6 +996,10 @@ func GetHeadscaleConfig() (*Config, error) {
 
 		ACL: GetACLConfig(),
 
+		RegistrationCache: GetRegistrationCacheConfig(),
+
+		Webhook: GetWebhookConfig(),
+
 		Log: GetLogConfig(),
 	}, nil
 }
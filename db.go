@@ -20,6 +20,14 @@ import (
 const (
 	dbVersion        = "1"
 	errValueNotFound = Error("not found")
+
+	// lastStateChangeKey holds the time of the last state change in KV, so
+	// that it survives a restart.
+	lastStateChangeKey = "last_state_change"
+
+	// aclPolicyKey holds the ACL policy in KV, with acl_policy_mode:
+	// database.
+	aclPolicyKey = "acl_policy"
 )
 
 // KV is a key-value store in a psql table. For future use...
@@ -3,17 +3,22 @@ package headscale
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v2"
 	"tailscale.com/tailcfg"
 )
 
+const errInvalidDERPMap = Error("invalid DERP map")
+
 func loadDERPMapFromPath(path string) (*tailcfg.DERPMap, error) {
 	derpFile, err := os.Open(path)
 	if err != nil {
@@ -133,6 +138,132 @@ func GetDERPMap(cfg DERPConfig) *tailcfg.DERPMap {
 	return derpMap
 }
 
+// loadCustomDERPMap reads and validates the custom DERP map, encoded in JSON
+// like the DERP maps served by the Tailscale control plane.
+func loadCustomDERPMap(path string) (*tailcfg.DERPMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var derpMap tailcfg.DERPMap
+	if err := json.Unmarshal(data, &derpMap); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidDERPMap, err)
+	}
+
+	if err := validateDERPMap(&derpMap); err != nil {
+		return nil, err
+	}
+
+	return &derpMap, nil
+}
+
+func validateDERPMap(derpMap *tailcfg.DERPMap) error {
+	if len(derpMap.Regions) == 0 {
+		return fmt.Errorf("%w: no region", errInvalidDERPMap)
+	}
+
+	for regionID, region := range derpMap.Regions {
+		if region == nil {
+			return fmt.Errorf("%w: region %d is empty", errInvalidDERPMap, regionID)
+		}
+
+		if region.RegionID != regionID {
+			return fmt.Errorf(
+				"%w: region %d has the region ID %d",
+				errInvalidDERPMap,
+				regionID,
+				region.RegionID,
+			)
+		}
+
+		if len(region.Nodes) == 0 {
+			return fmt.Errorf("%w: region %d has no node", errInvalidDERPMap, regionID)
+		}
+
+		for _, node := range region.Nodes {
+			if node == nil || node.Name == "" || node.HostName == "" {
+				return fmt.Errorf(
+					"%w: the nodes of region %d need a name and a host name",
+					errInvalidDERPMap,
+					regionID,
+				)
+			}
+
+			if node.RegionID != regionID {
+				return fmt.Errorf(
+					"%w: node %s of region %d has the region ID %d",
+					errInvalidDERPMap,
+					node.Name,
+					regionID,
+					node.RegionID,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyCustomDERPMap adds the regions of the custom DERP map to derpMap.
+// If the custom DERP map sets OmitDefaultRegions, its regions replace
+// the ones of derpMap, otherwise they prevail on colliding region IDs.
+func applyCustomDERPMap(derpMap, customDERPMap *tailcfg.DERPMap) *tailcfg.DERPMap {
+	if customDERPMap == nil {
+		return mergeDERPMaps([]*tailcfg.DERPMap{derpMap})
+	}
+
+	if customDERPMap.OmitDefaultRegions {
+		result := mergeDERPMaps([]*tailcfg.DERPMap{customDERPMap})
+		result.OmitDefaultRegions = true
+
+		return result
+	}
+
+	return mergeDERPMaps([]*tailcfg.DERPMap{derpMap, customDERPMap})
+}
+
+func (h *Headscale) getDERPMap() *tailcfg.DERPMap {
+	h.derpMapMutex.RLock()
+	defer h.derpMapMutex.RUnlock()
+
+	return h.DERPMap
+}
+
+// setSourcesDERPMap sets the DERP map loaded from derp.urls and derp.paths.
+func (h *Headscale) setSourcesDERPMap(derpMap *tailcfg.DERPMap) {
+	h.derpMapMutex.Lock()
+	defer h.derpMapMutex.Unlock()
+
+	h.sourcesDERPMap = derpMap
+	h.buildDERPMap()
+}
+
+func (h *Headscale) setCustomDERPMap(derpMap *tailcfg.DERPMap) {
+	h.derpMapMutex.Lock()
+	defer h.derpMapMutex.Unlock()
+
+	h.customDERPMap = derpMap
+	h.buildDERPMap()
+}
+
+// buildDERPMap rebuilds the DERP map sent to the clients from its sources.
+// The region of the embedded DERP server always prevails.
+// derpMapMutex must be held.
+func (h *Headscale) buildDERPMap() {
+	derpMap := h.sourcesDERPMap
+	if derpMap == nil {
+		derpMap = &tailcfg.DERPMap{}
+	}
+
+	derpMap = applyCustomDERPMap(derpMap, h.customDERPMap)
+	if h.cfg.DERP.ServerEnabled {
+		derpMap.Regions[h.DERPServer.region.RegionID] = &h.DERPServer.region
+	}
+
+	h.DERPMap = derpMap
+}
+
 func (h *Headscale) scheduledDERPMapUpdateWorker(cancelChan <-chan struct{}) {
 	log.Info().
 		Dur("frequency", h.cfg.DERP.UpdateFrequency).
@@ -146,12 +277,77 @@ func (h *Headscale) scheduledDERPMapUpdateWorker(cancelChan <-chan struct{}) {
 
 		case <-ticker.C:
 			log.Info().Msg("Fetching DERPMap updates")
-			h.DERPMap = GetDERPMap(h.cfg.DERP)
-			if h.cfg.DERP.ServerEnabled {
-				h.DERPMap.Regions[h.DERPServer.region.RegionID] = &h.DERPServer.region
-			}
+			h.setSourcesDERPMap(GetDERPMap(h.cfg.DERP))
 
 			h.setLastStateChangeToNow()
 		}
 	}
 }
+
+// watchCustomDERPMap reloads the custom DERP map when its file changes.
+// The directory of the file is watched rather than the file itself, so that
+// the files replaced by editors or by a rename are still followed.
+func (h *Headscale) watchCustomDERPMap() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Clean(h.cfg.DERP.CustomMapPath)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(event.Name) != path ||
+					event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+
+				h.reloadCustomDERPMap()
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Error().
+					Caller().
+					Err(err).
+					Str("path", path).
+					Msg("Error while watching the custom DERP map")
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
+func (h *Headscale) reloadCustomDERPMap() {
+	derpMap, err := loadCustomDERPMap(h.cfg.DERP.CustomMapPath)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("path", h.cfg.DERP.CustomMapPath).
+			Msg("Failed to reload the custom DERP map, keeping the previous one")
+
+		return
+	}
+
+	log.Info().
+		Str("path", h.cfg.DERP.CustomMapPath).
+		Int("regions", len(derpMap.Regions)).
+		Msg("Custom DERP map reloaded")
+
+	h.setCustomDERPMap(derpMap)
+	h.setLastStateChangeToNow()
+}
@@ -208,7 +208,7 @@ func (h *Headscale) DERPBootstrapDNSHandler(
 	resolvCtx, cancel := context.WithTimeout(req.Context(), time.Minute)
 	defer cancel()
 	var resolver net.Resolver
-	for _, region := range h.DERPMap.Regions {
+	for _, region := range h.getDERPMap().Regions {
 		for _, node := range region.Nodes { // we don't care if we override some nodes
 			addrs, err := resolver.LookupIP(resolvCtx, "ip", node.HostName)
 			if err != nil {
@@ -0,0 +1,157 @@
+package headscale
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+	"tailscale.com/tailcfg"
+)
+
+const customDERPMapJSON = `{
+  "Regions": {
+    "900": {
+      "RegionID": 900,
+      "RegionCode": "custom",
+      "RegionName": "Custom relay",
+      "Nodes": [
+        {
+          "Name": "900a",
+          "RegionID": 900,
+          "HostName": "derp.example.com"
+        }
+      ]
+    }
+  }
+}`
+
+func (s *Suite) TestLoadCustomDERPMap(c *check.C) {
+	dir := c.MkDir()
+
+	path := filepath.Join(dir, "derp.json")
+	err := os.WriteFile(path, []byte(customDERPMapJSON), 0o600)
+	c.Assert(err, check.IsNil)
+
+	derpMap, err := loadCustomDERPMap(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(derpMap.Regions, check.HasLen, 1)
+	c.Assert(derpMap.Regions[900].Nodes[0].HostName, check.Equals, "derp.example.com")
+
+	invalid := []string{
+		`not json`,
+		`{"Regions": {}}`,
+		`{"Regions": {"900": {"RegionID": 901, "Nodes": [{"Name": "900a", "RegionID": 900, "HostName": "derp.example.com"}]}}}`,
+		`{"Regions": {"900": {"RegionID": 900, "Nodes": []}}}`,
+		`{"Regions": {"900": {"RegionID": 900, "Nodes": [{"Name": "900a", "RegionID": 900}]}}}`,
+		`{"Regions": {"900": {"RegionID": 900, "Nodes": [{"Name": "900a", "RegionID": 1, "HostName": "derp.example.com"}]}}}`,
+	}
+	for _, content := range invalid {
+		err = os.WriteFile(path, []byte(content), 0o600)
+		c.Assert(err, check.IsNil)
+
+		_, err = loadCustomDERPMap(path)
+		c.Assert(errors.Is(err, errInvalidDERPMap), check.Equals, true, check.Commentf(content))
+	}
+}
+
+func (s *Suite) TestApplyCustomDERPMap(c *check.C) {
+	derpMap := &tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			1:   {RegionID: 1, RegionCode: "default"},
+			900: {RegionID: 900, RegionCode: "default"},
+		},
+	}
+	customDERPMap := &tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			900: {RegionID: 900, RegionCode: "custom"},
+		},
+	}
+
+	merged := applyCustomDERPMap(derpMap, customDERPMap)
+	c.Assert(merged.Regions, check.HasLen, 2)
+	c.Assert(merged.Regions[1].RegionCode, check.Equals, "default")
+	c.Assert(merged.Regions[900].RegionCode, check.Equals, "custom")
+	c.Assert(merged.OmitDefaultRegions, check.Equals, false)
+
+	customDERPMap.OmitDefaultRegions = true
+	replaced := applyCustomDERPMap(derpMap, customDERPMap)
+	c.Assert(replaced.Regions, check.HasLen, 1)
+	c.Assert(replaced.Regions[900].RegionCode, check.Equals, "custom")
+	c.Assert(replaced.OmitDefaultRegions, check.Equals, true)
+
+	// the sources are left untouched
+	c.Assert(derpMap.Regions, check.HasLen, 2)
+	c.Assert(applyCustomDERPMap(derpMap, nil).Regions, check.HasLen, 2)
+}
+
+func (s *Suite) TestReloadCustomDERPMap(c *check.C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "derp.json")
+	err := os.WriteFile(path, []byte(customDERPMapJSON), 0o600)
+	c.Assert(err, check.IsNil)
+
+	app.cfg.DERP.CustomMapPath = path
+	defer func() {
+		app.cfg.DERP.CustomMapPath = ""
+		app.setCustomDERPMap(nil)
+		app.setSourcesDERPMap(nil)
+	}()
+
+	app.setSourcesDERPMap(&tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			1: {RegionID: 1, RegionCode: "default"},
+		},
+	})
+	app.reloadCustomDERPMap()
+	c.Assert(app.getDERPMap().Regions, check.HasLen, 2)
+	c.Assert(app.getDERPMap().Regions[900].RegionCode, check.Equals, "custom")
+
+	// an invalid file keeps the previous custom DERP map
+	err = os.WriteFile(path, []byte(`{"Regions": {}}`), 0o600)
+	c.Assert(err, check.IsNil)
+	app.reloadCustomDERPMap()
+	c.Assert(app.getDERPMap().Regions, check.HasLen, 2)
+	c.Assert(app.getDERPMap().Regions[900].RegionCode, check.Equals, "custom")
+}
+
+func (s *Suite) TestEmbeddedDERPRegion(c *check.C) {
+	app.cfg.ServerURL = "https://headscale.example.com"
+	app.cfg.DERP.ServerEnabled = true
+	app.cfg.DERP.ServerRegionID = 900
+	app.cfg.DERP.ServerRegionCode = "headscale"
+	app.cfg.DERP.STUNAddr = "0.0.0.0:3478"
+	defer func() {
+		app.cfg.DERP = DERPConfig{}
+		app.DERPServer = nil
+		app.setCustomDERPMap(nil)
+		app.setSourcesDERPMap(nil)
+	}()
+
+	region, err := app.generateRegionLocalDERP()
+	c.Assert(err, check.IsNil)
+	c.Assert(region.RegionID, check.Equals, 900)
+	c.Assert(region.Nodes, check.HasLen, 1)
+	c.Assert(region.Nodes[0].HostName, check.Equals, "headscale.example.com")
+	c.Assert(region.Nodes[0].DERPPort, check.Equals, 443)
+	c.Assert(region.Nodes[0].STUNPort, check.Equals, 3478)
+
+	app.DERPServer = &DERPServer{region: region}
+
+	// the embedded region prevails on the colliding custom region
+	app.setCustomDERPMap(&tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			900: {RegionID: 900, RegionCode: "custom"},
+		},
+	})
+	app.setSourcesDERPMap(&tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			1: {RegionID: 1, RegionCode: "default"},
+		},
+	})
+
+	derpMap := app.getDERPMap()
+	c.Assert(derpMap.Regions, check.HasLen, 2)
+	c.Assert(derpMap.Regions[900].RegionCode, check.Equals, "headscale")
+	c.Assert(derpMap.Regions[900].Nodes[0].HostName, check.Equals, "headscale.example.com")
+}
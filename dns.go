@@ -3,9 +3,11 @@ package headscale
 import (
 	"fmt"
 	"net/netip"
+	"sort"
 	"strings"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/rs/zerolog/log"
 	"go4.org/netipx"
 	"tailscale.com/tailcfg"
 	"tailscale.com/util/dnsname"
@@ -20,6 +22,24 @@ const (
 	ipv6AddressLength = 128
 )
 
+const (
+	errDNSBaseDomainNotSet = Error("base_domain must be set to generate DNS records")
+)
+
+const (
+	dnsRecordTypeA    = "A"
+	dnsRecordTypeAAAA = "AAAA"
+	dnsRecordTypePTR  = "PTR"
+)
+
+// dnsRecord is a forward or reverse DNS record of a machine.
+type dnsRecord struct {
+	Name      string
+	Type      string
+	Value     string
+	MachineID uint64
+}
+
 // generateMagicDNSRootDomains generates a list of DNS entries to be included in `Routes` in `MapResponse`.
 // This list of reverse DNS entries instructs the OS on what subnets and domains the Tailscale embedded DNS
 // server (listening in 100.100.100.100 udp/53) should be used for.
@@ -160,24 +180,27 @@ func getMapResponseDNSConfig(
 ) *tailcfg.DNSConfig {
 	var dnsConfig *tailcfg.DNSConfig
 	if dnsConfigOrig != nil && dnsConfigOrig.Proxied { // if MagicDNS is enabled
-		// Only inject the Search Domain of the current namespace - shared nodes should use their full FQDN
+		// Only inject the Search Domain of the current namespace - shared nodes should use their full FQDN.
+		// It comes first, so that the short names resolve within the namespace.
 		dnsConfig = dnsConfigOrig.Clone()
 		dnsConfig.Domains = append(
-			dnsConfig.Domains,
-			fmt.Sprintf(
-				"%s.%s",
-				machine.Namespace.Name,
-				baseDomain,
-			),
+			[]string{
+				fmt.Sprintf(
+					"%s.%s",
+					machine.Namespace.Name,
+					baseDomain,
+				),
+			},
+			dnsConfig.Domains...,
 		)
 
-		namespaceSet := mapset.NewSet[Namespace]()
-		namespaceSet.Add(machine.Namespace)
+		namespaceSet := mapset.NewSet[string]()
+		namespaceSet.Add(machine.Namespace.Name)
 		for _, p := range peers {
-			namespaceSet.Add(p.Namespace)
+			namespaceSet.Add(p.Namespace.Name)
 		}
 		for _, namespace := range namespaceSet.ToSlice() {
-			dnsRoute := fmt.Sprintf("%v.%v", namespace.Name, baseDomain)
+			dnsRoute := fmt.Sprintf("%v.%v", namespace, baseDomain)
 			dnsConfig.Routes[dnsRoute] = nil
 		}
 	} else {
@@ -186,3 +209,181 @@ func getMapResponseDNSConfig(
 
 	return dnsConfig
 }
+
+// magicDNSNames returns the MagicDNS name of the machines, by machine ID.
+// The machines of a namespace sharing a GivenName are told apart with a
+// numeric suffix, given in the order of their IDs, so that the names do not
+// depend on the order of the machines.
+func magicDNSNames(machines []Machine, baseDomain string) map[uint64]string {
+	sorted := make([]Machine, len(machines))
+	copy(sorted, machines)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	names := make(map[uint64]string, len(sorted))
+	taken := make(map[string]bool, len(sorted))
+	for _, machine := range sorted {
+		name := machine.magicDNSName(baseDomain)
+		for suffix := 1; taken[name]; suffix++ {
+			name = fmt.Sprintf(
+				"%s-%d.%s.%s",
+				machine.GivenName,
+				suffix,
+				machine.Namespace.Name,
+				baseDomain,
+			)
+		}
+
+		taken[name] = true
+		names[machine.ID] = name
+	}
+
+	return names
+}
+
+// getMagicDNSRecords returns the A and AAAA records of the machine and of
+// its peers in the same namespace, resolved by the MagicDNS of the client.
+func (h *Headscale) getMagicDNSRecords(
+	machine Machine,
+	peers Machines,
+) ([]tailcfg.DNSRecord, error) {
+	// The names are computed over the whole namespace, so that the
+	// suffixes are the same whatever the peers the ACLs let a client see.
+	namespaceMachines := []Machine{}
+	if err := h.db.Where("namespace_id = ?", machine.NamespaceID).
+		Find(&namespaceMachines).Error; err != nil {
+		return nil, err
+	}
+	for index := range namespaceMachines {
+		namespaceMachines[index].Namespace = machine.Namespace
+	}
+	names := magicDNSNames(namespaceMachines, h.cfg.BaseDomain)
+
+	visible := append(Machines{machine}, peers...)
+	sort.Slice(visible, func(i, j int) bool { return visible[i].ID < visible[j].ID })
+
+	records := []tailcfg.DNSRecord{}
+	for _, visibleMachine := range visible {
+		if visibleMachine.NamespaceID != machine.NamespaceID {
+			continue
+		}
+
+		name, ok := names[visibleMachine.ID]
+		if !ok || len(name) > maxHostnameLength {
+			continue
+		}
+
+		for _, ip := range visibleMachine.IPAddresses {
+			records = append(records, tailcfg.DNSRecord{
+				Name:  name,
+				Type:  dnsRecordType(ip),
+				Value: ip.String(),
+			})
+		}
+	}
+
+	return records, nil
+}
+
+func dnsRecordType(ip netip.Addr) string {
+	if ip.Is6() {
+		return dnsRecordTypeAAAA
+	}
+
+	return dnsRecordTypeA
+}
+
+// generateDNSRecords computes the forward (A/AAAA) and reverse (PTR) records
+// of every machine, using the same names as MagicDNS, so they can be exported
+// to an external resolver.
+func (h *Headscale) generateDNSRecords() ([]dnsRecord, error) {
+	if h.cfg.BaseDomain == "" {
+		return nil, errDNSBaseDomainNotSet
+	}
+
+	machines, err := h.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(machines, func(i, j int) bool { return machines[i].ID < machines[j].ID })
+
+	names := magicDNSNames(machines, h.cfg.BaseDomain)
+
+	records := []dnsRecord{}
+	for _, machine := range machines {
+		name := names[machine.ID]
+		if len(name) > maxHostnameLength {
+			log.Warn().
+				Str("func", "generateDNSRecords").
+				Str("machine", machine.Hostname).
+				Msg("Skipping machine with a hostname too long for DNS")
+
+			continue
+		}
+
+		fqdn, err := dnsname.ToFQDN(name)
+		if err != nil {
+			log.Warn().
+				Str("func", "generateDNSRecords").
+				Str("machine", machine.Hostname).
+				Err(err).
+				Msg("Skipping machine with an invalid DNS name")
+
+			continue
+		}
+
+		for _, ip := range machine.IPAddresses {
+			records = append(records,
+				dnsRecord{
+					Name:      fqdn.WithTrailingDot(),
+					Type:      dnsRecordType(ip),
+					Value:     ip.String(),
+					MachineID: machine.ID,
+				},
+				dnsRecord{
+					Name:      reverseDNSName(ip),
+					Type:      dnsRecordTypePTR,
+					Value:     fqdn.WithTrailingDot(),
+					MachineID: machine.ID,
+				},
+			)
+		}
+	}
+
+	return records, nil
+}
+
+// reverseDNSName returns the in-addr.arpa. or ip6.arpa. name of an address.
+func reverseDNSName(ip netip.Addr) string {
+	var builder strings.Builder
+
+	if ip.Is4() {
+		octets := ip.As4()
+		for i := len(octets) - 1; i >= 0; i-- {
+			fmt.Fprintf(&builder, "%d.", octets[i])
+		}
+		builder.WriteString("in-addr.arpa.")
+
+		return builder.String()
+	}
+
+	bytes := ip.As16()
+	for i := len(bytes) - 1; i >= 0; i-- {
+		fmt.Fprintf(&builder, "%x.%x.", bytes[i]&0xf, bytes[i]>>4)
+	}
+	builder.WriteString("ip6.arpa.")
+
+	return builder.String()
+}
+
+// dnsRecordsToZoneFile renders the records in the RFC 1035 zone file format.
+func dnsRecordsToZoneFile(records []dnsRecord) string {
+	var builder strings.Builder
+
+	builder.WriteString("; Generated by headscale\n")
+	for _, record := range records {
+		fmt.Fprintf(&builder, "%s\tIN\t%s\t%s\n", record.Name, record.Type, record.Value)
+	}
+
+	return builder.String()
+}
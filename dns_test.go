@@ -3,6 +3,7 @@ package headscale
 import (
 	"fmt"
 	"net/netip"
+	"strings"
 
 	"gopkg.in/check.v1"
 	"tailscale.com/tailcfg"
@@ -126,6 +127,10 @@ func (s *Suite) TestDNSConfigMapResponseWithMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -134,6 +139,10 @@ func (s *Suite) TestDNSConfigMapResponseWithMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -142,6 +151,10 @@ func (s *Suite) TestDNSConfigMapResponseWithMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -150,6 +163,10 @@ func (s *Suite) TestDNSConfigMapResponseWithMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -241,6 +258,28 @@ func (s *Suite) TestDNSConfigMapResponseWithMagicDNS(c *check.C) {
 
 	c.Assert(len(dnsConfig.Routes), check.Equals, 3)
 
+	// the search domain of the namespace comes first, the other namespaces
+	// reached through shared machines get no search domain
+	c.Assert(dnsConfig.Domains, check.DeepEquals, []string{
+		fmt.Sprintf("%s.%s", namespaceShared1.Name, baseDomain),
+		baseDomain,
+	})
+	c.Assert(dnsConfigOrig.Domains, check.DeepEquals, []string{baseDomain})
+
+	peersOfMachineInShared2, err := app.getPeers(machineInShared2)
+	c.Assert(err, check.IsNil)
+
+	dnsConfigShared2 := getMapResponseDNSConfig(
+		&dnsConfigOrig,
+		baseDomain,
+		*machineInShared2,
+		peersOfMachineInShared2,
+	)
+	c.Assert(dnsConfigShared2.Domains, check.DeepEquals, []string{
+		fmt.Sprintf("%s.%s", namespaceShared2.Name, baseDomain),
+		baseDomain,
+	})
+
 	domainRouteShared1 := fmt.Sprintf("%s.%s", namespaceShared1.Name, baseDomain)
 	_, ok := dnsConfig.Routes[domainRouteShared1]
 	c.Assert(ok, check.Equals, true)
@@ -269,6 +308,10 @@ func (s *Suite) TestDNSConfigMapResponseWithoutMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -277,6 +320,10 @@ func (s *Suite) TestDNSConfigMapResponseWithoutMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -285,6 +332,10 @@ func (s *Suite) TestDNSConfigMapResponseWithoutMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -293,6 +344,10 @@ func (s *Suite) TestDNSConfigMapResponseWithoutMagicDNS(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -384,3 +439,157 @@ func (s *Suite) TestDNSConfigMapResponseWithoutMagicDNS(c *check.C) {
 	c.Assert(len(dnsConfig.Routes), check.Equals, 0)
 	c.Assert(len(dnsConfig.Domains), check.Equals, 1)
 }
+
+func (s *Suite) TestReverseDNSName(c *check.C) {
+	c.Assert(
+		reverseDNSName(netip.MustParseAddr("100.64.0.1")),
+		check.Equals,
+		"1.0.64.100.in-addr.arpa.",
+	)
+	c.Assert(
+		reverseDNSName(netip.MustParseAddr("fd7a:115c:a1e0::1")),
+		check.Equals,
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.e.1.a.c.5.1.1.a.7.d.f.ip6.arpa.",
+	)
+}
+
+func (s *Suite) TestGenerateDNSRecords(c *check.C) {
+	_, err := app.generateDNSRecords()
+	c.Assert(err, check.Equals, errDNSBaseDomainNotSet)
+
+	app.cfg.BaseDomain = "example.com"
+	defer func() { app.cfg.BaseDomain = "" }()
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:          1,
+		MachineKey:  "foo",
+		NodeKey:     "bar",
+		DiscoKey:    "faa",
+		Hostname:    "test-machine",
+		GivenName:   "renamed",
+		NamespaceID: namespace.ID,
+		IPAddresses: MachineAddresses{
+			netip.MustParseAddr("100.64.0.1"),
+			netip.MustParseAddr("fd7a:115c:a1e0::1"),
+		},
+	}
+	app.db.Save(&machine)
+
+	records, err := app.generateDNSRecords()
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.DeepEquals, []dnsRecord{
+		{
+			Name:      "renamed.test.example.com.",
+			Type:      dnsRecordTypeA,
+			Value:     "100.64.0.1",
+			MachineID: 1,
+		},
+		{
+			Name:      "1.0.64.100.in-addr.arpa.",
+			Type:      dnsRecordTypePTR,
+			Value:     "renamed.test.example.com.",
+			MachineID: 1,
+		},
+		{
+			Name:      "renamed.test.example.com.",
+			Type:      dnsRecordTypeAAAA,
+			Value:     "fd7a:115c:a1e0::1",
+			MachineID: 1,
+		},
+		{
+			Name:      "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.e.1.a.c.5.1.1.a.7.d.f.ip6.arpa.",
+			Type:      dnsRecordTypePTR,
+			Value:     "renamed.test.example.com.",
+			MachineID: 1,
+		},
+	})
+
+	zone := dnsRecordsToZoneFile(records)
+	c.Assert(
+		strings.Contains(zone, "renamed.test.example.com.\tIN\tA\t100.64.0.1\n"),
+		check.Equals,
+		true,
+	)
+}
+
+func (s *Suite) TestMagicDNSNames(c *check.C) {
+	namespace := Namespace{Name: "test"}
+	machines := []Machine{
+		{ID: 3, GivenName: "laptop", Namespace: namespace},
+		{ID: 1, GivenName: "laptop", Namespace: namespace},
+		{ID: 2, GivenName: "laptop", Namespace: namespace},
+		{ID: 4, GivenName: "laptop", Namespace: Namespace{Name: "other"}},
+		{ID: 5, GivenName: "desktop", Namespace: namespace},
+	}
+
+	names := magicDNSNames(machines, "example.com")
+	c.Assert(names, check.DeepEquals, map[uint64]string{
+		1: "laptop.test.example.com",
+		2: "laptop-1.test.example.com",
+		3: "laptop-2.test.example.com",
+		4: "laptop.other.example.com",
+		5: "desktop.test.example.com",
+	})
+}
+
+func (s *Suite) TestGetMagicDNSRecords(c *check.C) {
+	app.cfg.BaseDomain = "example.com"
+	defer func() { app.cfg.BaseDomain = "" }()
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+	other, err := app.CreateNamespace("other")
+	c.Assert(err, check.IsNil)
+
+	machines := []Machine{
+		{
+			ID:          1,
+			GivenName:   "laptop",
+			NamespaceID: namespace.ID,
+			Namespace:   *namespace,
+			IPAddresses: MachineAddresses{
+				netip.MustParseAddr("100.64.0.1"),
+				netip.MustParseAddr("fd7a:115c:a1e0::1"),
+			},
+		},
+		{
+			ID:          2,
+			GivenName:   "laptop",
+			NamespaceID: namespace.ID,
+			Namespace:   *namespace,
+			IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.2")},
+		},
+		{
+			ID:          3,
+			GivenName:   "shared",
+			NamespaceID: other.ID,
+			Namespace:   *other,
+			IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.3")},
+		},
+	}
+	for index := range machines {
+		machines[index].MachineKey = fmt.Sprintf("machine-key-%d", index)
+		machines[index].NodeKey = fmt.Sprintf("node-key-%d", index)
+		machines[index].DiscoKey = fmt.Sprintf("disco-key-%d", index)
+		app.db.Save(&machines[index])
+	}
+
+	// the peers of another namespace keep their own names and are left out
+	records, err := app.getMagicDNSRecords(machines[1], Machines{machines[2], machines[0]})
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.DeepEquals, []tailcfg.DNSRecord{
+		{Name: "laptop.test.example.com", Type: dnsRecordTypeA, Value: "100.64.0.1"},
+		{Name: "laptop.test.example.com", Type: dnsRecordTypeAAAA, Value: "fd7a:115c:a1e0::1"},
+		{Name: "laptop-1.test.example.com", Type: dnsRecordTypeA, Value: "100.64.0.2"},
+	})
+
+	// the suffixes do not depend on the peers visible to the client
+	records, err = app.getMagicDNSRecords(machines[1], Machines{})
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.DeepEquals, []tailcfg.DNSRecord{
+		{Name: "laptop-1.test.example.com", Type: dnsRecordTypeA, Value: "100.64.0.2"},
+	})
+}
@@ -0,0 +1,997 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: headscale/v1/acl.proto
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ACLPolicyError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Message  string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	AclIndex int32  `protobuf:"varint,2,opt,name=acl_index,json=aclIndex,proto3" json:"acl_index,omitempty"`
+	Field    string `protobuf:"bytes,3,opt,name=field,proto3" json:"field,omitempty"`
+}
+
+func (x *ACLPolicyError) Reset() {
+	*x = ACLPolicyError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ACLPolicyError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLPolicyError) ProtoMessage() {}
+
+func (x *ACLPolicyError) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLPolicyError.ProtoReflect.Descriptor instead.
+func (*ACLPolicyError) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ACLPolicyError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ACLPolicyError) GetAclIndex() int32 {
+	if x != nil {
+		return x.AclIndex
+	}
+	return 0
+}
+
+func (x *ACLPolicyError) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+type CheckACLPolicyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Policy []byte `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+}
+
+func (x *CheckACLPolicyRequest) Reset() {
+	*x = CheckACLPolicyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CheckACLPolicyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckACLPolicyRequest) ProtoMessage() {}
+
+func (x *CheckACLPolicyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckACLPolicyRequest.ProtoReflect.Descriptor instead.
+func (*CheckACLPolicyRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CheckACLPolicyRequest) GetPolicy() []byte {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type CheckACLPolicyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Valid     bool            `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	RuleCount uint64          `protobuf:"varint,2,opt,name=rule_count,json=ruleCount,proto3" json:"rule_count,omitempty"`
+	Warnings  []string        `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	Error     *ACLPolicyError `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *CheckACLPolicyResponse) Reset() {
+	*x = CheckACLPolicyResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CheckACLPolicyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckACLPolicyResponse) ProtoMessage() {}
+
+func (x *CheckACLPolicyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckACLPolicyResponse.ProtoReflect.Descriptor instead.
+func (*CheckACLPolicyResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CheckACLPolicyResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *CheckACLPolicyResponse) GetRuleCount() uint64 {
+	if x != nil {
+		return x.RuleCount
+	}
+	return 0
+}
+
+func (x *CheckACLPolicyResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *CheckACLPolicyResponse) GetError() *ACLPolicyError {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+type ACLDestination struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ip        string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	FirstPort uint32 `protobuf:"varint,2,opt,name=first_port,json=firstPort,proto3" json:"first_port,omitempty"`
+	LastPort  uint32 `protobuf:"varint,3,opt,name=last_port,json=lastPort,proto3" json:"last_port,omitempty"`
+}
+
+func (x *ACLDestination) Reset() {
+	*x = ACLDestination{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ACLDestination) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLDestination) ProtoMessage() {}
+
+func (x *ACLDestination) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLDestination.ProtoReflect.Descriptor instead.
+func (*ACLDestination) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ACLDestination) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *ACLDestination) GetFirstPort() uint32 {
+	if x != nil {
+		return x.FirstPort
+	}
+	return 0
+}
+
+func (x *ACLDestination) GetLastPort() uint32 {
+	if x != nil {
+		return x.LastPort
+	}
+	return 0
+}
+
+type ACLRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SrcIps   []string          `protobuf:"bytes,1,rep,name=src_ips,json=srcIps,proto3" json:"src_ips,omitempty"`
+	DstPorts []*ACLDestination `protobuf:"bytes,2,rep,name=dst_ports,json=dstPorts,proto3" json:"dst_ports,omitempty"`
+	IpProto  []int32           `protobuf:"varint,3,rep,packed,name=ip_proto,json=ipProto,proto3" json:"ip_proto,omitempty"`
+	AclIndex int32             `protobuf:"varint,4,opt,name=acl_index,json=aclIndex,proto3" json:"acl_index,omitempty"`
+}
+
+func (x *ACLRule) Reset() {
+	*x = ACLRule{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ACLRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLRule) ProtoMessage() {}
+
+func (x *ACLRule) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLRule.ProtoReflect.Descriptor instead.
+func (*ACLRule) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ACLRule) GetSrcIps() []string {
+	if x != nil {
+		return x.SrcIps
+	}
+	return nil
+}
+
+func (x *ACLRule) GetDstPorts() []*ACLDestination {
+	if x != nil {
+		return x.DstPorts
+	}
+	return nil
+}
+
+func (x *ACLRule) GetIpProto() []int32 {
+	if x != nil {
+		return x.IpProto
+	}
+	return nil
+}
+
+func (x *ACLRule) GetAclIndex() int32 {
+	if x != nil {
+		return x.AclIndex
+	}
+	return 0
+}
+
+type GetACLRulesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetACLRulesRequest) Reset() {
+	*x = GetACLRulesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetACLRulesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetACLRulesRequest) ProtoMessage() {}
+
+func (x *GetACLRulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetACLRulesRequest.ProtoReflect.Descriptor instead.
+func (*GetACLRulesRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{5}
+}
+
+type GetACLRulesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rules []*ACLRule `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *GetACLRulesResponse) Reset() {
+	*x = GetACLRulesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetACLRulesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetACLRulesResponse) ProtoMessage() {}
+
+func (x *GetACLRulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetACLRulesResponse.ProtoReflect.Descriptor instead.
+func (*GetACLRulesResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetACLRulesResponse) GetRules() []*ACLRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type GetACLPolicyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetACLPolicyRequest) Reset() {
+	*x = GetACLPolicyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetACLPolicyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetACLPolicyRequest) ProtoMessage() {}
+
+func (x *GetACLPolicyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetACLPolicyRequest.ProtoReflect.Descriptor instead.
+func (*GetACLPolicyRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{7}
+}
+
+type GetACLPolicyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Policy []byte `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+}
+
+func (x *GetACLPolicyResponse) Reset() {
+	*x = GetACLPolicyResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetACLPolicyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetACLPolicyResponse) ProtoMessage() {}
+
+func (x *GetACLPolicyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetACLPolicyResponse.ProtoReflect.Descriptor instead.
+func (*GetACLPolicyResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetACLPolicyResponse) GetPolicy() []byte {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type SetACLPolicyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Policy []byte `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+}
+
+func (x *SetACLPolicyRequest) Reset() {
+	*x = SetACLPolicyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetACLPolicyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetACLPolicyRequest) ProtoMessage() {}
+
+func (x *SetACLPolicyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetACLPolicyRequest.ProtoReflect.Descriptor instead.
+func (*SetACLPolicyRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SetACLPolicyRequest) GetPolicy() []byte {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type SetACLPolicyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Warnings []string `protobuf:"bytes,1,rep,name=warnings,proto3" json:"warnings,omitempty"`
+}
+
+func (x *SetACLPolicyResponse) Reset() {
+	*x = SetACLPolicyResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetACLPolicyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetACLPolicyResponse) ProtoMessage() {}
+
+func (x *SetACLPolicyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetACLPolicyResponse.ProtoReflect.Descriptor instead.
+func (*SetACLPolicyResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SetACLPolicyResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type PreviewMachineRulesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MachineId uint64 `protobuf:"varint,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+}
+
+func (x *PreviewMachineRulesRequest) Reset() {
+	*x = PreviewMachineRulesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PreviewMachineRulesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviewMachineRulesRequest) ProtoMessage() {}
+
+func (x *PreviewMachineRulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviewMachineRulesRequest.ProtoReflect.Descriptor instead.
+func (*PreviewMachineRulesRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PreviewMachineRulesRequest) GetMachineId() uint64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+type PreviewMachineRulesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IpAddresses []string   `protobuf:"bytes,1,rep,name=ip_addresses,json=ipAddresses,proto3" json:"ip_addresses,omitempty"`
+	Rules       []*ACLRule `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *PreviewMachineRulesResponse) Reset() {
+	*x = PreviewMachineRulesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_acl_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PreviewMachineRulesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviewMachineRulesResponse) ProtoMessage() {}
+
+func (x *PreviewMachineRulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_acl_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviewMachineRulesResponse.ProtoReflect.Descriptor instead.
+func (*PreviewMachineRulesResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_acl_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PreviewMachineRulesResponse) GetIpAddresses() []string {
+	if x != nil {
+		return x.IpAddresses
+	}
+	return nil
+}
+
+func (x *PreviewMachineRulesResponse) GetRules() []*ACLRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+var File_headscale_v1_acl_proto protoreflect.FileDescriptor
+
+var file_headscale_v1_acl_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2f, 0x76, 0x31, 0x2f, 0x61,
+	0x63, 0x6c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63,
+	0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x22, 0x5d, 0x0a, 0x0e, 0x41, 0x43, 0x4c, 0x50, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x63, 0x6c, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x61, 0x63, 0x6c, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12,
+	0x14, 0x0a, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x66, 0x69, 0x65, 0x6c, 0x64, 0x22, 0x2f, 0x0a, 0x15, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x41, 0x43,
+	0x4c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16,
+	0x0a, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06,
+	0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x22, 0x9d, 0x01, 0x0a, 0x16, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x41, 0x43, 0x4c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x75, 0x6c, 0x65, 0x5f,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x72, 0x75, 0x6c,
+	0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e,
+	0x67, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e,
+	0x67, 0x73, 0x12, 0x32, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1c, 0x2e, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31,
+	0x2e, 0x41, 0x43, 0x4c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52,
+	0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x5c, 0x0a, 0x0e, 0x41, 0x43, 0x4c, 0x44, 0x65, 0x73,
+	0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x70, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x70, 0x12, 0x1d, 0x0a, 0x0a, 0x66, 0x69, 0x72, 0x73,
+	0x74, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x66, 0x69,
+	0x72, 0x73, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x5f,
+	0x70, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x6c, 0x61, 0x73, 0x74,
+	0x50, 0x6f, 0x72, 0x74, 0x22, 0x95, 0x01, 0x0a, 0x07, 0x41, 0x43, 0x4c, 0x52, 0x75, 0x6c, 0x65,
+	0x12, 0x17, 0x0a, 0x07, 0x73, 0x72, 0x63, 0x5f, 0x69, 0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x72, 0x63, 0x49, 0x70, 0x73, 0x12, 0x39, 0x0a, 0x09, 0x64, 0x73, 0x74,
+	0x5f, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x68,
+	0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x43, 0x4c, 0x44,
+	0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x64, 0x73, 0x74, 0x50,
+	0x6f, 0x72, 0x74, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x70, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x05, 0x52, 0x07, 0x69, 0x70, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x1b, 0x0a, 0x09, 0x61, 0x63, 0x6c, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x08, 0x61, 0x63, 0x6c, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x22, 0x14, 0x0a, 0x12,
+	0x47, 0x65, 0x74, 0x41, 0x43, 0x4c, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x22, 0x42, 0x0a, 0x13, 0x47, 0x65, 0x74, 0x41, 0x43, 0x4c, 0x52, 0x75, 0x6c, 0x65,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x05, 0x72, 0x75, 0x6c,
+	0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x68, 0x65, 0x61, 0x64, 0x73,
+	0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x43, 0x4c, 0x52, 0x75, 0x6c, 0x65, 0x52,
+	0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x22, 0x15, 0x0a, 0x13, 0x47, 0x65, 0x74, 0x41, 0x43, 0x4c,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x2e, 0x0a,
+	0x14, 0x47, 0x65, 0x74, 0x41, 0x43, 0x4c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x22, 0x2d, 0x0a,
+	0x13, 0x53, 0x65, 0x74, 0x41, 0x43, 0x4c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x22, 0x32, 0x0a, 0x14,
+	0x53, 0x65, 0x74, 0x41, 0x43, 0x4c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73,
+	0x22, 0x3b, 0x0a, 0x1a, 0x50, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77, 0x4d, 0x61, 0x63, 0x68, 0x69,
+	0x6e, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d,
+	0x0a, 0x0a, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x09, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x49, 0x64, 0x22, 0x6d, 0x0a,
+	0x1b, 0x50, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x52,
+	0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x21, 0x0a, 0x0c,
+	0x69, 0x70, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0b, 0x69, 0x70, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12,
+	0x2b, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15,
+	0x2e, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x43,
+	0x4c, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x42, 0x29, 0x5a, 0x27,
+	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x75, 0x61, 0x6e, 0x66,
+	0x6f, 0x6e, 0x74, 0x2f, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2f, 0x67, 0x65,
+	0x6e, 0x2f, 0x67, 0x6f, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_headscale_v1_acl_proto_rawDescOnce sync.Once
+	file_headscale_v1_acl_proto_rawDescData = file_headscale_v1_acl_proto_rawDesc
+)
+
+func file_headscale_v1_acl_proto_rawDescGZIP() []byte {
+	file_headscale_v1_acl_proto_rawDescOnce.Do(func() {
+		file_headscale_v1_acl_proto_rawDescData = protoimpl.X.CompressGZIP(file_headscale_v1_acl_proto_rawDescData)
+	})
+	return file_headscale_v1_acl_proto_rawDescData
+}
+
+var file_headscale_v1_acl_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_headscale_v1_acl_proto_goTypes = []interface{}{
+	(*ACLPolicyError)(nil),              // 0: headscale.v1.ACLPolicyError
+	(*CheckACLPolicyRequest)(nil),       // 1: headscale.v1.CheckACLPolicyRequest
+	(*CheckACLPolicyResponse)(nil),      // 2: headscale.v1.CheckACLPolicyResponse
+	(*ACLDestination)(nil),              // 3: headscale.v1.ACLDestination
+	(*ACLRule)(nil),                     // 4: headscale.v1.ACLRule
+	(*GetACLRulesRequest)(nil),          // 5: headscale.v1.GetACLRulesRequest
+	(*GetACLRulesResponse)(nil),         // 6: headscale.v1.GetACLRulesResponse
+	(*GetACLPolicyRequest)(nil),         // 7: headscale.v1.GetACLPolicyRequest
+	(*GetACLPolicyResponse)(nil),        // 8: headscale.v1.GetACLPolicyResponse
+	(*SetACLPolicyRequest)(nil),         // 9: headscale.v1.SetACLPolicyRequest
+	(*SetACLPolicyResponse)(nil),        // 10: headscale.v1.SetACLPolicyResponse
+	(*PreviewMachineRulesRequest)(nil),  // 11: headscale.v1.PreviewMachineRulesRequest
+	(*PreviewMachineRulesResponse)(nil), // 12: headscale.v1.PreviewMachineRulesResponse
+}
+var file_headscale_v1_acl_proto_depIdxs = []int32{
+	0, // 0: headscale.v1.CheckACLPolicyResponse.error:type_name -> headscale.v1.ACLPolicyError
+	3, // 1: headscale.v1.ACLRule.dst_ports:type_name -> headscale.v1.ACLDestination
+	4, // 2: headscale.v1.GetACLRulesResponse.rules:type_name -> headscale.v1.ACLRule
+	4, // 3: headscale.v1.PreviewMachineRulesResponse.rules:type_name -> headscale.v1.ACLRule
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_headscale_v1_acl_proto_init() }
+func file_headscale_v1_acl_proto_init() {
+	if File_headscale_v1_acl_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_headscale_v1_acl_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ACLPolicyError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CheckACLPolicyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CheckACLPolicyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ACLDestination); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ACLRule); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetACLRulesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetACLRulesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetACLPolicyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetACLPolicyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetACLPolicyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetACLPolicyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PreviewMachineRulesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_acl_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PreviewMachineRulesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_headscale_v1_acl_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_headscale_v1_acl_proto_goTypes,
+		DependencyIndexes: file_headscale_v1_acl_proto_depIdxs,
+		MessageInfos:      file_headscale_v1_acl_proto_msgTypes,
+	}.Build()
+	File_headscale_v1_acl_proto = out.File
+	file_headscale_v1_acl_proto_rawDesc = nil
+	file_headscale_v1_acl_proto_goTypes = nil
+	file_headscale_v1_acl_proto_depIdxs = nil
+}
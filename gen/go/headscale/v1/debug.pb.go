@@ -0,0 +1,1035 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: headscale/v1/debug.proto
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DebugNotifierClient struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MachineId            uint64                 `protobuf:"varint,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	Name                 string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Namespace            string                 `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	LastSuccessfulUpdate *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_successful_update,json=lastSuccessfulUpdate,proto3" json:"last_successful_update,omitempty"`
+	UpdateLag            *durationpb.Duration   `protobuf:"bytes,5,opt,name=update_lag,json=updateLag,proto3" json:"update_lag,omitempty"`
+	Outdated             bool                   `protobuf:"varint,6,opt,name=outdated,proto3" json:"outdated,omitempty"`
+}
+
+func (x *DebugNotifierClient) Reset() {
+	*x = DebugNotifierClient{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugNotifierClient) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugNotifierClient) ProtoMessage() {}
+
+func (x *DebugNotifierClient) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugNotifierClient.ProtoReflect.Descriptor instead.
+func (*DebugNotifierClient) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DebugNotifierClient) GetMachineId() uint64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+func (x *DebugNotifierClient) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DebugNotifierClient) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *DebugNotifierClient) GetLastSuccessfulUpdate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSuccessfulUpdate
+	}
+	return nil
+}
+
+func (x *DebugNotifierClient) GetUpdateLag() *durationpb.Duration {
+	if x != nil {
+		return x.UpdateLag
+	}
+	return nil
+}
+
+func (x *DebugNotifierClient) GetOutdated() bool {
+	if x != nil {
+		return x.Outdated
+	}
+	return false
+}
+
+type DebugNotifierStateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DebugNotifierStateRequest) Reset() {
+	*x = DebugNotifierStateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugNotifierStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugNotifierStateRequest) ProtoMessage() {}
+
+func (x *DebugNotifierStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugNotifierStateRequest.ProtoReflect.Descriptor instead.
+func (*DebugNotifierStateRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{1}
+}
+
+type DebugNotifierStateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SubscribedClients uint32                 `protobuf:"varint,1,opt,name=subscribed_clients,json=subscribedClients,proto3" json:"subscribed_clients,omitempty"`
+	LastStateChange   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=last_state_change,json=lastStateChange,proto3" json:"last_state_change,omitempty"`
+	Clients           []*DebugNotifierClient `protobuf:"bytes,3,rep,name=clients,proto3" json:"clients,omitempty"`
+}
+
+func (x *DebugNotifierStateResponse) Reset() {
+	*x = DebugNotifierStateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugNotifierStateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugNotifierStateResponse) ProtoMessage() {}
+
+func (x *DebugNotifierStateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugNotifierStateResponse.ProtoReflect.Descriptor instead.
+func (*DebugNotifierStateResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DebugNotifierStateResponse) GetSubscribedClients() uint32 {
+	if x != nil {
+		return x.SubscribedClients
+	}
+	return 0
+}
+
+func (x *DebugNotifierStateResponse) GetLastStateChange() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastStateChange
+	}
+	return nil
+}
+
+func (x *DebugNotifierStateResponse) GetClients() []*DebugNotifierClient {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+type GetDebugInfoRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetDebugInfoRequest) Reset() {
+	*x = GetDebugInfoRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetDebugInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDebugInfoRequest) ProtoMessage() {}
+
+func (x *GetDebugInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDebugInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetDebugInfoRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{3}
+}
+
+type GetDebugInfoResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastStateChange *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=last_state_change,json=lastStateChange,proto3" json:"last_state_change,omitempty"`
+	UpdateChannels  uint32                 `protobuf:"varint,2,opt,name=update_channels,json=updateChannels,proto3" json:"update_channels,omitempty"`
+	Machines        []*DebugNotifierClient `protobuf:"bytes,3,rep,name=machines,proto3" json:"machines,omitempty"`
+}
+
+func (x *GetDebugInfoResponse) Reset() {
+	*x = GetDebugInfoResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetDebugInfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDebugInfoResponse) ProtoMessage() {}
+
+func (x *GetDebugInfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDebugInfoResponse.ProtoReflect.Descriptor instead.
+func (*GetDebugInfoResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetDebugInfoResponse) GetLastStateChange() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastStateChange
+	}
+	return nil
+}
+
+func (x *GetDebugInfoResponse) GetUpdateChannels() uint32 {
+	if x != nil {
+		return x.UpdateChannels
+	}
+	return 0
+}
+
+func (x *GetDebugInfoResponse) GetMachines() []*DebugNotifierClient {
+	if x != nil {
+		return x.Machines
+	}
+	return nil
+}
+
+type DebugPeerReason struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	AclIndex     int32    `protobuf:"varint,1,opt,name=acl_index,json=aclIndex,proto3" json:"acl_index,omitempty"`
+	Sources      []string `protobuf:"bytes,2,rep,name=sources,proto3" json:"sources,omitempty"`
+	Destinations []string `protobuf:"bytes,3,rep,name=destinations,proto3" json:"destinations,omitempty"`
+	Description  string   `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *DebugPeerReason) Reset() {
+	*x = DebugPeerReason{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugPeerReason) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugPeerReason) ProtoMessage() {}
+
+func (x *DebugPeerReason) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugPeerReason.ProtoReflect.Descriptor instead.
+func (*DebugPeerReason) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DebugPeerReason) GetAclIndex() int32 {
+	if x != nil {
+		return x.AclIndex
+	}
+	return 0
+}
+
+func (x *DebugPeerReason) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *DebugPeerReason) GetDestinations() []string {
+	if x != nil {
+		return x.Destinations
+	}
+	return nil
+}
+
+func (x *DebugPeerReason) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type DebugPeer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MachineId uint64             `protobuf:"varint,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	Name      string             `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Namespace string             `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Reasons   []*DebugPeerReason `protobuf:"bytes,4,rep,name=reasons,proto3" json:"reasons,omitempty"`
+}
+
+func (x *DebugPeer) Reset() {
+	*x = DebugPeer{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugPeer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugPeer) ProtoMessage() {}
+
+func (x *DebugPeer) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugPeer.ProtoReflect.Descriptor instead.
+func (*DebugPeer) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DebugPeer) GetMachineId() uint64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+func (x *DebugPeer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DebugPeer) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *DebugPeer) GetReasons() []*DebugPeerReason {
+	if x != nil {
+		return x.Reasons
+	}
+	return nil
+}
+
+type DebugTagGrant struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Tag        string   `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	AclIndexes []int32  `protobuf:"varint,2,rep,packed,name=acl_indexes,json=aclIndexes,proto3" json:"acl_indexes,omitempty"`
+	Namespaces []string `protobuf:"bytes,3,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+}
+
+func (x *DebugTagGrant) Reset() {
+	*x = DebugTagGrant{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugTagGrant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugTagGrant) ProtoMessage() {}
+
+func (x *DebugTagGrant) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugTagGrant.ProtoReflect.Descriptor instead.
+func (*DebugTagGrant) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DebugTagGrant) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *DebugTagGrant) GetAclIndexes() []int32 {
+	if x != nil {
+		return x.AclIndexes
+	}
+	return nil
+}
+
+func (x *DebugTagGrant) GetNamespaces() []string {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+type DebugGetMapResponseRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MachineId uint64 `protobuf:"varint,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+}
+
+func (x *DebugGetMapResponseRequest) Reset() {
+	*x = DebugGetMapResponseRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugGetMapResponseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugGetMapResponseRequest) ProtoMessage() {}
+
+func (x *DebugGetMapResponseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugGetMapResponseRequest.ProtoReflect.Descriptor instead.
+func (*DebugGetMapResponseRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DebugGetMapResponseRequest) GetMachineId() uint64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+type DebugGetMapResponseResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MapResponse string           `protobuf:"bytes,1,opt,name=map_response,json=mapResponse,proto3" json:"map_response,omitempty"`
+	Peers       []*DebugPeer     `protobuf:"bytes,2,rep,name=peers,proto3" json:"peers,omitempty"`
+	TagGrants   []*DebugTagGrant `protobuf:"bytes,3,rep,name=tag_grants,json=tagGrants,proto3" json:"tag_grants,omitempty"`
+}
+
+func (x *DebugGetMapResponseResponse) Reset() {
+	*x = DebugGetMapResponseResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebugGetMapResponseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebugGetMapResponseResponse) ProtoMessage() {}
+
+func (x *DebugGetMapResponseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebugGetMapResponseResponse.ProtoReflect.Descriptor instead.
+func (*DebugGetMapResponseResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DebugGetMapResponseResponse) GetMapResponse() string {
+	if x != nil {
+		return x.MapResponse
+	}
+	return ""
+}
+
+func (x *DebugGetMapResponseResponse) GetPeers() []*DebugPeer {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+func (x *DebugGetMapResponseResponse) GetTagGrants() []*DebugTagGrant {
+	if x != nil {
+		return x.TagGrants
+	}
+	return nil
+}
+
+type ForceUpdateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MachineId uint64 `protobuf:"varint,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+}
+
+func (x *ForceUpdateRequest) Reset() {
+	*x = ForceUpdateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ForceUpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForceUpdateRequest) ProtoMessage() {}
+
+func (x *ForceUpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForceUpdateRequest.ProtoReflect.Descriptor instead.
+func (*ForceUpdateRequest) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ForceUpdateRequest) GetMachineId() uint64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+type ForceUpdateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ForceUpdateResponse) Reset() {
+	*x = ForceUpdateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_headscale_v1_debug_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ForceUpdateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForceUpdateResponse) ProtoMessage() {}
+
+func (x *ForceUpdateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_headscale_v1_debug_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForceUpdateResponse.ProtoReflect.Descriptor instead.
+func (*ForceUpdateResponse) Descriptor() ([]byte, []int) {
+	return file_headscale_v1_debug_proto_rawDescGZIP(), []int{11}
+}
+
+var File_headscale_v1_debug_proto protoreflect.FileDescriptor
+
+var file_headscale_v1_debug_proto_rawDesc = []byte{
+	0x0a, 0x18, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2f, 0x76, 0x31, 0x2f, 0x64,
+	0x65, 0x62, 0x75, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x68, 0x65, 0x61, 0x64,
+	0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x8e, 0x02, 0x0a, 0x13, 0x44, 0x65,
+	0x62, 0x75, 0x67, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x49, 0x64,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61,
+	0x63, 0x65, 0x12, 0x50, 0x0a, 0x16, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x73, 0x75, 0x63, 0x63, 0x65,
+	0x73, 0x73, 0x66, 0x75, 0x6c, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x14,
+	0x6c, 0x61, 0x73, 0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x66, 0x75, 0x6c, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x12, 0x38, 0x0a, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x6c,
+	0x61, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x09, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4c, 0x61, 0x67, 0x12, 0x1a,
+	0x0a, 0x08, 0x6f, 0x75, 0x74, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x08, 0x6f, 0x75, 0x74, 0x64, 0x61, 0x74, 0x65, 0x64, 0x22, 0x1b, 0x0a, 0x19, 0x44, 0x65,
+	0x62, 0x75, 0x67, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xd0, 0x01, 0x0a, 0x1a, 0x44, 0x65, 0x62, 0x75,
+	0x67, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2d, 0x0a, 0x12, 0x73, 0x75, 0x62, 0x73, 0x63, 0x72,
+	0x69, 0x62, 0x65, 0x64, 0x5f, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x11, 0x73, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x64, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x46, 0x0a, 0x11, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0f, 0x6c, 0x61,
+	0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x3b, 0x0a,
+	0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21,
+	0x2e, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65,
+	0x62, 0x75, 0x67, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x52, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x22, 0x15, 0x0a, 0x13, 0x47, 0x65,
+	0x74, 0x44, 0x65, 0x62, 0x75, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x22, 0xc6, 0x01, 0x0a, 0x14, 0x47, 0x65, 0x74, 0x44, 0x65, 0x62, 0x75, 0x67, 0x49, 0x6e,
+	0x66, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x11, 0x6c, 0x61,
+	0x73, 0x74, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x0f, 0x6c, 0x61, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x43, 0x68, 0x61, 0x6e,
+	0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x63, 0x68, 0x61,
+	0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x12, 0x3d, 0x0a, 0x08, 0x6d,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e,
+	0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x62,
+	0x75, 0x67, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x52, 0x08, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x73, 0x22, 0x8e, 0x01, 0x0a, 0x0f, 0x44,
+	0x65, 0x62, 0x75, 0x67, 0x50, 0x65, 0x65, 0x72, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x1b,
+	0x0a, 0x09, 0x61, 0x63, 0x6c, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x08, 0x61, 0x63, 0x6c, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x18, 0x0a, 0x07, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x64, 0x65, 0x73,
+	0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73,
+	0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x95, 0x01, 0x0a, 0x09,
+	0x44, 0x65, 0x62, 0x75, 0x67, 0x50, 0x65, 0x65, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x63,
+	0x68, 0x69, 0x6e, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6d,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09,
+	0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x37, 0x0a, 0x07, 0x72, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x68, 0x65,
+	0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x62, 0x75, 0x67,
+	0x50, 0x65, 0x65, 0x72, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x52, 0x07, 0x72, 0x65, 0x61, 0x73,
+	0x6f, 0x6e, 0x73, 0x22, 0x62, 0x0a, 0x0d, 0x44, 0x65, 0x62, 0x75, 0x67, 0x54, 0x61, 0x67, 0x47,
+	0x72, 0x61, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x61, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x74, 0x61, 0x67, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x63, 0x6c, 0x5f, 0x69, 0x6e,
+	0x64, 0x65, 0x78, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x05, 0x52, 0x0a, 0x61, 0x63, 0x6c,
+	0x49, 0x6e, 0x64, 0x65, 0x78, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73,
+	0x70, 0x61, 0x63, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x6e, 0x61, 0x6d,
+	0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x22, 0x3b, 0x0a, 0x1a, 0x44, 0x65, 0x62, 0x75, 0x67,
+	0x47, 0x65, 0x74, 0x4d, 0x61, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6d, 0x61, 0x63, 0x68, 0x69,
+	0x6e, 0x65, 0x49, 0x64, 0x22, 0xab, 0x01, 0x0a, 0x1b, 0x44, 0x65, 0x62, 0x75, 0x67, 0x47, 0x65,
+	0x74, 0x4d, 0x61, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x61, 0x70, 0x5f, 0x72, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x6d, 0x61, 0x70, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2d, 0x0a, 0x05, 0x70, 0x65, 0x65, 0x72, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61,
+	0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x62, 0x75, 0x67, 0x50, 0x65, 0x65, 0x72, 0x52,
+	0x05, 0x70, 0x65, 0x65, 0x72, 0x73, 0x12, 0x3a, 0x0a, 0x0a, 0x74, 0x61, 0x67, 0x5f, 0x67, 0x72,
+	0x61, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x68, 0x65, 0x61,
+	0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x62, 0x75, 0x67, 0x54,
+	0x61, 0x67, 0x47, 0x72, 0x61, 0x6e, 0x74, 0x52, 0x09, 0x74, 0x61, 0x67, 0x47, 0x72, 0x61, 0x6e,
+	0x74, 0x73, 0x22, 0x33, 0x0a, 0x12, 0x46, 0x6f, 0x72, 0x63, 0x65, 0x55, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x63, 0x68,
+	0x69, 0x6e, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6d, 0x61,
+	0x63, 0x68, 0x69, 0x6e, 0x65, 0x49, 0x64, 0x22, 0x15, 0x0a, 0x13, 0x46, 0x6f, 0x72, 0x63, 0x65,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x29,
+	0x5a, 0x27, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x75, 0x61,
+	0x6e, 0x66, 0x6f, 0x6e, 0x74, 0x2f, 0x68, 0x65, 0x61, 0x64, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x2f,
+	0x67, 0x65, 0x6e, 0x2f, 0x67, 0x6f, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_headscale_v1_debug_proto_rawDescOnce sync.Once
+	file_headscale_v1_debug_proto_rawDescData = file_headscale_v1_debug_proto_rawDesc
+)
+
+func file_headscale_v1_debug_proto_rawDescGZIP() []byte {
+	file_headscale_v1_debug_proto_rawDescOnce.Do(func() {
+		file_headscale_v1_debug_proto_rawDescData = protoimpl.X.CompressGZIP(file_headscale_v1_debug_proto_rawDescData)
+	})
+	return file_headscale_v1_debug_proto_rawDescData
+}
+
+var file_headscale_v1_debug_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_headscale_v1_debug_proto_goTypes = []interface{}{
+	(*DebugNotifierClient)(nil),         // 0: headscale.v1.DebugNotifierClient
+	(*DebugNotifierStateRequest)(nil),   // 1: headscale.v1.DebugNotifierStateRequest
+	(*DebugNotifierStateResponse)(nil),  // 2: headscale.v1.DebugNotifierStateResponse
+	(*GetDebugInfoRequest)(nil),         // 3: headscale.v1.GetDebugInfoRequest
+	(*GetDebugInfoResponse)(nil),        // 4: headscale.v1.GetDebugInfoResponse
+	(*DebugPeerReason)(nil),             // 5: headscale.v1.DebugPeerReason
+	(*DebugPeer)(nil),                   // 6: headscale.v1.DebugPeer
+	(*DebugTagGrant)(nil),               // 7: headscale.v1.DebugTagGrant
+	(*DebugGetMapResponseRequest)(nil),  // 8: headscale.v1.DebugGetMapResponseRequest
+	(*DebugGetMapResponseResponse)(nil), // 9: headscale.v1.DebugGetMapResponseResponse
+	(*ForceUpdateRequest)(nil),          // 10: headscale.v1.ForceUpdateRequest
+	(*ForceUpdateResponse)(nil),         // 11: headscale.v1.ForceUpdateResponse
+	(*timestamppb.Timestamp)(nil),       // 12: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),         // 13: google.protobuf.Duration
+}
+var file_headscale_v1_debug_proto_depIdxs = []int32{
+	12, // 0: headscale.v1.DebugNotifierClient.last_successful_update:type_name -> google.protobuf.Timestamp
+	13, // 1: headscale.v1.DebugNotifierClient.update_lag:type_name -> google.protobuf.Duration
+	12, // 2: headscale.v1.DebugNotifierStateResponse.last_state_change:type_name -> google.protobuf.Timestamp
+	0,  // 3: headscale.v1.DebugNotifierStateResponse.clients:type_name -> headscale.v1.DebugNotifierClient
+	12, // 4: headscale.v1.GetDebugInfoResponse.last_state_change:type_name -> google.protobuf.Timestamp
+	0,  // 5: headscale.v1.GetDebugInfoResponse.machines:type_name -> headscale.v1.DebugNotifierClient
+	5,  // 6: headscale.v1.DebugPeer.reasons:type_name -> headscale.v1.DebugPeerReason
+	6,  // 7: headscale.v1.DebugGetMapResponseResponse.peers:type_name -> headscale.v1.DebugPeer
+	7,  // 8: headscale.v1.DebugGetMapResponseResponse.tag_grants:type_name -> headscale.v1.DebugTagGrant
+	9,  // [9:9] is the sub-list for method output_type
+	9,  // [9:9] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_headscale_v1_debug_proto_init() }
+func file_headscale_v1_debug_proto_init() {
+	if File_headscale_v1_debug_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_headscale_v1_debug_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugNotifierClient); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugNotifierStateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugNotifierStateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetDebugInfoRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetDebugInfoResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugPeerReason); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugPeer); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugTagGrant); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugGetMapResponseRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DebugGetMapResponseResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ForceUpdateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_headscale_v1_debug_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ForceUpdateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_headscale_v1_debug_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_headscale_v1_debug_proto_goTypes,
+		DependencyIndexes: file_headscale_v1_debug_proto_depIdxs,
+		MessageInfos:      file_headscale_v1_debug_proto_msgTypes,
+	}.Build()
+	File_headscale_v1_debug_proto = out.File
+	file_headscale_v1_debug_proto_rawDesc = nil
+	file_headscale_v1_debug_proto_goTypes = nil
+	file_headscale_v1_debug_proto_depIdxs = nil
+}
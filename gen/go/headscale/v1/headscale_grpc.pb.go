@@ -22,6 +22,9 @@ type HeadscaleServiceClient interface {
 	GetNamespace(ctx context.Context, in *GetNamespaceRequest, opts ...grpc.CallOption) (*GetNamespaceResponse, error)
 	CreateNamespace(ctx context.Context, in *CreateNamespaceRequest, opts ...grpc.CallOption) (*CreateNamespaceResponse, error)
 	RenameNamespace(ctx context.Context, in *RenameNamespaceRequest, opts ...grpc.CallOption) (*RenameNamespaceResponse, error)
+	SetNamespaceTags(ctx context.Context, in *SetNamespaceTagsRequest, opts ...grpc.CallOption) (*SetNamespaceTagsResponse, error)
+	SetNamespaceACLPolicy(ctx context.Context, in *SetNamespaceACLPolicyRequest, opts ...grpc.CallOption) (*SetNamespaceACLPolicyResponse, error)
+	SetNamespaceQuota(ctx context.Context, in *SetNamespaceQuotaRequest, opts ...grpc.CallOption) (*SetNamespaceQuotaResponse, error)
 	DeleteNamespace(ctx context.Context, in *DeleteNamespaceRequest, opts ...grpc.CallOption) (*DeleteNamespaceResponse, error)
 	ListNamespaces(ctx context.Context, in *ListNamespacesRequest, opts ...grpc.CallOption) (*ListNamespacesResponse, error)
 	// --- PreAuthKeys start ---
@@ -32,19 +35,45 @@ type HeadscaleServiceClient interface {
 	DebugCreateMachine(ctx context.Context, in *DebugCreateMachineRequest, opts ...grpc.CallOption) (*DebugCreateMachineResponse, error)
 	GetMachine(ctx context.Context, in *GetMachineRequest, opts ...grpc.CallOption) (*GetMachineResponse, error)
 	SetTags(ctx context.Context, in *SetTagsRequest, opts ...grpc.CallOption) (*SetTagsResponse, error)
+	BulkSetNamespaceTags(ctx context.Context, in *BulkSetNamespaceTagsRequest, opts ...grpc.CallOption) (*BulkSetNamespaceTagsResponse, error)
 	RegisterMachine(ctx context.Context, in *RegisterMachineRequest, opts ...grpc.CallOption) (*RegisterMachineResponse, error)
 	DeleteMachine(ctx context.Context, in *DeleteMachineRequest, opts ...grpc.CallOption) (*DeleteMachineResponse, error)
+	RestoreMachine(ctx context.Context, in *RestoreMachineRequest, opts ...grpc.CallOption) (*RestoreMachineResponse, error)
 	ExpireMachine(ctx context.Context, in *ExpireMachineRequest, opts ...grpc.CallOption) (*ExpireMachineResponse, error)
+	ExpireNamespaceMachines(ctx context.Context, in *ExpireNamespaceMachinesRequest, opts ...grpc.CallOption) (*ExpireNamespaceMachinesResponse, error)
+	ExtendMachineExpiry(ctx context.Context, in *ExtendMachineExpiryRequest, opts ...grpc.CallOption) (*ExtendMachineExpiryResponse, error)
+	SetMachineExpiry(ctx context.Context, in *SetMachineExpiryRequest, opts ...grpc.CallOption) (*SetMachineExpiryResponse, error)
+	RevokeMachineSession(ctx context.Context, in *RevokeMachineSessionRequest, opts ...grpc.CallOption) (*RevokeMachineSessionResponse, error)
 	RenameMachine(ctx context.Context, in *RenameMachineRequest, opts ...grpc.CallOption) (*RenameMachineResponse, error)
 	ListMachines(ctx context.Context, in *ListMachinesRequest, opts ...grpc.CallOption) (*ListMachinesResponse, error)
 	MoveMachine(ctx context.Context, in *MoveMachineRequest, opts ...grpc.CallOption) (*MoveMachineResponse, error)
+	ReassignMachineIP(ctx context.Context, in *ReassignMachineIPRequest, opts ...grpc.CallOption) (*ReassignMachineIPResponse, error)
+	RotateMachineKey(ctx context.Context, in *RotateMachineKeyRequest, opts ...grpc.CallOption) (*RotateMachineKeyResponse, error)
+	WatchMachines(ctx context.Context, in *WatchMachinesRequest, opts ...grpc.CallOption) (HeadscaleService_WatchMachinesClient, error)
 	// --- Route start ---
 	GetMachineRoute(ctx context.Context, in *GetMachineRouteRequest, opts ...grpc.CallOption) (*GetMachineRouteResponse, error)
 	EnableMachineRoutes(ctx context.Context, in *EnableMachineRoutesRequest, opts ...grpc.CallOption) (*EnableMachineRoutesResponse, error)
+	EnableRoutesBatch(ctx context.Context, in *EnableRoutesBatchRequest, opts ...grpc.CallOption) (*EnableRoutesBatchResponse, error)
+	ListAvailableExitNodes(ctx context.Context, in *ListAvailableExitNodesRequest, opts ...grpc.CallOption) (*ListAvailableExitNodesResponse, error)
+	ListMachinesByRoute(ctx context.Context, in *ListMachinesByRouteRequest, opts ...grpc.CallOption) (*ListMachinesByRouteResponse, error)
 	// --- ApiKeys start ---
 	CreateApiKey(ctx context.Context, in *CreateApiKeyRequest, opts ...grpc.CallOption) (*CreateApiKeyResponse, error)
 	ExpireApiKey(ctx context.Context, in *ExpireApiKeyRequest, opts ...grpc.CallOption) (*ExpireApiKeyResponse, error)
 	ListApiKeys(ctx context.Context, in *ListApiKeysRequest, opts ...grpc.CallOption) (*ListApiKeysResponse, error)
+	// --- DNS start ---
+	GenerateDNSRecords(ctx context.Context, in *GenerateDNSRecordsRequest, opts ...grpc.CallOption) (*GenerateDNSRecordsResponse, error)
+	// --- ACL start ---
+	CheckACLPolicy(ctx context.Context, in *CheckACLPolicyRequest, opts ...grpc.CallOption) (*CheckACLPolicyResponse, error)
+	GetACLRules(ctx context.Context, in *GetACLRulesRequest, opts ...grpc.CallOption) (*GetACLRulesResponse, error)
+	PreviewMachineRules(ctx context.Context, in *PreviewMachineRulesRequest, opts ...grpc.CallOption) (*PreviewMachineRulesResponse, error)
+	GetACLPolicy(ctx context.Context, in *GetACLPolicyRequest, opts ...grpc.CallOption) (*GetACLPolicyResponse, error)
+	SetACLPolicy(ctx context.Context, in *SetACLPolicyRequest, opts ...grpc.CallOption) (*SetACLPolicyResponse, error)
+	// --- Debug start ---
+	DebugNotifierState(ctx context.Context, in *DebugNotifierStateRequest, opts ...grpc.CallOption) (*DebugNotifierStateResponse, error)
+	GetDebugInfo(ctx context.Context, in *GetDebugInfoRequest, opts ...grpc.CallOption) (*GetDebugInfoResponse, error)
+	DebugGetMapResponse(ctx context.Context, in *DebugGetMapResponseRequest, opts ...grpc.CallOption) (*DebugGetMapResponseResponse, error)
+	ForceUpdate(ctx context.Context, in *ForceUpdateRequest, opts ...grpc.CallOption) (*ForceUpdateResponse, error)
+	GetServerInfo(ctx context.Context, in *GetServerInfoRequest, opts ...grpc.CallOption) (*GetServerInfoResponse, error)
 }
 
 type headscaleServiceClient struct {
@@ -82,6 +111,33 @@ func (c *headscaleServiceClient) RenameNamespace(ctx context.Context, in *Rename
 	return out, nil
 }
 
+func (c *headscaleServiceClient) SetNamespaceTags(ctx context.Context, in *SetNamespaceTagsRequest, opts ...grpc.CallOption) (*SetNamespaceTagsResponse, error) {
+	out := new(SetNamespaceTagsResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/SetNamespaceTags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) SetNamespaceACLPolicy(ctx context.Context, in *SetNamespaceACLPolicyRequest, opts ...grpc.CallOption) (*SetNamespaceACLPolicyResponse, error) {
+	out := new(SetNamespaceACLPolicyResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/SetNamespaceACLPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) SetNamespaceQuota(ctx context.Context, in *SetNamespaceQuotaRequest, opts ...grpc.CallOption) (*SetNamespaceQuotaResponse, error) {
+	out := new(SetNamespaceQuotaResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/SetNamespaceQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *headscaleServiceClient) DeleteNamespace(ctx context.Context, in *DeleteNamespaceRequest, opts ...grpc.CallOption) (*DeleteNamespaceResponse, error) {
 	out := new(DeleteNamespaceResponse)
 	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/DeleteNamespace", in, out, opts...)
@@ -154,6 +210,15 @@ func (c *headscaleServiceClient) SetTags(ctx context.Context, in *SetTagsRequest
 	return out, nil
 }
 
+func (c *headscaleServiceClient) BulkSetNamespaceTags(ctx context.Context, in *BulkSetNamespaceTagsRequest, opts ...grpc.CallOption) (*BulkSetNamespaceTagsResponse, error) {
+	out := new(BulkSetNamespaceTagsResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/BulkSetNamespaceTags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *headscaleServiceClient) RegisterMachine(ctx context.Context, in *RegisterMachineRequest, opts ...grpc.CallOption) (*RegisterMachineResponse, error) {
 	out := new(RegisterMachineResponse)
 	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/RegisterMachine", in, out, opts...)
@@ -172,6 +237,15 @@ func (c *headscaleServiceClient) DeleteMachine(ctx context.Context, in *DeleteMa
 	return out, nil
 }
 
+func (c *headscaleServiceClient) RestoreMachine(ctx context.Context, in *RestoreMachineRequest, opts ...grpc.CallOption) (*RestoreMachineResponse, error) {
+	out := new(RestoreMachineResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/RestoreMachine", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *headscaleServiceClient) ExpireMachine(ctx context.Context, in *ExpireMachineRequest, opts ...grpc.CallOption) (*ExpireMachineResponse, error) {
 	out := new(ExpireMachineResponse)
 	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ExpireMachine", in, out, opts...)
@@ -181,6 +255,42 @@ func (c *headscaleServiceClient) ExpireMachine(ctx context.Context, in *ExpireMa
 	return out, nil
 }
 
+func (c *headscaleServiceClient) ExpireNamespaceMachines(ctx context.Context, in *ExpireNamespaceMachinesRequest, opts ...grpc.CallOption) (*ExpireNamespaceMachinesResponse, error) {
+	out := new(ExpireNamespaceMachinesResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ExpireNamespaceMachines", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) ExtendMachineExpiry(ctx context.Context, in *ExtendMachineExpiryRequest, opts ...grpc.CallOption) (*ExtendMachineExpiryResponse, error) {
+	out := new(ExtendMachineExpiryResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ExtendMachineExpiry", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) SetMachineExpiry(ctx context.Context, in *SetMachineExpiryRequest, opts ...grpc.CallOption) (*SetMachineExpiryResponse, error) {
+	out := new(SetMachineExpiryResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/SetMachineExpiry", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) RevokeMachineSession(ctx context.Context, in *RevokeMachineSessionRequest, opts ...grpc.CallOption) (*RevokeMachineSessionResponse, error) {
+	out := new(RevokeMachineSessionResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/RevokeMachineSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *headscaleServiceClient) RenameMachine(ctx context.Context, in *RenameMachineRequest, opts ...grpc.CallOption) (*RenameMachineResponse, error) {
 	out := new(RenameMachineResponse)
 	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/RenameMachine", in, out, opts...)
@@ -208,6 +318,56 @@ func (c *headscaleServiceClient) MoveMachine(ctx context.Context, in *MoveMachin
 	return out, nil
 }
 
+func (c *headscaleServiceClient) ReassignMachineIP(ctx context.Context, in *ReassignMachineIPRequest, opts ...grpc.CallOption) (*ReassignMachineIPResponse, error) {
+	out := new(ReassignMachineIPResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ReassignMachineIP", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) RotateMachineKey(ctx context.Context, in *RotateMachineKeyRequest, opts ...grpc.CallOption) (*RotateMachineKeyResponse, error) {
+	out := new(RotateMachineKeyResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/RotateMachineKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) WatchMachines(ctx context.Context, in *WatchMachinesRequest, opts ...grpc.CallOption) (HeadscaleService_WatchMachinesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &HeadscaleService_ServiceDesc.Streams[0], "/headscale.v1.HeadscaleService/WatchMachines", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &headscaleServiceWatchMachinesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type HeadscaleService_WatchMachinesClient interface {
+	Recv() (*MachineEvent, error)
+	grpc.ClientStream
+}
+
+type headscaleServiceWatchMachinesClient struct {
+	grpc.ClientStream
+}
+
+func (x *headscaleServiceWatchMachinesClient) Recv() (*MachineEvent, error) {
+	m := new(MachineEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *headscaleServiceClient) GetMachineRoute(ctx context.Context, in *GetMachineRouteRequest, opts ...grpc.CallOption) (*GetMachineRouteResponse, error) {
 	out := new(GetMachineRouteResponse)
 	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/GetMachineRoute", in, out, opts...)
@@ -226,6 +386,33 @@ func (c *headscaleServiceClient) EnableMachineRoutes(ctx context.Context, in *En
 	return out, nil
 }
 
+func (c *headscaleServiceClient) EnableRoutesBatch(ctx context.Context, in *EnableRoutesBatchRequest, opts ...grpc.CallOption) (*EnableRoutesBatchResponse, error) {
+	out := new(EnableRoutesBatchResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/EnableRoutesBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) ListAvailableExitNodes(ctx context.Context, in *ListAvailableExitNodesRequest, opts ...grpc.CallOption) (*ListAvailableExitNodesResponse, error) {
+	out := new(ListAvailableExitNodesResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ListAvailableExitNodes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) ListMachinesByRoute(ctx context.Context, in *ListMachinesByRouteRequest, opts ...grpc.CallOption) (*ListMachinesByRouteResponse, error) {
+	out := new(ListMachinesByRouteResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ListMachinesByRoute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *headscaleServiceClient) CreateApiKey(ctx context.Context, in *CreateApiKeyRequest, opts ...grpc.CallOption) (*CreateApiKeyResponse, error) {
 	out := new(CreateApiKeyResponse)
 	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/CreateApiKey", in, out, opts...)
@@ -253,6 +440,105 @@ func (c *headscaleServiceClient) ListApiKeys(ctx context.Context, in *ListApiKey
 	return out, nil
 }
 
+func (c *headscaleServiceClient) GenerateDNSRecords(ctx context.Context, in *GenerateDNSRecordsRequest, opts ...grpc.CallOption) (*GenerateDNSRecordsResponse, error) {
+	out := new(GenerateDNSRecordsResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/GenerateDNSRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) CheckACLPolicy(ctx context.Context, in *CheckACLPolicyRequest, opts ...grpc.CallOption) (*CheckACLPolicyResponse, error) {
+	out := new(CheckACLPolicyResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/CheckACLPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) GetACLRules(ctx context.Context, in *GetACLRulesRequest, opts ...grpc.CallOption) (*GetACLRulesResponse, error) {
+	out := new(GetACLRulesResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/GetACLRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) PreviewMachineRules(ctx context.Context, in *PreviewMachineRulesRequest, opts ...grpc.CallOption) (*PreviewMachineRulesResponse, error) {
+	out := new(PreviewMachineRulesResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/PreviewMachineRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) GetACLPolicy(ctx context.Context, in *GetACLPolicyRequest, opts ...grpc.CallOption) (*GetACLPolicyResponse, error) {
+	out := new(GetACLPolicyResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/GetACLPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) SetACLPolicy(ctx context.Context, in *SetACLPolicyRequest, opts ...grpc.CallOption) (*SetACLPolicyResponse, error) {
+	out := new(SetACLPolicyResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/SetACLPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) DebugNotifierState(ctx context.Context, in *DebugNotifierStateRequest, opts ...grpc.CallOption) (*DebugNotifierStateResponse, error) {
+	out := new(DebugNotifierStateResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/DebugNotifierState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) GetDebugInfo(ctx context.Context, in *GetDebugInfoRequest, opts ...grpc.CallOption) (*GetDebugInfoResponse, error) {
+	out := new(GetDebugInfoResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/GetDebugInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) DebugGetMapResponse(ctx context.Context, in *DebugGetMapResponseRequest, opts ...grpc.CallOption) (*DebugGetMapResponseResponse, error) {
+	out := new(DebugGetMapResponseResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/DebugGetMapResponse", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) ForceUpdate(ctx context.Context, in *ForceUpdateRequest, opts ...grpc.CallOption) (*ForceUpdateResponse, error) {
+	out := new(ForceUpdateResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/ForceUpdate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *headscaleServiceClient) GetServerInfo(ctx context.Context, in *GetServerInfoRequest, opts ...grpc.CallOption) (*GetServerInfoResponse, error) {
+	out := new(GetServerInfoResponse)
+	err := c.cc.Invoke(ctx, "/headscale.v1.HeadscaleService/GetServerInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HeadscaleServiceServer is the server API for HeadscaleService service.
 // All implementations must embed UnimplementedHeadscaleServiceServer
 // for forward compatibility
@@ -261,6 +547,9 @@ type HeadscaleServiceServer interface {
 	GetNamespace(context.Context, *GetNamespaceRequest) (*GetNamespaceResponse, error)
 	CreateNamespace(context.Context, *CreateNamespaceRequest) (*CreateNamespaceResponse, error)
 	RenameNamespace(context.Context, *RenameNamespaceRequest) (*RenameNamespaceResponse, error)
+	SetNamespaceTags(context.Context, *SetNamespaceTagsRequest) (*SetNamespaceTagsResponse, error)
+	SetNamespaceACLPolicy(context.Context, *SetNamespaceACLPolicyRequest) (*SetNamespaceACLPolicyResponse, error)
+	SetNamespaceQuota(context.Context, *SetNamespaceQuotaRequest) (*SetNamespaceQuotaResponse, error)
 	DeleteNamespace(context.Context, *DeleteNamespaceRequest) (*DeleteNamespaceResponse, error)
 	ListNamespaces(context.Context, *ListNamespacesRequest) (*ListNamespacesResponse, error)
 	// --- PreAuthKeys start ---
@@ -271,19 +560,45 @@ type HeadscaleServiceServer interface {
 	DebugCreateMachine(context.Context, *DebugCreateMachineRequest) (*DebugCreateMachineResponse, error)
 	GetMachine(context.Context, *GetMachineRequest) (*GetMachineResponse, error)
 	SetTags(context.Context, *SetTagsRequest) (*SetTagsResponse, error)
+	BulkSetNamespaceTags(context.Context, *BulkSetNamespaceTagsRequest) (*BulkSetNamespaceTagsResponse, error)
 	RegisterMachine(context.Context, *RegisterMachineRequest) (*RegisterMachineResponse, error)
 	DeleteMachine(context.Context, *DeleteMachineRequest) (*DeleteMachineResponse, error)
+	RestoreMachine(context.Context, *RestoreMachineRequest) (*RestoreMachineResponse, error)
 	ExpireMachine(context.Context, *ExpireMachineRequest) (*ExpireMachineResponse, error)
+	ExpireNamespaceMachines(context.Context, *ExpireNamespaceMachinesRequest) (*ExpireNamespaceMachinesResponse, error)
+	ExtendMachineExpiry(context.Context, *ExtendMachineExpiryRequest) (*ExtendMachineExpiryResponse, error)
+	SetMachineExpiry(context.Context, *SetMachineExpiryRequest) (*SetMachineExpiryResponse, error)
+	RevokeMachineSession(context.Context, *RevokeMachineSessionRequest) (*RevokeMachineSessionResponse, error)
 	RenameMachine(context.Context, *RenameMachineRequest) (*RenameMachineResponse, error)
 	ListMachines(context.Context, *ListMachinesRequest) (*ListMachinesResponse, error)
 	MoveMachine(context.Context, *MoveMachineRequest) (*MoveMachineResponse, error)
+	ReassignMachineIP(context.Context, *ReassignMachineIPRequest) (*ReassignMachineIPResponse, error)
+	RotateMachineKey(context.Context, *RotateMachineKeyRequest) (*RotateMachineKeyResponse, error)
+	WatchMachines(*WatchMachinesRequest, HeadscaleService_WatchMachinesServer) error
 	// --- Route start ---
 	GetMachineRoute(context.Context, *GetMachineRouteRequest) (*GetMachineRouteResponse, error)
 	EnableMachineRoutes(context.Context, *EnableMachineRoutesRequest) (*EnableMachineRoutesResponse, error)
+	EnableRoutesBatch(context.Context, *EnableRoutesBatchRequest) (*EnableRoutesBatchResponse, error)
+	ListAvailableExitNodes(context.Context, *ListAvailableExitNodesRequest) (*ListAvailableExitNodesResponse, error)
+	ListMachinesByRoute(context.Context, *ListMachinesByRouteRequest) (*ListMachinesByRouteResponse, error)
 	// --- ApiKeys start ---
 	CreateApiKey(context.Context, *CreateApiKeyRequest) (*CreateApiKeyResponse, error)
 	ExpireApiKey(context.Context, *ExpireApiKeyRequest) (*ExpireApiKeyResponse, error)
 	ListApiKeys(context.Context, *ListApiKeysRequest) (*ListApiKeysResponse, error)
+	// --- DNS start ---
+	GenerateDNSRecords(context.Context, *GenerateDNSRecordsRequest) (*GenerateDNSRecordsResponse, error)
+	// --- ACL start ---
+	CheckACLPolicy(context.Context, *CheckACLPolicyRequest) (*CheckACLPolicyResponse, error)
+	GetACLRules(context.Context, *GetACLRulesRequest) (*GetACLRulesResponse, error)
+	PreviewMachineRules(context.Context, *PreviewMachineRulesRequest) (*PreviewMachineRulesResponse, error)
+	GetACLPolicy(context.Context, *GetACLPolicyRequest) (*GetACLPolicyResponse, error)
+	SetACLPolicy(context.Context, *SetACLPolicyRequest) (*SetACLPolicyResponse, error)
+	// --- Debug start ---
+	DebugNotifierState(context.Context, *DebugNotifierStateRequest) (*DebugNotifierStateResponse, error)
+	GetDebugInfo(context.Context, *GetDebugInfoRequest) (*GetDebugInfoResponse, error)
+	DebugGetMapResponse(context.Context, *DebugGetMapResponseRequest) (*DebugGetMapResponseResponse, error)
+	ForceUpdate(context.Context, *ForceUpdateRequest) (*ForceUpdateResponse, error)
+	GetServerInfo(context.Context, *GetServerInfoRequest) (*GetServerInfoResponse, error)
 	mustEmbedUnimplementedHeadscaleServiceServer()
 }
 
@@ -300,6 +615,15 @@ func (UnimplementedHeadscaleServiceServer) CreateNamespace(context.Context, *Cre
 func (UnimplementedHeadscaleServiceServer) RenameNamespace(context.Context, *RenameNamespaceRequest) (*RenameNamespaceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RenameNamespace not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) SetNamespaceTags(context.Context, *SetNamespaceTagsRequest) (*SetNamespaceTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetNamespaceTags not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) SetNamespaceACLPolicy(context.Context, *SetNamespaceACLPolicyRequest) (*SetNamespaceACLPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetNamespaceACLPolicy not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) SetNamespaceQuota(context.Context, *SetNamespaceQuotaRequest) (*SetNamespaceQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetNamespaceQuota not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) DeleteNamespace(context.Context, *DeleteNamespaceRequest) (*DeleteNamespaceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteNamespace not implemented")
 }
@@ -324,15 +648,33 @@ func (UnimplementedHeadscaleServiceServer) GetMachine(context.Context, *GetMachi
 func (UnimplementedHeadscaleServiceServer) SetTags(context.Context, *SetTagsRequest) (*SetTagsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetTags not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) BulkSetNamespaceTags(context.Context, *BulkSetNamespaceTagsRequest) (*BulkSetNamespaceTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BulkSetNamespaceTags not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) RegisterMachine(context.Context, *RegisterMachineRequest) (*RegisterMachineResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RegisterMachine not implemented")
 }
 func (UnimplementedHeadscaleServiceServer) DeleteMachine(context.Context, *DeleteMachineRequest) (*DeleteMachineResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteMachine not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) RestoreMachine(context.Context, *RestoreMachineRequest) (*RestoreMachineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreMachine not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) ExpireMachine(context.Context, *ExpireMachineRequest) (*ExpireMachineResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ExpireMachine not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) ExpireNamespaceMachines(context.Context, *ExpireNamespaceMachinesRequest) (*ExpireNamespaceMachinesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExpireNamespaceMachines not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) ExtendMachineExpiry(context.Context, *ExtendMachineExpiryRequest) (*ExtendMachineExpiryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtendMachineExpiry not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) SetMachineExpiry(context.Context, *SetMachineExpiryRequest) (*SetMachineExpiryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMachineExpiry not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) RevokeMachineSession(context.Context, *RevokeMachineSessionRequest) (*RevokeMachineSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeMachineSession not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) RenameMachine(context.Context, *RenameMachineRequest) (*RenameMachineResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RenameMachine not implemented")
 }
@@ -342,12 +684,30 @@ func (UnimplementedHeadscaleServiceServer) ListMachines(context.Context, *ListMa
 func (UnimplementedHeadscaleServiceServer) MoveMachine(context.Context, *MoveMachineRequest) (*MoveMachineResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method MoveMachine not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) ReassignMachineIP(context.Context, *ReassignMachineIPRequest) (*ReassignMachineIPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReassignMachineIP not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) RotateMachineKey(context.Context, *RotateMachineKeyRequest) (*RotateMachineKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateMachineKey not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) WatchMachines(*WatchMachinesRequest, HeadscaleService_WatchMachinesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchMachines not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) GetMachineRoute(context.Context, *GetMachineRouteRequest) (*GetMachineRouteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMachineRoute not implemented")
 }
 func (UnimplementedHeadscaleServiceServer) EnableMachineRoutes(context.Context, *EnableMachineRoutesRequest) (*EnableMachineRoutesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method EnableMachineRoutes not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) EnableRoutesBatch(context.Context, *EnableRoutesBatchRequest) (*EnableRoutesBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableRoutesBatch not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) ListAvailableExitNodes(context.Context, *ListAvailableExitNodesRequest) (*ListAvailableExitNodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAvailableExitNodes not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) ListMachinesByRoute(context.Context, *ListMachinesByRouteRequest) (*ListMachinesByRouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMachinesByRoute not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) CreateApiKey(context.Context, *CreateApiKeyRequest) (*CreateApiKeyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateApiKey not implemented")
 }
@@ -357,6 +717,39 @@ func (UnimplementedHeadscaleServiceServer) ExpireApiKey(context.Context, *Expire
 func (UnimplementedHeadscaleServiceServer) ListApiKeys(context.Context, *ListApiKeysRequest) (*ListApiKeysResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListApiKeys not implemented")
 }
+func (UnimplementedHeadscaleServiceServer) GenerateDNSRecords(context.Context, *GenerateDNSRecordsRequest) (*GenerateDNSRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateDNSRecords not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) CheckACLPolicy(context.Context, *CheckACLPolicyRequest) (*CheckACLPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckACLPolicy not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) GetACLRules(context.Context, *GetACLRulesRequest) (*GetACLRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetACLRules not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) PreviewMachineRules(context.Context, *PreviewMachineRulesRequest) (*PreviewMachineRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PreviewMachineRules not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) GetACLPolicy(context.Context, *GetACLPolicyRequest) (*GetACLPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetACLPolicy not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) SetACLPolicy(context.Context, *SetACLPolicyRequest) (*SetACLPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetACLPolicy not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) DebugNotifierState(context.Context, *DebugNotifierStateRequest) (*DebugNotifierStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DebugNotifierState not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) GetDebugInfo(context.Context, *GetDebugInfoRequest) (*GetDebugInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDebugInfo not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) DebugGetMapResponse(context.Context, *DebugGetMapResponseRequest) (*DebugGetMapResponseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DebugGetMapResponse not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) ForceUpdate(context.Context, *ForceUpdateRequest) (*ForceUpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ForceUpdate not implemented")
+}
+func (UnimplementedHeadscaleServiceServer) GetServerInfo(context.Context, *GetServerInfoRequest) (*GetServerInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServerInfo not implemented")
+}
 func (UnimplementedHeadscaleServiceServer) mustEmbedUnimplementedHeadscaleServiceServer() {}
 
 // UnsafeHeadscaleServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -424,6 +817,60 @@ func _HeadscaleService_RenameNamespace_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HeadscaleService_SetNamespaceTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetNamespaceTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).SetNamespaceTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/SetNamespaceTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).SetNamespaceTags(ctx, req.(*SetNamespaceTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_SetNamespaceACLPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetNamespaceACLPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).SetNamespaceACLPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/SetNamespaceACLPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).SetNamespaceACLPolicy(ctx, req.(*SetNamespaceACLPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_SetNamespaceQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetNamespaceQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).SetNamespaceQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/SetNamespaceQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).SetNamespaceQuota(ctx, req.(*SetNamespaceQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _HeadscaleService_DeleteNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteNamespaceRequest)
 	if err := dec(in); err != nil {
@@ -568,6 +1015,24 @@ func _HeadscaleService_SetTags_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HeadscaleService_BulkSetNamespaceTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BulkSetNamespaceTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).BulkSetNamespaceTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/BulkSetNamespaceTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).BulkSetNamespaceTags(ctx, req.(*BulkSetNamespaceTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _HeadscaleService_RegisterMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RegisterMachineRequest)
 	if err := dec(in); err != nil {
@@ -604,31 +1069,121 @@ func _HeadscaleService_DeleteMachine_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
-func _HeadscaleService_ExpireMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ExpireMachineRequest)
+func _HeadscaleService_RestoreMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreMachineRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(HeadscaleServiceServer).ExpireMachine(ctx, in)
+		return srv.(HeadscaleServiceServer).RestoreMachine(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/headscale.v1.HeadscaleService/ExpireMachine",
+		FullMethod: "/headscale.v1.HeadscaleService/RestoreMachine",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(HeadscaleServiceServer).ExpireMachine(ctx, req.(*ExpireMachineRequest))
+		return srv.(HeadscaleServiceServer).RestoreMachine(ctx, req.(*RestoreMachineRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _HeadscaleService_RenameMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RenameMachineRequest)
+func _HeadscaleService_ExpireMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpireMachineRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(HeadscaleServiceServer).RenameMachine(ctx, in)
+		return srv.(HeadscaleServiceServer).ExpireMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ExpireMachine",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ExpireMachine(ctx, req.(*ExpireMachineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_ExpireNamespaceMachines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpireNamespaceMachinesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).ExpireNamespaceMachines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ExpireNamespaceMachines",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ExpireNamespaceMachines(ctx, req.(*ExpireNamespaceMachinesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_ExtendMachineExpiry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtendMachineExpiryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).ExtendMachineExpiry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ExtendMachineExpiry",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ExtendMachineExpiry(ctx, req.(*ExtendMachineExpiryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_SetMachineExpiry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMachineExpiryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).SetMachineExpiry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/SetMachineExpiry",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).SetMachineExpiry(ctx, req.(*SetMachineExpiryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_RevokeMachineSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeMachineSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).RevokeMachineSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/RevokeMachineSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).RevokeMachineSession(ctx, req.(*RevokeMachineSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_RenameMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameMachineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).RenameMachine(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
@@ -676,6 +1231,63 @@ func _HeadscaleService_MoveMachine_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HeadscaleService_ReassignMachineIP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReassignMachineIPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).ReassignMachineIP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ReassignMachineIP",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ReassignMachineIP(ctx, req.(*ReassignMachineIPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_RotateMachineKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateMachineKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).RotateMachineKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/RotateMachineKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).RotateMachineKey(ctx, req.(*RotateMachineKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_WatchMachines_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMachinesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HeadscaleServiceServer).WatchMachines(m, &headscaleServiceWatchMachinesServer{stream})
+}
+
+type HeadscaleService_WatchMachinesServer interface {
+	Send(*MachineEvent) error
+	grpc.ServerStream
+}
+
+type headscaleServiceWatchMachinesServer struct {
+	grpc.ServerStream
+}
+
+func (x *headscaleServiceWatchMachinesServer) Send(m *MachineEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _HeadscaleService_GetMachineRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetMachineRouteRequest)
 	if err := dec(in); err != nil {
@@ -712,6 +1324,60 @@ func _HeadscaleService_EnableMachineRoutes_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HeadscaleService_EnableRoutesBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableRoutesBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).EnableRoutesBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/EnableRoutesBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).EnableRoutesBatch(ctx, req.(*EnableRoutesBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_ListAvailableExitNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAvailableExitNodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).ListAvailableExitNodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ListAvailableExitNodes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ListAvailableExitNodes(ctx, req.(*ListAvailableExitNodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_ListMachinesByRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMachinesByRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).ListMachinesByRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ListMachinesByRoute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ListMachinesByRoute(ctx, req.(*ListMachinesByRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _HeadscaleService_CreateApiKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateApiKeyRequest)
 	if err := dec(in); err != nil {
@@ -766,6 +1432,204 @@ func _HeadscaleService_ListApiKeys_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HeadscaleService_GenerateDNSRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateDNSRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).GenerateDNSRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/GenerateDNSRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).GenerateDNSRecords(ctx, req.(*GenerateDNSRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_CheckACLPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckACLPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).CheckACLPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/CheckACLPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).CheckACLPolicy(ctx, req.(*CheckACLPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_GetACLRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetACLRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).GetACLRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/GetACLRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).GetACLRules(ctx, req.(*GetACLRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_PreviewMachineRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PreviewMachineRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).PreviewMachineRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/PreviewMachineRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).PreviewMachineRules(ctx, req.(*PreviewMachineRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_GetACLPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetACLPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).GetACLPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/GetACLPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).GetACLPolicy(ctx, req.(*GetACLPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_SetACLPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetACLPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).SetACLPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/SetACLPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).SetACLPolicy(ctx, req.(*SetACLPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_DebugNotifierState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DebugNotifierStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).DebugNotifierState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/DebugNotifierState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).DebugNotifierState(ctx, req.(*DebugNotifierStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_GetDebugInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDebugInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).GetDebugInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/GetDebugInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).GetDebugInfo(ctx, req.(*GetDebugInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_DebugGetMapResponse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DebugGetMapResponseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).DebugGetMapResponse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/DebugGetMapResponse",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).DebugGetMapResponse(ctx, req.(*DebugGetMapResponseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_ForceUpdate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForceUpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).ForceUpdate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/ForceUpdate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).ForceUpdate(ctx, req.(*ForceUpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HeadscaleService_GetServerInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServerInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HeadscaleServiceServer).GetServerInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/headscale.v1.HeadscaleService/GetServerInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HeadscaleServiceServer).GetServerInfo(ctx, req.(*GetServerInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // HeadscaleService_ServiceDesc is the grpc.ServiceDesc for HeadscaleService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -785,6 +1649,18 @@ var HeadscaleService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RenameNamespace",
 			Handler:    _HeadscaleService_RenameNamespace_Handler,
 		},
+		{
+			MethodName: "SetNamespaceTags",
+			Handler:    _HeadscaleService_SetNamespaceTags_Handler,
+		},
+		{
+			MethodName: "SetNamespaceACLPolicy",
+			Handler:    _HeadscaleService_SetNamespaceACLPolicy_Handler,
+		},
+		{
+			MethodName: "SetNamespaceQuota",
+			Handler:    _HeadscaleService_SetNamespaceQuota_Handler,
+		},
 		{
 			MethodName: "DeleteNamespace",
 			Handler:    _HeadscaleService_DeleteNamespace_Handler,
@@ -817,6 +1693,10 @@ var HeadscaleService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SetTags",
 			Handler:    _HeadscaleService_SetTags_Handler,
 		},
+		{
+			MethodName: "BulkSetNamespaceTags",
+			Handler:    _HeadscaleService_BulkSetNamespaceTags_Handler,
+		},
 		{
 			MethodName: "RegisterMachine",
 			Handler:    _HeadscaleService_RegisterMachine_Handler,
@@ -825,10 +1705,30 @@ var HeadscaleService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteMachine",
 			Handler:    _HeadscaleService_DeleteMachine_Handler,
 		},
+		{
+			MethodName: "RestoreMachine",
+			Handler:    _HeadscaleService_RestoreMachine_Handler,
+		},
 		{
 			MethodName: "ExpireMachine",
 			Handler:    _HeadscaleService_ExpireMachine_Handler,
 		},
+		{
+			MethodName: "ExpireNamespaceMachines",
+			Handler:    _HeadscaleService_ExpireNamespaceMachines_Handler,
+		},
+		{
+			MethodName: "ExtendMachineExpiry",
+			Handler:    _HeadscaleService_ExtendMachineExpiry_Handler,
+		},
+		{
+			MethodName: "SetMachineExpiry",
+			Handler:    _HeadscaleService_SetMachineExpiry_Handler,
+		},
+		{
+			MethodName: "RevokeMachineSession",
+			Handler:    _HeadscaleService_RevokeMachineSession_Handler,
+		},
 		{
 			MethodName: "RenameMachine",
 			Handler:    _HeadscaleService_RenameMachine_Handler,
@@ -841,6 +1741,14 @@ var HeadscaleService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "MoveMachine",
 			Handler:    _HeadscaleService_MoveMachine_Handler,
 		},
+		{
+			MethodName: "ReassignMachineIP",
+			Handler:    _HeadscaleService_ReassignMachineIP_Handler,
+		},
+		{
+			MethodName: "RotateMachineKey",
+			Handler:    _HeadscaleService_RotateMachineKey_Handler,
+		},
 		{
 			MethodName: "GetMachineRoute",
 			Handler:    _HeadscaleService_GetMachineRoute_Handler,
@@ -849,6 +1757,18 @@ var HeadscaleService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "EnableMachineRoutes",
 			Handler:    _HeadscaleService_EnableMachineRoutes_Handler,
 		},
+		{
+			MethodName: "EnableRoutesBatch",
+			Handler:    _HeadscaleService_EnableRoutesBatch_Handler,
+		},
+		{
+			MethodName: "ListAvailableExitNodes",
+			Handler:    _HeadscaleService_ListAvailableExitNodes_Handler,
+		},
+		{
+			MethodName: "ListMachinesByRoute",
+			Handler:    _HeadscaleService_ListMachinesByRoute_Handler,
+		},
 		{
 			MethodName: "CreateApiKey",
 			Handler:    _HeadscaleService_CreateApiKey_Handler,
@@ -861,7 +1781,57 @@ var HeadscaleService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListApiKeys",
 			Handler:    _HeadscaleService_ListApiKeys_Handler,
 		},
+		{
+			MethodName: "GenerateDNSRecords",
+			Handler:    _HeadscaleService_GenerateDNSRecords_Handler,
+		},
+		{
+			MethodName: "CheckACLPolicy",
+			Handler:    _HeadscaleService_CheckACLPolicy_Handler,
+		},
+		{
+			MethodName: "GetACLRules",
+			Handler:    _HeadscaleService_GetACLRules_Handler,
+		},
+		{
+			MethodName: "PreviewMachineRules",
+			Handler:    _HeadscaleService_PreviewMachineRules_Handler,
+		},
+		{
+			MethodName: "GetACLPolicy",
+			Handler:    _HeadscaleService_GetACLPolicy_Handler,
+		},
+		{
+			MethodName: "SetACLPolicy",
+			Handler:    _HeadscaleService_SetACLPolicy_Handler,
+		},
+		{
+			MethodName: "DebugNotifierState",
+			Handler:    _HeadscaleService_DebugNotifierState_Handler,
+		},
+		{
+			MethodName: "GetDebugInfo",
+			Handler:    _HeadscaleService_GetDebugInfo_Handler,
+		},
+		{
+			MethodName: "DebugGetMapResponse",
+			Handler:    _HeadscaleService_DebugGetMapResponse_Handler,
+		},
+		{
+			MethodName: "ForceUpdate",
+			Handler:    _HeadscaleService_ForceUpdate_Handler,
+		},
+		{
+			MethodName: "GetServerInfo",
+			Handler:    _HeadscaleService_GetServerInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMachines",
+			Handler:       _HeadscaleService_WatchMachines_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "headscale/v1/headscale.proto",
 }
@@ -3,7 +3,11 @@ package headscale
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/netip"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,7 +15,10 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
 )
 
 type headscaleV1APIServer struct { // v1.HeadscaleServiceServer
@@ -34,7 +41,12 @@ func (api headscaleV1APIServer) GetNamespace(
 		return nil, err
 	}
 
-	return &v1.GetNamespaceResponse{Namespace: namespace.toProto()}, nil
+	protoNamespace, err := api.h.namespaceToProto(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.GetNamespaceResponse{Namespace: protoNamespace}, nil
 }
 
 func (api headscaleV1APIServer) CreateNamespace(
@@ -42,11 +54,22 @@ func (api headscaleV1APIServer) CreateNamespace(
 	request *v1.CreateNamespaceRequest,
 ) (*v1.CreateNamespaceResponse, error) {
 	namespace, err := api.h.CreateNamespace(request.GetName())
+	if errors.Is(err, ErrInvalidNamespaceName) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrNamespaceExists) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	protoNamespace, err := api.h.namespaceToProto(namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	return &v1.CreateNamespaceResponse{Namespace: namespace.toProto()}, nil
+	return &v1.CreateNamespaceResponse{Namespace: protoNamespace}, nil
 }
 
 func (api headscaleV1APIServer) RenameNamespace(
@@ -63,7 +86,83 @@ func (api headscaleV1APIServer) RenameNamespace(
 		return nil, err
 	}
 
-	return &v1.RenameNamespaceResponse{Namespace: namespace.toProto()}, nil
+	protoNamespace, err := api.h.namespaceToProto(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.RenameNamespaceResponse{Namespace: protoNamespace}, nil
+}
+
+func (api headscaleV1APIServer) SetNamespaceTags(
+	ctx context.Context,
+	request *v1.SetNamespaceTagsRequest,
+) (*v1.SetNamespaceTagsResponse, error) {
+	for _, tag := range request.GetTags() {
+		err := validateTag(tag)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	namespace, err := api.h.SetNamespaceTags(request.GetName(), request.GetTags())
+	if errors.Is(err, ErrNamespaceNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, ErrTagNotInTagOwners) || errors.Is(err, ErrNamespaceTagNotOwned) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	protoNamespace, err := api.h.namespaceToProto(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.SetNamespaceTagsResponse{Namespace: protoNamespace}, nil
+}
+
+func (api headscaleV1APIServer) SetNamespaceACLPolicy(
+	ctx context.Context,
+	request *v1.SetNamespaceACLPolicyRequest,
+) (*v1.SetNamespaceACLPolicyResponse, error) {
+	warnings, err := api.h.SetNamespaceACLPolicy(request.GetName(), request.GetPolicy())
+	if errors.Is(err, ErrNamespaceNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, ErrInvalidNamespaceACLPolicy) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.SetNamespaceACLPolicyResponse{Warnings: warnings}, nil
+}
+
+func (api headscaleV1APIServer) SetNamespaceQuota(
+	ctx context.Context,
+	request *v1.SetNamespaceQuotaRequest,
+) (*v1.SetNamespaceQuotaResponse, error) {
+	namespace, err := api.h.SetNamespaceMachineQuota(
+		request.GetName(),
+		uint(request.GetMachineQuota()),
+	)
+	if errors.Is(err, ErrNamespaceNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	protoNamespace, err := api.h.namespaceToProto(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.SetNamespaceQuotaResponse{Namespace: protoNamespace}, nil
 }
 
 func (api headscaleV1APIServer) DeleteNamespace(
@@ -88,8 +187,11 @@ func (api headscaleV1APIServer) ListNamespaces(
 	}
 
 	response := make([]*v1.Namespace, len(namespaces))
-	for index, namespace := range namespaces {
-		response[index] = namespace.toProto()
+	for index := range namespaces {
+		response[index], err = api.h.namespaceToProto(&namespaces[index])
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	log.Trace().Caller().Interface("namespaces", response).Msg("")
@@ -106,13 +208,37 @@ func (api headscaleV1APIServer) CreatePreAuthKey(
 		expiration = request.GetExpiration().AsTime()
 	}
 
+	for _, tag := range request.GetAclTags() {
+		err := validateTag(tag)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	var machineExpiry *time.Duration
+	if request.GetMachineExpiry() != nil {
+		duration := request.GetMachineExpiry().AsDuration()
+		machineExpiry = &duration
+	}
+
 	preAuthKey, err := api.h.CreatePreAuthKey(
 		request.GetNamespace(),
 		request.GetReusable(),
 		request.GetEphemeral(),
 		&expiration,
+		request.GetLabel(),
+		uint(request.GetMaxUses()),
+		request.GetAclTags(),
+		machineExpiry,
 	)
 	if err != nil {
+		if errors.Is(err, ErrPreAuthKeyLabelTooLong) ||
+			errors.Is(err, ErrPreAuthKeyNegativeMachineExpiry) ||
+			errors.Is(err, ErrTagNotInTagOwners) ||
+			errors.Is(err, ErrPreAuthKeyTagNotOwned) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
 		return nil, err
 	}
 
@@ -145,9 +271,24 @@ func (api headscaleV1APIServer) ListPreAuthKeys(
 		return nil, err
 	}
 
+	machinesByKey, err := api.h.getPreAuthKeysMachines(preAuthKeys)
+	if err != nil {
+		return nil, err
+	}
+
 	response := make([]*v1.PreAuthKey, len(preAuthKeys))
 	for index, key := range preAuthKeys {
 		response[index] = key.toProto()
+
+		machines := machinesByKey[key.ID]
+		response[index].Machines = make([]*v1.PreAuthKeyMachine, len(machines))
+		for machineIndex, machine := range machines {
+			response[index].Machines[machineIndex] = &v1.PreAuthKeyMachine{
+				Id:        machine.ID,
+				Name:      machine.Hostname,
+				GivenName: machine.GivenName,
+			}
+		}
 	}
 
 	return &v1.ListPreAuthKeysResponse{PreAuthKeys: response}, nil
@@ -166,7 +307,11 @@ func (api headscaleV1APIServer) RegisterMachine(
 		request.GetKey(),
 		request.GetNamespace(),
 		RegisterMethodCLI,
+		nil,
 	)
+	if errors.Is(err, ErrNamespaceMachineQuotaExceeded) || errors.Is(err, ErrCouldNotAllocateIP) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -183,7 +328,15 @@ func (api headscaleV1APIServer) GetMachine(
 		return nil, err
 	}
 
-	return &v1.GetMachineResponse{Machine: machine.toProto()}, nil
+	response := &v1.GetMachineResponse{
+		Machine: machine.toProto(),
+		Online:  api.h.isMachineOnline(*machine),
+	}
+	if machine.LastSeen != nil {
+		response.LastSeen = timestamppb.New(*machine.LastSeen)
+	}
+
+	return response, nil
 }
 
 func (api headscaleV1APIServer) SetTags(
@@ -219,6 +372,50 @@ func (api headscaleV1APIServer) SetTags(
 	return &v1.SetTagsResponse{Machine: machine.toProto()}, nil
 }
 
+func (api headscaleV1APIServer) BulkSetNamespaceTags(
+	ctx context.Context,
+	request *v1.BulkSetNamespaceTagsRequest,
+) (*v1.BulkSetNamespaceTagsResponse, error) {
+	var mode BulkTagMode
+	switch request.GetMode() {
+	case v1.BulkTagMode_BULK_TAG_MODE_ADD:
+		mode = BulkTagModeAdd
+	case v1.BulkTagMode_BULK_TAG_MODE_REMOVE:
+		mode = BulkTagModeRemove
+	case v1.BulkTagMode_BULK_TAG_MODE_REPLACE:
+		mode = BulkTagModeReplace
+	default:
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidBulkTagMode.Error())
+	}
+
+	for _, tag := range request.GetTags() {
+		err := validateTag(tag)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	affected, err := api.h.BulkSetNamespaceTags(
+		request.GetNamespace(),
+		request.GetTags(),
+		mode,
+	)
+	if errors.Is(err, ErrTagNotInTagOwners) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Trace().
+		Str("namespace", request.GetNamespace()).
+		Strs("tags", request.GetTags()).
+		Int("affected", affected).
+		Msg("Changing tags of machines in namespace")
+
+	return &v1.BulkSetNamespaceTagsResponse{AffectedMachines: uint64(affected)}, nil
+}
+
 func validateTag(tag string) error {
 	if strings.Index(tag, "tag:") != 0 {
 		return fmt.Errorf("tag must start with the string 'tag:'")
@@ -251,6 +448,28 @@ func (api headscaleV1APIServer) DeleteMachine(
 	return &v1.DeleteMachineResponse{}, nil
 }
 
+func (api headscaleV1APIServer) RestoreMachine(
+	ctx context.Context,
+	request *v1.RestoreMachineRequest,
+) (*v1.RestoreMachineResponse, error) {
+	machine, err := api.h.RestoreMachine(request.GetMachineId())
+	if errors.Is(err, ErrDeletedMachineNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, ErrMachineRestoreConflict) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Trace().
+		Str("machine", machine.Hostname).
+		Msg("machine restored")
+
+	return &v1.RestoreMachineResponse{Machine: machine.toProto()}, nil
+}
+
 func (api headscaleV1APIServer) ExpireMachine(
 	ctx context.Context,
 	request *v1.ExpireMachineRequest,
@@ -272,6 +491,108 @@ func (api headscaleV1APIServer) ExpireMachine(
 	return &v1.ExpireMachineResponse{Machine: machine.toProto()}, nil
 }
 
+func (api headscaleV1APIServer) ExpireNamespaceMachines(
+	ctx context.Context,
+	request *v1.ExpireNamespaceMachinesRequest,
+) (*v1.ExpireNamespaceMachinesResponse, error) {
+	affected, err := api.h.ExpireNamespaceMachines(request.GetNamespace())
+	if errors.Is(err, ErrNamespaceNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Trace().
+		Str("namespace", request.GetNamespace()).
+		Int("affected", affected).
+		Msg("machines of namespace expired")
+
+	return &v1.ExpireNamespaceMachinesResponse{AffectedMachines: uint64(affected)}, nil
+}
+
+func (api headscaleV1APIServer) ExtendMachineExpiry(
+	ctx context.Context,
+	request *v1.ExtendMachineExpiryRequest,
+) (*v1.ExtendMachineExpiryResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.h.ExtendMachineExpiry(
+		machine,
+		request.GetDuration().AsDuration(),
+	)
+	if errors.Is(err, ErrMachineHasNoExpiry) ||
+		errors.Is(err, ErrInvalidExpiryExtension) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Trace().
+		Str("machine", machine.Hostname).
+		Time("expiry", *machine.Expiry).
+		Msg("machine expiry extended")
+
+	return &v1.ExtendMachineExpiryResponse{Machine: machine.toProto()}, nil
+}
+
+func (api headscaleV1APIServer) SetMachineExpiry(
+	ctx context.Context,
+	request *v1.SetMachineExpiryRequest,
+) (*v1.SetMachineExpiryResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	var expiry *time.Time
+	if request.GetExpiry() != nil {
+		requestedExpiry := request.GetExpiry().AsTime()
+		expiry = &requestedExpiry
+	}
+
+	err = api.h.SetMachineExpiry(machine, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Trace().
+		Str("machine", machine.Hostname).
+		Bool("expires", machine.Expiry != nil).
+		Msg("machine expiry set")
+
+	return &v1.SetMachineExpiryResponse{Machine: machine.toProto()}, nil
+}
+
+func (api headscaleV1APIServer) RevokeMachineSession(
+	ctx context.Context,
+	request *v1.RevokeMachineSessionRequest,
+) (*v1.RevokeMachineSessionResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := api.h.RevokeMachineSession(ctx, machine)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Trace().
+		Str("machine", machine.Hostname).
+		Bool("revoked", revoked).
+		Msg("machine session revoked")
+
+	return &v1.RevokeMachineSessionResponse{
+		Machine:        machine.toProto(),
+		SessionRevoked: revoked,
+	}, nil
+}
+
 func (api headscaleV1APIServer) RenameMachine(
 	ctx context.Context,
 	request *v1.RenameMachineRequest,
@@ -285,13 +606,19 @@ func (api headscaleV1APIServer) RenameMachine(
 		machine,
 		request.GetNewName(),
 	)
+	if errors.Is(err, ErrInvalidNamespaceName) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrMachineNameExists) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	log.Trace().
 		Str("machine", machine.Hostname).
-		Str("new_name", request.GetNewName()).
+		Str("new_name", machine.GivenName).
 		Msg("machine renamed")
 
 	return &v1.RenameMachineResponse{Machine: machine.toProto()}, nil
@@ -301,30 +628,31 @@ func (api headscaleV1APIServer) ListMachines(
 	ctx context.Context,
 	request *v1.ListMachinesRequest,
 ) (*v1.ListMachinesResponse, error) {
-	if request.GetNamespace() != "" {
-		machines, err := api.h.ListMachinesInNamespace(request.GetNamespace())
-		if err != nil {
-			return nil, err
-		}
-
-		response := make([]*v1.Machine, len(machines))
-		for index, machine := range machines {
-			response[index] = machine.toProto()
+	machines, nextPageToken, err := api.h.ListMachinesPage(
+		request.GetNamespace(),
+		request.GetOnlineOnly(),
+		int(request.GetPageSize()),
+		request.GetPageToken(),
+	)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
 
-		return &v1.ListMachinesResponse{Machines: response}, nil
-	}
-
-	machines, err := api.h.ListMachines()
-	if err != nil {
 		return nil, err
 	}
 
 	response := make([]*v1.Machine, len(machines))
 	for index, machine := range machines {
+		if request.GetNamespace() != "" {
+			response[index] = machine.toProto()
+
+			continue
+		}
+
 		m := machine.toProto()
 		validTags, invalidTags := getTags(
-			api.h.aclPolicy,
+			api.h.getACLPolicy(),
 			machine,
 			api.h.cfg.OIDC.StripEmaildomain,
 		)
@@ -333,7 +661,10 @@ func (api headscaleV1APIServer) ListMachines(
 		response[index] = m
 	}
 
-	return &v1.ListMachinesResponse{Machines: response}, nil
+	return &v1.ListMachinesResponse{
+		Machines:      response,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 func (api headscaleV1APIServer) MoveMachine(
@@ -346,6 +677,9 @@ func (api headscaleV1APIServer) MoveMachine(
 	}
 
 	err = api.h.SetMachineNamespace(machine, request.GetNamespace())
+	if errors.Is(err, ErrNamespaceNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -353,6 +687,133 @@ func (api headscaleV1APIServer) MoveMachine(
 	return &v1.MoveMachineResponse{Machine: machine.toProto()}, nil
 }
 
+func (api headscaleV1APIServer) ReassignMachineIP(
+	ctx context.Context,
+	request *v1.ReassignMachineIPRequest,
+) (*v1.ReassignMachineIPResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.h.ReassignMachineIP(machine)
+	if errors.Is(err, ErrCouldNotAllocateIP) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.ReassignMachineIPResponse{Machine: machine.toProto()}, nil
+}
+
+func (api headscaleV1APIServer) RotateMachineKey(
+	ctx context.Context,
+	request *v1.RotateMachineKeyRequest,
+) (*v1.RotateMachineKeyResponse, error) {
+	var machineKey key.MachinePublic
+	err := machineKey.UnmarshalText(
+		[]byte(MachinePublicKeyEnsurePrefix(request.GetMachineKey())),
+	)
+	if err != nil {
+		return nil, status.Error(
+			codes.InvalidArgument,
+			fmt.Sprintf("%s: %s", ErrInvalidMachineKey, err),
+		)
+	}
+
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.h.RotateMachineKey(machine, machineKey)
+	if errors.Is(err, ErrInvalidMachineKey) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrMachineKeyInUse) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.RotateMachineKeyResponse{Machine: machine.toProto()}, nil
+}
+
+// WatchMachines sends a snapshot of the machines, then an event every time
+// a machine is created, deleted, comes online or goes offline.
+// A watcher lagging too far behind is disconnected, and gets a new snapshot
+// when it reconnects.
+func (api headscaleV1APIServer) WatchMachines(
+	request *v1.WatchMachinesRequest,
+	stream v1.HeadscaleService_WatchMachinesServer,
+) error {
+	var namespace *Namespace
+	if request.GetNamespace() != "" {
+		var err error
+		namespace, err = api.h.GetNamespace(request.GetNamespace())
+		if err != nil {
+			return err
+		}
+	}
+
+	// subscribe before taking the snapshot, so no event is missed in between
+	events := api.h.machineEvents.subscribe()
+	defer api.h.machineEvents.unsubscribe(events)
+
+	var machines []Machine
+	var err error
+	if namespace != nil {
+		machines, err = api.h.ListMachinesInNamespace(namespace.Name)
+	} else {
+		machines, err = api.h.ListMachines()
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, machine := range machines {
+		if err := stream.Send(&v1.MachineEvent{
+			Type:    v1.MachineEventType_MACHINE_EVENT_TYPE_SNAPSHOT,
+			Machine: machine.toProto(),
+			Online:  api.h.isMachineOnline(machine),
+		}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case <-api.h.shutdownChan:
+			return nil
+
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(
+					codes.ResourceExhausted,
+					"machine events are not consumed fast enough, reconnect to get a new snapshot",
+				)
+			}
+
+			if namespace != nil && event.machine.NamespaceID != namespace.ID {
+				continue
+			}
+
+			if err := stream.Send(&v1.MachineEvent{
+				Type:    event.eventType,
+				Machine: event.machine.toProto(),
+				Online:  event.online,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (api headscaleV1APIServer) GetMachineRoute(
 	ctx context.Context,
 	request *v1.GetMachineRouteRequest,
@@ -376,7 +837,15 @@ func (api headscaleV1APIServer) EnableMachineRoutes(
 		return nil, err
 	}
 
-	err = api.h.EnableRoutes(machine, request.GetRoutes()...)
+	routes := request.GetRoutes()
+	if request.GetExitNode() {
+		routes, err = machine.withExitRoutes(routes)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	err = api.h.EnableRoutes(machine, routes...)
 	if err != nil {
 		return nil, err
 	}
@@ -386,6 +855,81 @@ func (api headscaleV1APIServer) EnableMachineRoutes(
 	}, nil
 }
 
+func (api headscaleV1APIServer) EnableRoutesBatch(
+	ctx context.Context,
+	request *v1.EnableRoutesBatchRequest,
+) (*v1.EnableRoutesBatchResponse, error) {
+	batch := make([]MachineRoutes, len(request.GetMachines()))
+	for index, entry := range request.GetMachines() {
+		batch[index] = MachineRoutes{
+			MachineID: entry.GetMachineId(),
+			Routes:    entry.GetRoutes(),
+			ExitNode:  entry.GetExitNode(),
+		}
+	}
+
+	machines, err := api.h.EnableRoutesBatch(batch)
+	if errors.Is(err, ErrMachineNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	var batchErr RoutesBatchError
+	if errors.As(err, &batchErr) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*v1.MachineRoutes, len(machines))
+	for index, machine := range machines {
+		response[index] = &v1.MachineRoutes{
+			MachineId: machine.ID,
+			Routes:    machine.RoutesToProto(),
+		}
+	}
+
+	return &v1.EnableRoutesBatchResponse{Machines: response}, nil
+}
+
+func (api headscaleV1APIServer) ListAvailableExitNodes(
+	ctx context.Context,
+	request *v1.ListAvailableExitNodesRequest,
+) (*v1.ListAvailableExitNodesResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	exitNodes, err := api.h.getAvailableExitNodes(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*v1.Machine, len(exitNodes))
+	for index, exitNode := range exitNodes {
+		response[index] = exitNode.toProto()
+	}
+
+	return &v1.ListAvailableExitNodesResponse{Machines: response}, nil
+}
+
+func (api headscaleV1APIServer) ListMachinesByRoute(
+	ctx context.Context,
+	request *v1.ListMachinesByRouteRequest,
+) (*v1.ListMachinesByRouteResponse, error) {
+	prefix, err := netip.ParsePrefix(request.GetPrefix())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	machines, err := api.h.listMachinesByRoute(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.ListMachinesByRouteResponse{Machines: machines}, nil
+}
+
 func (api headscaleV1APIServer) CreateApiKey(
 	ctx context.Context,
 	request *v1.CreateApiKeyRequest,
@@ -397,8 +941,13 @@ func (api headscaleV1APIServer) CreateApiKey(
 
 	apiKey, _, err := api.h.CreateAPIKey(
 		&expiration,
+		request.GetScopes(),
 	)
 	if err != nil {
+		if errors.Is(err, ErrAPIKeyInvalidScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
 		return nil, err
 	}
 
@@ -487,13 +1036,358 @@ func (api headscaleV1APIServer) DebugCreateMachine(
 		HostInfo: HostInfo(hostinfo),
 	}
 
-	api.h.registrationCache.Set(
+	api.h.setRegistrationCache(
 		request.GetKey(),
 		newMachine,
-		registerCacheExpiration,
 	)
 
 	return &v1.DebugCreateMachineResponse{Machine: newMachine.toProto()}, nil
 }
 
+func (api headscaleV1APIServer) GenerateDNSRecords(
+	ctx context.Context,
+	request *v1.GenerateDNSRecordsRequest,
+) (*v1.GenerateDNSRecordsResponse, error) {
+	records, err := api.h.generateDNSRecords()
+	if err != nil {
+		if errors.Is(err, errDNSBaseDomainNotSet) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+
+		return nil, err
+	}
+
+	response := make([]*v1.DNSRecord, len(records))
+	for index, record := range records {
+		response[index] = &v1.DNSRecord{
+			Name:      record.Name,
+			Type:      record.Type,
+			Value:     record.Value,
+			MachineId: record.MachineID,
+		}
+	}
+
+	return &v1.GenerateDNSRecordsResponse{
+		Records:  response,
+		ZoneFile: dnsRecordsToZoneFile(records),
+	}, nil
+}
+
+func (api headscaleV1APIServer) CheckACLPolicy(
+	ctx context.Context,
+	request *v1.CheckACLPolicyRequest,
+) (*v1.CheckACLPolicyResponse, error) {
+	ruleCount, warnings, err := api.h.CheckACLPolicy(request.GetPolicy())
+	if err != nil {
+		policyError := &v1.ACLPolicyError{
+			Message:  err.Error(),
+			AclIndex: -1,
+		}
+		var aclErr ACLError
+		if errors.As(err, &aclErr) {
+			policyError.Message = aclErr.Err.Error()
+			policyError.AclIndex = int32(aclErr.Index)
+			policyError.Field = aclErr.Field
+		}
+
+		return &v1.CheckACLPolicyResponse{Error: policyError}, nil
+	}
+
+	return &v1.CheckACLPolicyResponse{
+		Valid:     true,
+		RuleCount: uint64(ruleCount),
+		Warnings:  warnings,
+	}, nil
+}
+
+func (api headscaleV1APIServer) GetACLRules(
+	ctx context.Context,
+	request *v1.GetACLRulesRequest,
+) (*v1.GetACLRulesResponse, error) {
+	_, rules, ruleIndexes := api.h.getACL()
+
+	response := &v1.GetACLRulesResponse{
+		Rules: make([]*v1.ACLRule, len(rules)),
+	}
+	for index, rule := range rules {
+		aclIndex := -1
+		if index < len(ruleIndexes) {
+			aclIndex = ruleIndexes[index]
+		}
+		response.Rules[index] = filterRuleToProto(rule, aclIndex)
+	}
+
+	return response, nil
+}
+
+func (api headscaleV1APIServer) PreviewMachineRules(
+	ctx context.Context,
+	request *v1.PreviewMachineRulesRequest,
+) (*v1.PreviewMachineRulesResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	rules, ruleIndexes, err := api.h.previewMachineRules(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &v1.PreviewMachineRulesResponse{
+		IpAddresses: machine.IPAddresses.ToStringSlice(),
+		Rules:       make([]*v1.ACLRule, len(rules)),
+	}
+	for index, rule := range rules {
+		response.Rules[index] = filterRuleToProto(rule, ruleIndexes[index])
+	}
+
+	return response, nil
+}
+
+func (api headscaleV1APIServer) GetACLPolicy(
+	ctx context.Context,
+	request *v1.GetACLPolicyRequest,
+) (*v1.GetACLPolicyResponse, error) {
+	policy, err := api.h.GetACLPolicy()
+	if errors.Is(err, errACLPolicyNotSet) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.GetACLPolicyResponse{Policy: policy}, nil
+}
+
+func (api headscaleV1APIServer) SetACLPolicy(
+	ctx context.Context,
+	request *v1.SetACLPolicyRequest,
+) (*v1.SetACLPolicyResponse, error) {
+	warnings, err := api.h.SetACLPolicy(request.GetPolicy())
+	if errors.Is(err, errACLPolicyNotInDatabase) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, ErrInvalidACLPolicy) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.SetACLPolicyResponse{Warnings: warnings}, nil
+}
+
+func (api headscaleV1APIServer) DebugNotifierState(
+	ctx context.Context,
+	request *v1.DebugNotifierStateRequest,
+) (*v1.DebugNotifierStateResponse, error) {
+	machines := []Machine{}
+	api.h.clientsUpdateChannels.Range(
+		func(machineKeyStr string, _ chan struct{}) bool {
+			var machineKey key.MachinePublic
+			err := machineKey.UnmarshalText(
+				[]byte(MachinePublicKeyEnsurePrefix(machineKeyStr)),
+			)
+			if err != nil {
+				return true
+			}
+
+			machine, err := api.h.GetMachineByMachineKey(machineKey)
+			if err != nil {
+				log.Error().
+					Caller().
+					Err(err).
+					Str("machine_key", machineKeyStr).
+					Msg("Cannot find subscribed machine in the database")
+
+				return true
+			}
+			machines = append(machines, *machine)
+
+			return true
+		},
+	)
+
+	lastStateChange, updateChannels, clients := api.h.notifierSnapshot(machines)
+
+	return &v1.DebugNotifierStateResponse{
+		SubscribedClients: updateChannels,
+		LastStateChange:   timestamppb.New(lastStateChange),
+		Clients:           clients,
+	}, nil
+}
+
+// GetDebugInfo reports the same as DebugNotifierState for every machine,
+// connected or not.
+func (api headscaleV1APIServer) GetDebugInfo(
+	ctx context.Context,
+	request *v1.GetDebugInfoRequest,
+) (*v1.GetDebugInfoResponse, error) {
+	machines, err := api.h.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	lastStateChange, updateChannels, clients := api.h.notifierSnapshot(machines)
+
+	return &v1.GetDebugInfoResponse{
+		LastStateChange: timestamppb.New(lastStateChange),
+		UpdateChannels:  updateChannels,
+		Machines:        clients,
+	}, nil
+}
+
+// notifierSnapshot returns the last state change, the number of update
+// channels, and how long the last successful update of each of the machines
+// is behind the last state change, sorted by machine ID.
+func (h *Headscale) notifierSnapshot(
+	machines []Machine,
+) (time.Time, uint32, []*v1.DebugNotifierClient) {
+	lastStateChange := h.getLastStateChange()
+
+	clients := make([]*v1.DebugNotifierClient, 0, len(machines))
+	for index := range machines {
+		machine := &machines[index]
+		client := &v1.DebugNotifierClient{
+			MachineId: machine.ID,
+			Name:      machine.GivenName,
+			Namespace: machine.Namespace.Name,
+		}
+
+		lastUpdate := machine.CreatedAt
+		if machine.LastSuccessfulUpdate != nil {
+			lastUpdate = *machine.LastSuccessfulUpdate
+			client.LastSuccessfulUpdate = timestamppb.New(lastUpdate)
+		}
+
+		if lag := lastStateChange.Sub(lastUpdate); lag > 0 {
+			client.UpdateLag = durationpb.New(lag)
+			client.Outdated = true
+		}
+
+		clients = append(clients, client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].MachineId < clients[j].MachineId
+	})
+
+	return lastStateChange, uint32(h.clientsUpdateChannels.Size()), clients
+}
+
+func (api headscaleV1APIServer) DebugGetMapResponse(
+	ctx context.Context,
+	request *v1.DebugGetMapResponseRequest,
+) (*v1.DebugGetMapResponseResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	hostinfo := tailcfg.Hostinfo(machine.HostInfo)
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo: &hostinfo,
+	}
+
+	mapResponse, err := api.h.generateMapResponse(mapRequest, machine)
+	if err != nil {
+		return nil, err
+	}
+
+	mapResponseJSON, err := json.MarshalIndent(mapResponse, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	peers, err := api.h.getValidPeers(machine)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+
+	debugPeers := make([]*v1.DebugPeer, len(peers))
+	for index := range peers {
+		peer := &peers[index]
+
+		reasons := []*v1.DebugPeerReason{}
+		for _, reason := range api.h.getPeerReasons(machine, peer) {
+			reasons = append(reasons, &v1.DebugPeerReason{
+				AclIndex:     int32(reason.ACLIndex),
+				Sources:      reason.Sources,
+				Destinations: reason.Destinations,
+				Description:  reason.Description,
+			})
+		}
+
+		debugPeers[index] = &v1.DebugPeer{
+			MachineId: peer.ID,
+			Name:      peer.GivenName,
+			Namespace: peer.Namespace.Name,
+			Reasons:   reasons,
+		}
+	}
+
+	tagGrants, err := api.h.getTagGrants(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	debugTagGrants := make([]*v1.DebugTagGrant, len(tagGrants))
+	for index, grant := range tagGrants {
+		aclIndexes := make([]int32, len(grant.ACLIndexes))
+		for i, aclIndex := range grant.ACLIndexes {
+			aclIndexes[i] = int32(aclIndex)
+		}
+
+		debugTagGrants[index] = &v1.DebugTagGrant{
+			Tag:        grant.Tag,
+			AclIndexes: aclIndexes,
+			Namespaces: grant.Namespaces,
+		}
+	}
+
+	return &v1.DebugGetMapResponseResponse{
+		MapResponse: string(mapResponseJSON),
+		Peers:       debugPeers,
+		TagGrants:   debugTagGrants,
+	}, nil
+}
+
+func (api headscaleV1APIServer) ForceUpdate(
+	ctx context.Context,
+	request *v1.ForceUpdateRequest,
+) (*v1.ForceUpdateResponse, error) {
+	machine, err := api.h.GetMachineByID(request.GetMachineId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.h.forceUpdate(machine)
+	if errors.Is(err, ErrMachineNotConnected) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.ForceUpdateResponse{}, nil
+}
+
+// GetServerInfo lets the tools adapt to the server. It must not return
+// anything secret, every API key can call it whatever its scopes.
+func (api headscaleV1APIServer) GetServerInfo(
+	ctx context.Context,
+	request *v1.GetServerInfoRequest,
+) (*v1.GetServerInfoResponse, error) {
+	return &v1.GetServerInfoResponse{
+		Version:             api.h.cfg.Version,
+		ServerUrl:           api.h.cfg.ServerURL,
+		OidcEnabled:         len(api.h.cfg.OIDCProviders) > 0,
+		EmbeddedDerpEnabled: api.h.cfg.DERP.ServerEnabled,
+		AclPolicyMode:       api.h.cfg.ACL.PolicyMode,
+		Features:            api.h.enabledFeatures(),
+	}, nil
+}
+
 func (api headscaleV1APIServer) mustEmbedUnimplementedHeadscaleServiceServer() {}
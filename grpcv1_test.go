@@ -1,6 +1,23 @@
 package headscale
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/check.v1"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+)
 
 func Test_validateTag(t *testing.T) {
 	type args struct {
@@ -40,3 +57,849 @@ func Test_validateTag(t *testing.T) {
 		})
 	}
 }
+
+func (s *Suite) TestDebugNotifierState(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	lastUpdate := time.Now().UTC().Add(-time.Minute)
+	machineKey := key.NewMachine()
+	machine := Machine{
+		ID:                   0,
+		MachineKey:           MachinePublicKeyStripPrefix(machineKey.Public()),
+		NodeKey:              "bar",
+		DiscoKey:             "faa",
+		Hostname:             "testmachine",
+		GivenName:            "testmachine",
+		NamespaceID:          namespace.ID,
+		RegisterMethod:       RegisterMethodAuthKey,
+		LastSuccessfulUpdate: &lastUpdate,
+	}
+	app.db.Save(&machine)
+
+	app.setLastStateChangeToNow()
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.DebugNotifierState(
+		context.Background(),
+		&v1.DebugNotifierStateRequest{},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetSubscribedClients(), check.Equals, uint32(0))
+	c.Assert(response.GetClients(), check.HasLen, 0)
+
+	app.clientsUpdateChannels.Store(machine.MachineKey, make(chan struct{}))
+
+	response, err = api.DebugNotifierState(
+		context.Background(),
+		&v1.DebugNotifierStateRequest{},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetSubscribedClients(), check.Equals, uint32(1))
+	c.Assert(response.GetClients(), check.HasLen, 1)
+
+	client := response.GetClients()[0]
+	c.Assert(client.GetMachineId(), check.Equals, machine.ID)
+	c.Assert(client.GetNamespace(), check.Equals, "test")
+	c.Assert(client.GetOutdated(), check.Equals, true)
+	c.Assert(client.GetUpdateLag().AsDuration() >= time.Minute, check.Equals, true)
+}
+
+func (s *Suite) TestGetDebugInfo(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	lastUpdate := time.Now().UTC().Add(-time.Minute)
+	var connectedKey string
+	for index, hostname := range []string{"connected", "disconnected"} {
+		machine := Machine{
+			ID:                   uint64(index + 1),
+			MachineKey:           MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:              NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:             DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:             hostname,
+			GivenName:            hostname,
+			NamespaceID:          namespace.ID,
+			RegisterMethod:       RegisterMethodAuthKey,
+			LastSuccessfulUpdate: &lastUpdate,
+		}
+		c.Assert(app.db.Save(&machine).Error, check.IsNil)
+		if hostname == "connected" {
+			connectedKey = machine.MachineKey
+			app.clientsUpdateChannels.Store(connectedKey, make(chan struct{}))
+		}
+	}
+
+	app.setLastStateChangeToNow()
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.GetDebugInfo(context.Background(), &v1.GetDebugInfoRequest{})
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetUpdateChannels(), check.Equals, uint32(1))
+	c.Assert(
+		response.GetLastStateChange().AsTime().Equal(app.getLastStateChange()),
+		check.Equals,
+		true,
+	)
+
+	// the disconnected machines are listed as well
+	c.Assert(response.GetMachines(), check.HasLen, 2)
+	for _, machine := range response.GetMachines() {
+		c.Assert(machine.GetNamespace(), check.Equals, "test")
+		c.Assert(machine.GetOutdated(), check.Equals, true)
+		c.Assert(machine.GetUpdateLag().AsDuration() >= time.Minute, check.Equals, true)
+	}
+
+	app.clientsUpdateChannels.Delete(connectedKey)
+}
+
+func (s *Suite) TestListMachinesOnlineOnly(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	now := time.Now().UTC()
+	anHourAgo := now.Add(-time.Hour)
+	machines := []Machine{
+		// holds a long poll
+		{ID: 1, LastSeen: &now},
+		// its long poll is stale
+		{ID: 2, LastSeen: &anHourAgo},
+		// has been seen recently, but is disconnected
+		{ID: 3, LastSeen: &now},
+		// last seen an hour ago
+		{ID: 4, LastSeen: &anHourAgo},
+	}
+	for index := range machines {
+		machines[index].MachineKey = MachinePublicKeyStripPrefix(key.NewMachine().Public())
+		machines[index].NodeKey = fmt.Sprintf("bar%d", index)
+		machines[index].DiscoKey = fmt.Sprintf("faa%d", index)
+		machines[index].Hostname = fmt.Sprintf("testmachine%d", index)
+		machines[index].NamespaceID = namespace.ID
+		machines[index].RegisterMethod = RegisterMethodAuthKey
+		app.db.Save(&machines[index])
+	}
+	app.clientsUpdateChannels.Store(machines[0].MachineKey, make(chan struct{}))
+	app.clientsUpdateChannels.Store(machines[1].MachineKey, make(chan struct{}))
+
+	c.Assert(app.isMachineOnline(machines[0]), check.Equals, true)
+	c.Assert(app.isMachineOnline(machines[1]), check.Equals, false)
+	c.Assert(app.isMachineOnline(machines[2]), check.Equals, false)
+	c.Assert(app.isMachineOnline(machines[3]), check.Equals, false)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.ListMachines(
+		context.Background(),
+		&v1.ListMachinesRequest{Namespace: "test", OnlineOnly: true},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachines(), check.HasLen, 1)
+	c.Assert(response.GetMachines()[0].GetId(), check.Equals, machines[0].ID)
+
+	response, err = api.ListMachines(
+		context.Background(),
+		&v1.ListMachinesRequest{Namespace: "test"},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachines(), check.HasLen, 4)
+
+	// once its long poll is closed, the machine is not online anymore
+	app.clientsUpdateChannels.Delete(machines[0].MachineKey)
+
+	response, err = api.ListMachines(
+		context.Background(),
+		&v1.ListMachinesRequest{OnlineOnly: true},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachines(), check.HasLen, 0)
+}
+
+func (s *Suite) TestGetMachineOnline(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	lastSeen := time.Now().UTC()
+	machine := Machine{
+		ID:             0,
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		LastSeen:       &lastSeen,
+	}
+	app.db.Save(&machine)
+
+	api := newHeadscaleV1APIServer(&app)
+	request := &v1.GetMachineRequest{MachineId: machine.ID}
+
+	app.clientsUpdateChannels.Store(machine.MachineKey, make(chan struct{}))
+
+	response, err := api.GetMachine(context.Background(), request)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetOnline(), check.Equals, true)
+	c.Assert(response.GetLastSeen().AsTime().Equal(lastSeen), check.Equals, true)
+
+	// the poll deletes the update channel of the machine when it disconnects
+	app.clientsUpdateChannels.Delete(machine.MachineKey)
+
+	response, err = api.GetMachine(context.Background(), request)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetOnline(), check.Equals, false)
+	c.Assert(response.GetLastSeen().AsTime().Equal(lastSeen), check.Equals, true)
+}
+
+func (s *Suite) TestDebugGetMapResponse(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machines := make([]*Machine, 3)
+	for index := range machines {
+		machine, err := app.RegisterMachine(Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       fmt.Sprintf("testmachine%d", index),
+			GivenName:      fmt.Sprintf("testmachine%d", index),
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		})
+		c.Assert(err, check.IsNil)
+		machines[index] = machine
+	}
+
+	first := machines[0].IPAddresses[0].String()
+	second := machines[1].IPAddresses[0].String()
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{first},
+				Destinations: []string{second + ":22"},
+			},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.DebugGetMapResponse(
+		context.Background(),
+		&v1.DebugGetMapResponseRequest{MachineId: machines[1].ID},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMapResponse(), check.Not(check.Equals), "")
+	c.Assert(response.GetPeers(), check.HasLen, 1)
+
+	peer := response.GetPeers()[0]
+	c.Assert(peer.GetMachineId(), check.Equals, machines[0].ID)
+	c.Assert(peer.GetReasons(), check.HasLen, 1)
+	c.Assert(peer.GetReasons()[0].GetAclIndex(), check.Equals, int32(0))
+	c.Assert(peer.GetReasons()[0].GetSources(), check.DeepEquals, []string{first})
+	c.Assert(
+		peer.GetReasons()[0].GetDestinations(),
+		check.DeepEquals,
+		[]string{second + ":22"},
+	)
+}
+
+func (s *Suite) TestMoveMachine(c *check.C) {
+	oldNamespace, err := app.CreateNamespace("old")
+	c.Assert(err, check.IsNil)
+
+	newNamespace, err := app.CreateNamespace("new")
+	c.Assert(err, check.IsNil)
+
+	machineKey := MachinePublicKeyStripPrefix(key.NewMachine().Public())
+	machine := Machine{
+		ID:             0,
+		MachineKey:     machineKey,
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    oldNamespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		IPAddresses:    MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+	}
+	app.db.Save(&machine)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	_, err = api.MoveMachine(context.Background(), &v1.MoveMachineRequest{
+		MachineId: machine.ID,
+		Namespace: "non-existing-namespace",
+	})
+	c.Assert(status.Code(err), check.Equals, codes.NotFound)
+
+	response, err := api.MoveMachine(context.Background(), &v1.MoveMachineRequest{
+		MachineId: machine.ID,
+		Namespace: newNamespace.Name,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachine().GetNamespace().GetName(), check.Equals, newNamespace.Name)
+
+	moved, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(moved.NamespaceID, check.Equals, newNamespace.ID)
+	c.Assert(moved.MachineKey, check.Equals, machineKey)
+	c.Assert(moved.IPAddresses, check.DeepEquals, machine.IPAddresses)
+	c.Assert(app.getLastStateChange(newNamespace.Name).IsZero(), check.Equals, false)
+}
+
+func (s *Suite) TestListPreAuthKeysMachines(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	used, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	_, err = app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	for index := 0; index < 2; index++ {
+		machine := Machine{
+			ID:             0,
+			MachineKey:     fmt.Sprintf("foo%d", index),
+			NodeKey:        fmt.Sprintf("bar%d", index),
+			DiscoKey:       fmt.Sprintf("faa%d", index),
+			Hostname:       fmt.Sprintf("testmachine%d", index),
+			GivenName:      fmt.Sprintf("given%d", index),
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+			AuthKeyID:      uint(used.ID),
+		}
+		app.db.Save(&machine)
+	}
+
+	api := newHeadscaleV1APIServer(&app)
+	response, err := api.ListPreAuthKeys(
+		context.Background(),
+		&v1.ListPreAuthKeysRequest{Namespace: namespace.Name},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetPreAuthKeys(), check.HasLen, 2)
+
+	for _, preAuthKey := range response.GetPreAuthKeys() {
+		if preAuthKey.GetKey() != used.Key {
+			c.Assert(preAuthKey.GetMachines(), check.HasLen, 0)
+
+			continue
+		}
+
+		c.Assert(preAuthKey.GetMachines(), check.HasLen, 2)
+		c.Assert(preAuthKey.GetMachines()[0].GetName(), check.Equals, "testmachine0")
+		c.Assert(preAuthKey.GetMachines()[1].GetGivenName(), check.Equals, "given1")
+	}
+}
+
+func (s *Suite) TestRenameMachine(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	for index, name := range []string{"testmachine", "other"} {
+		machine := Machine{
+			ID:             uint64(index + 1),
+			MachineKey:     fmt.Sprintf("foo%d", index),
+			NodeKey:        fmt.Sprintf("bar%d", index),
+			DiscoKey:       fmt.Sprintf("faa%d", index),
+			Hostname:       name,
+			GivenName:      name,
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+		app.db.Save(&machine)
+	}
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.RenameMachine(context.Background(), &v1.RenameMachineRequest{
+		MachineId: 1,
+		NewName:   "My Laptop",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachine().GetGivenName(), check.Equals, "my-laptop")
+	c.Assert(response.GetMachine().GetName(), check.Equals, "testmachine")
+
+	_, err = api.RenameMachine(context.Background(), &v1.RenameMachineRequest{
+		MachineId: 1,
+		NewName:   "my.laptop",
+	})
+	c.Assert(status.Code(err), check.Equals, codes.InvalidArgument)
+
+	_, err = api.RenameMachine(context.Background(), &v1.RenameMachineRequest{
+		MachineId: 2,
+		NewName:   "my-laptop",
+	})
+	c.Assert(status.Code(err), check.Equals, codes.AlreadyExists)
+
+	// renaming a machine to its own name is fine
+	_, err = api.RenameMachine(context.Background(), &v1.RenameMachineRequest{
+		MachineId: 1,
+		NewName:   "my-laptop",
+	})
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.GetMachineByID(1)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.GivenName, check.Equals, "my-laptop")
+	c.Assert(machine.Hostname, check.Equals, "testmachine")
+}
+
+func (s *Suite) TestGetACLRules(c *check.C) {
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Protocol:     "tcp",
+				Sources:      []string{"100.64.0.1"},
+				Destinations: []string{"100.64.0.2:22,80-90"},
+			},
+		},
+	}
+	err := app.UpdateACLRules()
+	c.Assert(err, check.IsNil)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.GetACLRules(context.Background(), &v1.GetACLRulesRequest{})
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetRules(), check.HasLen, 1)
+
+	rule := response.GetRules()[0]
+	c.Assert(rule.GetAclIndex(), check.Equals, int32(0))
+	c.Assert(rule.GetSrcIps(), check.DeepEquals, []string{"100.64.0.1"})
+	c.Assert(rule.GetIpProto(), check.DeepEquals, []int32{protocolTCP})
+	c.Assert(rule.GetDstPorts(), check.HasLen, 2)
+	c.Assert(rule.GetDstPorts()[1].GetIp(), check.Equals, "100.64.0.2")
+	c.Assert(rule.GetDstPorts()[1].GetFirstPort(), check.Equals, uint32(80))
+	c.Assert(rule.GetDstPorts()[1].GetLastPort(), check.Equals, uint32(90))
+}
+
+func (s *Suite) TestPreviewMachineRules(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:          1,
+		MachineKey:  "foo",
+		NodeKey:     "bar",
+		DiscoKey:    "faa",
+		Hostname:    "testmachine",
+		NamespaceID: namespace.ID,
+		IPAddresses: MachineAddresses{
+			netip.MustParseAddr("100.64.0.1"),
+			netip.MustParseAddr("fd7a:115c:a1e0::1"),
+		},
+	}
+	app.db.Save(&machine)
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"100.64.0.2"},
+				Destinations: []string{"100.64.0.1:22"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"100.64.0.0/24"},
+				Destinations: []string{"100.64.0.3:80"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"fd7a:115c:a1e0::1"},
+				Destinations: []string{"100.64.0.4:443"},
+			},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.PreviewMachineRules(
+		context.Background(),
+		&v1.PreviewMachineRulesRequest{MachineId: 1},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(
+		response.GetIpAddresses(),
+		check.DeepEquals,
+		[]string{"100.64.0.1", "fd7a:115c:a1e0::1"},
+	)
+	// the first rule has the machine as destination only
+	c.Assert(response.GetRules(), check.HasLen, 2)
+	c.Assert(response.GetRules()[0].GetAclIndex(), check.Equals, int32(1))
+	c.Assert(response.GetRules()[0].GetSrcIps(), check.DeepEquals, []string{"100.64.0.0/24"})
+	c.Assert(response.GetRules()[1].GetAclIndex(), check.Equals, int32(2))
+	c.Assert(response.GetRules()[1].GetDstPorts()[0].GetIp(), check.Equals, "100.64.0.4")
+}
+
+func (s *Suite) TestForceUpdateRPC(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:             1,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	}
+	app.db.Save(&machine)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	_, err = api.ForceUpdate(context.Background(), &v1.ForceUpdateRequest{MachineId: 1})
+	c.Assert(status.Code(err), check.Equals, codes.FailedPrecondition)
+
+	updateChan := make(chan struct{}, 1)
+	app.clientsUpdateChannels.Store(machine.MachineKey, updateChan)
+
+	_, err = api.ForceUpdate(context.Background(), &v1.ForceUpdateRequest{MachineId: 1})
+	c.Assert(err, check.IsNil)
+	c.Assert(updateChan, check.HasLen, 1)
+}
+
+func (s *Suite) TestRestoreMachineRPC(c *check.C) {
+	app.cfg.MachineDeletionGracePeriod = time.Hour
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:             1,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	}
+	app.db.Save(&machine)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	_, err = api.RestoreMachine(context.Background(), &v1.RestoreMachineRequest{MachineId: 1})
+	c.Assert(status.Code(err), check.Equals, codes.NotFound)
+
+	_, err = api.DeleteMachine(context.Background(), &v1.DeleteMachineRequest{MachineId: 1})
+	c.Assert(err, check.IsNil)
+
+	response, err := api.RestoreMachine(
+		context.Background(),
+		&v1.RestoreMachineRequest{MachineId: 1},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachine().GetName(), check.Equals, "testmachine")
+}
+
+func (s *Suite) TestSetMachineExpiryRPC(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:             1,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	}
+	app.db.Save(&machine)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	response, err := api.SetMachineExpiry(
+		context.Background(),
+		&v1.SetMachineExpiryRequest{MachineId: 1, Expiry: timestamppb.New(expiry)},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachine().GetExpiry().AsTime().Equal(expiry), check.Equals, true)
+
+	// without an expiry, the machine never expires
+	response, err = api.SetMachineExpiry(
+		context.Background(),
+		&v1.SetMachineExpiryRequest{MachineId: 1},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachine().GetExpiry(), check.IsNil)
+}
+
+func (s *Suite) TestCreateNamespaceRPCErrors(c *check.C) {
+	api := newHeadscaleV1APIServer(&app)
+
+	for _, name := range []string{"", "Namespace", "localhost"} {
+		_, err := api.CreateNamespace(
+			context.Background(),
+			&v1.CreateNamespaceRequest{Name: name},
+		)
+		c.Assert(status.Code(err), check.Equals, codes.InvalidArgument, check.Commentf("name %q", name))
+	}
+
+	_, err := api.CreateNamespace(context.Background(), &v1.CreateNamespaceRequest{Name: "test"})
+	c.Assert(err, check.IsNil)
+
+	_, err = api.CreateNamespace(context.Background(), &v1.CreateNamespaceRequest{Name: "test"})
+	c.Assert(status.Code(err), check.Equals, codes.AlreadyExists)
+}
+
+func (s *Suite) TestSetNamespaceACLPolicyRPCErrors(c *check.C) {
+	api := newHeadscaleV1APIServer(&app)
+
+	_, err := api.SetNamespaceACLPolicy(
+		context.Background(),
+		&v1.SetNamespaceACLPolicyRequest{Name: "test", Policy: []byte(`{"acls": []}`)},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.NotFound)
+
+	_, err = app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	_, err = api.SetNamespaceACLPolicy(
+		context.Background(),
+		&v1.SetNamespaceACLPolicyRequest{Name: "test", Policy: []byte(`{"hosts": {}`)},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.InvalidArgument)
+}
+
+func (s *Suite) TestSetACLPolicyRPCErrors(c *check.C) {
+	api := newHeadscaleV1APIServer(&app)
+
+	_, err := api.GetACLPolicy(context.Background(), &v1.GetACLPolicyRequest{})
+	c.Assert(status.Code(err), check.Equals, codes.NotFound)
+
+	_, err = api.SetACLPolicy(
+		context.Background(),
+		&v1.SetACLPolicyRequest{Policy: []byte(`{"acls": []}`)},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.FailedPrecondition)
+
+	app.cfg.ACL.PolicyMode = ACLPolicyModeDatabase
+
+	_, err = api.SetACLPolicy(
+		context.Background(),
+		&v1.SetACLPolicyRequest{Policy: []byte(`{"acls": []}`)},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.InvalidArgument)
+}
+
+func (s *Suite) TestNamespaceMachineQuota(c *check.C) {
+	_, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	api := newHeadscaleV1APIServer(&app)
+
+	quotaResponse, err := api.SetNamespaceQuota(
+		context.Background(),
+		&v1.SetNamespaceQuotaRequest{Name: "test", MachineQuota: 2},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(quotaResponse.GetNamespace().GetMachineQuota(), check.Equals, uint32(2))
+
+	_, err = api.SetNamespaceQuota(
+		context.Background(),
+		&v1.SetNamespaceQuotaRequest{Name: "does-not-exist", MachineQuota: 2},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.NotFound)
+
+	namespace, err := app.GetNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	newMachine := func(index int) Machine {
+		return Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       fmt.Sprintf("testmachine%d", index),
+			GivenName:      fmt.Sprintf("testmachine%d", index),
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+	}
+
+	var registered *Machine
+	for index := 0; index < 2; index++ {
+		registered, err = app.RegisterMachine(newMachine(index))
+		c.Assert(err, check.IsNil)
+	}
+
+	_, err = app.RegisterMachine(newMachine(2))
+	c.Assert(errors.Is(err, ErrNamespaceMachineQuotaExceeded), check.Equals, true)
+
+	// registering again an existing machine does not count against the quota
+	_, err = app.RegisterMachine(*registered)
+	c.Assert(err, check.IsNil)
+
+	namespaceResponse, err := api.GetNamespace(
+		context.Background(),
+		&v1.GetNamespaceRequest{Name: "test"},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(namespaceResponse.GetNamespace().GetMachineQuota(), check.Equals, uint32(2))
+	c.Assert(namespaceResponse.GetNamespace().GetMachineCount(), check.Equals, uint64(2))
+
+	// 0 removes the quota
+	_, err = app.SetNamespaceMachineQuota("test", 0)
+	c.Assert(err, check.IsNil)
+
+	_, err = app.RegisterMachine(newMachine(2))
+	c.Assert(err, check.IsNil)
+}
+
+func (s *Suite) TestGetServerInfo(c *check.C) {
+	app.cfg.Version = "v0.17.0"
+	app.cfg.ServerURL = "https://headscale.example.com"
+	app.cfg.DERP.ServerEnabled = true
+	app.cfg.ACL.PolicyMode = ACLPolicyModeDatabase
+	app.cfg.DNSConfig = &tailcfg.DNSConfig{Proxied: true}
+	app.cfg.Webhook.URL = "https://hooks.example.com"
+	app.cfg.OIDCProviders = map[string]OIDCConfig{
+		DefaultOIDCProvider: {Issuer: "https://sso.example.com"},
+	}
+
+	api := newHeadscaleV1APIServer(&app)
+
+	response, err := api.GetServerInfo(context.Background(), &v1.GetServerInfoRequest{})
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetVersion(), check.Equals, "v0.17.0")
+	c.Assert(response.GetServerUrl(), check.Equals, "https://headscale.example.com")
+	c.Assert(response.GetOidcEnabled(), check.Equals, true)
+	c.Assert(response.GetEmbeddedDerpEnabled(), check.Equals, true)
+	c.Assert(response.GetAclPolicyMode(), check.Equals, ACLPolicyModeDatabase)
+	c.Assert(response.GetFeatures(), check.DeepEquals, []string{"magic_dns", "webhook"})
+}
+
+func (s *Suite) TestRotateMachineKey(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	privateKey := key.NewMachine()
+	app.privateKey = &privateKey
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      registerCacheExpiration,
+		CleanupInterval: registerCacheCleanup,
+	})
+
+	register := func(hostname string, nodeKey key.NodePublic) *Machine {
+		machine, err := app.RegisterMachine(Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(nodeKey),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       hostname,
+			GivenName:      hostname,
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		})
+		c.Assert(err, check.IsNil)
+
+		return machine
+	}
+	nodeKey := key.NewNode().Public()
+	rotated := register("rotated", nodeKey)
+	peer := register("peer", key.NewNode().Public())
+	addresses := rotated.IPAddresses.ToStringSlice()
+
+	api := newHeadscaleV1APIServer(&app)
+
+	newKey := key.NewMachine().Public()
+	response, err := api.RotateMachineKey(
+		context.Background(),
+		&v1.RotateMachineKeyRequest{
+			MachineId:  rotated.ID,
+			MachineKey: MachinePublicKeyStripPrefix(newKey),
+		},
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(response.GetMachine().GetId(), check.Equals, rotated.ID)
+	c.Assert(response.GetMachine().GetIpAddresses(), check.DeepEquals, addresses)
+
+	machine, err := app.GetMachineByMachineKey(newKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.ID, check.Equals, rotated.ID)
+	c.Assert(machine.Namespace.Name, check.Equals, "test")
+	c.Assert(machine.IPAddresses.ToStringSlice(), check.DeepEquals, addresses)
+
+	// the peers reach the machine at the same addresses, under the new key
+	peers, err := app.getPeers(peer)
+	c.Assert(err, check.IsNil)
+	c.Assert(len(peers), check.Equals, 1)
+	c.Assert(peers[0].IPAddresses.ToStringSlice(), check.DeepEquals, addresses)
+	node, err := peers[0].toNode("", nil, true, defaultKeepAliveInterval)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Machine, check.Equals, newKey)
+
+	_, err = api.RotateMachineKey(
+		context.Background(),
+		&v1.RotateMachineKeyRequest{MachineId: rotated.ID, MachineKey: "invalid"},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.InvalidArgument)
+
+	_, err = api.RotateMachineKey(
+		context.Background(),
+		&v1.RotateMachineKeyRequest{MachineId: rotated.ID, MachineKey: peer.MachineKey},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.AlreadyExists)
+
+	// the node key is known to every peer: a client presenting it with
+	// another machine key is refused, whatever its auth
+	otherNamespace, err := app.CreateNamespace("other")
+	c.Assert(err, check.IsNil)
+	otherPak, err := app.CreatePreAuthKey(otherNamespace.Name, true, false, nil, "", 0, nil, nil)
+	c.Assert(err, check.IsNil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	attackerKey := key.NewMachine().Public()
+	for _, authKey := range []string{otherPak.Key, pak.Key, ""} {
+		registerRequest := tailcfg.RegisterRequest{
+			NodeKey:  nodeKey,
+			Hostinfo: &tailcfg.Hostinfo{Hostname: "rotated"},
+		}
+		registerRequest.Auth.AuthKey = authKey
+
+		writer := httptest.NewRecorder()
+		app.handleRegisterCommon(writer, nil, registerRequest, attackerKey)
+		c.Assert(writer.Code, check.Equals, http.StatusUnauthorized)
+
+		_, cached := app.registrationCache.Get(NodePublicKeyStripPrefix(nodeKey))
+		c.Assert(cached, check.Equals, false)
+
+		machine, err = app.GetMachineByID(rotated.ID)
+		c.Assert(err, check.IsNil)
+		c.Assert(machine.MachineKey, check.Equals, MachinePublicKeyStripPrefix(newKey))
+		c.Assert(machine.Namespace.Name, check.Equals, "test")
+	}
+
+	// a pre-auth key of another namespace does not refresh the machine
+	registerRequest := tailcfg.RegisterRequest{
+		NodeKey:  nodeKey,
+		Hostinfo: &tailcfg.Hostinfo{Hostname: "rotated"},
+	}
+	registerRequest.Auth.AuthKey = otherPak.Key
+	writer := httptest.NewRecorder()
+	app.handleAuthKeyCommon(writer, registerRequest, newKey)
+	c.Assert(writer.Code, check.Equals, http.StatusUnauthorized)
+
+	// the machine itself refreshes with a pre-auth key of its namespace
+	registerRequest.Auth.AuthKey = pak.Key
+	writer = httptest.NewRecorder()
+	app.handleAuthKeyCommon(writer, registerRequest, newKey)
+	c.Assert(writer.Code, check.Equals, http.StatusOK)
+
+	machine, err = app.GetMachineByID(rotated.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.MachineKey, check.Equals, MachinePublicKeyStripPrefix(newKey))
+	c.Assert(machine.IPAddresses.ToStringSlice(), check.DeepEquals, addresses)
+}
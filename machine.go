@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
 	"tailscale.com/tailcfg"
 	"tailscale.com/types/key"
 )
@@ -20,6 +22,7 @@ import (
 const (
 	ErrMachineNotFound                  = Error("machine not found")
 	ErrMachineRouteIsNotAvailable       = Error("route is not available on machine")
+	ErrMachineTooManyRoutes             = Error("machine exceeds the maximum number of routes")
 	ErrMachineAddressesInvalid          = Error("failed to parse machine addresses")
 	ErrMachineNotFoundRegistrationCache = Error(
 		"machine not found in registration cache",
@@ -29,12 +32,27 @@ const (
 	ErrDifferentRegisteredNamespace    = Error(
 		"machine was previously registered with a different namespace",
 	)
+	ErrMachineHasNoExpiry      = Error("machine does not expire")
+	ErrInvalidExpiryExtension  = Error("invalid expiry extension")
+	ErrTagNotInTagOwners       = Error("tag is not declared in the tagOwners of the ACL policy")
+	ErrInvalidBulkTagMode      = Error("invalid bulk tag mode")
+	ErrInvalidPageToken        = Error("invalid page token")
+	ErrMachineNameExists       = Error("machine name already exists in the namespace")
+	ErrMachineNotConnected     = Error("machine has no open update stream")
+	ErrDeletedMachineNotFound  = Error("no restorable deleted machine with this id")
+	ErrMachineRestoreConflict  = Error("machine key or name registered again since the deletion")
+	ErrInvalidMachineKey       = Error("invalid machine key")
+	ErrMachineKeyInUse         = Error("machine key is used by another machine")
+	ErrMachineKeyChanged       = Error("machine key changed, it can only be rotated by an admin")
+	ErrMachineKeyNamespace     = Error("pre-auth key is not from the namespace of the machine")
 	MachineGivenNameHashLength = 8
 	MachineGivenNameTrimSize   = 2
 )
 
 const (
 	maxHostnameLength = 255
+
+	defaultMaxRoutesPerMachine = 1024
 )
 
 var (
@@ -72,6 +90,18 @@ type Machine struct {
 	AuthKeyID uint
 	AuthKey   *PreAuthKey
 
+	// OIDCRefreshToken is the refresh token issued by the OIDC provider
+	// when the machine was (re)authenticated, if any, encrypted with a key
+	// derived from the private key of the server. It is used to revoke the
+	// session when the machine is logged out.
+	OIDCRefreshToken string
+	// OIDCProvider is the name of the OIDC provider which issued the
+	// refresh token.
+	OIDCProvider string
+
+	// FirstSeen is set once, when the machine is first registered, and
+	// is never updated afterwards.
+	FirstSeen            *time.Time
 	LastSeen             *time.Time
 	LastSuccessfulUpdate *time.Time
 	Expiry               *time.Time
@@ -82,7 +112,7 @@ type Machine struct {
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	DeletedAt *time.Time
+	DeletedAt gorm.DeletedAt
 }
 
 type (
@@ -143,6 +173,24 @@ func (machine Machine) isExpired() bool {
 	return time.Now().UTC().After(*machine.Expiry)
 }
 
+// isMachineOnline reports whether the machine holds an open long poll that
+// is not stale.
+func (h *Headscale) isMachineOnline(machine Machine) bool {
+	if _, ok := h.clientsUpdateChannels.Load(machine.MachineKey); !ok {
+		return false
+	}
+
+	return machine.LastSeen != nil &&
+		machine.LastSeen.After(time.Now().UTC().Add(-h.onlineLastSeenThreshold()))
+}
+
+// onlineLastSeenThreshold is how long a machine holding a long poll can go
+// unseen before its poll is considered stale. It is seen at least every
+// keep alive interval, so leave it some slack.
+func (h *Headscale) onlineLastSeenThreshold() time.Duration {
+	return 2 * h.keepAliveInterval()
+}
+
 func containsAddresses(inputs []string, addrs []string) bool {
 	for _, addr := range addrs {
 		if contains(inputs, addr) {
@@ -164,6 +212,55 @@ func matchSourceAndDestinationWithRule(
 		containsAddresses(ruleDestinations, destination)
 }
 
+// ruleAllowsPeer reports whether the filter rule makes peer visible to
+// machine, in either direction.
+func ruleAllowsPeer(rule tailcfg.FilterRule, machine *Machine, peer *Machine) bool {
+	var dst []string
+	for _, d := range rule.DstPorts {
+		dst = append(dst, d.IP)
+	}
+
+	machineIPs := machine.IPAddresses.ToStringSlice()
+	peerIPs := peer.IPAddresses.ToStringSlice()
+
+	return matchSourceAndDestinationWithRule(
+		rule.SrcIPs,
+		dst,
+		machineIPs,
+		peerIPs,
+	) || // match source and destination
+		matchSourceAndDestinationWithRule(
+			rule.SrcIPs,
+			dst,
+			peerIPs,
+			machineIPs,
+		) || // match return path
+		matchSourceAndDestinationWithRule(
+			rule.SrcIPs,
+			dst,
+			machineIPs,
+			[]string{"*"},
+		) || // match source and all destination
+		matchSourceAndDestinationWithRule(
+			rule.SrcIPs,
+			dst,
+			[]string{"*"},
+			[]string{"*"},
+		) || // match source and all destination
+		matchSourceAndDestinationWithRule(
+			rule.SrcIPs,
+			dst,
+			[]string{"*"},
+			peerIPs,
+		) || // match source and all destination
+		matchSourceAndDestinationWithRule(
+			rule.SrcIPs,
+			dst,
+			[]string{"*"},
+			machineIPs,
+		) // match all sources and source
+}
+
 // getFilteredByACLPeerss should return the list of peers authorized to be accessed from machine.
 func getFilteredByACLPeers(
 	machines []Machine,
@@ -178,52 +275,14 @@ func getFilteredByACLPeers(
 	peers := make(map[uint64]Machine)
 	// Aclfilter peers here. We are itering through machines in all namespaces and search through the computed aclRules
 	// for match between rule SrcIPs and DstPorts. If the rule is a match we allow the machine to be viewable.
-	for _, peer := range machines {
+	for index := range machines {
+		peer := &machines[index]
 		if peer.ID == machine.ID {
 			continue
 		}
 		for _, rule := range rules {
-			var dst []string
-			for _, d := range rule.DstPorts {
-				dst = append(dst, d.IP)
-			}
-			if matchSourceAndDestinationWithRule(
-				rule.SrcIPs,
-				dst,
-				machine.IPAddresses.ToStringSlice(),
-				peer.IPAddresses.ToStringSlice(),
-			) || // match source and destination
-				matchSourceAndDestinationWithRule(
-					rule.SrcIPs,
-					dst,
-					peer.IPAddresses.ToStringSlice(),
-					machine.IPAddresses.ToStringSlice(),
-				) || // match return path
-				matchSourceAndDestinationWithRule(
-					rule.SrcIPs,
-					dst,
-					machine.IPAddresses.ToStringSlice(),
-					[]string{"*"},
-				) || // match source and all destination
-				matchSourceAndDestinationWithRule(
-					rule.SrcIPs,
-					dst,
-					[]string{"*"},
-					[]string{"*"},
-				) || // match source and all destination
-				matchSourceAndDestinationWithRule(
-					rule.SrcIPs,
-					dst,
-					[]string{"*"},
-					peer.IPAddresses.ToStringSlice(),
-				) || // match source and all destination
-				matchSourceAndDestinationWithRule(
-					rule.SrcIPs,
-					dst,
-					[]string{"*"},
-					machine.IPAddresses.ToStringSlice(),
-				) { // match all sources and source
-				peers[peer.ID] = peer
+			if ruleAllowsPeer(rule, machine, peer) {
+				peers[peer.ID] = *peer
 			}
 		}
 	}
@@ -245,6 +304,148 @@ func getFilteredByACLPeers(
 	return authorizedPeers
 }
 
+// peerReason explains why a peer is part of the map of a machine.
+type peerReason struct {
+	// ACLIndex is the index of the ACL allowing the peer,
+	// or -1 when no ACL policy is loaded.
+	ACLIndex     int
+	Sources      []string
+	Destinations []string
+	Description  string
+}
+
+// getPeerReasons returns the reasons why peer is visible to machine,
+// based on the same filter rule evaluation as getFilteredByACLPeers.
+func (h *Headscale) getPeerReasons(machine *Machine, peer *Machine) []peerReason {
+	aclPolicy, aclRules, aclRuleIndexes := h.getMergedACL()
+	if aclPolicy == nil {
+		return []peerReason{{
+			ACLIndex:    -1,
+			Description: "no ACL policy is loaded, all machines are peers",
+		}}
+	}
+
+	reasons := []peerReason{}
+	for ruleIndex, rule := range aclRules {
+		if !ruleAllowsPeer(rule, machine, peer) {
+			continue
+		}
+
+		index := aclRuleIndexes[ruleIndex]
+		// An ACL split by deny ACLs generates consecutive rules.
+		if len(reasons) > 0 && reasons[len(reasons)-1].ACLIndex == index {
+			continue
+		}
+
+		reason := peerReason{
+			ACLIndex:    index,
+			Description: fmt.Sprintf("allowed by ACL %d", index),
+		}
+		if index < len(aclPolicy.ACLs) {
+			reason.Sources = aclPolicy.ACLs[index].Sources
+			reason.Destinations = aclPolicy.ACLs[index].Destinations
+		}
+
+		reasons = append(reasons, reason)
+	}
+
+	return reasons
+}
+
+// tagGrant lists the ACLs that apply to a machine because of one of its
+// tags, and the namespaces of the peers these ACLs give access to.
+type tagGrant struct {
+	Tag        string
+	ACLIndexes []int
+	Namespaces []string
+}
+
+// getTagGrants returns, for each valid or forced tag of the machine, the
+// rules referencing the tag and the namespaces they connect the machine to.
+// The output is sorted by tag, ACL index and namespace.
+func (h *Headscale) getTagGrants(machine *Machine) ([]tagGrant, error) {
+	aclPolicy, aclRules, aclRuleIndexes := h.getMergedACL()
+	if aclPolicy == nil {
+		return []tagGrant{}, nil
+	}
+
+	validTags, _ := getTags(aclPolicy, *machine, h.cfg.OIDC.StripEmaildomain)
+	tagSet := map[string]bool{}
+	for _, tag := range append(validTags, machine.ForcedTags...) {
+		tagSet[tag] = true
+	}
+	tags := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	machines, err := h.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	grants := make([]tagGrant, 0, len(tags))
+	for _, tag := range tags {
+		grant := tagGrant{
+			Tag:        tag,
+			ACLIndexes: []int{},
+			Namespaces: []string{},
+		}
+		namespaces := map[string]bool{}
+
+		for index, acl := range aclPolicy.ACLs {
+			if acl.Action != "accept" || !aclReferencesAlias(acl, tag) {
+				continue
+			}
+
+			grant.ACLIndexes = append(grant.ACLIndexes, index)
+			for ruleIndex, rule := range aclRules {
+				if aclRuleIndexes[ruleIndex] != index {
+					continue
+				}
+				for peerIndex := range machines {
+					peer := &machines[peerIndex]
+					if peer.ID == machine.ID {
+						continue
+					}
+					if ruleAllowsPeer(rule, machine, peer) {
+						namespaces[peer.Namespace.Name] = true
+					}
+				}
+			}
+		}
+
+		for namespace := range namespaces {
+			grant.Namespaces = append(grant.Namespaces, namespace)
+		}
+		sort.Strings(grant.Namespaces)
+
+		grants = append(grants, grant)
+	}
+
+	return grants, nil
+}
+
+// aclReferencesAlias reports whether the alias is one of the sources of the
+// ACL, or the host part of one of its destinations.
+func aclReferencesAlias(acl ACL, alias string) bool {
+	for _, src := range acl.Sources {
+		if src == alias {
+			return true
+		}
+	}
+
+	for _, dest := range acl.Destinations {
+		separator := strings.LastIndex(dest, ":")
+		if separator != -1 && dest[:separator] == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (h *Headscale) ListPeers(machine *Machine) (Machines, error) {
 	log.Trace().
 		Caller().
@@ -275,7 +476,7 @@ func (h *Headscale) getPeers(machine *Machine) (Machines, error) {
 
 	// If ACLs rules are defined, filter visible host list with the ACLs
 	// else use the classic namespace scope
-	if h.aclPolicy != nil {
+	if aclPolicy, aclRules, _ := h.getACL(); aclPolicy != nil {
 		var machines []Machine
 		machines, err = h.ListMachines()
 		if err != nil {
@@ -283,7 +484,7 @@ func (h *Headscale) getPeers(machine *Machine) (Machines, error) {
 
 			return Machines{}, err
 		}
-		peers = getFilteredByACLPeers(machines, h.aclRules, machine)
+		peers = getFilteredByACLPeers(machines, aclRules, machine)
 	} else {
 		peers, err = h.ListPeers(machine)
 		if err != nil {
@@ -332,6 +533,74 @@ func (h *Headscale) ListMachines() ([]Machine, error) {
 	return machines, nil
 }
 
+// ListMachinesPage returns, ordered by ID, at most pageSize machines (all of
+// them when pageSize is 0) following the page token, optionally restricted to
+// a namespace and to the online machines. The token of the next page is empty
+// on the last page.
+func (h *Headscale) ListMachinesPage(
+	namespaceName string,
+	onlineOnly bool,
+	pageSize int,
+	pageToken string,
+) ([]Machine, string, error) {
+	query := h.db.Preload("AuthKey").
+		Preload("AuthKey.Namespace").
+		Preload("Namespace").
+		Order("id")
+
+	if namespaceName != "" {
+		err := CheckForFQDNRules(namespaceName)
+		if err != nil {
+			return nil, "", err
+		}
+		namespace, err := h.GetNamespace(namespaceName)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where(&Machine{NamespaceID: namespace.ID})
+	}
+
+	if onlineOnly {
+		// the same conditions as isMachineOnline, so the page is still
+		// bounded by the database
+		machineKeys := []string{}
+		h.clientsUpdateChannels.Range(func(machineKey string, _ chan struct{}) bool {
+			machineKeys = append(machineKeys, machineKey)
+
+			return true
+		})
+		query = query.
+			Where("machine_key IN ?", machineKeys).
+			Where("last_seen > ?", time.Now().UTC().Add(-h.onlineLastSeenThreshold()))
+	}
+
+	if pageToken != "" {
+		afterID, err := strconv.ParseUint(pageToken, Base10, BitSize64)
+		if err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		query = query.Where("id > ?", afterID)
+	}
+
+	if pageSize > 0 {
+		// fetch one more machine to know whether there is a next page
+		query = query.Limit(pageSize + 1)
+	}
+
+	machines := []Machine{}
+	if err := query.Find(&machines).Error; err != nil {
+		return nil, "", err
+	}
+
+	nextPageToken := ""
+	if pageSize > 0 && len(machines) > pageSize {
+		machines = machines[:pageSize]
+		nextPageToken = strconv.FormatUint(machines[pageSize-1].ID, Base10)
+	}
+
+	return machines, nextPageToken, nil
+}
+
 // GetMachine finds a Machine by name and namespace and returns the Machine struct.
 func (h *Headscale) GetMachine(namespace string, name string) (*Machine, error) {
 	machines, err := h.ListMachinesInNamespace(namespace)
@@ -427,6 +696,104 @@ func (h *Headscale) SetTags(machine *Machine, tags []string) error {
 	return nil
 }
 
+// BulkTagMode defines how BulkSetNamespaceTags combines the given tags with
+// the forced tags already set on the machines.
+type BulkTagMode int
+
+const (
+	BulkTagModeAdd BulkTagMode = iota
+	BulkTagModeRemove
+	BulkTagModeReplace
+)
+
+// BulkSetNamespaceTags adds, removes or replaces the forced tags of every
+// machine of a namespace in a single transaction, and returns the number of
+// machines whose tags changed.
+// When an ACL policy is loaded, the tags must be declared in its tagOwners.
+func (h *Headscale) BulkSetNamespaceTags(
+	namespaceName string,
+	tags []string,
+	mode BulkTagMode,
+) (int, error) {
+	if mode != BulkTagModeAdd && mode != BulkTagModeRemove && mode != BulkTagModeReplace {
+		return 0, ErrInvalidBulkTagMode
+	}
+
+	if aclPolicy := h.getACLPolicy(); aclPolicy != nil && mode != BulkTagModeRemove {
+		for _, tag := range tags {
+			if _, ok := aclPolicy.TagOwners[tag]; !ok {
+				return 0, fmt.Errorf("%w: %s", ErrTagNotInTagOwners, tag)
+			}
+		}
+	}
+
+	machines, err := h.ListMachinesInNamespace(namespaceName)
+	if err != nil {
+		return 0, err
+	}
+
+	affected := 0
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for index := range machines {
+			machine := &machines[index]
+
+			newTags := bulkTags(machine.ForcedTags, tags, mode)
+			if reflect.DeepEqual([]string(machine.ForcedTags), newTags) {
+				continue
+			}
+
+			if err := tx.Model(machine).Update("forced_tags", StringList(newTags)).Error; err != nil {
+				return fmt.Errorf("failed to update tags for machine in the database: %w", err)
+			}
+			affected++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if affected > 0 {
+		if err := h.UpdateACLRules(); err != nil && !errors.Is(err, errEmptyPolicy) {
+			return affected, err
+		}
+		h.setLastStateChangeToNow()
+	}
+
+	return affected, nil
+}
+
+// bulkTags returns the forced tags of a machine once the mode is applied.
+func bulkTags(current []string, tags []string, mode BulkTagMode) []string {
+	newTags := []string{}
+
+	switch mode {
+	case BulkTagModeAdd:
+		for _, tag := range append(append([]string{}, current...), tags...) {
+			if !contains(newTags, tag) {
+				newTags = append(newTags, tag)
+			}
+		}
+
+	case BulkTagModeRemove:
+		for _, tag := range current {
+			if !contains(tags, tag) && !contains(newTags, tag) {
+				newTags = append(newTags, tag)
+			}
+		}
+
+	case BulkTagModeReplace:
+		for _, tag := range tags {
+			if !contains(newTags, tag) {
+				newTags = append(newTags, tag)
+			}
+		}
+	}
+
+	return newTags
+}
+
 // ExpireMachine takes a Machine struct and sets the expire field to now.
 func (h *Headscale) ExpireMachine(machine *Machine) error {
 	now := time.Now()
@@ -438,33 +805,126 @@ func (h *Headscale) ExpireMachine(machine *Machine) error {
 		return fmt.Errorf("failed to expire machine in the database: %w", err)
 	}
 
+	h.notifyWebhook(WebhookEventMachineExpired, *machine)
+
+	return nil
+}
+
+// SetMachineExpiry sets the expiry of the machine to an arbitrary time. A
+// nil or zero expiry clears it, so the machine never expires, and an expiry
+// in the past expires the machine right away, as ExpireMachine does.
+func (h *Headscale) SetMachineExpiry(machine *Machine, expiry *time.Time) error {
+	if expiry != nil && !expiry.IsZero() && !expiry.After(time.Now()) {
+		return h.ExpireMachine(machine)
+	}
+
+	if expiry != nil && !expiry.IsZero() {
+		machineExpiry := expiry.UTC()
+		machine.Expiry = &machineExpiry
+	} else {
+		machine.Expiry = nil
+	}
+
+	h.setLastStateChangeToNow()
+
+	if err := h.db.Save(machine).Error; err != nil {
+		return fmt.Errorf("failed to set machine expiry in the database: %w", err)
+	}
+
 	return nil
 }
 
+// ExpireNamespaceMachines expires all the machines of a namespace in a single
+// transaction, and returns the number of machines expired. The machines
+// already expired keep their expiry.
+func (h *Headscale) ExpireNamespaceMachines(namespaceName string) (int, error) {
+	machines, err := h.ListMachinesInNamespace(namespaceName)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	expired := []Machine{}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for index := range machines {
+			machine := &machines[index]
+			if machine.isExpired() {
+				continue
+			}
+
+			if err := tx.Model(machine).Update("expiry", now).Error; err != nil {
+				return fmt.Errorf("failed to expire machine in the database: %w", err)
+			}
+			expired = append(expired, *machine)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	affected := len(expired)
+	if affected > 0 {
+		h.setLastStateChangeToNow()
+	}
+
+	for _, machine := range expired {
+		h.notifyWebhook(WebhookEventMachineExpired, machine)
+	}
+
+	return affected, nil
+}
+
 // RenameMachine takes a Machine struct and a new GivenName for the machines
-// and renames it.
+// and renames it. The name is normalised to a DNS label, and must not be
+// given to another machine of the namespace. As the DNS name of the machine
+// comes from its GivenName, it no longer follows the hostname of the client.
 func (h *Headscale) RenameMachine(machine *Machine, newName string) error {
-	err := CheckForFQDNRules(
-		newName,
-	)
+	// A name too long to be normalised is reported by CheckForFQDNRules
+	givenName := newName
+	if normalized, err := NormalizeToFQDNRules(newName, false); err == nil {
+		givenName = normalized
+	}
+
+	err := CheckForFQDNRules(givenName)
+	if err == nil && (givenName == "" || strings.Contains(givenName, ".")) {
+		err = fmt.Errorf(
+			"machine name %q must be a single DNS label: %w",
+			givenName,
+			ErrInvalidNamespaceName,
+		)
+	}
 	if err != nil {
 		log.Error().
 			Caller().
 			Str("func", "RenameMachine").
 			Str("machine", machine.Hostname).
 			Str("newName", newName).
-			Err(err)
+			Err(err).
+			Msg("Invalid machine name")
 
 		return err
 	}
-	machine.GivenName = newName
 
-	h.setLastStateChangeToNow()
+	var count int64
+	if err := h.db.Model(&Machine{}).
+		Where("namespace_id = ? AND given_name = ? AND id <> ?", machine.NamespaceID, givenName, machine.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check the machine names of the namespace: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s", ErrMachineNameExists, givenName)
+	}
+
+	machine.GivenName = givenName
 
 	if err := h.db.Save(machine).Error; err != nil {
 		return fmt.Errorf("failed to rename machine in the database: %w", err)
 	}
 
+	h.setLastStateChangeToNow()
+
 	return nil
 }
 
@@ -487,15 +947,184 @@ func (h *Headscale) RefreshMachine(machine *Machine, expiry time.Time) error {
 	return nil
 }
 
-// DeleteMachine softs deletes a Machine from the database.
+// registrationExpiry returns the expiry of a machine registering now. A
+// non-zero requested expiry is kept, otherwise the machine expires after the
+// machine expiry of its pre-auth key, or default_machine_expiry if the key
+// has none. A zero duration means the machine never expires.
+func (h *Headscale) registrationExpiry(
+	requested *time.Time,
+	pak *PreAuthKey,
+) *time.Time {
+	if requested != nil && !requested.IsZero() {
+		return requested
+	}
+
+	duration := h.cfg.DefaultMachineExpiry
+	if pak != nil && pak.MachineExpiry != nil {
+		duration = *pak.MachineExpiry
+	}
+
+	if duration <= 0 {
+		return requested
+	}
+
+	expiry := time.Now().UTC().Add(duration)
+
+	return &expiry
+}
+
+// ExtendMachineExpiry pushes the expiry of the machine forward by duration.
+// The new expiry is capped at max_expiry_extension from now, and an expiry
+// already past the cap is kept as is.
+func (h *Headscale) ExtendMachineExpiry(
+	machine *Machine,
+	duration time.Duration,
+) error {
+	if machine.Expiry == nil || machine.Expiry.IsZero() {
+		return ErrMachineHasNoExpiry
+	}
+
+	if duration <= 0 {
+		return fmt.Errorf(
+			"%w: duration must be positive, got %s",
+			ErrInvalidExpiryExtension,
+			duration,
+		)
+	}
+
+	now := time.Now().UTC()
+	base := *machine.Expiry
+	if base.Before(now) {
+		base = now
+	}
+
+	newExpiry := base.Add(duration)
+	if maxExpiry := now.Add(h.cfg.MaxExpiryExtension); newExpiry.After(maxExpiry) {
+		newExpiry = maxExpiry
+	}
+	if !newExpiry.After(*machine.Expiry) {
+		return nil
+	}
+
+	machine.Expiry = &newExpiry
+
+	h.setLastStateChangeToNow()
+
+	if err := h.db.Save(machine).Error; err != nil {
+		return fmt.Errorf("failed to extend machine expiry in the database: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMachine deletes a Machine from the database. With a
+// machine_deletion_grace_period, the machine is only soft deleted: it is
+// gone from the netmaps but can be restored with RestoreMachine until
+// purgeDeletedMachines removes it for good.
 func (h *Headscale) DeleteMachine(machine *Machine) error {
-	if err := h.db.Delete(&machine).Error; err != nil {
-		return err
+	if h.cfg.MachineDeletionGracePeriod <= 0 {
+		if err := h.HardDeleteMachine(machine); err != nil {
+			return err
+		}
+	} else {
+		if err := h.db.Delete(&machine).Error; err != nil {
+			return err
+		}
+
+		h.publishMachineEvent(v1.MachineEventType_MACHINE_EVENT_TYPE_DELETED, *machine)
 	}
 
+	h.setLastStateChangeToNow()
+	h.notifyWebhook(WebhookEventMachineDeleted, *machine)
+
 	return nil
 }
 
+// RestoreMachine undoes the soft deletion of a Machine, as long as its
+// deletion grace period is not over.
+func (h *Headscale) RestoreMachine(machineID uint64) (*Machine, error) {
+	machine := Machine{}
+	if result := h.db.Unscoped().Preload("Namespace").First(&machine, machineID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrDeletedMachineNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	if !machine.DeletedAt.Valid ||
+		time.Now().After(machine.DeletedAt.Time.Add(h.cfg.MachineDeletionGracePeriod)) {
+		return nil, ErrDeletedMachineNotFound
+	}
+
+	// The client may have registered again since the deletion.
+	result := h.db.Where("machine_key = ? OR given_name = ?", machine.MachineKey, machine.GivenName).
+		Limit(1).
+		Find(&Machine{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil, ErrMachineRestoreConflict
+	}
+
+	if err := h.db.Unscoped().Model(&machine).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("failed to restore machine in the database: %w", err)
+	}
+	machine.DeletedAt = gorm.DeletedAt{}
+
+	h.publishMachineEvent(v1.MachineEventType_MACHINE_EVENT_TYPE_CREATED, machine)
+	h.setLastStateChangeToNow()
+
+	return &machine, nil
+}
+
+// purgeDeletedMachinesWorker hard deletes the soft deleted machines whose
+// deletion grace period is over.
+func (h *Headscale) purgeDeletedMachinesWorker() {
+	machines := Machines{}
+	if err := h.db.Unscoped().Where("deleted_at IS NOT NULL").Find(&machines).Error; err != nil {
+		log.Error().
+			Err(err).
+			Msg("Error listing the deleted machines")
+
+		return
+	}
+
+	for index := range machines {
+		machine := &machines[index]
+		if time.Now().Before(machine.DeletedAt.Time.Add(h.cfg.MachineDeletionGracePeriod)) {
+			continue
+		}
+
+		if err := h.db.Unscoped().Delete(machine).Error; err != nil {
+			log.Error().
+				Err(err).
+				Str("machine", machine.Hostname).
+				Msg("Cannot purge deleted machine from the database")
+
+			continue
+		}
+
+		log.Info().
+			Str("machine", machine.Hostname).
+			Msg("Deleted machine purged from the database")
+	}
+}
+
+// savePollState persists the columns a map request updates, and only those:
+// a poll works on an in-memory copy of the machine, and writing every column
+// would overwrite the changes made by an admin in the meantime (tags, name...).
+func (h *Headscale) savePollState(machine *Machine, includeEndpoints bool) error {
+	columns := []string{"hostname", "host_info", "disco_key"}
+	if includeEndpoints {
+		columns = append(columns, "endpoints", "last_seen")
+	}
+
+	return h.db.Model(machine).Select(columns).Updates(machine).Error
+}
+
+// TouchMachine only updates the LastSeen and LastSuccessfulUpdate columns.
 func (h *Headscale) TouchMachine(machine *Machine) error {
 	return h.db.Updates(Machine{
 		ID:                   machine.ID,
@@ -510,6 +1139,8 @@ func (h *Headscale) HardDeleteMachine(machine *Machine) error {
 		return err
 	}
 
+	h.publishMachineEvent(v1.MachineEventType_MACHINE_EVENT_TYPE_DELETED, *machine)
+
 	return nil
 }
 
@@ -574,11 +1205,12 @@ func (machines Machines) toNodes(
 	baseDomain string,
 	dnsConfig *tailcfg.DNSConfig,
 	includeRoutes bool,
+	keepAliveInterval time.Duration,
 ) ([]*tailcfg.Node, error) {
 	nodes := make([]*tailcfg.Node, len(machines))
 
 	for index, machine := range machines {
-		node, err := machine.toNode(baseDomain, dnsConfig, includeRoutes)
+		node, err := machine.toNode(baseDomain, dnsConfig, includeRoutes, keepAliveInterval)
 		if err != nil {
 			return nil, err
 		}
@@ -589,12 +1221,24 @@ func (machines Machines) toNodes(
 	return nodes, nil
 }
 
+// magicDNSName returns the name under which the machine is known in MagicDNS.
+func (machine Machine) magicDNSName(baseDomain string) string {
+	return fmt.Sprintf(
+		"%s.%s.%s",
+		machine.GivenName,
+		machine.Namespace.Name,
+		baseDomain,
+	)
+}
+
 // toNode converts a Machine into a Tailscale Node. includeRoutes is false for shared nodes
 // as per the expected behaviour in the official SaaS.
+// The node is online if it was seen within the last keepAliveInterval.
 func (machine Machine) toNode(
 	baseDomain string,
 	dnsConfig *tailcfg.DNSConfig,
 	includeRoutes bool,
+	keepAliveInterval time.Duration,
 ) (*tailcfg.Node, error) {
 	var nodeKey key.NodePublic
 	err := nodeKey.UnmarshalText([]byte(NodePublicKeyEnsurePrefix(machine.NodeKey)))
@@ -640,7 +1284,15 @@ func (machine Machine) toNode(
 		[]netip.Prefix{},
 		addrs...) // we append the node own IP, as it is required by the clients
 
-	allowedIPs = append(allowedIPs, machine.EnabledRoutes...)
+	for _, route := range machine.EnabledRoutes {
+		// the exit routes are only offered together, when the machine
+		// has been approved as an exit node
+		if contains(exitRoutes, route) && !machine.isExitNode() {
+			continue
+		}
+
+		allowedIPs = append(allowedIPs, route)
+	}
 
 	// TODO(kradalby): This is kind of a hack where we say that
 	// all the announced routes (except exit), is presented as primary
@@ -650,7 +1302,7 @@ func (machine Machine) toNode(
 	primaryRoutes := []netip.Prefix{}
 	if len(machine.EnabledRoutes) > 0 {
 		for _, route := range machine.EnabledRoutes {
-			if route == ExitRouteV4 || route == ExitRouteV6 {
+			if contains(exitRoutes, route) {
 				continue
 			}
 
@@ -674,12 +1326,7 @@ func (machine Machine) toNode(
 
 	var hostname string
 	if dnsConfig != nil && dnsConfig.Proxied { // MagicDNS
-		hostname = fmt.Sprintf(
-			"%s.%s.%s",
-			machine.GivenName,
-			machine.Namespace.Name,
-			baseDomain,
-		)
+		hostname = machine.magicDNSName(baseDomain)
 		if len(hostname) > maxHostnameLength {
 			return nil, fmt.Errorf(
 				"hostname %q is too long it cannot except 255 ASCII chars: %w",
@@ -695,7 +1342,8 @@ func (machine Machine) toNode(
 
 	// A node is Online if it is connected to the control server,
 	// and we now we update LastSeen every keepAliveInterval duration at least.
-	online := machine.LastSeen.After(time.Now().Add(-keepAliveInterval))
+	online := machine.LastSeen != nil &&
+		machine.LastSeen.After(time.Now().Add(-keepAliveInterval))
 
 	node := tailcfg.Node{
 		ID: tailcfg.NodeID(machine.ID), // this is the actual ID
@@ -750,6 +1398,10 @@ func (machine *Machine) toProto() *v1.Machine {
 		machineProto.PreAuthKey = machine.AuthKey.toProto()
 	}
 
+	if machine.FirstSeen != nil {
+		machineProto.FirstSeen = timestamppb.New(*machine.FirstSeen)
+	}
+
 	if machine.LastSeen != nil {
 		machineProto.LastSeen = timestamppb.New(*machine.LastSeen)
 	}
@@ -782,8 +1434,15 @@ func getTags(
 	}
 	validTagMap := make(map[string]bool)
 	invalidTagMap := make(map[string]bool)
-	for _, tag := range machine.HostInfo.RequestTags {
-		owners, err := expandTagOwners(*aclPolicy, tag, stripEmailDomain)
+	tags := append([]string{}, machine.HostInfo.RequestTags...)
+	tags = append(tags, machine.Namespace.DefaultTags...)
+	for _, tag := range tags {
+		owners, err := expandTagOwners(
+			*aclPolicy,
+			tag,
+			[]string{machine.Namespace.Name},
+			stripEmailDomain,
+		)
 		if errors.Is(err, errInvalidTag) {
 			invalidTagMap[tag] = true
 
@@ -811,10 +1470,14 @@ func getTags(
 	return validTags, invalidTags
 }
 
+// RegisterMachineFromAuthCallback registers a machine waiting in the
+// registration cache. If machineExpiry is set, it replaces the expiry
+// requested by the client.
 func (h *Headscale) RegisterMachineFromAuthCallback(
 	nodeKeyStr string,
 	namespaceName string,
 	registrationMethod string,
+	machineExpiry *time.Time,
 ) (*Machine, error) {
 	if machineInterface, ok := h.registrationCache.Get(nodeKeyStr); ok {
 		if registrationMachine, ok := machineInterface.(Machine); ok {
@@ -834,6 +1497,13 @@ func (h *Headscale) RegisterMachineFromAuthCallback(
 
 			registrationMachine.NamespaceID = namespace.ID
 			registrationMachine.RegisterMethod = registrationMethod
+			if machineExpiry != nil {
+				registrationMachine.Expiry = machineExpiry
+			}
+			registrationMachine.Expiry = h.registrationExpiry(
+				registrationMachine.Expiry,
+				nil,
+			)
 
 			machine, err := h.RegisterMachine(
 				registrationMachine,
@@ -868,18 +1538,36 @@ func (h *Headscale) RegisterMachine(machine Machine,
 	h.ipAllocationMutex.Lock()
 	defer h.ipAllocationMutex.Unlock()
 
-	ips, err := h.getAvailableIPs()
-	if err != nil {
-		log.Error().
-			Caller().
-			Err(err).
-			Str("machine", machine.Hostname).
-			Msg("Could not find IP for the new machine")
+	// expired machines registering again keep their ID, and their place in
+	// the quota of the namespace
+	created := machine.ID == 0
+	if created {
+		if err := h.checkNamespaceMachineQuota(machine.NamespaceID); err != nil {
+			return nil, err
+		}
+	}
 
-		return nil, err
+	// machines registering again after an expiry keep their addresses so
+	// the peers can still reach them
+	if created || len(machine.IPAddresses) == 0 {
+		ips, err := h.getAvailableIPs()
+		if err != nil {
+			log.Error().
+				Caller().
+				Err(err).
+				Str("machine", machine.Hostname).
+				Msg("Could not find IP for the new machine")
+
+			return nil, err
+		}
+
+		machine.IPAddresses = ips
 	}
 
-	machine.IPAddresses = ips
+	if machine.FirstSeen == nil {
+		now := time.Now().UTC()
+		machine.FirstSeen = &now
+	}
 
 	if err := h.db.Save(&machine).Error; err != nil {
 		return nil, fmt.Errorf("failed register(save) machine in the database: %w", err)
@@ -888,12 +1576,112 @@ func (h *Headscale) RegisterMachine(machine Machine,
 	log.Trace().
 		Caller().
 		Str("machine", machine.Hostname).
-		Str("ip", strings.Join(ips.ToStringSlice(), ",")).
+		Str("ip", strings.Join(machine.IPAddresses.ToStringSlice(), ",")).
 		Msg("Machine registered with the database")
 
+	// the namespace of the machine may not be loaded yet
+	if registeredMachine, err := h.GetMachineByID(machine.ID); err == nil {
+		if created {
+			h.publishMachineEvent(
+				v1.MachineEventType_MACHINE_EVENT_TYPE_CREATED,
+				*registeredMachine,
+			)
+		}
+		h.notifyWebhook(WebhookEventMachineRegistered, *registeredMachine)
+	}
+
 	return &machine, nil
 }
 
+// ReassignMachineIP gives new addresses from the ip_prefixes to the machine,
+// to resolve an address it shares with another machine. The peers get the
+// new addresses with their next map update.
+func (h *Headscale) ReassignMachineIP(machine *Machine) error {
+	h.ipAllocationMutex.Lock()
+	defer h.ipAllocationMutex.Unlock()
+
+	ips, err := h.getAvailableIPs()
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("machine", machine.Hostname).
+		Str("old_ips", strings.Join(machine.IPAddresses.ToStringSlice(), ",")).
+		Str("new_ips", strings.Join(ips.ToStringSlice(), ",")).
+		Msg("Reassigning the IP addresses of the machine")
+
+	machine.IPAddresses = ips
+	if err := h.db.Save(machine).Error; err != nil {
+		return fmt.Errorf("failed to reassign the IP addresses of the machine in the database: %w", err)
+	}
+
+	// the filter rules are made of the addresses of the machines
+	if err := h.UpdateACLRules(); err != nil && !errors.Is(err, errEmptyPolicy) {
+		return err
+	}
+	h.setLastStateChangeToNow()
+
+	return nil
+}
+
+// RotateMachineKey replaces the machine key of the machine in place. The
+// machine keeps its ID, namespace and addresses, and the peers get the new
+// key with their next map update.
+func (h *Headscale) RotateMachineKey(machine *Machine, machineKey key.MachinePublic) error {
+	if machineKey.IsZero() {
+		return ErrInvalidMachineKey
+	}
+
+	machineKeyStr := MachinePublicKeyStripPrefix(machineKey)
+	if machine.MachineKey == machineKeyStr {
+		return nil
+	}
+
+	var count int64
+	if err := h.db.Unscoped().
+		Model(&Machine{}).
+		Where("machine_key = ? AND id <> ?", machineKeyStr, machine.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check the machine key in the database: %w", err)
+	}
+	if count > 0 {
+		return ErrMachineKeyInUse
+	}
+
+	log.Info().
+		Str("machine", machine.Hostname).
+		Str("old_machine_key", machine.MachineKey).
+		Str("new_machine_key", machineKeyStr).
+		Msg("Rotating the machine key of the machine")
+
+	if err := h.db.Model(machine).Update("machine_key", machineKeyStr).Error; err != nil {
+		return fmt.Errorf("failed to rotate the machine key of the machine in the database: %w", err)
+	}
+	machine.MachineKey = machineKeyStr
+
+	h.setLastStateChangeToNow()
+
+	return nil
+}
+
+// checkAdvertisedRoutes refuses the routes advertised by a machine beyond
+// max_routes_per_machine, so a misconfigured subnet router cannot bloat the
+// maps sent to every peer.
+func (h *Headscale) checkAdvertisedRoutes(hostinfo *tailcfg.Hostinfo) error {
+	limit := h.cfg.MaxRoutesPerMachine
+	if limit <= 0 || len(hostinfo.RoutableIPs) <= limit {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%d routes advertised, max_routes_per_machine is %d: %w",
+		len(hostinfo.RoutableIPs),
+		limit,
+		ErrMachineTooManyRoutes,
+	)
+}
+
 func (machine *Machine) GetAdvertisedRoutes() []netip.Prefix {
 	return machine.HostInfo.RoutableIPs
 }
@@ -922,19 +1710,50 @@ func (machine *Machine) IsRoutesEnabled(routeStr string) bool {
 // EnableNodeRoute enables new routes based on a list of new routes. It will _replace_ the
 // previous list of routes.
 func (h *Headscale) EnableRoutes(machine *Machine, routeStrs ...string) error {
+	newRoutes, err := h.parseEnabledRoutes(machine, routeStrs)
+	if err != nil {
+		return err
+	}
+
+	machine.EnabledRoutes = newRoutes
+
+	if err := h.db.Save(machine).Error; err != nil {
+		return fmt.Errorf("failed enable routes for machine in the database: %w", err)
+	}
+
+	h.setLastStateChangeToNow()
+
+	return nil
+}
+
+// parseEnabledRoutes checks that the routes can be enabled on the machine.
+func (h *Headscale) parseEnabledRoutes(
+	machine *Machine,
+	routeStrs []string,
+) ([]netip.Prefix, error) {
 	newRoutes := make([]netip.Prefix, len(routeStrs))
 	for index, routeStr := range routeStrs {
 		route, err := netip.ParsePrefix(routeStr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		newRoutes[index] = route
 	}
 
+	if limit := h.cfg.MaxRoutesPerMachine; limit > 0 && len(newRoutes) > limit {
+		return nil, fmt.Errorf(
+			"cannot enable %d routes on node %s, the limit is %d: %w",
+			len(newRoutes),
+			machine.Hostname,
+			limit,
+			ErrMachineTooManyRoutes,
+		)
+	}
+
 	for _, newRoute := range newRoutes {
 		if !contains(machine.GetAdvertisedRoutes(), newRoute) {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"route (%s) is not available on node %s: %w",
 				machine.Hostname,
 				newRoute, ErrMachineRouteIsNotAvailable,
@@ -942,13 +1761,80 @@ func (h *Headscale) EnableRoutes(machine *Machine, routeStrs ...string) error {
 		}
 	}
 
-	machine.EnabledRoutes = newRoutes
+	return newRoutes, nil
+}
 
-	if err := h.db.Save(machine).Error; err != nil {
-		return fmt.Errorf("failed enable routes for machine in the database: %w", err)
+// MachineRoutes are the routes to enable on a machine, as an entry of a
+// routes batch.
+type MachineRoutes struct {
+	MachineID uint64
+	Routes    []string
+	// ExitNode also enables the exit routes of the machine.
+	ExitNode bool
+}
+
+// RoutesBatchError reports the entry of a routes batch which cannot be
+// applied, because its machine does not exist or its routes are invalid.
+type RoutesBatchError struct {
+	Index     int
+	MachineID uint64
+	Err       error
+}
+
+func (e RoutesBatchError) Error() string {
+	return fmt.Sprintf("entry %d, machine %d: %s", e.Index, e.MachineID, e.Err)
+}
+
+func (e RoutesBatchError) Unwrap() error {
+	return e.Err
+}
+
+// EnableRoutesBatch replaces the enabled routes of several machines in a
+// single transaction: if any entry fails, no machine is changed.
+// The clients are notified once, when the whole batch is applied.
+func (h *Headscale) EnableRoutesBatch(batch []MachineRoutes) ([]Machine, error) {
+	machines := make([]Machine, len(batch))
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for index, entry := range batch {
+			machine := &machines[index]
+			err := tx.Preload("Namespace").First(machine, entry.MachineID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return RoutesBatchError{Index: index, MachineID: entry.MachineID, Err: ErrMachineNotFound}
+			}
+			if err != nil {
+				return err
+			}
+
+			routes := entry.Routes
+			if entry.ExitNode {
+				routes, err = machine.withExitRoutes(routes)
+				if err != nil {
+					return RoutesBatchError{Index: index, MachineID: entry.MachineID, Err: err}
+				}
+			}
+
+			newRoutes, err := h.parseEnabledRoutes(machine, routes)
+			if err != nil {
+				return RoutesBatchError{Index: index, MachineID: entry.MachineID, Err: err}
+			}
+
+			machine.EnabledRoutes = newRoutes
+			if err := tx.Model(machine).Update("enabled_routes", machine.EnabledRoutes).Error; err != nil {
+				return fmt.Errorf("failed enable routes for machine in the database: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if len(batch) > 0 {
+		h.setLastStateChangeToNow()
+	}
+
+	return machines, nil
 }
 
 func (machine *Machine) RoutesToProto() *v1.Routes {
@@ -957,9 +1843,44 @@ func (machine *Machine) RoutesToProto() *v1.Routes {
 	enabledRoutes := machine.GetEnabledRoutes()
 
 	return &v1.Routes{
-		AdvertisedRoutes: ipPrefixToString(availableRoutes),
-		EnabledRoutes:    ipPrefixToString(enabledRoutes),
+		AdvertisedRoutes:   ipPrefixToString(availableRoutes),
+		EnabledRoutes:      ipPrefixToString(enabledRoutes),
+		AdvertisedExitNode: machine.advertisesExitRoutes(),
+		ExitNode:           machine.isExitNode(),
+		RouteStatuses:      machine.routeStatusesToProto(),
+	}
+}
+
+// routeStatusesToProto lists the advertised routes of the machine, followed
+// by the routes that are enabled but no longer advertised, with the state
+// of each.
+func (machine *Machine) routeStatusesToProto() []*v1.RouteStatus {
+	advertisedRoutes := machine.GetAdvertisedRoutes()
+	enabledRoutes := machine.GetEnabledRoutes()
+
+	statuses := make([]*v1.RouteStatus, 0, len(advertisedRoutes))
+	for _, route := range advertisedRoutes {
+		statuses = append(statuses, &v1.RouteStatus{
+			Prefix:     route.String(),
+			Advertised: true,
+			Enabled:    contains(enabledRoutes, route),
+			ExitRoute:  contains(exitRoutes, route),
+		})
 	}
+
+	for _, route := range enabledRoutes {
+		if contains(advertisedRoutes, route) {
+			continue
+		}
+
+		statuses = append(statuses, &v1.RouteStatus{
+			Prefix:    route.String(),
+			Enabled:   true,
+			ExitRoute: contains(exitRoutes, route),
+		})
+	}
+
+	return statuses
 }
 
 func (h *Headscale) GenerateGivenName(suppliedName string) (string, error) {
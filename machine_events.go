@@ -0,0 +1,83 @@
+package headscale
+
+import (
+	"sync"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/rs/zerolog/log"
+)
+
+// machineEventBufferSize is the number of events a watcher can lag behind
+// before it is disconnected.
+const machineEventBufferSize = 64
+
+// machineEvent is a change in the lifecycle of a machine, as sent to the
+// WatchMachines streams.
+type machineEvent struct {
+	eventType v1.MachineEventType
+	machine   Machine
+	online    bool
+}
+
+// machineEventBroker fans out the machine events to the watchers.
+// Its zero value is ready to use.
+type machineEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan machineEvent]struct{}
+}
+
+func (broker *machineEventBroker) subscribe() chan machineEvent {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if broker.subscribers == nil {
+		broker.subscribers = make(map[chan machineEvent]struct{})
+	}
+
+	events := make(chan machineEvent, machineEventBufferSize)
+	broker.subscribers[events] = struct{}{}
+
+	return events
+}
+
+func (broker *machineEventBroker) unsubscribe(events chan machineEvent) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if _, ok := broker.subscribers[events]; ok {
+		delete(broker.subscribers, events)
+		close(events)
+	}
+}
+
+// publish never blocks. A watcher too slow to keep up is unsubscribed,
+// its channel is closed so it knows it has to resynchronise.
+func (broker *machineEventBroker) publish(event machineEvent) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for events := range broker.subscribers {
+		select {
+		case events <- event:
+		default:
+			log.Warn().
+				Str("func", "publish").
+				Str("machine", event.machine.Hostname).
+				Msg("Machine events watcher is too slow, disconnecting it")
+
+			delete(broker.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+func (h *Headscale) publishMachineEvent(
+	eventType v1.MachineEventType,
+	machine Machine,
+) {
+	h.machineEvents.publish(machineEvent{
+		eventType: eventType,
+		machine:   machine,
+		online:    eventType == v1.MachineEventType_MACHINE_EVENT_TYPE_ONLINE,
+	})
+}
@@ -0,0 +1,78 @@
+package headscale
+
+import (
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestMachineEventBroker(c *check.C) {
+	var broker machineEventBroker
+
+	events := broker.subscribe()
+	broker.publish(machineEvent{
+		eventType: v1.MachineEventType_MACHINE_EVENT_TYPE_ONLINE,
+		machine:   Machine{Hostname: "testmachine"},
+		online:    true,
+	})
+
+	c.Assert(events, check.HasLen, 1)
+	event := <-events
+	c.Assert(event.eventType, check.Equals, v1.MachineEventType_MACHINE_EVENT_TYPE_ONLINE)
+	c.Assert(event.machine.Hostname, check.Equals, "testmachine")
+	c.Assert(event.online, check.Equals, true)
+
+	broker.unsubscribe(events)
+	_, ok := <-events
+	c.Assert(ok, check.Equals, false)
+
+	// unsubscribing twice must not close the channel twice
+	broker.unsubscribe(events)
+}
+
+func (s *Suite) TestMachineEventBrokerDisconnectsSlowWatchers(c *check.C) {
+	var broker machineEventBroker
+
+	slow := broker.subscribe()
+	for i := 0; i <= machineEventBufferSize; i++ {
+		broker.publish(machineEvent{
+			eventType: v1.MachineEventType_MACHINE_EVENT_TYPE_OFFLINE,
+		})
+	}
+
+	c.Assert(broker.subscribers, check.HasLen, 0)
+	received := 0
+	for range slow {
+		received++
+	}
+	c.Assert(received, check.Equals, machineEventBufferSize)
+
+	broker.unsubscribe(slow)
+}
+
+func (s *Suite) TestDeleteMachinePublishesEvent(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+	machine := Machine{
+		ID:             0,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		AuthKeyID:      uint(1),
+	}
+	app.db.Save(&machine)
+
+	events := app.machineEvents.subscribe()
+	defer app.machineEvents.unsubscribe(events)
+
+	err = app.DeleteMachine(&machine)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(events, check.HasLen, 1)
+	event := <-events
+	c.Assert(event.eventType, check.Equals, v1.MachineEventType_MACHINE_EVENT_TYPE_DELETED)
+	c.Assert(event.machine.ID, check.Equals, machine.ID)
+	c.Assert(event.online, check.Equals, false)
+}
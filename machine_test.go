@@ -1,7 +1,11 @@
 package headscale
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/netip"
 	"reflect"
 	"strconv"
@@ -9,7 +13,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/puzpuzpuz/xsync"
 	"gopkg.in/check.v1"
+	"gorm.io/gorm"
 	"tailscale.com/tailcfg"
 	"tailscale.com/types/key"
 )
@@ -18,7 +24,7 @@ func (s *Suite) TestGetMachine(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "testmachine")
@@ -44,7 +50,7 @@ func (s *Suite) TestGetMachineByID(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachineByID(0)
@@ -70,7 +76,7 @@ func (s *Suite) TestGetMachineByNodeKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachineByID(0)
@@ -98,7 +104,7 @@ func (s *Suite) TestGetMachineByAnyNodeKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachineByID(0)
@@ -143,6 +149,74 @@ func (s *Suite) TestDeleteMachine(c *check.C) {
 
 	_, err = app.GetMachine(namespace.Name, "testmachine")
 	c.Assert(err, check.NotNil)
+
+	// Without a deletion grace period, the machine is gone for good.
+	_, err = app.RestoreMachine(machine.ID)
+	c.Assert(err, check.Equals, ErrDeletedMachineNotFound)
+}
+
+func (s *Suite) TestSoftDeleteAndRestoreMachine(c *check.C) {
+	app.cfg.MachineDeletionGracePeriod = time.Hour
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	register := func(hostname string, machineKey string, ip string) *Machine {
+		machine := Machine{
+			MachineKey:     machineKey,
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       hostname,
+			GivenName:      hostname,
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+			IPAddresses:    MachineAddresses{netip.MustParseAddr(ip)},
+		}
+		c.Assert(app.db.Save(&machine).Error, check.IsNil)
+
+		return &machine
+	}
+	machine := register("deleted", "deleted-key", "100.64.0.1")
+	peer := register("peer", "peer-key", "100.64.0.2")
+
+	c.Assert(app.DeleteMachine(machine), check.IsNil)
+
+	// The soft deleted machine is gone from the netmaps...
+	_, err = app.GetMachineByID(machine.ID)
+	c.Assert(err, check.NotNil)
+	peers, err := app.getValidPeers(peer)
+	c.Assert(err, check.IsNil)
+	c.Assert(peers, check.HasLen, 0)
+
+	// ...but keeps its address until it is purged.
+	usedIPs, err := app.getUsedIPs()
+	c.Assert(err, check.IsNil)
+	c.Assert(usedIPs.Contains(netip.MustParseAddr("100.64.0.1")), check.Equals, true)
+
+	restored, err := app.RestoreMachine(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored.Namespace.Name, check.Equals, namespace.Name)
+	peers, err = app.getValidPeers(peer)
+	c.Assert(err, check.IsNil)
+	c.Assert(peers, check.HasLen, 1)
+
+	_, err = app.RestoreMachine(machine.ID)
+	c.Assert(err, check.Equals, ErrDeletedMachineNotFound)
+
+	// A machine registered again since its deletion cannot be restored.
+	c.Assert(app.DeleteMachine(restored), check.IsNil)
+	register("registered-again", "deleted-key", "100.64.0.3")
+	_, err = app.RestoreMachine(machine.ID)
+	c.Assert(err, check.Equals, ErrMachineRestoreConflict)
+
+	// Past the grace period, the machine cannot be restored and is purged.
+	app.cfg.MachineDeletionGracePeriod = time.Nanosecond
+	_, err = app.RestoreMachine(machine.ID)
+	c.Assert(err, check.Equals, ErrDeletedMachineNotFound)
+
+	app.purgeDeletedMachinesWorker()
+	err = app.db.Unscoped().First(&Machine{}, machine.ID).Error
+	c.Assert(errors.Is(err, gorm.ErrRecordNotFound), check.Equals, true)
 }
 
 func (s *Suite) TestHardDeleteMachine(c *check.C) {
@@ -171,7 +245,7 @@ func (s *Suite) TestListPeers(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachineByID(0)
@@ -203,6 +277,73 @@ func (s *Suite) TestListPeers(c *check.C) {
 	c.Assert(peersOfMachine0[8].Hostname, check.Equals, "testmachine10")
 }
 
+func (s *Suite) TestListMachinesPage(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+	otherNamespace, err := app.CreateNamespace("other")
+	c.Assert(err, check.IsNil)
+
+	for index := 1; index <= 7; index++ {
+		namespaceID := namespace.ID
+		if index%3 == 0 {
+			namespaceID = otherNamespace.ID
+		}
+		machine := Machine{
+			ID:             uint64(index),
+			MachineKey:     "foo" + strconv.Itoa(index),
+			NodeKey:        "bar" + strconv.Itoa(index),
+			DiscoKey:       "faa" + strconv.Itoa(index),
+			Hostname:       "testmachine" + strconv.Itoa(index),
+			NamespaceID:    namespaceID,
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+		app.db.Save(&machine)
+	}
+
+	machineIDs := func(machines []Machine) []uint64 {
+		ids := make([]uint64, len(machines))
+		for index, machine := range machines {
+			ids[index] = machine.ID
+		}
+
+		return ids
+	}
+
+	// without a page size, every machine is returned
+	machines, nextPageToken, err := app.ListMachinesPage("", false, 0, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(machineIDs(machines), check.DeepEquals, []uint64{1, 2, 3, 4, 5, 6, 7})
+	c.Assert(nextPageToken, check.Equals, "")
+
+	machines, nextPageToken, err = app.ListMachinesPage("", false, 3, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(machineIDs(machines), check.DeepEquals, []uint64{1, 2, 3})
+	c.Assert(nextPageToken, check.Not(check.Equals), "")
+
+	machines, nextPageToken, err = app.ListMachinesPage("", false, 3, nextPageToken)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineIDs(machines), check.DeepEquals, []uint64{4, 5, 6})
+	c.Assert(nextPageToken, check.Not(check.Equals), "")
+
+	machines, nextPageToken, err = app.ListMachinesPage("", false, 3, nextPageToken)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineIDs(machines), check.DeepEquals, []uint64{7})
+	c.Assert(nextPageToken, check.Equals, "")
+
+	machines, nextPageToken, err = app.ListMachinesPage(namespace.Name, false, 4, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(machineIDs(machines), check.DeepEquals, []uint64{1, 2, 4, 5})
+	c.Assert(nextPageToken, check.Not(check.Equals), "")
+
+	machines, nextPageToken, err = app.ListMachinesPage(namespace.Name, false, 4, nextPageToken)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineIDs(machines), check.DeepEquals, []uint64{7})
+	c.Assert(nextPageToken, check.Equals, "")
+
+	_, _, err = app.ListMachinesPage("", false, 3, "not-a-token")
+	c.Assert(err, check.Equals, ErrInvalidPageToken)
+}
+
 func (s *Suite) TestGetACLFilteredPeers(c *check.C) {
 	type base struct {
 		namespace *Namespace
@@ -214,7 +355,7 @@ func (s *Suite) TestGetACLFilteredPeers(c *check.C) {
 	for _, name := range []string{"test", "admin"} {
 		namespace, err := app.CreateNamespace(name)
 		c.Assert(err, check.IsNil)
-		pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+		pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 		c.Assert(err, check.IsNil)
 		stor = append(stor, base{namespace, pak})
 	}
@@ -294,7 +435,7 @@ func (s *Suite) TestExpireMachine(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "testmachine")
@@ -325,6 +466,272 @@ func (s *Suite) TestExpireMachine(c *check.C) {
 	c.Assert(machineFromDB.isExpired(), check.Equals, true)
 }
 
+func (s *Suite) TestSetMachineExpiry(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := &Machine{
+		ID:             0,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		Expiry:         &time.Time{},
+	}
+	app.db.Save(machine)
+
+	machineFromDB, err := app.GetMachine("test", "testmachine")
+	c.Assert(err, check.IsNil)
+
+	// a new expiry is pushed to the peers through the polls
+	before := time.Now()
+	future := before.Add(7 * 24 * time.Hour).Truncate(time.Second)
+	err = app.SetMachineExpiry(machineFromDB, &future)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.getLastStateChange(namespace.Name).Before(before), check.Equals, false)
+
+	machineFromDB, err = app.GetMachine("test", "testmachine")
+	c.Assert(err, check.IsNil)
+	c.Assert(machineFromDB.Expiry.Equal(future), check.Equals, true)
+	c.Assert(machineFromDB.isExpired(), check.Equals, false)
+
+	err = app.SetMachineExpiry(machineFromDB, nil)
+	c.Assert(err, check.IsNil)
+
+	machineFromDB, err = app.GetMachine("test", "testmachine")
+	c.Assert(err, check.IsNil)
+	c.Assert(machineFromDB.Expiry, check.IsNil)
+	c.Assert(machineFromDB.isExpired(), check.Equals, false)
+
+	// a past expiry expires the machine now, as ExpireMachine does
+	before = time.Now()
+	past := before.Add(-time.Hour)
+	err = app.SetMachineExpiry(machineFromDB, &past)
+	c.Assert(err, check.IsNil)
+
+	machineFromDB, err = app.GetMachine("test", "testmachine")
+	c.Assert(err, check.IsNil)
+	c.Assert(machineFromDB.isExpired(), check.Equals, true)
+	c.Assert(machineFromDB.Expiry.Before(before), check.Equals, false)
+}
+
+func (s *Suite) TestGetTagGrants(c *check.C) {
+	servers, err := app.CreateNamespace("servers")
+	c.Assert(err, check.IsNil)
+	users, err := app.CreateNamespace("users")
+	c.Assert(err, check.IsNil)
+	admins, err := app.CreateNamespace("admins")
+	c.Assert(err, check.IsNil)
+
+	tagged := Machine{
+		ID:          1,
+		MachineKey:  "foo1",
+		NodeKey:     "bar1",
+		DiscoKey:    "faa1",
+		Hostname:    "web",
+		NamespaceID: servers.ID,
+		Namespace:   *servers,
+		IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		HostInfo: HostInfo{
+			RequestTags: []string{"tag:web", "tag:unowned"},
+		},
+		ForcedTags: StringList{"tag:monitored"},
+	}
+	app.db.Save(&tagged)
+
+	for index, namespace := range []*Namespace{users, admins} {
+		machine := Machine{
+			ID:          uint64(index + 2),
+			MachineKey:  fmt.Sprintf("foo%d", index+2),
+			NodeKey:     fmt.Sprintf("bar%d", index+2),
+			DiscoKey:    fmt.Sprintf("faa%d", index+2),
+			Hostname:    namespace.Name,
+			NamespaceID: namespace.ID,
+			IPAddresses: MachineAddresses{
+				netip.MustParseAddr(fmt.Sprintf("100.64.0.%d", index+2)),
+			},
+		}
+		app.db.Save(&machine)
+	}
+
+	app.aclPolicy = &ACLPolicy{
+		TagOwners: TagOwners{
+			"tag:web":     []string{"servers"},
+			"tag:unowned": []string{"admins"},
+		},
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"users", "admins"},
+				Destinations: []string{"tag:web:80,443"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"admins"},
+				Destinations: []string{"users:22"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"tag:monitored"},
+				Destinations: []string{"admins:9100"},
+			},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	grants, err := app.getTagGrants(&tagged)
+	c.Assert(err, check.IsNil)
+	c.Assert(grants, check.DeepEquals, []tagGrant{
+		{
+			Tag:        "tag:monitored",
+			ACLIndexes: []int{2},
+			Namespaces: []string{"admins"},
+		},
+		{
+			Tag:        "tag:web",
+			ACLIndexes: []int{0},
+			Namespaces: []string{"admins", "users"},
+		},
+	})
+}
+
+func (s *Suite) TestPeerReasonsWithNamespacePolicy(c *check.C) {
+	servers, err := app.CreateNamespace("servers")
+	c.Assert(err, check.IsNil)
+	users, err := app.CreateNamespace("users")
+	c.Assert(err, check.IsNil)
+
+	web := Machine{
+		ID:          1,
+		MachineKey:  "foo1",
+		NodeKey:     "bar1",
+		DiscoKey:    "faa1",
+		Hostname:    "web",
+		NamespaceID: servers.ID,
+		Namespace:   *servers,
+		IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		HostInfo: HostInfo{
+			RequestTags: []string{"tag:web"},
+		},
+	}
+	db := Machine{
+		ID:          2,
+		MachineKey:  "foo2",
+		NodeKey:     "bar2",
+		DiscoKey:    "faa2",
+		Hostname:    "db",
+		NamespaceID: servers.ID,
+		Namespace:   *servers,
+		IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.2")},
+	}
+	laptop := Machine{
+		ID:          3,
+		MachineKey:  "foo3",
+		NodeKey:     "bar3",
+		DiscoKey:    "faa3",
+		Hostname:    "laptop",
+		NamespaceID: users.ID,
+		Namespace:   *users,
+		IPAddresses: MachineAddresses{netip.MustParseAddr("100.64.0.3")},
+	}
+	for _, machine := range []*Machine{&web, &db, &laptop} {
+		c.Assert(app.db.Save(machine).Error, check.IsNil)
+	}
+
+	app.aclPolicy = &ACLPolicy{
+		TagOwners: TagOwners{"tag:web": []string{"servers"}},
+		ACLs: []ACL{
+			{Action: "accept", Sources: []string{"users"}, Destinations: []string{"users:*"}},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	// the ACL of the namespace policy comes after the ones of the global
+	// policy
+	_, err = app.SetNamespaceACLPolicy(
+		"servers",
+		[]byte(`{"acls": [{"action": "accept", "src": ["tag:web"], "dst": ["servers:5432"]}]}`),
+	)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(app.getPeerReasons(&web, &db), check.DeepEquals, []peerReason{
+		{
+			ACLIndex:     1,
+			Sources:      []string{"tag:web"},
+			Destinations: []string{"servers:5432"},
+			Description:  "allowed by ACL 1",
+		},
+	})
+
+	grants, err := app.getTagGrants(&web)
+	c.Assert(err, check.IsNil)
+	c.Assert(grants, check.DeepEquals, []tagGrant{
+		{
+			Tag:        "tag:web",
+			ACLIndexes: []int{1},
+			Namespaces: []string{"servers"},
+		},
+	})
+}
+
+func (s *Suite) TestExtendMachineExpiry(c *check.C) {
+	app.cfg.MaxExpiryExtension = 24 * time.Hour
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	expiry := time.Now().UTC().Add(time.Hour)
+	machine := &Machine{
+		ID:             0,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		Expiry:         &expiry,
+	}
+	app.db.Save(machine)
+
+	err = app.ExtendMachineExpiry(machine, 2*time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.Expiry.Equal(expiry.Add(2*time.Hour)), check.Equals, true)
+
+	machineFromDB, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineFromDB.Expiry.Equal(expiry.Add(2*time.Hour)), check.Equals, true)
+
+	// Extending past the maximum is capped, even in several steps.
+	maxExpiry := time.Now().UTC().Add(24 * time.Hour)
+	err = app.ExtendMachineExpiry(machine, 22*time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.Expiry.Before(maxExpiry), check.Equals, false)
+	c.Assert(machine.Expiry.After(time.Now().UTC().Add(24*time.Hour)), check.Equals, false)
+
+	// An expiry already past the maximum is kept.
+	later := time.Now().UTC().Add(48 * time.Hour)
+	machine.Expiry = &later
+	err = app.ExtendMachineExpiry(machine, time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.Expiry.Equal(later), check.Equals, true)
+
+	err = app.ExtendMachineExpiry(machine, -time.Hour)
+	c.Assert(errors.Is(err, ErrInvalidExpiryExtension), check.Equals, true)
+
+	// An expired machine is extended from now.
+	past := time.Now().UTC().Add(-time.Hour)
+	machine.Expiry = &past
+	err = app.ExtendMachineExpiry(machine, time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.isExpired(), check.Equals, false)
+
+	machine.Expiry = nil
+	err = app.ExtendMachineExpiry(machine, time.Hour)
+	c.Assert(errors.Is(err, ErrMachineHasNoExpiry), check.Equals, true)
+}
+
 func (s *Suite) TestSerdeAddressStrignSlice(c *check.C) {
 	input := MachineAddresses([]netip.Addr{
 		netip.MustParseAddr("192.0.2.1"),
@@ -350,7 +757,7 @@ func (s *Suite) TestSetTags(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "testmachine")
@@ -389,6 +796,150 @@ func (s *Suite) TestSetTags(c *check.C) {
 	)
 }
 
+func (s *Suite) TestExpireNamespaceMachines(c *check.C) {
+	_, err := app.ExpireNamespaceMachines("test")
+	c.Assert(err, check.Equals, ErrNamespaceNotFound)
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	other, err := app.CreateNamespace("other")
+	c.Assert(err, check.IsNil)
+
+	expired := time.Now().Add(-time.Hour).UTC()
+	future := time.Now().Add(time.Hour).UTC()
+	for index, expiry := range []*time.Time{&expired, nil, &future, nil} {
+		namespaceID := namespace.ID
+		if index == 3 {
+			namespaceID = other.ID
+		}
+		machine := &Machine{
+			ID:             uint64(index + 1),
+			MachineKey:     "foo" + strconv.Itoa(index),
+			NodeKey:        "bar" + strconv.Itoa(index),
+			DiscoKey:       "faa" + strconv.Itoa(index),
+			Hostname:       "testmachine" + strconv.Itoa(index),
+			NamespaceID:    namespaceID,
+			RegisterMethod: RegisterMethodAuthKey,
+			Expiry:         expiry,
+		}
+		app.db.Save(machine)
+	}
+
+	affected, err := app.ExpireNamespaceMachines("test")
+	c.Assert(err, check.IsNil)
+	c.Assert(affected, check.Equals, 2)
+
+	machines, err := app.ListMachinesInNamespace("test")
+	c.Assert(err, check.IsNil)
+	for _, machine := range machines {
+		c.Assert(machine.isExpired(), check.Equals, true)
+	}
+	// the machine already expired is not stamped again
+	machine, err := app.GetMachineByID(1)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.Expiry.Equal(expired), check.Equals, true)
+
+	machine, err = app.GetMachineByID(4)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.Expiry, check.IsNil)
+
+	lastStateChange := app.getLastStateChange()
+	affected, err = app.ExpireNamespaceMachines("test")
+	c.Assert(err, check.IsNil)
+	c.Assert(affected, check.Equals, 0)
+	c.Assert(app.getLastStateChange(), check.Equals, lastStateChange)
+}
+
+func (s *Suite) TestBulkSetNamespaceTags(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	other, err := app.CreateNamespace("other")
+	c.Assert(err, check.IsNil)
+
+	for index, namespaceID := range []uint{namespace.ID, namespace.ID, other.ID} {
+		machine := &Machine{
+			ID:             uint64(index + 1),
+			MachineKey:     "foo" + strconv.Itoa(index),
+			NodeKey:        "bar" + strconv.Itoa(index),
+			DiscoKey:       "faa" + strconv.Itoa(index),
+			Hostname:       "testmachine" + strconv.Itoa(index),
+			NamespaceID:    namespaceID,
+			RegisterMethod: RegisterMethodAuthKey,
+			ForcedTags:     StringList{"tag:foo"},
+		}
+		app.db.Save(machine)
+	}
+
+	forcedTags := func(namespaceName string) []StringList {
+		machines, err := app.ListMachinesInNamespace(namespaceName)
+		c.Assert(err, check.IsNil)
+
+		tags := []StringList{}
+		for _, machine := range machines {
+			tags = append(tags, machine.ForcedTags)
+		}
+
+		return tags
+	}
+
+	affected, err := app.BulkSetNamespaceTags(
+		"test",
+		[]string{"tag:bar", "tag:foo"},
+		BulkTagModeAdd,
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(affected, check.Equals, 2)
+	c.Assert(forcedTags("test"), check.DeepEquals, []StringList{
+		{"tag:foo", "tag:bar"},
+		{"tag:foo", "tag:bar"},
+	})
+	c.Assert(forcedTags("other"), check.DeepEquals, []StringList{{"tag:foo"}})
+
+	// adding tags the machines already have changes nothing
+	affected, err = app.BulkSetNamespaceTags("test", []string{"tag:bar"}, BulkTagModeAdd)
+	c.Assert(err, check.IsNil)
+	c.Assert(affected, check.Equals, 0)
+
+	affected, err = app.BulkSetNamespaceTags("test", []string{"tag:foo"}, BulkTagModeRemove)
+	c.Assert(err, check.IsNil)
+	c.Assert(affected, check.Equals, 2)
+	c.Assert(forcedTags("test"), check.DeepEquals, []StringList{
+		{"tag:bar"},
+		{"tag:bar"},
+	})
+
+	affected, err = app.BulkSetNamespaceTags(
+		"test",
+		[]string{"tag:baz", "tag:baz"},
+		BulkTagModeReplace,
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(affected, check.Equals, 2)
+	c.Assert(forcedTags("test"), check.DeepEquals, []StringList{
+		{"tag:baz"},
+		{"tag:baz"},
+	})
+
+	// with a policy, only the tags declared in tagOwners can be set
+	app.aclPolicy = &ACLPolicy{
+		TagOwners: TagOwners{"tag:baz": []string{"test"}},
+	}
+	_, err = app.BulkSetNamespaceTags("test", []string{"tag:unknown"}, BulkTagModeAdd)
+	c.Assert(errors.Is(err, ErrTagNotInTagOwners), check.Equals, true)
+	c.Assert(forcedTags("test"), check.DeepEquals, []StringList{
+		{"tag:baz"},
+		{"tag:baz"},
+	})
+
+	_, err = app.BulkSetNamespaceTags("test", []string{"tag:baz"}, BulkTagMode(42))
+	c.Assert(errors.Is(err, ErrInvalidBulkTagMode), check.Equals, true)
+
+	_, err = app.BulkSetNamespaceTags("unknown", []string{"tag:baz"}, BulkTagModeAdd)
+	c.Assert(errors.Is(err, ErrNamespaceNotFound), check.Equals, true)
+}
+
 func Test_getTags(t *testing.T) {
 	type args struct {
 		aclPolicy        *ACLPolicy
@@ -422,6 +973,26 @@ func Test_getTags(t *testing.T) {
 			wantValid:   []string{"tag:valid"},
 			wantInvalid: nil,
 		},
+		{
+			name: "namespace default tags",
+			args: args{
+				aclPolicy: &ACLPolicy{
+					TagOwners: TagOwners{
+						"tag:valid":   []string{"joe"},
+						"tag:notjoes": []string{"marc"},
+					},
+				},
+				machine: Machine{
+					Namespace: Namespace{
+						Name:        "joe",
+						DefaultTags: StringList{"tag:valid", "tag:notjoes"},
+					},
+				},
+				stripEmailDomain: false,
+			},
+			wantValid:   []string{"tag:valid"},
+			wantInvalid: []string{"tag:notjoes"},
+		},
 		{
 			name: "invalid tag and valid tag one machine",
 			args: args{
@@ -1050,3 +1621,96 @@ func TestHeadscale_GenerateGivenName(t *testing.T) {
 		})
 	}
 }
+
+func (s *Suite) TestFirstSeenIsNotUpdatedByPolls(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.FirstSeen, check.NotNil)
+	firstSeen := *machine.FirstSeen
+
+	for i := 0; i < 2; i++ {
+		machine, err = app.GetMachineByID(machine.ID)
+		c.Assert(err, check.IsNil)
+
+		mapRequest := tailcfg.MapRequest{
+			Hostinfo:  &tailcfg.Hostinfo{Hostname: "testmachine"},
+			OmitPeers: true,
+		}
+		recorder := httptest.NewRecorder()
+		app.handlePollCommon(recorder, context.Background(), machine, mapRequest, true)
+		c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	}
+
+	machine, err = app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.LastSeen, check.NotNil)
+	c.Assert(machine.LastSeen.After(firstSeen), check.Equals, true)
+	c.Assert(machine.FirstSeen.Equal(firstSeen), check.Equals, true)
+
+	// Registering the machine again, e.g. after it expired, keeps it too.
+	machine, err = app.RegisterMachine(*machine)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.FirstSeen.Equal(firstSeen), check.Equals, true)
+}
+
+func (s *Suite) TestIsOutdatedAfterRestart(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	beforeChange := time.Now().UTC()
+	outdated := Machine{
+		MachineKey:           "foo",
+		NodeKey:              "bar",
+		DiscoKey:             "faa",
+		Hostname:             "outdated",
+		GivenName:            "outdated",
+		NamespaceID:          namespace.ID,
+		RegisterMethod:       RegisterMethodAuthKey,
+		LastSuccessfulUpdate: &beforeChange,
+	}
+	app.db.Save(&outdated)
+
+	time.Sleep(time.Millisecond)
+	app.setLastStateChangeToNow()
+	lastChange := app.getLastStateChange()
+	time.Sleep(time.Millisecond)
+
+	// the state change itself does not write to the database, the notifier
+	// persists it later
+	_, err = app.getValue(lastStateChangeKey)
+	c.Assert(errors.Is(err, errValueNotFound), check.Equals, true)
+	c.Assert(app.persistLastStateChange(time.Time{}).Equal(lastChange), check.Equals, true)
+	c.Assert(app.persistLastStateChange(lastChange).Equal(lastChange), check.Equals, true)
+
+	afterChange := time.Now().UTC()
+	upToDate := Machine{
+		MachineKey:           "foo2",
+		NodeKey:              "bar2",
+		DiscoKey:             "faa2",
+		Hostname:             "uptodate",
+		GivenName:            "uptodate",
+		NamespaceID:          namespace.ID,
+		RegisterMethod:       RegisterMethodAuthKey,
+		LastSuccessfulUpdate: &afterChange,
+	}
+	app.db.Save(&upToDate)
+
+	// the in-memory state is lost on restart
+	app.lastStateChange = xsync.NewMapOf[time.Time]()
+	c.Assert(app.restoreLastStateChange(), check.IsNil)
+
+	c.Assert(app.getLastStateChange().Equal(lastChange), check.Equals, true)
+	c.Assert(app.isOutdated(&outdated), check.Equals, true)
+	c.Assert(app.isOutdated(&upToDate), check.Equals, false)
+}
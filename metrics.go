@@ -22,6 +22,12 @@ var (
 		Help:      "The total amount of registered machine attempts",
 	}, []string{"action", "auth", "status", "namespace"})
 
+	registrationCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "registration_cache_entries",
+		Help:      "The number of pending registrations in the registration cache",
+	})
+
 	updateRequestsFromNode = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: prometheusNamespace,
 		Name:      "update_request_from_node_total",
@@ -38,4 +44,27 @@ var (
 		Name:      "update_request_received_on_channel_total",
 		Help:      "The number of update requests received on an update channel",
 	}, []string{"namespace", "machine"})
+
+	pollStreamsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "poll_streams_active",
+		Help:      "The number of long-poll map streams currently open",
+	})
+	keepAlivesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "keepalives_sent_total",
+		Help:      "The number of keep alive messages sent to the nodes",
+	})
+	mapUpdateSendDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Name:      "map_update_send_duration_seconds",
+		Help:      "Time spent generating and sending a map update to a node",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	oidcCallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "oidc_callbacks_total",
+		Help:      "The number of OIDC callbacks handled",
+	}, []string{"status"})
 )
@@ -1,6 +1,7 @@
 package headscale
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"regexp"
@@ -20,6 +21,9 @@ const (
 	ErrNamespaceNotFound        = Error("Namespace not found")
 	ErrNamespaceNotEmptyOfNodes = Error("Namespace not empty: node(s) found")
 	ErrInvalidNamespaceName     = Error("Invalid namespace name")
+	ErrNamespaceTagNotOwned     = Error("namespace is not an owner of the tag")
+
+	ErrNamespaceMachineQuotaExceeded = Error("namespace machine quota exceeded")
 )
 
 const (
@@ -29,6 +33,10 @@ const (
 
 var invalidCharsInNamespaceRegex = regexp.MustCompile("[^a-z0-9-.]+")
 
+// reservedNamespaceNames are special-use DNS names (RFC 6761), which cannot
+// be used as namespace names.
+var reservedNamespaceNames = []string{"localhost", "invalid"}
+
 // Namespace is the way Headscale implements the concept of users in Tailscale
 //
 // At the end of the day, users in Tailscale are some kind of 'bubbles' or namespaces
@@ -36,12 +44,28 @@ var invalidCharsInNamespaceRegex = regexp.MustCompile("[^a-z0-9-.]+")
 type Namespace struct {
 	gorm.Model
 	Name string `gorm:"unique"`
+
+	// DefaultTags are considered present on every machine of the namespace
+	// when the ACLs are expanded, as long as the namespace owns them.
+	DefaultTags StringList
+
+	// EmailDomain is the email domain of the OIDC user the namespace was
+	// created for, when strip_email_domain left it out of the name.
+	EmailDomain string
+
+	// ACLPolicy is the policy managed for the namespace, whose ACLs are
+	// merged into the global ACL policy. Empty when it has none.
+	ACLPolicy string
+
+	// MachineQuota is the maximum number of machines of the namespace,
+	// checked when a new machine registers. 0 means no limit.
+	MachineQuota uint
 }
 
 // CreateNamespace creates a new Namespace. Returns error if could not be created
 // or another namespace already exists.
 func (h *Headscale) CreateNamespace(name string) (*Namespace, error) {
-	err := CheckForFQDNRules(name)
+	err := validateNamespaceName(name)
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +102,13 @@ func (h *Headscale) DestroyNamespace(name string) error {
 		return ErrNamespaceNotEmptyOfNodes
 	}
 
+	// The soft deleted machines cannot be restored without their namespace.
+	if result := h.db.Unscoped().
+		Where("namespace_id = ? AND deleted_at IS NOT NULL", namespace.ID).
+		Delete(&Machine{}); result.Error != nil {
+		return result.Error
+	}
+
 	keys, err := h.ListPreAuthKeys(name)
 	if err != nil {
 		return err
@@ -92,6 +123,7 @@ func (h *Headscale) DestroyNamespace(name string) error {
 	if result := h.db.Unscoped().Delete(&namespace); result.Error != nil {
 		return result.Error
 	}
+	h.resetNamespaceACLPolicies()
 
 	return nil
 }
@@ -121,6 +153,173 @@ func (h *Headscale) RenameNamespace(oldName, newName string) error {
 	if result := h.db.Save(&oldNamespace); result.Error != nil {
 		return result.Error
 	}
+	h.resetNamespaceACLPolicies()
+
+	return nil
+}
+
+// SetNamespaceTags replaces the default tags of a Namespace. When an ACL
+// policy is loaded, the namespace must be an owner of the tags.
+func (h *Headscale) SetNamespaceTags(name string, tags []string) (*Namespace, error) {
+	namespace, err := h.GetNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	newTags := []string{}
+	for _, tag := range tags {
+		if !contains(newTags, tag) {
+			newTags = append(newTags, tag)
+		}
+	}
+
+	err = h.checkNamespaceOwnsTags(namespace, newTags, ErrNamespaceTagNotOwned)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace.DefaultTags = newTags
+	if err := h.db.Model(namespace).Update("default_tags", namespace.DefaultTags).Error; err != nil {
+		return nil, fmt.Errorf("failed to update the default tags of the namespace: %w", err)
+	}
+
+	if err := h.UpdateACLRules(); err != nil && !errors.Is(err, errEmptyPolicy) {
+		return nil, err
+	}
+	h.setLastStateChangeToNow()
+
+	return namespace, nil
+}
+
+// SetNamespaceMachineQuota sets the maximum number of machines of the
+// namespace, 0 removing the limit. The machines already registered beyond
+// a lowered quota are kept.
+func (h *Headscale) SetNamespaceMachineQuota(name string, quota uint) (*Namespace, error) {
+	namespace, err := h.GetNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace.MachineQuota = quota
+	if err := h.db.Model(namespace).Update("machine_quota", quota).Error; err != nil {
+		return nil, fmt.Errorf("failed to update the machine quota of the namespace: %w", err)
+	}
+
+	return namespace, nil
+}
+
+// countNamespaceMachines returns the number of machines of the namespace.
+func (h *Headscale) countNamespaceMachines(namespaceID uint) (int64, error) {
+	var count int64
+	if err := h.db.Model(&Machine{}).
+		Where("namespace_id = ?", namespaceID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// checkNamespaceMachineQuota returns ErrNamespaceMachineQuotaExceeded if
+// the namespace already has as many machines as its quota allows.
+func (h *Headscale) checkNamespaceMachineQuota(namespaceID uint) error {
+	namespace := Namespace{}
+	err := h.db.First(&namespace, namespaceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find the namespace of the machine: %w", err)
+	}
+
+	if namespace.MachineQuota == 0 {
+		return nil
+	}
+
+	count, err := h.countNamespaceMachines(namespace.ID)
+	if err != nil {
+		return err
+	}
+
+	if count >= int64(namespace.MachineQuota) {
+		return fmt.Errorf(
+			"%w: %s already has %d machines, the maximum",
+			ErrNamespaceMachineQuotaExceeded,
+			namespace.Name,
+			count,
+		)
+	}
+
+	return nil
+}
+
+// SetNamespaceACLPolicy replaces the ACL policy of the namespace, which can
+// only contain ACLs between the machines of the namespace and of the tags it
+// owns. An empty policy removes it. It returns the warnings of the policy.
+func (h *Headscale) SetNamespaceACLPolicy(name string, policyBytes []byte) ([]string, error) {
+	namespace, err := h.GetNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []string{}
+	if len(bytes.TrimSpace(policyBytes)) == 0 {
+		policyBytes = nil
+	} else {
+		_, warnings, err = h.checkNamespaceACLPolicy(
+			h.getACLPolicy(),
+			namespace,
+			policyBytes,
+			h.cfg.ACL.EmptyAlias,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.db.Model(namespace).Update("acl_policy", string(policyBytes)).Error; err != nil {
+		return nil, fmt.Errorf("failed to update the ACL policy of the namespace: %w", err)
+	}
+	h.resetNamespaceACLPolicies()
+
+	if err := h.UpdateACLRules(); err != nil && !errors.Is(err, errEmptyPolicy) {
+		return nil, err
+	}
+	h.setLastStateChangeToNow()
+
+	return warnings, nil
+}
+
+// checkNamespaceOwnsTags checks that the namespace is an owner of the tags in
+// the ACL policy, returning notOwnedErr for the first tag it does not own.
+func (h *Headscale) checkNamespaceOwnsTags(
+	namespace *Namespace,
+	tags []string,
+	notOwnedErr error,
+) error {
+	aclPolicy := h.getACLPolicy()
+	if aclPolicy == nil {
+		return nil
+	}
+
+	for _, tag := range tags {
+		owners, err := expandTagOwners(
+			*aclPolicy,
+			tag,
+			[]string{namespace.Name},
+			h.cfg.OIDC.StripEmaildomain,
+		)
+		if errors.Is(err, errInvalidTag) {
+			return fmt.Errorf("%w: %s", ErrTagNotInTagOwners, tag)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !contains(owners, namespace.Name) {
+			return fmt.Errorf("%w: %s", notOwnedErr, tag)
+		}
+	}
 
 	return nil
 }
@@ -183,6 +382,8 @@ func (h *Headscale) ListMachinesInNamespace(name string) ([]Machine, error) {
 }
 
 // SetMachineNamespace assigns a Machine to a namespace.
+// The machine keeps its keys and IP addresses, the ACL rules are recompiled
+// as they may depend on the namespace of the machine.
 func (h *Headscale) SetMachineNamespace(machine *Machine, namespaceName string) error {
 	err := CheckForFQDNRules(namespaceName)
 	if err != nil {
@@ -192,11 +393,17 @@ func (h *Headscale) SetMachineNamespace(machine *Machine, namespaceName string)
 	if err != nil {
 		return err
 	}
+	machine.NamespaceID = namespace.ID
 	machine.Namespace = *namespace
-	if result := h.db.Save(&machine); result.Error != nil {
+	if result := h.db.Save(machine); result.Error != nil {
 		return result.Error
 	}
 
+	if err := h.UpdateACLRules(); err != nil && !errors.Is(err, errEmptyPolicy) {
+		return err
+	}
+	h.setLastStateChangeToNow()
+
 	return nil
 }
 
@@ -248,10 +455,25 @@ func getMapResponseUserProfiles(machine Machine, peers Machines) []tailcfg.UserP
 
 func (n *Namespace) toProto() *v1.Namespace {
 	return &v1.Namespace{
-		Id:        strconv.FormatUint(uint64(n.ID), Base10),
-		Name:      n.Name,
-		CreatedAt: timestamppb.New(n.CreatedAt),
+		Id:           strconv.FormatUint(uint64(n.ID), Base10),
+		Name:         n.Name,
+		CreatedAt:    timestamppb.New(n.CreatedAt),
+		DefaultTags:  n.DefaultTags,
+		MachineQuota: uint32(n.MachineQuota),
+	}
+}
+
+// namespaceToProto returns the namespace along with its number of machines.
+func (h *Headscale) namespaceToProto(namespace *Namespace) (*v1.Namespace, error) {
+	count, err := h.countNamespaceMachines(namespace.ID)
+	if err != nil {
+		return nil, err
 	}
+
+	protoNamespace := namespace.toProto()
+	protoNamespace.MachineCount = uint64(count)
+
+	return protoNamespace, nil
 }
 
 // NormalizeToFQDNRules will replace forbidden chars in namespace
@@ -305,3 +527,55 @@ func CheckForFQDNRules(name string) error {
 
 	return nil
 }
+
+// validateNamespaceName checks that name can be used for a new namespace,
+// and reports why it cannot: empty, too long, invalid character or reserved.
+// For an invalid character, the error suggests the name normalised with
+// NormalizeToFQDNRules.
+func validateNamespaceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("namespace name must not be empty: %w", ErrInvalidNamespaceName)
+	}
+
+	if len(name) > labelHostnameLength {
+		return fmt.Errorf(
+			"namespace name %q is %d characters long, at most %d are allowed: %w",
+			name,
+			len(name),
+			labelHostnameLength,
+			ErrInvalidNamespaceName,
+		)
+	}
+
+	for position, char := range name {
+		if invalidCharsInNamespaceRegex.MatchString(string(char)) {
+			suggestion := ""
+			if normalized, err := NormalizeToFQDNRules(name, false); err == nil &&
+				normalized != "" && normalized != name {
+				suggestion = fmt.Sprintf(" (try %q)", normalized)
+			}
+
+			return fmt.Errorf(
+				"namespace name %q has an invalid character %q at position %d, "+
+					"only lowercase ASCII letters, digits, hyphens and dots are allowed%s: %w",
+				name,
+				char,
+				position,
+				suggestion,
+				ErrInvalidNamespaceName,
+			)
+		}
+	}
+
+	for _, reserved := range reservedNamespaceNames {
+		if name == reserved {
+			return fmt.Errorf(
+				"namespace name %q is reserved: %w",
+				name,
+				ErrInvalidNamespaceName,
+			)
+		}
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package headscale
 
 import (
+	"errors"
 	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/check.v1"
 	"gorm.io/gorm"
@@ -31,7 +34,7 @@ func (s *Suite) TestDestroyNamespaceErrors(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	err = app.DestroyNamespace("test")
@@ -44,7 +47,7 @@ func (s *Suite) TestDestroyNamespaceErrors(c *check.C) {
 	namespace, err = app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err = app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err = app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	machine := Machine{
@@ -61,6 +64,182 @@ func (s *Suite) TestDestroyNamespaceErrors(c *check.C) {
 
 	err = app.DestroyNamespace("test")
 	c.Assert(err, check.Equals, ErrNamespaceNotEmptyOfNodes)
+
+	// the soft deleted machines are purged with their namespace
+	app.cfg.MachineDeletionGracePeriod = time.Hour
+	err = app.DeleteMachine(&machine)
+	c.Assert(err, check.IsNil)
+
+	err = app.DestroyNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	result = app.db.Unscoped().First(&Machine{}, machine.ID)
+	c.Assert(result.Error, check.Equals, gorm.ErrRecordNotFound)
+}
+
+func (s *Suite) TestSetNamespaceTags(c *check.C) {
+	_, err := app.SetNamespaceTags("test", []string{"tag:ci"})
+	c.Assert(err, check.Equals, ErrNamespaceNotFound)
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	namespace, err = app.SetNamespaceTags(namespace.Name, []string{"tag:ci", "tag:ci", "tag:db"})
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace.DefaultTags, check.DeepEquals, StringList{"tag:ci", "tag:db"})
+
+	namespace, err = app.GetNamespace("test")
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace.toProto().GetDefaultTags(), check.DeepEquals, []string{"tag:ci", "tag:db"})
+
+	// with an ACL policy, the namespace must own the tags
+	app.aclPolicy = &ACLPolicy{
+		TagOwners: TagOwners{
+			"tag:ci":    []string{"test"},
+			"tag:other": []string{"other"},
+		},
+	}
+
+	_, err = app.SetNamespaceTags(namespace.Name, []string{"tag:unknown"})
+	c.Assert(errors.Is(err, ErrTagNotInTagOwners), check.Equals, true)
+
+	_, err = app.SetNamespaceTags(namespace.Name, []string{"tag:other"})
+	c.Assert(errors.Is(err, ErrNamespaceTagNotOwned), check.Equals, true)
+
+	namespace, err = app.SetNamespaceTags(namespace.Name, []string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace.DefaultTags, check.HasLen, 0)
+}
+
+func (s *Suite) TestSetNamespaceACLPolicy(c *check.C) {
+	_, err := app.SetNamespaceACLPolicy("test", []byte(`{"acls": []}`))
+	c.Assert(err, check.Equals, ErrNamespaceNotFound)
+
+	for index, name := range []string{"test", "other"} {
+		namespace, err := app.CreateNamespace(name)
+		c.Assert(err, check.IsNil)
+
+		machine := Machine{
+			ID:             uint64(index + 1),
+			MachineKey:     "foo" + name,
+			NodeKey:        "bar" + name,
+			DiscoKey:       "faa" + name,
+			Hostname:       "machine-" + name,
+			NamespaceID:    namespace.ID,
+			IPAddresses:    MachineAddresses{netip.AddrFrom4([4]byte{100, 64, 0, byte(index + 1)})},
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+		app.db.Save(&machine)
+	}
+
+	app.aclPolicy = &ACLPolicy{
+		Groups:    Groups{"group:admins": []string{"other"}},
+		TagOwners: TagOwners{"tag:ci": []string{"test"}, "tag:db": []string{"other"}},
+		ACLs: []ACL{
+			{Action: "accept", Sources: []string{"other"}, Destinations: []string{"other:*"}},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 1)
+
+	// the policy can only reference the namespace and the tags it owns
+	for _, policy := range []string{
+		`{"acls": [{"action": "accept", "src": ["other"], "dst": ["test:*"]}]}`,
+		`{"acls": [{"action": "accept", "src": ["test"], "dst": ["tag:db:*"]}]}`,
+		`{"acls": [{"action": "accept", "src": ["group:admins"], "dst": ["test:*"]}]}`,
+		`{"acls": [{"action": "accept", "src": ["*"], "dst": ["test:*"]}]}`,
+		`{"groups": {"group:test": ["test"]}, "acls": []}`,
+		`{"ssh": [{"action": "accept", "src": ["test"], "dst": ["test"], "users": ["root"]}]}`,
+	} {
+		_, err = app.SetNamespaceACLPolicy("test", []byte(policy))
+		c.Assert(
+			errors.Is(err, ErrInvalidNamespaceACLPolicy),
+			check.Equals,
+			true,
+			check.Commentf("policy %s", policy),
+		)
+	}
+
+	_, err = app.SetNamespaceACLPolicy(
+		"test",
+		[]byte(`{"acls": [{"action": "accept", "src": ["test"], "dst": ["test:22", "tag:ci:*"]}]}`),
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 2)
+	c.Assert(app.aclRules[1].SrcIPs, check.DeepEquals, []string{"100.64.0.1"})
+
+	namespace, err := app.GetNamespace("test")
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace.ACLPolicy, check.Not(check.Equals), "")
+
+	// the policy is kept when the global policy is reloaded
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 2)
+
+	// and left out once the namespace loses the ownership of its tags
+	policy := *app.aclPolicy
+	policy.TagOwners = TagOwners{"tag:ci": []string{"other"}}
+	app.aclPolicy = &policy
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 1)
+
+	_, err = app.SetNamespaceACLPolicy("test", []byte(" \n"))
+	c.Assert(err, check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 1)
+
+	namespace, err = app.GetNamespace("test")
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace.ACLPolicy, check.Equals, "")
+}
+
+func (s *Suite) TestNamespaceACLPoliciesCache(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine := Machine{
+		ID:             1,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "machine-test",
+		NamespaceID:    namespace.ID,
+		IPAddresses:    MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		RegisterMethod: RegisterMethodAuthKey,
+	}
+	app.db.Save(&machine)
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{Action: "accept", Sources: []string{"test"}, Destinations: []string{"test:80"}},
+		},
+	}
+	_, err = app.SetNamespaceACLPolicy(
+		"test",
+		[]byte(`{"acls": [{"action": "accept", "src": ["test"], "dst": ["test:22"]}]}`),
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 2)
+
+	// the policies of the namespaces are not read again when compiling the
+	// rules, until they change in the database
+	c.Assert(
+		app.db.Model(namespace).Update("acl_policy", "").Error,
+		check.IsNil,
+	)
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 2)
+
+	changed, err := app.namespaceACLPoliciesChanged()
+	c.Assert(err, check.IsNil)
+	c.Assert(changed, check.Equals, true)
+
+	app.resetNamespaceACLPolicies()
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+	c.Assert(app.aclRules, check.HasLen, 1)
+
+	changed, err = app.namespaceACLPoliciesChanged()
+	c.Assert(err, check.IsNil)
+	c.Assert(changed, check.Equals, false)
 }
 
 func (s *Suite) TestRenameNamespace(c *check.C) {
@@ -107,6 +286,10 @@ func (s *Suite) TestGetMapResponseUserProfiles(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -115,6 +298,10 @@ func (s *Suite) TestGetMapResponseUserProfiles(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -123,6 +310,10 @@ func (s *Suite) TestGetMapResponseUserProfiles(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -131,6 +322,10 @@ func (s *Suite) TestGetMapResponseUserProfiles(c *check.C) {
 		false,
 		false,
 		nil,
+		"",
+		0,
+		nil,
+		nil,
 	)
 	c.Assert(err, check.IsNil)
 
@@ -373,6 +568,63 @@ func TestCheckForFQDNRules(t *testing.T) {
 	}
 }
 
+func TestValidateNamespaceName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			input: "valid-namespace.example",
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: "must not be empty",
+		},
+		{
+			name:    "too long",
+			input:   "super-long-namespace-name-that-should-be-a-little-more-than-63-chars",
+			wantErr: "is 68 characters long, at most 63 are allowed",
+		},
+		{
+			name:    "uppercase character",
+			input:   "Namespace",
+			wantErr: `invalid character 'N' at position 0, only lowercase ASCII letters, digits, hyphens and dots are allowed (try "namespace")`,
+		},
+		{
+			name:    "invalid character",
+			input:   "super-namespace+name",
+			wantErr: `invalid character '+' at position 15, only lowercase ASCII letters, digits, hyphens and dots are allowed (try "super-namespace-name")`,
+		},
+		{
+			name:    "reserved",
+			input:   "localhost",
+			wantErr: `namespace name "localhost" is reserved`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNamespaceName(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNamespaceName() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidNamespaceName) {
+				t.Fatalf("validateNamespaceName() error = %v, want ErrInvalidNamespaceName", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNamespaceName() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func (s *Suite) TestSetMachineNamespace(c *check.C) {
 	oldNamespace, err := app.CreateNamespace("old")
 	c.Assert(err, check.IsNil)
@@ -380,7 +632,7 @@ func (s *Suite) TestSetMachineNamespace(c *check.C) {
 	newNamespace, err := app.CreateNamespace("new")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(oldNamespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(oldNamespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	machine := Machine{
@@ -0,0 +1,207 @@
+package headscale
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// notifyStateChange wakes up the notifier. It never blocks, state changes
+// happening while the notifier is busy are coalesced into one broadcast.
+func (h *Headscale) notifyStateChange() {
+	select {
+	case h.stateChangeChan <- struct{}{}:
+	default:
+	}
+}
+
+// runNotifier fans out the state changes to the update channels of the
+// connected clients, so that a state change costs one database read and a
+// channel send per outdated client instead of every client checking the
+// database.
+// As a safety net, the clients are also notified every
+// node_update_check_interval if the state changed since the last broadcast.
+// The last state change is persisted on the same interval.
+func (h *Headscale) runNotifier(cancelChan <-chan struct{}) {
+	ticker := time.NewTicker(h.cfg.NodeUpdateCheckInterval)
+	defer ticker.Stop()
+
+	lastBroadcast := time.Time{}
+	lastPersisted := time.Time{}
+	for {
+		select {
+		case <-cancelChan:
+			return
+
+		case <-h.stateChangeChan:
+		case <-ticker.C:
+			lastPersisted = h.persistLastStateChange(lastPersisted)
+			if !h.stateChangedSince(lastBroadcast) {
+				continue
+			}
+		}
+
+		lastBroadcast = time.Now().UTC()
+		h.notifyOutdatedClients()
+	}
+}
+
+// notifyOutdatedClients requests an update from the connected clients whose
+// machine missed the last state change. The last successful updates of the
+// machines are read once for all the clients, the streams woken up do not
+// check them again.
+func (h *Headscale) notifyOutdatedClients() {
+	lastChange := h.getLastStateChange()
+
+	machines := []Machine{}
+	err := h.db.Select("machine_key", "created_at", "last_successful_update").
+		Find(&machines).Error
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("Could not load the last updates of the machines, requesting an update from every client")
+		h.broadcastUpdate()
+
+		return
+	}
+
+	notified := 0
+	for index := range machines {
+		updateChan, ok := h.clientsUpdateChannels.Load(machines[index].MachineKey)
+		if !ok {
+			continue
+		}
+
+		lastUpdate := machines[index].CreatedAt
+		if machines[index].LastSuccessfulUpdate != nil {
+			lastUpdate = *machines[index].LastSuccessfulUpdate
+		}
+		if !lastUpdate.Before(lastChange) {
+			continue
+		}
+
+		select {
+		case updateChan <- struct{}{}:
+			notified++
+		default:
+		}
+	}
+
+	log.Debug().
+		Str("func", "notifyOutdatedClients").
+		Int("clients", h.clientsUpdateChannels.Size()).
+		Int("notified", notified).
+		Time("last_state_change", lastChange).
+		Msg("Requested an update from the outdated clients")
+}
+
+// stateChangedSince reports whether the state changed after the given time.
+// It is answered from memory, an idle interval costs no database query.
+func (h *Headscale) stateChangedSince(since time.Time) bool {
+	return h.getLastStateChange().After(since)
+}
+
+// persistLastStateChange saves the last state change in the database if it
+// changed after persisted, and returns the time now persisted. It is called
+// by the notifier and on shutdown rather than on every state change; a crash
+// loses at most one interval, and the clients get a full map when they
+// reconnect anyway.
+func (h *Headscale) persistLastStateChange(persisted time.Time) time.Time {
+	if h.lastStateChange == nil || h.lastStateChange.Size() == 0 {
+		return persisted
+	}
+
+	lastChange := h.getLastStateChange()
+	if !lastChange.After(persisted) {
+		return persisted
+	}
+
+	if err := h.setValue(lastStateChangeKey, lastChange.Format(time.RFC3339Nano)); err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("failed to persist the last state change")
+
+		return persisted
+	}
+
+	return lastChange
+}
+
+// registerUpdateChannel makes updateChan the update channel of the client of
+// machineKey, replacing the channel of a previous stream of the client.
+func (h *Headscale) registerUpdateChannel(machineKey string, updateChan chan struct{}) {
+	h.clientsUpdateChannelsMutex.Lock()
+	defer h.clientsUpdateChannelsMutex.Unlock()
+
+	h.clientsUpdateChannels.Store(machineKey, updateChan)
+}
+
+// unregisterUpdateChannel removes updateChan when a stream ends. When the
+// client reconnected before the end of its previous stream, the channel of
+// the new stream is left in place.
+func (h *Headscale) unregisterUpdateChannel(machineKey string, updateChan chan struct{}) {
+	h.clientsUpdateChannelsMutex.Lock()
+	defer h.clientsUpdateChannelsMutex.Unlock()
+
+	current, ok := h.clientsUpdateChannels.Load(machineKey)
+	if !ok || current != updateChan {
+		log.Debug().
+			Str("func", "unregisterUpdateChannel").
+			Str("machine_key", machineKey).
+			Msg("Update channel already replaced by a newer stream")
+
+		return
+	}
+
+	h.clientsUpdateChannels.Delete(machineKey)
+	h.forcedUpdates.Delete(machineKey)
+	h.mapResponseHashes.Delete(machineKey)
+}
+
+// forceUpdate requests an update from the client of machine that is sent
+// even if the machine looks up to date, for the clients missing an update
+// because their LastSuccessfulUpdate raced with a state change.
+func (h *Headscale) forceUpdate(machine *Machine) error {
+	updateChan, ok := h.clientsUpdateChannels.Load(machine.MachineKey)
+	if !ok {
+		return ErrMachineNotConnected
+	}
+
+	h.forcedUpdates.Store(machine.MachineKey, struct{}{})
+	select {
+	case updateChan <- struct{}{}:
+	default:
+		// a pending update request will pick up the forced update
+	}
+
+	log.Info().
+		Str("func", "forceUpdate").
+		Str("machine", machine.Hostname).
+		Msg("Forced an update of the machine")
+
+	return nil
+}
+
+// broadcastUpdate requests an update from every connected client.
+// A client which already has pending update requests is skipped, as it
+// will check for the latest state anyway.
+func (h *Headscale) broadcastUpdate() {
+	notified := 0
+	h.clientsUpdateChannels.Range(func(machineKey string, updateChan chan struct{}) bool {
+		select {
+		case updateChan <- struct{}{}:
+			notified++
+		default:
+		}
+
+		return true
+	})
+
+	log.Debug().
+		Str("func", "broadcastUpdate").
+		Int("clients", h.clientsUpdateChannels.Size()).
+		Int("notified", notified).
+		Msg("Requested an update from the connected clients")
+}
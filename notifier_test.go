@@ -0,0 +1,358 @@
+package headscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/puzpuzpuz/xsync"
+	"gopkg.in/check.v1"
+	"gorm.io/gorm"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+)
+
+func (s *Suite) TestNotifyStateChangeIsCoalesced(c *check.C) {
+	app.stateChangeChan = make(chan struct{}, 1)
+
+	app.notifyStateChange()
+	app.notifyStateChange()
+	app.notifyStateChange()
+
+	c.Assert(app.stateChangeChan, check.HasLen, 1)
+}
+
+func (s *Suite) TestBroadcastUpdate(c *check.C) {
+	idle := make(chan struct{}, 1)
+	busy := make(chan struct{}, 1)
+	busy <- struct{}{}
+	app.clientsUpdateChannels.Store("idle", idle)
+	app.clientsUpdateChannels.Store("busy", busy)
+
+	app.broadcastUpdate()
+
+	c.Assert(idle, check.HasLen, 1)
+	// the pending update request is enough, the broadcast does not block
+	c.Assert(busy, check.HasLen, 1)
+}
+
+func (s *Suite) TestNotifyOutdatedClients(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	app.setLastStateChangeToNow()
+	lastChange := app.getLastStateChange()
+	before := lastChange.Add(-time.Minute)
+	after := lastChange.Add(time.Minute)
+
+	updateChans := map[string]chan struct{}{}
+	for index, lastUpdate := range []*time.Time{&before, &after} {
+		machine := Machine{
+			ID:                   uint64(index + 1),
+			MachineKey:           fmt.Sprintf("machine%d", index),
+			NodeKey:              fmt.Sprintf("node%d", index),
+			DiscoKey:             fmt.Sprintf("disco%d", index),
+			Hostname:             fmt.Sprintf("machine%d", index),
+			NamespaceID:          namespace.ID,
+			LastSuccessfulUpdate: lastUpdate,
+		}
+		c.Assert(app.db.Save(&machine).Error, check.IsNil)
+
+		updateChans[machine.MachineKey] = make(chan struct{}, 1)
+		app.clientsUpdateChannels.Store(machine.MachineKey, updateChans[machine.MachineKey])
+	}
+
+	app.notifyOutdatedClients()
+
+	// only the client which missed the state change is woken up
+	c.Assert(updateChans["machine0"], check.HasLen, 1)
+	c.Assert(updateChans["machine1"], check.HasLen, 0)
+
+	for machineKey := range updateChans {
+		app.clientsUpdateChannels.Delete(machineKey)
+	}
+}
+
+func (s *Suite) TestForceUpdate(c *check.C) {
+	connected := &Machine{MachineKey: "connected", Hostname: "connected"}
+	busy := make(chan struct{}, 1)
+	busy <- struct{}{}
+	app.clientsUpdateChannels.Store(connected.MachineKey, busy)
+
+	err := app.forceUpdate(connected)
+	c.Assert(err, check.IsNil)
+	// the pending update request carries the forced update
+	c.Assert(busy, check.HasLen, 1)
+	_, forced := app.forcedUpdates.Load(connected.MachineKey)
+	c.Assert(forced, check.Equals, true)
+
+	err = app.forceUpdate(&Machine{MachineKey: "offline", Hostname: "offline"})
+	c.Assert(errors.Is(err, ErrMachineNotConnected), check.Equals, true)
+	_, forced = app.forcedUpdates.Load("offline")
+	c.Assert(forced, check.Equals, false)
+}
+
+// streamingResponseWriter hands every write of a stream to the test.
+type streamingResponseWriter struct {
+	*httptest.ResponseRecorder
+	written chan []byte
+}
+
+func (w *streamingResponseWriter) Write(data []byte) (int, error) {
+	w.written <- append([]byte{}, data...)
+
+	return len(data), nil
+}
+
+func (s *Suite) TestReconnectKeepsLiveUpdateChannel(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	registered, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+
+	// stream opens a stream of the machine, like a client connecting, and
+	// waits until its update channel is registered
+	stream := func(
+		ctx context.Context,
+		writer http.ResponseWriter,
+	) (chan struct{}, chan struct{}) {
+		machine, err := app.GetMachineByID(registered.ID)
+		c.Assert(err, check.IsNil)
+
+		updateChan := make(chan struct{}, 1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			app.pollNetMapStream(
+				writer,
+				ctx,
+				machine,
+				tailcfg.MapRequest{
+					Hostinfo: &tailcfg.Hostinfo{Hostname: machine.Hostname},
+					Stream:   true,
+				},
+				make(chan []byte),
+				make(chan []byte),
+				updateChan,
+				true,
+			)
+		}()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			current, ok := app.clientsUpdateChannels.Load(registered.MachineKey)
+			if ok && current == updateChan {
+				break
+			}
+			c.Assert(time.Now().Before(deadline), check.Equals, true)
+			time.Sleep(time.Millisecond)
+		}
+
+		return updateChan, done
+	}
+
+	// the client reconnects before its previous stream ended
+	firstCtx, firstCancel := context.WithCancel(context.Background())
+	defer firstCancel()
+	_, firstDone := stream(firstCtx, httptest.NewRecorder())
+
+	secondCtx, secondCancel := context.WithCancel(context.Background())
+	defer secondCancel()
+	writer := &streamingResponseWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		written:          make(chan []byte, 1),
+	}
+	live, secondDone := stream(secondCtx, writer)
+
+	// the end of the first stream leaves the channel of the second one
+	firstCancel()
+	<-firstDone
+
+	current, ok := app.clientsUpdateChannels.Load(registered.MachineKey)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(current == live, check.Equals, true)
+
+	// and a state change still reaches the client
+	app.setLastStateChangeToNow()
+	app.broadcastUpdate()
+
+	select {
+	case data := <-writer.written:
+		c.Assert(len(data) > reservedResponseHeaderSize, check.Equals, true)
+
+		streamed := tailcfg.MapResponse{}
+		c.Assert(json.Unmarshal(data[reservedResponseHeaderSize:], &streamed), check.IsNil)
+		c.Assert(streamed.Node, check.NotNil)
+		c.Assert(streamed.Node.Name, check.Matches, "testmachine.*")
+	case <-time.After(5 * time.Second):
+		c.Fatal("the update did not reach the live stream")
+	}
+
+	secondCancel()
+	<-secondDone
+
+	_, ok = app.clientsUpdateChannels.Load(registered.MachineKey)
+	c.Assert(ok, check.Equals, false)
+}
+
+// BenchmarkNotifier compares the database queries of a state change with
+// 500 connected clients, all missing it. Before the notifier, every client
+// checked the database on its own whether it was outdated; the notifier reads
+// the machines once and only wakes up the outdated clients. Sending the map
+// itself costs the same in both cases and is left out.
+// It also measures an idle node_update_check_interval, answered from memory,
+// and the recording of a state change, which writes nothing as the notifier
+// persists it later.
+func BenchmarkNotifier(b *testing.B) {
+	const clients = 500
+
+	h := Headscale{
+		cfg:      &Config{NodeUpdateCheckInterval: time.Hour},
+		dbType:   "sqlite3",
+		dbString: b.TempDir() + "/headscale_bench.db",
+
+		clientsUpdateChannels: xsync.NewMapOf[chan struct{}](),
+		stateChangeChan:       make(chan struct{}, 1),
+	}
+	err := h.initDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	h.db, err = h.openDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	namespace, err := h.CreateNamespace("bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	machines := make([]Machine, clients)
+	for index := range machines {
+		machines[index] = Machine{
+			MachineKey:  fmt.Sprintf("machine%d", index),
+			NodeKey:     fmt.Sprintf("node%d", index),
+			DiscoKey:    fmt.Sprintf("disco%d", index),
+			Hostname:    fmt.Sprintf("machine%d", index),
+			GivenName:   fmt.Sprintf("machine%d", index),
+			NamespaceID: namespace.ID,
+		}
+	}
+	if err := h.db.CreateInBatches(&machines, 100).Error; err != nil {
+		b.Fatal(err)
+	}
+	h.setLastStateChangeToNow()
+
+	var queries, writes int64
+	err = h.db.Callback().Query().After("gorm:query").
+		Register("bench:count_queries", func(*gorm.DB) {
+			atomic.AddInt64(&queries, 1)
+		})
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = h.db.Callback().Create().After("gorm:create").
+		Register("bench:count_creates", func(*gorm.DB) {
+			atomic.AddInt64(&writes, 1)
+		})
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = h.db.Callback().Update().After("gorm:update").
+		Register("bench:count_updates", func(*gorm.DB) {
+			atomic.AddInt64(&writes, 1)
+		})
+	if err != nil {
+		b.Fatal(err)
+	}
+	report := func(b *testing.B) {
+		b.ReportMetric(float64(atomic.LoadInt64(&queries))/float64(b.N), "queries/op")
+		b.ReportMetric(float64(atomic.LoadInt64(&writes))/float64(b.N), "writes/op")
+	}
+	reset := func() {
+		atomic.StoreInt64(&queries, 0)
+		atomic.StoreInt64(&writes, 0)
+	}
+
+	// the clients are woken up like the long-poll streams; before the
+	// notifier, they checked whether they were outdated
+	var woken sync.WaitGroup
+	checkOutdated := false
+	for index := range machines {
+		updateChan := make(chan struct{}, 1)
+		h.clientsUpdateChannels.Store(machines[index].MachineKey, updateChan)
+		go func(machine Machine) {
+			for range updateChan {
+				if checkOutdated {
+					h.isOutdated(&machine)
+				}
+				woken.Done()
+			}
+		}(machines[index])
+		defer close(updateChan)
+	}
+
+	// stateChange makes every machine outdated, without a query
+	stateChange := func() {
+		h.lastStateChange.Store(namespace.Name, time.Now().UTC())
+	}
+
+	b.Run("per-client-polling", func(b *testing.B) {
+		checkOutdated = true
+		reset()
+		for n := 0; n < b.N; n++ {
+			stateChange()
+			woken.Add(clients)
+			h.broadcastUpdate()
+			woken.Wait()
+		}
+		report(b)
+	})
+
+	b.Run("notifier", func(b *testing.B) {
+		checkOutdated = false
+		reset()
+		for n := 0; n < b.N; n++ {
+			stateChange()
+			woken.Add(clients)
+			h.notifyOutdatedClients()
+			woken.Wait()
+		}
+		report(b)
+	})
+
+	b.Run("idle-interval", func(b *testing.B) {
+		lastBroadcast := time.Now().UTC()
+		reset()
+		for n := 0; n < b.N; n++ {
+			if h.stateChangedSince(lastBroadcast) {
+				b.Fatal("no state change expected")
+			}
+		}
+		report(b)
+	})
+
+	b.Run("state-change", func(b *testing.B) {
+		reset()
+		for n := 0; n < b.N; n++ {
+			h.setLastStateChangeToNow()
+		}
+		report(b)
+	})
+}
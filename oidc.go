@@ -4,18 +4,25 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gorilla/mux"
+	"github.com/patrickmn/go-cache"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/nacl/secretbox"
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
 	"tailscale.com/types/key"
 )
 
@@ -26,8 +33,41 @@ const (
 	errNoOIDCIDToken           = Error("could not extract ID Token for OIDC callback")
 	errOIDCAllowedDomains      = Error("authenticated principal does not match any allowed domain")
 	errOIDCAllowedUsers        = Error("authenticated principal does not match any allowed user")
+	errOIDCAllowedGroups       = Error("authenticated principal is not in any allowed group")
 	errOIDCInvalidMachineState = Error("requested machine state key expired before authorisation completed")
-	errOIDCNodeKeyMissing      = Error("could not get node key from cache")
+	errOIDCMalformedState      = Error("OIDC state is not 32 hexadecimal characters")
+	errOIDCRevocationFailed    = Error("OIDC provider refused to revoke the session")
+	errOIDCNoRevocationSupport = Error("OIDC provider does not support token revocation")
+	errOIDCInvalidConfirmation = Error("pending OIDC registration expired before confirmation")
+	errOIDCUnknownProvider     = Error("unknown OIDC provider")
+	errOIDCNotInitialized      = Error("OIDC provider is not initialized")
+	errOIDCEmailDomainConflict = Error("namespace was created for another email domain")
+	errOIDCNamespaceClaim      = Error("OIDC claim naming the namespace is missing or empty")
+	errOIDCRefreshTokenSealed  = Error("failed to decrypt the OIDC refresh token")
+
+	// oidcStateLength is the length of the hex encoded state handed to the
+	// provider, randomByteSize random bytes.
+	oidcStateLength        = 2 * randomByteSize
+	oidcConfirmNonceLength = 32
+	// 32 random bytes give the 43 characters minimum of RFC 7636.
+	oidcCodeVerifierLength = 32
+	oidcCodeChallengeS256  = "S256"
+	// oidcOfflineAccessScope asks the provider for a refresh token.
+	oidcOfflineAccessScope = "offline_access"
+	// oidcRefreshTokenKeyLabel derives the key encrypting the stored
+	// refresh tokens from the private key of the server.
+	oidcRefreshTokenKeyLabel  = "headscale oidc refresh token"
+	oidcRefreshTokenNonceSize = 24
+
+	// emailDomainHashLength is the number of hexadecimal characters of the
+	// hash of the email domain suffixed to colliding namespace names.
+	emailDomainHashLength = 8
+
+	defaultOIDCRegisterRateLimit = 10
+	// oidcRegisterLimiterExpiration is how long the rate limiter of an
+	// idle client IP is kept. A limiter refills within a minute, so an
+	// older one is as good as a new one.
+	oidcRegisterLimiterExpiration = time.Minute
 )
 
 type IDTokenClaims struct {
@@ -35,45 +75,138 @@ type IDTokenClaims struct {
 	Groups   []string `json:"groups,omitempty"`
 	Email    string   `json:"email"`
 	Username string   `json:"preferred_username,omitempty"`
+
+	// All holds every claim, to look up the one configured as
+	// namespace_claim.
+	All map[string]interface{} `json:"-"`
+}
+
+// oidcRegistrationState is held in the registration cache under the OIDC
+// state while the user authenticates at the provider.
+type oidcRegistrationState struct {
+	NodeKey      string
+	Provider     string
+	CodeVerifier string
+}
+
+// oidcClient is a configured OIDC provider, with its own rules on who can
+// authenticate through it.
+type oidcClient struct {
+	name         string
+	cfg          OIDCConfig
+	provider     *oidc.Provider
+	oauth2Config *oauth2.Config
 }
 
 func (h *Headscale) initOIDC() error {
-	var err error
 	// grab oidc config if it hasn't been already
-	if h.oauth2Config == nil {
-		h.oidcProvider, err = oidc.NewProvider(context.Background(), h.cfg.OIDC.Issuer)
+	if h.oidcClients != nil {
+		return nil
+	}
 
+	clients := make(map[string]*oidcClient, len(h.cfg.OIDCProviders))
+	for name, cfg := range h.cfg.OIDCProviders {
+		provider, err := oidc.NewProvider(context.Background(), cfg.Issuer)
 		if err != nil {
 			log.Error().
 				Err(err).
 				Caller().
+				Str("provider", name).
 				Msgf("Could not retrieve OIDC Config: %s", err.Error())
 
 			return err
 		}
 
-		h.oauth2Config = &oauth2.Config{
-			ClientID:     h.cfg.OIDC.ClientID,
-			ClientSecret: h.cfg.OIDC.ClientSecret,
-			Endpoint:     h.oidcProvider.Endpoint(),
-			RedirectURL: fmt.Sprintf(
-				"%s/oidc/callback",
-				strings.TrimSuffix(h.cfg.ServerURL, "/"),
-			),
-			Scopes: h.cfg.OIDC.Scope,
+		// the refresh token revoked at logout is only issued with the
+		// offline_access scope by most providers
+		var discovery struct {
+			RevocationEndpoint string   `json:"revocation_endpoint"`
+			ScopesSupported    []string `json:"scopes_supported"`
+		}
+		if err := provider.Claims(&discovery); err != nil {
+			log.Warn().
+				Caller().
+				Err(err).
+				Str("provider", name).
+				Msg("Could not read OIDC discovery document")
+		}
+
+		clients[name] = &oidcClient{
+			name:     name,
+			cfg:      cfg,
+			provider: provider,
+			oauth2Config: &oauth2.Config{
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				Endpoint:     provider.Endpoint(),
+				RedirectURL: fmt.Sprintf(
+					"%s/oidc/callback",
+					strings.TrimSuffix(h.cfg.ServerURL, "/"),
+				),
+				Scopes: oidcScopes(
+					cfg.Scope,
+					discovery.RevocationEndpoint,
+					discovery.ScopesSupported,
+				),
+			},
+		}
+	}
+	h.oidcClients = clients
+
+	return nil
+}
+
+// checkOIDCInitialized returns an error when one of the configured OIDC
+// providers has no client.
+func (h *Headscale) checkOIDCInitialized() error {
+	for name := range h.cfg.OIDCProviders {
+		if client, ok := h.oidcClients[name]; !ok || client.provider == nil {
+			return fmt.Errorf("%w: %s", errOIDCNotInitialized, name)
 		}
 	}
 
 	return nil
 }
 
+type oidcProvidersTemplateConfig struct {
+	Links map[string]string
+}
+
+var oidcProvidersTemplate = template.Must(
+	template.New("oidcproviders").Parse(`<html>
+	<body>
+	<h1>headscale</h1>
+	<p>
+			Choose how to authenticate:
+	</p>
+	<ul>
+	{{range $name, $link := .Links}}
+			<li><a href="{{$link}}">{{$name}}</a></li>
+	{{end}}
+	</ul>
+	</body>
+	</html>`),
+)
+
 // RegisterOIDC redirects to the OIDC provider for authentication
 // Puts NodeKey in cache so the callback can retrieve it using the oidc state param
-// Listens in /oidc/register/:nKey.
+// When several providers are configured and none is given, a page to choose
+// one is rendered instead.
+// Listens in /oidc/register/:nKey and /oidc/register/:nKey/:provider.
 func (h *Headscale) RegisterOIDC(
 	writer http.ResponseWriter,
 	req *http.Request,
 ) {
+	if !h.allowOIDCRegister(req) {
+		log.Warn().
+			Caller().
+			Str("client_address", req.RemoteAddr).
+			Msg("Too many oidc register calls from the client")
+		http.Error(writer, "Too many requests", http.StatusTooManyRequests)
+
+		return
+	}
+
 	vars := mux.Vars(req)
 	nodeKeyStr, ok := vars["nkey"]
 	if !ok || nodeKeyStr == "" {
@@ -88,8 +221,34 @@ func (h *Headscale) RegisterOIDC(
 	log.Trace().
 		Caller().
 		Str("node_key", nodeKeyStr).
+		Str("provider", vars["provider"]).
 		Msg("Received oidc register call")
 
+	providerName := vars["provider"]
+	if providerName == "" {
+		if len(h.oidcClients) != 1 {
+			h.renderOIDCProvidersTemplate(writer, nodeKeyStr)
+
+			return
+		}
+
+		for name := range h.oidcClients {
+			providerName = name
+		}
+	}
+
+	client, ok := h.oidcClients[providerName]
+	if !ok {
+		log.Error().
+			Caller().
+			Err(errOIDCUnknownProvider).
+			Str("provider", providerName).
+			Msg("Received an oidc register call for an unknown provider")
+		http.Error(writer, "Unknown OIDC provider", http.StatusNotFound)
+
+		return
+	}
+
 	randomBlob := make([]byte, randomByteSize)
 	if _, err := rand.Read(randomBlob); err != nil {
 		log.Error().
@@ -100,24 +259,113 @@ func (h *Headscale) RegisterOIDC(
 		return
 	}
 
-	stateStr := hex.EncodeToString(randomBlob)[:32]
+	stateStr := hex.EncodeToString(randomBlob)
 
-	// place the node key into the state cache, so it can be retrieved later
-	h.registrationCache.Set(stateStr, nodeKeyStr, registerCacheExpiration)
+	registration := oidcRegistrationState{
+		NodeKey:  nodeKeyStr,
+		Provider: client.name,
+	}
 
 	// Add any extra parameter provided in the configuration to the Authorize Endpoint request
-	extras := make([]oauth2.AuthCodeOption, 0, len(h.cfg.OIDC.ExtraParams))
+	extras := make([]oauth2.AuthCodeOption, 0, len(client.cfg.ExtraParams))
 
-	for k, v := range h.cfg.OIDC.ExtraParams {
+	for k, v := range client.cfg.ExtraParams {
 		extras = append(extras, oauth2.SetAuthURLParam(k, v))
 	}
 
-	authURL := h.oauth2Config.AuthCodeURL(stateStr, extras...)
+	if client.usePKCE() {
+		codeVerifier, err := GenerateRandomStringURLSafe(oidcCodeVerifierLength)
+		if err != nil {
+			log.Error().
+				Caller().
+				Err(err).
+				Msg("could not generate PKCE code verifier")
+			http.Error(writer, "Internal server error", http.StatusInternalServerError)
+
+			return
+		}
+
+		registration.CodeVerifier = codeVerifier
+		extras = append(extras,
+			oauth2.SetAuthURLParam("code_challenge", oidcCodeChallenge(codeVerifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", oidcCodeChallengeS256),
+		)
+	}
+
+	// place the node key into the state cache, so it can be retrieved later
+	h.setRegistrationCache(stateStr, registration)
+
+	authURL := client.oauth2Config.AuthCodeURL(stateStr, extras...)
 	log.Debug().Msgf("Redirecting to %s for authentication", authURL)
 
 	http.Redirect(writer, req, authURL, http.StatusFound)
 }
 
+// allowOIDCRegister tells whether the client IP of req is still within
+// oidc.register_rate_limit, as every /oidc/register call stores a new state
+// in the registration cache.
+func (h *Headscale) allowOIDCRegister(req *http.Request) bool {
+	limit := h.cfg.OIDCRegisterRateLimit
+	if limit <= 0 || h.oidcRegisterLimiters == nil {
+		return true
+	}
+
+	clientIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		clientIP = req.RemoteAddr
+	}
+
+	limiter := rate.NewLimiter(rate.Every(time.Minute/time.Duration(limit)), limit)
+	if err := h.oidcRegisterLimiters.Add(clientIP, limiter, cache.DefaultExpiration); err != nil {
+		if existing, ok := h.oidcRegisterLimiters.Get(clientIP); ok {
+			if existingLimiter, ok := existing.(*rate.Limiter); ok {
+				limiter = existingLimiter
+			}
+		}
+		// keep the limiter as long as the client is active
+		h.oidcRegisterLimiters.Set(clientIP, limiter, cache.DefaultExpiration)
+	}
+
+	return limiter.Allow()
+}
+
+func (h *Headscale) renderOIDCProvidersTemplate(
+	writer http.ResponseWriter,
+	nodeKeyStr string,
+) {
+	links := make(map[string]string, len(h.oidcClients))
+	for name := range h.oidcClients {
+		links[name] = fmt.Sprintf(
+			"%s/oidc/register/%s/%s",
+			strings.TrimSuffix(h.cfg.ServerURL, "/"),
+			url.PathEscape(nodeKeyStr),
+			url.PathEscape(name),
+		)
+	}
+
+	var content bytes.Buffer
+	if err := oidcProvidersTemplate.Execute(&content, oidcProvidersTemplateConfig{
+		Links: links,
+	}); err != nil {
+		log.Error().
+			Str("func", "RegisterOIDC").
+			Err(err).
+			Msg("Could not render OIDC providers template")
+		http.Error(writer, "Could not render OIDC providers template", http.StatusInternalServerError)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	if _, err := writer.Write(content.Bytes()); err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("Failed to write response")
+	}
+}
+
 type oidcCallbackTemplateConfig struct {
 	User string
 	Verb string
@@ -134,76 +382,192 @@ var oidcCallbackTemplate = template.Must(
 	</html>`),
 )
 
+// oidcPendingRegistration is a new machine authenticated through OIDC,
+// waiting in the registration cache for the user to confirm it.
+type oidcPendingRegistration struct {
+	NodeKey       key.NodePublic
+	NamespaceName string
+	Provider      string
+	RefreshToken  string
+	Expiry        time.Time
+	Claims        IDTokenClaims
+
+	// EmailDomain is recorded on the namespace if it is created for the
+	// registration, when its name is the email without the domain.
+	EmailDomain string
+}
+
+type oidcConfirmTemplateConfig struct {
+	User        string
+	Fingerprint string
+	Namespace   string
+	Action      string
+	Nonce       string
+}
+
+var oidcConfirmTemplate = template.Must(
+	template.New("oidcconfirm").Parse(`<html>
+	<body>
+	<h1>headscale</h1>
+	<p>
+			Authenticated as {{.User}}.
+			Do you want to register the machine {{.Fingerprint}} in the namespace {{.Namespace}}?
+	</p>
+	<p>
+			Only confirm if you started this registration yourself.
+	</p>
+	<form method="POST" action="{{.Action}}">
+			<input type="hidden" name="nonce" value="{{.Nonce}}">
+			<button type="submit">Register machine</button>
+	</form>
+	</body>
+	</html>`),
+)
+
 // OIDCCallback handles the callback from the OIDC endpoint
-// Retrieves the nkey from the state cache and adds the machine to the users email namespace
-// TODO: A confirmation page for new machines should be added to avoid phishing vulnerabilities
+// Retrieves the nkey from the state cache, reauthenticates known machines and asks
+// the user to confirm the registration of new machines, in the namespace mapped to
+// the users groups or in the users email namespace
 // TODO: Add groups information from OIDC tokens into machine HostInfo
 // Listens in /oidc/callback.
 func (h *Headscale) OIDCCallback(
 	writer http.ResponseWriter,
 	req *http.Request,
 ) {
+	callbackStatus := "failure"
+	defer func() {
+		oidcCallbacks.WithLabelValues(callbackStatus).Inc()
+	}()
+
 	code, state, err := validateOIDCCallbackParams(writer, req)
 	if err != nil {
 		return
 	}
 
-	rawIDToken, err := h.getIDTokenForOIDCCallback(req.Context(), writer, code, state)
+	client, registration, err := h.getOIDCClientForOIDCCallback(writer, state)
 	if err != nil {
 		return
 	}
 
-	idToken, err := h.verifyIDTokenForOIDCCallback(req.Context(), writer, rawIDToken)
+	oauth2Token, rawIDToken, err := getIDTokenForOIDCCallback(
+		req.Context(),
+		writer,
+		client,
+		code,
+		state,
+		registration.CodeVerifier,
+	)
 	if err != nil {
 		return
 	}
 
-	// TODO: we can use userinfo at some point to grab additional information about the user (groups membership, etc)
-	// userInfo, err := oidcProvider.UserInfo(context.Background(), oauth2.StaticTokenSource(oauth2Token))
-	// if err != nil {
-	// 	c.String(http.StatusBadRequest, fmt.Sprintf("Failed to retrieve userinfo"))
-	// 	return
-	// }
+	idToken, err := verifyIDTokenForOIDCCallback(req.Context(), writer, client, rawIDToken)
+	if err != nil {
+		return
+	}
 
 	claims, err := extractIDTokenClaims(writer, idToken)
 	if err != nil {
 		return
 	}
 
-	if err := validateOIDCAllowedDomains(writer, h.cfg.OIDC.AllowedDomains, claims); err != nil {
-		return
+	if client.cfg.UseUserInfo {
+		client.mergeUserInfoClaims(req.Context(), oauth2Token, claims)
 	}
 
-	if err := validateOIDCAllowedUsers(writer, h.cfg.OIDC.AllowedUsers, claims); err != nil {
+	if err := validateOIDCAllowedDomains(writer, client.cfg.AllowedDomains, claims); err != nil {
 		return
 	}
 
-	nodeKey, machineExists, err := h.validateMachineForOIDCCallback(writer, state, claims)
-	if err != nil || machineExists {
+	if err := validateOIDCAllowedUsers(
+		writer,
+		client.cfg.AllowedUsers,
+		client.cfg.CaseSensitiveLocalPart,
+		claims,
+	); err != nil {
 		return
 	}
 
-	namespaceName, err := getNamespaceName(writer, claims, h.cfg.OIDC.StripEmaildomain)
-	if err != nil {
+	if err := validateOIDCAllowedGroups(writer, client.cfg.AllowedGroups, claims); err != nil {
 		return
 	}
 
-	// register the machine if it's new
-	log.Debug().Msg("Registering new machine after successful callback")
+	// a zero expiry leaves the machine without expiry, as before
+	var machineExpiry time.Time
+	if client.cfg.UseExpiryFromToken {
+		machineExpiry = idToken.Expiry
+	}
 
-	namespace, err := h.findOrCreateNewNamespaceForOIDCCallback(writer, namespaceName)
+	nodeKey, machineExists, err := h.validateMachineForOIDCCallback(
+		writer,
+		state,
+		claims,
+		client.name,
+		oauth2Token.RefreshToken,
+		machineExpiry,
+	)
 	if err != nil {
 		return
 	}
+	if machineExists {
+		callbackStatus = "success"
 
-	if err := h.registerMachineForOIDCCallback(writer, namespace, nodeKey); err != nil {
 		return
 	}
 
-	content, err := renderOIDCCallbackTemplate(writer, claims)
+	var emailDomain string
+	namespaceName, ok := getNamespaceNameFromGroups(
+		claims.Groups,
+		client.cfg.GroupNamespaces,
+		client.cfg.GroupPriority,
+	)
+	if !ok {
+		namespaceName, err = getNamespaceName(
+			writer,
+			claims,
+			client.cfg.NamespaceClaim,
+			client.cfg.StripEmaildomain,
+		)
+		if err != nil {
+			return
+		}
+
+		// the email domain collisions only concern the namespaces named
+		// after the emails
+		if client.cfg.StripEmaildomain && namespaceClaimName(client.cfg.NamespaceClaim) == "email" {
+			emailDomain = getEmailDomain(claims.Email)
+			namespaceName, err = h.resolveOIDCEmailDomainCollision(
+				writer,
+				namespaceName,
+				emailDomain,
+				client.cfg.EmailDomainCollision,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	setAccessLogNamespace(writer, namespaceName)
+
+	// new machines are only registered once the user confirmed it, so that
+	// a registration link sent by someone else cannot silently add their
+	// machine to the namespace of the user.
+	log.Debug().Msg("Asking for confirmation of new machine after successful callback")
+
+	content, err := h.renderOIDCConfirmTemplate(writer, oidcPendingRegistration{
+		NodeKey:       *nodeKey,
+		NamespaceName: namespaceName,
+		Provider:      client.name,
+		RefreshToken:  oauth2Token.RefreshToken,
+		Expiry:        machineExpiry,
+		Claims:        *claims,
+		EmailDomain:   emailDomain,
+	})
 	if err != nil {
 		return
 	}
+	callbackStatus = "success"
 
 	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
 	writer.WriteHeader(http.StatusOK)
@@ -215,6 +579,55 @@ func (h *Headscale) OIDCCallback(
 	}
 }
 
+// oidcScopes returns the configured scopes, with offline_access added when
+// the provider can revoke the refresh tokens and supports the scope.
+func oidcScopes(
+	scopes []string,
+	revocationEndpoint string,
+	scopesSupported []string,
+) []string {
+	if revocationEndpoint == "" ||
+		!contains(scopesSupported, oidcOfflineAccessScope) ||
+		contains(scopes, oidcOfflineAccessScope) {
+		return scopes
+	}
+
+	return append(append([]string{}, scopes...), oidcOfflineAccessScope)
+}
+
+// usePKCE tells whether the authorization code flow is protected with
+// PKCE (RFC 7636). In auto mode, it is used when the provider advertises
+// the S256 challenge method in its discovery document.
+func (client *oidcClient) usePKCE() bool {
+	switch client.cfg.PKCE {
+	case OIDCPKCEEnabled:
+		return true
+	case OIDCPKCEDisabled:
+		return false
+	}
+
+	var discovery struct {
+		CodeChallengeMethods []string `json:"code_challenge_methods_supported"`
+	}
+	if err := client.provider.Claims(&discovery); err != nil {
+		log.Warn().
+			Caller().
+			Err(err).
+			Msg("Could not read OIDC discovery document, not using PKCE")
+
+		return false
+	}
+
+	return contains(discovery.CodeChallengeMethods, oidcCodeChallengeS256)
+}
+
+// oidcCodeChallenge returns the S256 code challenge of a PKCE code verifier.
+func oidcCodeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
 func validateOIDCCallbackParams(
 	writer http.ResponseWriter,
 	req *http.Request,
@@ -222,29 +635,94 @@ func validateOIDCCallbackParams(
 	code := req.URL.Query().Get("code")
 	state := req.URL.Query().Get("state")
 
-	if code == "" || state == "" {
+	var err error = errEmptyOIDCCallbackParams
+	if code != "" && state != "" {
+		// the state is checked before it is looked up, so oversized or
+		// malformed values never reach the registration cache
+		err = validateOIDCState(state)
+	}
+
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Received an oidc callback with invalid params")
 		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		writer.WriteHeader(http.StatusBadRequest)
-		_, err := writer.Write([]byte("Wrong params"))
-		if err != nil {
+		_, werr := writer.Write([]byte("Wrong params"))
+		if werr != nil {
 			log.Error().
 				Caller().
-				Err(err).
+				Err(werr).
 				Msg("Failed to write response")
 		}
 
-		return "", "", errEmptyOIDCCallbackParams
+		return "", "", err
 	}
 
 	return code, state, nil
 }
 
-func (h *Headscale) getIDTokenForOIDCCallback(
+// validateOIDCState checks that the state has the shape of the ones
+// generated by RegisterOIDC.
+func validateOIDCState(state string) error {
+	if len(state) != oidcStateLength {
+		return errOIDCMalformedState
+	}
+
+	for _, char := range state {
+		if (char < '0' || char > '9') && (char < 'a' || char > 'f') {
+			return errOIDCMalformedState
+		}
+	}
+
+	return nil
+}
+
+// getOIDCClientForOIDCCallback returns the provider the user authenticated
+// with, according to the registration held under the OIDC state.
+func (h *Headscale) getOIDCClientForOIDCCallback(
+	writer http.ResponseWriter,
+	state string,
+) (*oidcClient, *oidcRegistrationState, error) {
+	registrationIf, registrationFound := h.registrationCache.Get(state)
+	registration, registrationOK := registrationIf.(oidcRegistrationState)
+	if !registrationFound || !registrationOK {
+		log.Error().
+			Msg("requested machine state key expired before authorisation completed")
+		http.Error(writer, "state has expired", http.StatusBadRequest)
+
+		return nil, nil, errOIDCInvalidMachineState
+	}
+
+	client, ok := h.oidcClients[registration.Provider]
+	if !ok {
+		log.Error().
+			Caller().
+			Err(errOIDCUnknownProvider).
+			Str("provider", registration.Provider).
+			Msg("Received an oidc callback for an unknown provider")
+		http.Error(writer, "Unknown OIDC provider", http.StatusBadRequest)
+
+		return nil, nil, errOIDCUnknownProvider
+	}
+
+	return client, &registration, nil
+}
+
+func getIDTokenForOIDCCallback(
 	ctx context.Context,
 	writer http.ResponseWriter,
-	code, state string,
-) (string, error) {
-	oauth2Token, err := h.oauth2Config.Exchange(ctx, code)
+	client *oidcClient,
+	code, state, codeVerifier string,
+) (*oauth2.Token, string, error) {
+	exchangeOpts := []oauth2.AuthCodeOption{}
+	if codeVerifier != "" {
+		exchangeOpts = append(exchangeOpts,
+			oauth2.SetAuthURLParam("code_verifier", codeVerifier),
+		)
+	}
+
+	oauth2Token, err := client.oauth2Config.Exchange(ctx, code, exchangeOpts...)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -260,7 +738,7 @@ func (h *Headscale) getIDTokenForOIDCCallback(
 				Msg("Failed to write response")
 		}
 
-		return "", err
+		return nil, "", err
 	}
 
 	log.Trace().
@@ -281,18 +759,19 @@ func (h *Headscale) getIDTokenForOIDCCallback(
 				Msg("Failed to write response")
 		}
 
-		return "", errNoOIDCIDToken
+		return nil, "", errNoOIDCIDToken
 	}
 
-	return rawIDToken, nil
+	return oauth2Token, rawIDToken, nil
 }
 
-func (h *Headscale) verifyIDTokenForOIDCCallback(
+func verifyIDTokenForOIDCCallback(
 	ctx context.Context,
 	writer http.ResponseWriter,
+	client *oidcClient,
 	rawIDToken string,
 ) (*oidc.IDToken, error) {
-	verifier := h.oidcProvider.Verifier(&oidc.Config{ClientID: h.cfg.OIDC.ClientID})
+	verifier := client.provider.Verifier(&oidc.Config{ClientID: client.cfg.ClientID})
 	idToken, err := verifier.Verify(ctx, rawIDToken)
 	if err != nil {
 		log.Error().
@@ -320,7 +799,11 @@ func extractIDTokenClaims(
 	idToken *oidc.IDToken,
 ) (*IDTokenClaims, error) {
 	var claims IDTokenClaims
-	if err := idToken.Claims(&claims); err != nil {
+	err := idToken.Claims(&claims)
+	if err == nil {
+		err = idToken.Claims(&claims.All)
+	}
+	if err != nil {
 		log.Error().
 			Err(err).
 			Caller().
@@ -341,6 +824,59 @@ func extractIDTokenClaims(
 	return &claims, nil
 }
 
+// mergeUserInfoClaims completes the claims of the ID token with the
+// claims returned by the UserInfo endpoint, which win on conflicts.
+// The UserInfo endpoint is best effort, if it fails the ID token claims are
+// used as they are.
+func (client *oidcClient) mergeUserInfoClaims(
+	ctx context.Context,
+	oauth2Token *oauth2.Token,
+	claims *IDTokenClaims,
+) {
+	userInfo, err := client.provider.UserInfo(ctx, oauth2.StaticTokenSource(oauth2Token))
+	if err != nil {
+		log.Warn().
+			Caller().
+			Err(err).
+			Msg("Could not retrieve OIDC userinfo, using the ID token claims")
+
+		return
+	}
+
+	var userInfoClaims IDTokenClaims
+	if err := userInfo.Claims(&userInfoClaims); err != nil {
+		log.Warn().
+			Caller().
+			Err(err).
+			Msg("Could not decode OIDC userinfo claims, using the ID token claims")
+
+		return
+	}
+
+	if userInfoClaims.Name != "" {
+		claims.Name = userInfoClaims.Name
+	}
+	if userInfoClaims.Groups != nil {
+		claims.Groups = userInfoClaims.Groups
+	}
+	if userInfoClaims.Email != "" {
+		claims.Email = userInfoClaims.Email
+	}
+	if userInfoClaims.Username != "" {
+		claims.Username = userInfoClaims.Username
+	}
+
+	var allUserInfoClaims map[string]interface{}
+	if err := userInfo.Claims(&allUserInfoClaims); err == nil {
+		if claims.All == nil {
+			claims.All = make(map[string]interface{}, len(allUserInfoClaims))
+		}
+		for name, value := range allUserInfoClaims {
+			claims.All[name] = value
+		}
+	}
+}
+
 // validateOIDCAllowedDomains checks that if AllowedDomains is provided,
 // that the authenticated principal ends with @<alloweddomain>.
 func validateOIDCAllowedDomains(
@@ -350,7 +886,7 @@ func validateOIDCAllowedDomains(
 ) error {
 	if len(allowedDomains) > 0 {
 		if at := strings.LastIndex(claims.Email, "@"); at < 0 ||
-			!IsStringInSlice(allowedDomains, claims.Email[at+1:]) {
+			!isDomainInSlice(allowedDomains, claims.Email[at+1:]) {
 			log.Error().Msg("authenticated principal does not match any allowed domain")
 			writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			writer.WriteHeader(http.StatusBadRequest)
@@ -374,10 +910,11 @@ func validateOIDCAllowedDomains(
 func validateOIDCAllowedUsers(
 	writer http.ResponseWriter,
 	allowedUsers []string,
+	caseSensitiveLocalPart bool,
 	claims *IDTokenClaims,
 ) error {
 	if len(allowedUsers) > 0 &&
-		!IsStringInSlice(allowedUsers, claims.Email) {
+		!isEmailInSlice(allowedUsers, claims.Email, caseSensitiveLocalPart) {
 		log.Error().Msg("authenticated principal does not match any allowed user")
 		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		writer.WriteHeader(http.StatusBadRequest)
@@ -395,6 +932,81 @@ func validateOIDCAllowedUsers(
 	return nil
 }
 
+// validateOIDCAllowedGroups checks that if AllowedGroups is provided,
+// that the authenticated principal is in at least one of the groups.
+// Groups are compared case-insensitively, as in group_namespaces.
+func validateOIDCAllowedGroups(
+	writer http.ResponseWriter,
+	allowedGroups []string,
+	claims *IDTokenClaims,
+) error {
+	if len(allowedGroups) == 0 {
+		return nil
+	}
+
+	for _, group := range claims.Groups {
+		for _, allowedGroup := range allowedGroups {
+			if strings.EqualFold(group, allowedGroup) {
+				return nil
+			}
+		}
+	}
+
+	log.Error().Msg("authenticated principal is not in any allowed group")
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer.WriteHeader(http.StatusBadRequest)
+	_, err := writer.Write([]byte("unauthorized principal (group mismatch)"))
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("Failed to write response")
+	}
+
+	return errOIDCAllowedGroups
+}
+
+// isDomainInSlice reports whether domain is one of the domains, ignoring case.
+func isDomainInSlice(domains []string, domain string) bool {
+	for _, d := range domains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isEmailInSlice reports whether email is one of the emails. The domain part
+// is always compared ignoring case, the local part only if
+// caseSensitiveLocalPart is false.
+func isEmailInSlice(emails []string, email string, caseSensitiveLocalPart bool) bool {
+	local, domain := splitEmail(email)
+	for _, e := range emails {
+		allowedLocal, allowedDomain := splitEmail(e)
+		if !strings.EqualFold(allowedDomain, domain) {
+			continue
+		}
+
+		if caseSensitiveLocalPart && allowedLocal == local {
+			return true
+		}
+		if !caseSensitiveLocalPart && strings.EqualFold(allowedLocal, local) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitEmail(email string) (string, string) {
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		return email[:at], email[at+1:]
+	}
+
+	return email, ""
+}
+
 // validateMachine retrieves machine information if it exist
 // The error is not important, because if it does not
 // exist, then this is a new machine and we will move
@@ -403,10 +1015,16 @@ func (h *Headscale) validateMachineForOIDCCallback(
 	writer http.ResponseWriter,
 	state string,
 	claims *IDTokenClaims,
+	providerName string,
+	refreshToken string,
+	machineExpiry time.Time,
 ) (*key.NodePublic, bool, error) {
-	// retrieve machinekey from state cache
-	machineKeyIf, machineKeyFound := h.registrationCache.Get(state)
-	if !machineKeyFound {
+	// retrieve machinekey from state cache. Whatever else is held under
+	// the state gets the same answer as a missing state, so the callback
+	// tells nothing about the other entries of the registration cache.
+	registrationIf, registrationFound := h.registrationCache.Get(state)
+	registration, registrationOK := registrationIf.(oidcRegistrationState)
+	if !registrationFound || !registrationOK {
 		log.Error().
 			Msg("requested machine state key expired before authorisation completed")
 		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -423,9 +1041,8 @@ func (h *Headscale) validateMachineForOIDCCallback(
 	}
 
 	var nodeKey key.NodePublic
-	nodeKeyFromCache, nodeKeyOK := machineKeyIf.(string)
 	err := nodeKey.UnmarshalText(
-		[]byte(NodePublicKeyEnsurePrefix(nodeKeyFromCache)),
+		[]byte(NodePublicKeyEnsurePrefix(registration.NodeKey)),
 	)
 	if err != nil {
 		log.Error().
@@ -443,21 +1060,6 @@ func (h *Headscale) validateMachineForOIDCCallback(
 		return nil, false, err
 	}
 
-	if !nodeKeyOK {
-		log.Error().Msg("could not get node key from cache")
-		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		writer.WriteHeader(http.StatusInternalServerError)
-		_, err := writer.Write([]byte("could not get node key from cache"))
-		if err != nil {
-			log.Error().
-				Caller().
-				Err(err).
-				Msg("Failed to write response")
-		}
-
-		return nil, false, errOIDCNodeKeyMissing
-	}
-
 	// retrieve machine information if it exist
 	// The error is not important, because if it does not
 	// exist, then this is a new machine and we will move
@@ -465,12 +1067,15 @@ func (h *Headscale) validateMachineForOIDCCallback(
 	machine, _ := h.GetMachineByNodeKey(nodeKey)
 
 	if machine != nil {
+		setAccessLogMachine(writer, machine)
+
 		log.Trace().
 			Caller().
 			Str("machine", machine.Hostname).
 			Msg("machine already registered, reauthenticating")
 
-		err := h.RefreshMachine(machine, time.Time{})
+		expiry := h.registrationExpiry(&machineExpiry, nil)
+		err := h.RefreshMachine(machine, *expiry)
 		if err != nil {
 			log.Error().
 				Caller().
@@ -485,6 +1090,10 @@ func (h *Headscale) validateMachineForOIDCCallback(
 			return nil, true, err
 		}
 
+		if refreshToken != "" {
+			h.storeOIDCRefreshToken(machine, providerName, refreshToken)
+		}
+
 		var content bytes.Buffer
 		if err := oidcCallbackTemplate.Execute(&content, oidcCallbackTemplateConfig{
 			User: claims.Email,
@@ -525,20 +1134,110 @@ func (h *Headscale) validateMachineForOIDCCallback(
 	return &nodeKey, false, nil
 }
 
+// OIDCConfirm registers a new machine once the user confirmed it on the page
+// rendered by OIDCCallback. The pending registration can only be used once.
+// Listens in /oidc/confirm.
+func (h *Headscale) OIDCConfirm(
+	writer http.ResponseWriter,
+	req *http.Request,
+) {
+	nonce := req.PostFormValue("nonce")
+
+	pendingIf, pendingFound := h.registrationCache.Get(nonce)
+	pending, pendingOK := pendingIf.(oidcPendingRegistration)
+	if nonce == "" || !pendingFound || !pendingOK {
+		log.Error().
+			Caller().
+			Err(errOIDCInvalidConfirmation).
+			Msg("Received an unknown OIDC confirmation")
+		http.Error(writer, "registration has expired", http.StatusBadRequest)
+
+		return
+	}
+	h.registrationCache.Delete(nonce)
+
+	log.Debug().Msg("Registering new machine after confirmation")
+
+	namespace, err := h.findOrCreateNewNamespaceForOIDCCallback(
+		writer,
+		pending.NamespaceName,
+		pending.EmailDomain,
+	)
+	if err != nil {
+		return
+	}
+
+	if err := h.registerMachineForOIDCCallback(
+		writer,
+		namespace,
+		&pending.NodeKey,
+		pending.Provider,
+		pending.RefreshToken,
+		pending.Expiry,
+	); err != nil {
+		return
+	}
+
+	content, err := renderOIDCCallbackTemplate(writer, &pending.Claims)
+	if err != nil {
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	if _, err := writer.Write(content.Bytes()); err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("Failed to write response")
+	}
+}
+
+// namespaceClaimName returns the claim the namespaces are named after,
+// the email when namespace_claim is not set.
+func namespaceClaimName(namespaceClaim string) string {
+	if namespaceClaim == "" {
+		return "email"
+	}
+
+	return namespaceClaim
+}
+
+// getNamespaceName returns the namespace named after the namespaceClaim of
+// the user, or after their email if it is empty. A missing or empty claim is
+// refused, rather than registering the machine in a namespace named "".
 func getNamespaceName(
 	writer http.ResponseWriter,
 	claims *IDTokenClaims,
+	namespaceClaim string,
 	stripEmaildomain bool,
 ) (string, error) {
+	claimName := namespaceClaimName(namespaceClaim)
+	value := claims.Email
+	if claimName != "email" {
+		value, _ = claims.All[claimName].(string)
+	}
+
 	namespaceName, err := NormalizeToFQDNRules(
-		claims.Email,
+		strings.TrimSpace(value),
 		stripEmaildomain,
 	)
+	if err == nil && namespaceName == "" {
+		err = fmt.Errorf("%w: %s", errOIDCNamespaceClaim, claimName)
+	}
 	if err != nil {
-		log.Error().Err(err).Caller().Msgf("couldn't normalize email")
+		log.Error().
+			Err(err).
+			Caller().
+			Str("claim", claimName).
+			Msg("couldn't get the namespace name from the OIDC claims")
 		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		writer.WriteHeader(http.StatusInternalServerError)
-		_, werr := writer.Write([]byte("couldn't normalize email"))
+		writer.WriteHeader(http.StatusBadRequest)
+		_, werr := writer.Write([]byte(fmt.Sprintf(
+			"couldn't get the namespace name from the %s claim: %s",
+			claimName,
+			err,
+		)))
 		if werr != nil {
 			log.Error().
 				Caller().
@@ -552,13 +1251,141 @@ func getNamespaceName(
 	return namespaceName, nil
 }
 
+// getNamespaceNameFromGroups returns the namespace mapped to the groups of
+// the user. The groups in groupPriority are tried first, in order, then the
+// remaining groups of the user in the order of the claims.
+// Groups are compared case-insensitively, as the configuration keys are
+// lower cased.
+func getNamespaceNameFromGroups(
+	groups []string,
+	groupNamespaces map[string]string,
+	groupPriority []string,
+) (string, bool) {
+	if len(groupNamespaces) == 0 {
+		return "", false
+	}
+
+	userGroups := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		userGroups[strings.ToLower(group)] = true
+	}
+
+	candidates := make([]string, 0, len(groupPriority)+len(groups))
+	candidates = append(candidates, groupPriority...)
+	candidates = append(candidates, groups...)
+
+	for _, group := range candidates {
+		group = strings.ToLower(group)
+		if !userGroups[group] {
+			continue
+		}
+
+		if namespaceName, ok := groupNamespaces[group]; ok {
+			return namespaceName, true
+		}
+	}
+
+	return "", false
+}
+
+// getEmailDomain returns the lower case domain of an email, or an empty
+// string if it has none.
+func getEmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+
+	return strings.ToLower(email[at+1:])
+}
+
+// resolveOIDCEmailDomainCollision checks that the namespace an email was
+// mapped to by strip_email_domain was not created for another email domain,
+// so that alice@a.com and alice@b.com do not share a namespace unnoticed.
+// On a collision, the namespace is kept with a warning, refused, or suffixed
+// with a hash of the email domain, depending on email_domain_collision.
+// Namespaces created before their email domain was recorded never collide.
+func (h *Headscale) resolveOIDCEmailDomainCollision(
+	writer http.ResponseWriter,
+	namespaceName string,
+	emailDomain string,
+	collision string,
+) (string, error) {
+	namespace, err := h.GetNamespace(namespaceName)
+	if err != nil || emailDomain == "" || namespace.EmailDomain == "" ||
+		namespace.EmailDomain == emailDomain {
+		return namespaceName, nil
+	}
+
+	switch collision {
+	case OIDCEmailDomainCollisionRefuse:
+		log.Error().
+			Caller().
+			Str("namespace", namespaceName).
+			Str("namespace_domain", namespace.EmailDomain).
+			Str("email_domain", emailDomain).
+			Msg("Refusing email mapped to a namespace created for another email domain")
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writer.WriteHeader(http.StatusForbidden)
+		_, werr := writer.Write([]byte("namespace belongs to another email domain"))
+		if werr != nil {
+			log.Error().
+				Caller().
+				Err(werr).
+				Msg("Failed to write response")
+		}
+
+		return "", errOIDCEmailDomainConflict
+
+	case OIDCEmailDomainCollisionSuffix:
+		suffixedName := suffixNamespaceNameWithDomain(namespaceName, emailDomain)
+		log.Info().
+			Str("namespace", namespaceName).
+			Str("namespace_domain", namespace.EmailDomain).
+			Str("email_domain", emailDomain).
+			Str("suffixed_namespace", suffixedName).
+			Msg("Email mapped to a namespace created for another email domain, using a suffixed namespace")
+
+		return suffixedName, nil
+	}
+
+	log.Warn().
+		Str("namespace", namespaceName).
+		Str("namespace_domain", namespace.EmailDomain).
+		Str("email_domain", emailDomain).
+		Msg("Email mapped to a namespace created for another email domain, sharing it")
+
+	return namespaceName, nil
+}
+
+// suffixNamespaceNameWithDomain appends a short hash of the email domain to
+// the namespace name, shortening the name to keep it a valid DNS label.
+func suffixNamespaceNameWithDomain(namespaceName, emailDomain string) string {
+	sum := sha256.Sum256([]byte(emailDomain))
+	suffix := "-" + hex.EncodeToString(sum[:])[:emailDomainHashLength]
+
+	if len(namespaceName)+len(suffix) > labelHostnameLength {
+		namespaceName = namespaceName[:labelHostnameLength-len(suffix)]
+	}
+
+	return namespaceName + suffix
+}
+
+// findOrCreateNewNamespaceForOIDCCallback returns the namespace of a new
+// machine authenticated through OIDC, creating it if needed. The emailDomain,
+// if any, is recorded on a created namespace.
 func (h *Headscale) findOrCreateNewNamespaceForOIDCCallback(
 	writer http.ResponseWriter,
 	namespaceName string,
+	emailDomain string,
 ) (*Namespace, error) {
 	namespace, err := h.GetNamespace(namespaceName)
 	if errors.Is(err, ErrNamespaceNotFound) {
 		namespace, err = h.CreateNamespace(namespaceName)
+		if err == nil && emailDomain != "" {
+			namespace.EmailDomain = emailDomain
+			err = h.db.Save(namespace).Error
+		}
 
 		if err != nil {
 			log.Error().
@@ -603,14 +1430,24 @@ func (h *Headscale) registerMachineForOIDCCallback(
 	writer http.ResponseWriter,
 	namespace *Namespace,
 	nodeKey *key.NodePublic,
+	providerName string,
+	refreshToken string,
+	machineExpiry time.Time,
 ) error {
 	nodeKeyStr := NodePublicKeyStripPrefix(*nodeKey)
 
-	if _, err := h.RegisterMachineFromAuthCallback(
+	var expiry *time.Time
+	if !machineExpiry.IsZero() {
+		expiry = &machineExpiry
+	}
+
+	machine, err := h.RegisterMachineFromAuthCallback(
 		nodeKeyStr,
 		namespace.Name,
 		RegisterMethodOIDC,
-	); err != nil {
+		expiry,
+	)
+	if err != nil {
 		log.Error().
 			Caller().
 			Err(err).
@@ -628,9 +1465,66 @@ func (h *Headscale) registerMachineForOIDCCallback(
 		return err
 	}
 
+	if refreshToken != "" {
+		h.storeOIDCRefreshToken(machine, providerName, refreshToken)
+	}
+
 	return nil
 }
 
+// renderOIDCConfirmTemplate holds the pending registration in the
+// registration cache, under a fresh nonce, and renders the page asking the
+// user to confirm it.
+func (h *Headscale) renderOIDCConfirmTemplate(
+	writer http.ResponseWriter,
+	pending oidcPendingRegistration,
+) (*bytes.Buffer, error) {
+	nonce, err := GenerateRandomStringURLSafe(oidcConfirmNonceLength)
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("could not generate OIDC confirmation nonce")
+		http.Error(writer, "Internal server error", http.StatusInternalServerError)
+
+		return nil, err
+	}
+
+	h.setRegistrationCache(nonce, pending)
+
+	var content bytes.Buffer
+	if err := oidcConfirmTemplate.Execute(&content, oidcConfirmTemplateConfig{
+		User:        pending.Claims.Email,
+		Fingerprint: pending.NodeKey.ShortString(),
+		Namespace:   pending.NamespaceName,
+		Action: fmt.Sprintf(
+			"%s/oidc/confirm",
+			strings.TrimSuffix(h.cfg.ServerURL, "/"),
+		),
+		Nonce: nonce,
+	}); err != nil {
+		log.Error().
+			Str("func", "OIDCCallback").
+			Str("type", "confirm").
+			Err(err).
+			Msg("Could not render OIDC confirm template")
+
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, werr := writer.Write([]byte("Could not render OIDC confirm template"))
+		if werr != nil {
+			log.Error().
+				Caller().
+				Err(werr).
+				Msg("Failed to write response")
+		}
+
+		return nil, err
+	}
+
+	return &content, nil
+}
+
 func renderOIDCCallbackTemplate(
 	writer http.ResponseWriter,
 	claims *IDTokenClaims,
@@ -661,3 +1555,187 @@ func renderOIDCCallbackTemplate(
 
 	return &content, nil
 }
+
+// storeOIDCRefreshToken saves the refresh token of the machine, encrypted,
+// to revoke the session when the machine is logged out.
+func (h *Headscale) storeOIDCRefreshToken(
+	machine *Machine,
+	providerName string,
+	refreshToken string,
+) {
+	sealed, err := h.sealOIDCRefreshToken(refreshToken)
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Str("machine", machine.Hostname).
+			Msg("Failed to encrypt OIDC refresh token")
+
+		return
+	}
+
+	machine.OIDCRefreshToken = sealed
+	machine.OIDCProvider = providerName
+	if err := h.db.Save(machine).Error; err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Str("machine", machine.Hostname).
+			Msg("Failed to store OIDC refresh token")
+	}
+}
+
+// oidcRefreshTokenKey derives the key encrypting the stored refresh tokens
+// from the private key of the server.
+func (h *Headscale) oidcRefreshTokenKey() (*[32]byte, error) {
+	privateKey, err := h.privateKey.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	secret := sha256.Sum256(append([]byte(oidcRefreshTokenKeyLabel), privateKey...))
+
+	return &secret, nil
+}
+
+// sealOIDCRefreshToken encrypts a refresh token to store it in the
+// database.
+func (h *Headscale) sealOIDCRefreshToken(refreshToken string) (string, error) {
+	secret, err := h.oidcRefreshTokenKey()
+	if err != nil {
+		return "", err
+	}
+
+	var nonce [oidcRefreshTokenNonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+	sealed := secretbox.Seal(nonce[:], []byte(refreshToken), &nonce, secret)
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openOIDCRefreshToken decrypts a refresh token stored in the database.
+func (h *Headscale) openOIDCRefreshToken(sealed string) (string, error) {
+	secret, err := h.oidcRefreshTokenKey()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < oidcRefreshTokenNonceSize {
+		return "", errOIDCRefreshTokenSealed
+	}
+
+	var nonce [oidcRefreshTokenNonceSize]byte
+	copy(nonce[:], data[:oidcRefreshTokenNonceSize])
+	refreshToken, ok := secretbox.Open(nil, data[oidcRefreshTokenNonceSize:], &nonce, secret)
+	if !ok {
+		return "", errOIDCRefreshTokenSealed
+	}
+
+	return string(refreshToken), nil
+}
+
+// RevokeMachineSession expires the machine and, if it was authenticated
+// through OIDC, revokes the refresh token issued by the provider, so the
+// session cannot be used to silently log the machine back in.
+// It returns whether the session was revoked at the provider.
+func (h *Headscale) RevokeMachineSession(
+	ctx context.Context,
+	machine *Machine,
+) (bool, error) {
+	if err := h.ExpireMachine(machine); err != nil {
+		return false, err
+	}
+
+	// machines registered before several providers were supported have
+	// been authenticated by the default one.
+	providerName := machine.OIDCProvider
+	if providerName == "" {
+		providerName = DefaultOIDCProvider
+	}
+
+	client, ok := h.oidcClients[providerName]
+	if !ok || machine.OIDCRefreshToken == "" {
+		log.Info().
+			Str("machine", machine.Hostname).
+			Msg("Machine expired, no OIDC session to revoke")
+
+		return false, nil
+	}
+
+	refreshToken, err := h.openOIDCRefreshToken(machine.OIDCRefreshToken)
+	if err != nil {
+		return false, err
+	}
+
+	err = client.revokeRefreshToken(ctx, refreshToken)
+	if errors.Is(err, errOIDCNoRevocationSupport) {
+		log.Info().
+			Str("machine", machine.Hostname).
+			Msg("Machine expired, OIDC provider does not support revocation")
+
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	machine.OIDCRefreshToken = ""
+	if err := h.db.Save(machine).Error; err != nil {
+		return true, fmt.Errorf("failed to clear OIDC refresh token: %w", err)
+	}
+
+	log.Info().
+		Str("machine", machine.Hostname).
+		Msg("Machine expired and OIDC session revoked")
+
+	return true, nil
+}
+
+// revokeRefreshToken revokes a refresh token using the revocation
+// endpoint (RFC 7009) advertised in the provider discovery document.
+func (client *oidcClient) revokeRefreshToken(ctx context.Context, token string) error {
+	var discovery struct {
+		RevocationEndpoint string `json:"revocation_endpoint"`
+	}
+	if err := client.provider.Claims(&discovery); err != nil {
+		return fmt.Errorf("failed to read OIDC discovery document: %w", err)
+	}
+
+	if discovery.RevocationEndpoint == "" {
+		return errOIDCNoRevocationSupport
+	}
+
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"refresh_token"},
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		discovery.RevocationEndpoint,
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(
+		url.QueryEscape(client.cfg.ClientID),
+		url.QueryEscape(client.cfg.ClientSecret),
+	)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call OIDC revocation endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", errOIDCRevocationFailed, resp.Status)
+	}
+
+	return nil
+}
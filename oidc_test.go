@@ -0,0 +1,999 @@
+package headscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/mux"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/oauth2"
+	"gopkg.in/check.v1"
+	"tailscale.com/types/key"
+)
+
+func newTestOIDCProvider(
+	c *check.C,
+	withRevocation bool,
+	withPKCE bool,
+	revoked *[]string,
+) *httptest.Server {
+	serveMux := http.NewServeMux()
+	server := httptest.NewServer(serveMux)
+
+	serveMux.HandleFunc(
+		"/.well-known/openid-configuration",
+		func(writer http.ResponseWriter, req *http.Request) {
+			discovery := map[string]interface{}{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/auth",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/keys",
+				"userinfo_endpoint":      server.URL + "/userinfo",
+			}
+			if withRevocation {
+				discovery["revocation_endpoint"] = server.URL + "/revoke"
+			}
+			if withPKCE {
+				discovery["code_challenge_methods_supported"] = []string{"plain", "S256"}
+			}
+			writer.Header().Set("Content-Type", "application/json")
+			c.Assert(json.NewEncoder(writer).Encode(discovery), check.IsNil)
+		},
+	)
+	serveMux.HandleFunc("/revoke", func(writer http.ResponseWriter, req *http.Request) {
+		c.Assert(req.ParseForm(), check.IsNil)
+		*revoked = append(*revoked, req.PostForm.Get("token"))
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	serveMux.HandleFunc("/userinfo", func(writer http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer valid-access-token" {
+			writer.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		c.Assert(json.NewEncoder(writer).Encode(map[string]interface{}{
+			"sub":    "alice",
+			"email":  "alice@example.org",
+			"groups": []string{"engineering"},
+		}), check.IsNil)
+	})
+
+	return server
+}
+
+func newTestOIDCClient(c *check.C, name string, issuer string) *oidcClient {
+	provider, err := oidc.NewProvider(context.Background(), issuer)
+	c.Assert(err, check.IsNil)
+
+	return &oidcClient{
+		name:     name,
+		provider: provider,
+		oauth2Config: &oauth2.Config{
+			ClientID: "headscale",
+			Endpoint: provider.Endpoint(),
+		},
+	}
+}
+
+func (s *Suite) createOIDCMachine(c *check.C, refreshToken string) *Machine {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	privateKey := key.NewMachine()
+	app.privateKey = &privateKey
+
+	machine := &Machine{
+		ID:             0,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodOIDC,
+	}
+	app.storeOIDCRefreshToken(machine, DefaultOIDCProvider, refreshToken)
+
+	// the refresh token is not stored in plaintext
+	c.Assert(machine.OIDCRefreshToken, check.Not(check.Equals), "")
+	c.Assert(strings.Contains(machine.OIDCRefreshToken, refreshToken), check.Equals, false)
+
+	return machine
+}
+
+func (s *Suite) TestRevokeMachineSession(c *check.C) {
+	revoked := []string{}
+	server := newTestOIDCProvider(c, true, false, &revoked)
+	defer server.Close()
+
+	app.oidcClients = map[string]*oidcClient{
+		DefaultOIDCProvider: newTestOIDCClient(c, DefaultOIDCProvider, server.URL),
+	}
+
+	machine := s.createOIDCMachine(c, "refresh-token")
+
+	ok, err := app.RevokeMachineSession(context.Background(), machine)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(revoked, check.DeepEquals, []string{"refresh-token"})
+
+	machineFromDB, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineFromDB.isExpired(), check.Equals, true)
+	c.Assert(machineFromDB.OIDCRefreshToken, check.Equals, "")
+}
+
+func (s *Suite) TestRevokeMachineSessionWithoutRevocationEndpoint(c *check.C) {
+	revoked := []string{}
+	server := newTestOIDCProvider(c, false, false, &revoked)
+	defer server.Close()
+
+	app.oidcClients = map[string]*oidcClient{
+		DefaultOIDCProvider: newTestOIDCClient(c, DefaultOIDCProvider, server.URL),
+	}
+
+	machine := s.createOIDCMachine(c, "refresh-token")
+
+	ok, err := app.RevokeMachineSession(context.Background(), machine)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(revoked, check.HasLen, 0)
+
+	machineFromDB, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineFromDB.isExpired(), check.Equals, true)
+}
+
+func (s *Suite) TestOIDCUsePKCE(c *check.C) {
+	revoked := []string{}
+	for _, withPKCE := range []bool{false, true} {
+		server := newTestOIDCProvider(c, false, withPKCE, &revoked)
+		client := newTestOIDCClient(c, DefaultOIDCProvider, server.URL)
+
+		client.cfg.PKCE = OIDCPKCEAuto
+		c.Assert(client.usePKCE(), check.Equals, withPKCE)
+		client.cfg.PKCE = OIDCPKCEEnabled
+		c.Assert(client.usePKCE(), check.Equals, true)
+		client.cfg.PKCE = OIDCPKCEDisabled
+		c.Assert(client.usePKCE(), check.Equals, false)
+
+		server.Close()
+	}
+}
+
+func (s *Suite) TestRegisterOIDCWithPKCE(c *check.C) {
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      registerCacheExpiration,
+		CleanupInterval: registerCacheCleanup,
+	})
+	client := &oidcClient{
+		name: DefaultOIDCProvider,
+		oauth2Config: &oauth2.Config{
+			ClientID: "headscale",
+			Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"},
+		},
+	}
+	app.oidcClients = map[string]*oidcClient{DefaultOIDCProvider: client}
+	nodeKey := NodePublicKeyStripPrefix(key.NewNode().Public())
+
+	register := func() url.Values {
+		req := httptest.NewRequest(http.MethodGet, "/oidc/register/"+nodeKey, nil)
+		req = mux.SetURLVars(req, map[string]string{"nkey": nodeKey})
+		recorder := httptest.NewRecorder()
+		app.RegisterOIDC(recorder, req)
+		c.Assert(recorder.Code, check.Equals, http.StatusFound)
+
+		location, err := url.Parse(recorder.Header().Get("Location"))
+		c.Assert(err, check.IsNil)
+
+		return location.Query()
+	}
+
+	client.cfg.PKCE = OIDCPKCEEnabled
+	query := register()
+
+	registrationIf, found := app.registrationCache.Get(query.Get("state"))
+	c.Assert(found, check.Equals, true)
+	registration, ok := registrationIf.(oidcRegistrationState)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(registration.NodeKey, check.Equals, nodeKey)
+	c.Assert(registration.Provider, check.Equals, DefaultOIDCProvider)
+	c.Assert(len(registration.CodeVerifier) >= 43, check.Equals, true)
+	c.Assert(query.Get("code_challenge_method"), check.Equals, "S256")
+	c.Assert(
+		query.Get("code_challenge"),
+		check.Equals,
+		oidcCodeChallenge(registration.CodeVerifier),
+	)
+
+	client.cfg.PKCE = OIDCPKCEDisabled
+	query = register()
+	c.Assert(query.Get("code_challenge"), check.Equals, "")
+
+	registrationIf, found = app.registrationCache.Get(query.Get("state"))
+	c.Assert(found, check.Equals, true)
+	c.Assert(registrationIf.(oidcRegistrationState).CodeVerifier, check.Equals, "")
+}
+
+func (s *Suite) TestRegisterOIDCWithSeveralProviders(c *check.C) {
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      registerCacheExpiration,
+		CleanupInterval: registerCacheCleanup,
+	})
+	app.oidcClients = map[string]*oidcClient{}
+	for _, name := range []string{"employees", "contractors"} {
+		app.oidcClients[name] = &oidcClient{
+			name: name,
+			cfg:  OIDCConfig{PKCE: OIDCPKCEDisabled},
+			oauth2Config: &oauth2.Config{
+				ClientID: "headscale",
+				Endpoint: oauth2.Endpoint{AuthURL: "https://" + name + ".example.com/auth"},
+			},
+		}
+	}
+	nodeKey := NodePublicKeyStripPrefix(key.NewNode().Public())
+
+	register := func(vars map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/oidc/register/"+nodeKey, nil)
+		req = mux.SetURLVars(req, vars)
+		recorder := httptest.NewRecorder()
+		app.RegisterOIDC(recorder, req)
+
+		return recorder
+	}
+
+	// without a provider, the user is asked to choose one
+	recorder := register(map[string]string{"nkey": nodeKey})
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(
+		strings.Contains(recorder.Body.String(), "/oidc/register/"+nodeKey+"/employees"),
+		check.Equals,
+		true,
+	)
+	c.Assert(
+		strings.Contains(recorder.Body.String(), "/oidc/register/"+nodeKey+"/contractors"),
+		check.Equals,
+		true,
+	)
+
+	recorder = register(map[string]string{"nkey": nodeKey, "provider": "contractors"})
+	c.Assert(recorder.Code, check.Equals, http.StatusFound)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	c.Assert(err, check.IsNil)
+	c.Assert(location.Host, check.Equals, "contractors.example.com")
+
+	registrationIf, found := app.registrationCache.Get(location.Query().Get("state"))
+	c.Assert(found, check.Equals, true)
+	c.Assert(registrationIf.(oidcRegistrationState).Provider, check.Equals, "contractors")
+
+	recorder = register(map[string]string{"nkey": nodeKey, "provider": "unknown"})
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *Suite) TestMergeOIDCUserInfoClaims(c *check.C) {
+	revoked := []string{}
+	server := newTestOIDCProvider(c, false, false, &revoked)
+	defer server.Close()
+
+	client := newTestOIDCClient(c, DefaultOIDCProvider, server.URL)
+
+	claims := &IDTokenClaims{Name: "Alice", Email: "alice@example.com"}
+	client.mergeUserInfoClaims(
+		context.Background(),
+		&oauth2.Token{AccessToken: "valid-access-token"},
+		claims,
+	)
+	c.Assert(claims, check.DeepEquals, &IDTokenClaims{
+		Name:   "Alice",
+		Groups: []string{"engineering"},
+		Email:  "alice@example.org",
+		All: map[string]interface{}{
+			"sub":    "alice",
+			"email":  "alice@example.org",
+			"groups": []interface{}{"engineering"},
+		},
+	})
+
+	// a failing UserInfo endpoint leaves the ID token claims untouched
+	claims = &IDTokenClaims{Name: "Alice", Email: "alice@example.com"}
+	client.mergeUserInfoClaims(
+		context.Background(),
+		&oauth2.Token{AccessToken: "expired-access-token"},
+		claims,
+	)
+	c.Assert(claims, check.DeepEquals, &IDTokenClaims{Name: "Alice", Email: "alice@example.com"})
+}
+
+func (s *Suite) TestOIDCCallbackFailureIsCounted(c *check.C) {
+	failures := testutil.ToFloat64(oidcCallbacks.WithLabelValues("failure"))
+
+	req := httptest.NewRequest(http.MethodGet, "/oidc/callback", nil)
+	recorder := httptest.NewRecorder()
+	app.OIDCCallback(recorder, req)
+
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(
+		testutil.ToFloat64(oidcCallbacks.WithLabelValues("failure")),
+		check.Equals,
+		failures+1,
+	)
+}
+
+func (s *Suite) TestOIDCCallbackRejectsMalformedState(c *check.C) {
+	c.Assert(validateOIDCState("0123456789abcdef0123456789abcdef"), check.IsNil)
+
+	for _, state := range []string{
+		"0123456789abcdef",
+		"0123456789abcdef0123456789abcdef0",
+		"0123456789ABCDEF0123456789ABCDEF",
+		"0123456789abcdef0123456789abcdeg",
+		strings.Repeat("a", 4096),
+	} {
+		c.Assert(validateOIDCState(state), check.Equals, errOIDCMalformedState)
+
+		req := httptest.NewRequest(
+			http.MethodGet,
+			"/oidc/callback?code=foo&state="+state,
+			nil,
+		)
+		recorder := httptest.NewRecorder()
+		app.OIDCCallback(recorder, req)
+
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, "Wrong params")
+	}
+}
+
+func (s *Suite) TestOIDCCallbackStateOnlyMatchesOIDCRegistrations(c *check.C) {
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      registerCacheExpiration,
+		CleanupInterval: registerCacheCleanup,
+	})
+
+	// a state holding something else than an OIDC registration is answered
+	// like a missing one
+	state := "0123456789abcdef0123456789abcdef"
+	app.setRegistrationCache(state, Machine{
+		MachineKey: MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+	})
+
+	for _, state := range []string{state, "fedcba9876543210fedcba9876543210"} {
+		recorder := httptest.NewRecorder()
+		_, _, err := app.validateMachineForOIDCCallback(
+			recorder,
+			state,
+			&IDTokenClaims{Email: "alice@example.com"},
+			DefaultOIDCProvider,
+			"",
+			time.Time{},
+		)
+		c.Assert(err, check.Equals, errOIDCInvalidMachineState)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, "state has expired")
+	}
+}
+
+func (s *Suite) TestOIDCConfirmRegistersMachine(c *check.C) {
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      registerCacheExpiration,
+		CleanupInterval: registerCacheCleanup,
+	})
+
+	nodeKey := key.NewNode().Public()
+	app.setRegistrationCache(NodePublicKeyStripPrefix(nodeKey), Machine{
+		MachineKey: MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:    NodePublicKeyStripPrefix(nodeKey),
+		Hostname:   "confirmed",
+	})
+
+	recorder := httptest.NewRecorder()
+	content, err := app.renderOIDCConfirmTemplate(recorder, oidcPendingRegistration{
+		NodeKey:       nodeKey,
+		NamespaceName: "engineering",
+		Claims:        IDTokenClaims{Email: "alice@example.com"},
+	})
+	c.Assert(err, check.IsNil)
+	// html/template escapes the "+" of the base64 fingerprint
+	rendered := strings.ReplaceAll(content.String(), "&#43;", "+")
+	c.Assert(strings.Contains(rendered, nodeKey.ShortString()), check.Equals, true)
+
+	// nothing is registered before the confirmation
+	_, err = app.GetMachineByNodeKey(nodeKey)
+	c.Assert(err, check.NotNil)
+
+	var nonce string
+	for cacheKey, item := range app.registrationCache.Items() {
+		if _, ok := item.Object.(oidcPendingRegistration); ok {
+			nonce = cacheKey
+		}
+	}
+	c.Assert(nonce, check.Not(check.Equals), "")
+
+	confirm := func() *httptest.ResponseRecorder {
+		form := url.Values{"nonce": {nonce}}
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/oidc/confirm",
+			strings.NewReader(form.Encode()),
+		)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		app.OIDCConfirm(recorder, req)
+
+		return recorder
+	}
+
+	c.Assert(confirm().Code, check.Equals, http.StatusOK)
+
+	machine, err := app.GetMachineByNodeKey(nodeKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.Namespace.Name, check.Equals, "engineering")
+	c.Assert(machine.RegisterMethod, check.Equals, RegisterMethodOIDC)
+
+	// the confirmation can only be used once
+	c.Assert(confirm().Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *Suite) TestOIDCTokenExpiryExpiresMachine(c *check.C) {
+	app.registrationCache = newRegistrationCache(RegistrationCacheConfig{
+		Expiration:      registerCacheExpiration,
+		CleanupInterval: registerCacheCleanup,
+	})
+
+	namespace, err := app.CreateNamespace("token-expiry")
+	c.Assert(err, check.IsNil)
+
+	nodeKey := key.NewNode().Public()
+	app.setRegistrationCache(NodePublicKeyStripPrefix(nodeKey), Machine{
+		MachineKey: MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:    NodePublicKeyStripPrefix(nodeKey),
+		Hostname:   "token-expiry",
+	})
+
+	// a token which already expired expires the machine right away
+	tokenExpiry := time.Now().Add(-time.Minute)
+	err = app.registerMachineForOIDCCallback(
+		httptest.NewRecorder(),
+		namespace,
+		&nodeKey,
+		DefaultOIDCProvider,
+		"",
+		tokenExpiry,
+	)
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.GetMachineByNodeKey(nodeKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.isExpired(), check.Equals, true)
+
+	// reauthenticating extends the expiry to the new token
+	state := "token-expiry-state"
+	app.setRegistrationCache(state, oidcRegistrationState{
+		NodeKey:  NodePublicKeyStripPrefix(nodeKey),
+		Provider: DefaultOIDCProvider,
+	})
+	tokenExpiry = time.Now().Add(time.Hour)
+	_, machineExists, err := app.validateMachineForOIDCCallback(
+		httptest.NewRecorder(),
+		state,
+		&IDTokenClaims{Email: "alice@example.com"},
+		DefaultOIDCProvider,
+		"",
+		tokenExpiry,
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(machineExists, check.Equals, true)
+
+	machine, err = app.GetMachineByNodeKey(nodeKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.isExpired(), check.Equals, false)
+	c.Assert(machine.Expiry.Equal(tokenExpiry), check.Equals, true)
+}
+
+func (s *Suite) TestOIDCEmailDomainCollision(c *check.C) {
+	namespace, err := app.findOrCreateNewNamespaceForOIDCCallback(
+		httptest.NewRecorder(),
+		"alice",
+		"a.com",
+	)
+	c.Assert(err, check.IsNil)
+
+	namespace, err = app.GetNamespace(namespace.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace.EmailDomain, check.Equals, "a.com")
+
+	resolve := func(emailDomain, collision string) (string, int, error) {
+		recorder := httptest.NewRecorder()
+		name, err := app.resolveOIDCEmailDomainCollision(
+			recorder,
+			"alice",
+			emailDomain,
+			collision,
+		)
+
+		return name, recorder.Code, err
+	}
+
+	// the same domain never collides
+	name, _, err := resolve("a.com", OIDCEmailDomainCollisionRefuse)
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, "alice")
+
+	name, _, err = resolve("b.com", OIDCEmailDomainCollisionWarn)
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, "alice")
+
+	_, code, err := resolve("b.com", OIDCEmailDomainCollisionRefuse)
+	c.Assert(errors.Is(err, errOIDCEmailDomainConflict), check.Equals, true)
+	c.Assert(code, check.Equals, http.StatusForbidden)
+
+	name, _, err = resolve("b.com", OIDCEmailDomainCollisionSuffix)
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Matches, "alice-[0-9a-f]{8}")
+	c.Assert(CheckForFQDNRules(name), check.IsNil)
+
+	otherName, _, err := resolve("c.com", OIDCEmailDomainCollisionSuffix)
+	c.Assert(err, check.IsNil)
+	c.Assert(otherName, check.Not(check.Equals), name)
+
+	longName := suffixNamespaceNameWithDomain(strings.Repeat("a", labelHostnameLength), "b.com")
+	c.Assert(len(longName), check.Equals, labelHostnameLength)
+
+	// the domain of namespaces created otherwise is unknown
+	_, err = app.CreateNamespace("bob")
+	c.Assert(err, check.IsNil)
+
+	name, err = app.resolveOIDCEmailDomainCollision(
+		httptest.NewRecorder(),
+		"bob",
+		"b.com",
+		OIDCEmailDomainCollisionRefuse,
+	)
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, "bob")
+}
+
+func (s *Suite) TestRegisterOIDCRateLimit(c *check.C) {
+	app.cfg.OIDCRegisterRateLimit = 2
+	app.oidcRegisterLimiters = cache.New(oidcRegisterLimiterExpiration, registerCacheCleanup)
+	defer func() { app.oidcRegisterLimiters = nil }()
+
+	register := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/oidc/register/", nil)
+		req.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		app.RegisterOIDC(recorder, req)
+
+		return recorder.Code
+	}
+
+	// the requests within the limit go through, to fail on the missing key
+	c.Assert(register("192.0.2.1:1234"), check.Equals, http.StatusBadRequest)
+	c.Assert(register("192.0.2.1:5678"), check.Equals, http.StatusBadRequest)
+	c.Assert(register("192.0.2.1:1234"), check.Equals, http.StatusTooManyRequests)
+
+	// the other clients have their own limit
+	c.Assert(register("192.0.2.2:1234"), check.Equals, http.StatusBadRequest)
+}
+
+func Test_validateOIDCAllowedDomains(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedDomains []string
+		email          string
+		wantErr        bool
+	}{
+		{
+			name:           "no allowed domains",
+			allowedDomains: []string{},
+			email:          "alice@example.com",
+			wantErr:        false,
+		},
+		{
+			name:           "matching domain",
+			allowedDomains: []string{"example.com"},
+			email:          "alice@example.com",
+			wantErr:        false,
+		},
+		{
+			name:           "mixed case domain in email",
+			allowedDomains: []string{"example.com"},
+			email:          "alice@Example.COM",
+			wantErr:        false,
+		},
+		{
+			name:           "mixed case domain in allowlist",
+			allowedDomains: []string{"EXAMPLE.com"},
+			email:          "alice@example.com",
+			wantErr:        false,
+		},
+		{
+			name:           "other domain",
+			allowedDomains: []string{"example.com"},
+			email:          "alice@example.org",
+			wantErr:        true,
+		},
+		{
+			name:           "no domain",
+			allowedDomains: []string{"example.com"},
+			email:          "alice",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOIDCAllowedDomains(
+				httptest.NewRecorder(),
+				tt.allowedDomains,
+				&IDTokenClaims{Email: tt.email},
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(
+					"validateOIDCAllowedDomains() error = %v, wantErr %v",
+					err,
+					tt.wantErr,
+				)
+			}
+		})
+	}
+}
+
+func Test_validateOIDCAllowedUsers(t *testing.T) {
+	tests := []struct {
+		name                   string
+		allowedUsers           []string
+		caseSensitiveLocalPart bool
+		email                  string
+		wantErr                bool
+	}{
+		{
+			name:         "no allowed users",
+			allowedUsers: []string{},
+			email:        "alice@example.com",
+			wantErr:      false,
+		},
+		{
+			name:         "exact match",
+			allowedUsers: []string{"alice@example.com"},
+			email:        "alice@example.com",
+			wantErr:      false,
+		},
+		{
+			name:         "mixed case email",
+			allowedUsers: []string{"alice@example.com"},
+			email:        "Alice@Example.com",
+			wantErr:      false,
+		},
+		{
+			name:                   "mixed case domain with case sensitive local part",
+			allowedUsers:           []string{"alice@example.com"},
+			caseSensitiveLocalPart: true,
+			email:                  "alice@EXAMPLE.com",
+			wantErr:                false,
+		},
+		{
+			name:                   "mixed case local part with case sensitive local part",
+			allowedUsers:           []string{"alice@example.com"},
+			caseSensitiveLocalPart: true,
+			email:                  "Alice@example.com",
+			wantErr:                true,
+		},
+		{
+			name:         "other user",
+			allowedUsers: []string{"alice@example.com"},
+			email:        "bob@example.com",
+			wantErr:      true,
+		},
+		{
+			name:         "same user on other domain",
+			allowedUsers: []string{"alice@example.com"},
+			email:        "alice@example.org",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOIDCAllowedUsers(
+				httptest.NewRecorder(),
+				tt.allowedUsers,
+				tt.caseSensitiveLocalPart,
+				&IDTokenClaims{Email: tt.email},
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(
+					"validateOIDCAllowedUsers() error = %v, wantErr %v",
+					err,
+					tt.wantErr,
+				)
+			}
+		})
+	}
+}
+
+func Test_validateOIDCAllowedGroups(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowedGroups []string
+		groups        []string
+		wantErr       bool
+	}{
+		{
+			name:    "no allowed groups",
+			groups:  []string{},
+			wantErr: false,
+		},
+		{
+			name:          "member of one allowed group",
+			allowedGroups: []string{"tailnet-users", "admins"},
+			groups:        []string{"sales", "admins"},
+			wantErr:       false,
+		},
+		{
+			name:          "mixed case group",
+			allowedGroups: []string{"tailnet-users"},
+			groups:        []string{"Tailnet-Users"},
+			wantErr:       false,
+		},
+		{
+			name:          "member of no allowed group",
+			allowedGroups: []string{"tailnet-users"},
+			groups:        []string{"sales"},
+			wantErr:       true,
+		},
+		{
+			name:          "no groups claim",
+			allowedGroups: []string{"tailnet-users"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			err := validateOIDCAllowedGroups(
+				recorder,
+				tt.allowedGroups,
+				&IDTokenClaims{Email: "alice@example.com", Groups: tt.groups},
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(
+					"validateOIDCAllowedGroups() error = %v, wantErr %v",
+					err,
+					tt.wantErr,
+				)
+			}
+			if tt.wantErr && recorder.Code != http.StatusBadRequest {
+				t.Errorf("validateOIDCAllowedGroups() status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func Test_oidcScopes(t *testing.T) {
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	tests := []struct {
+		name               string
+		scopes             []string
+		revocationEndpoint string
+		scopesSupported    []string
+		want               []string
+	}{
+		{
+			name:               "revocation and offline_access supported",
+			scopes:             scopes,
+			revocationEndpoint: "https://idp.example.com/revoke",
+			scopesSupported:    []string{oidc.ScopeOpenID, oidcOfflineAccessScope},
+			want:               []string{oidc.ScopeOpenID, "profile", "email", oidcOfflineAccessScope},
+		},
+		{
+			name:            "no revocation endpoint",
+			scopes:          scopes,
+			scopesSupported: []string{oidc.ScopeOpenID, oidcOfflineAccessScope},
+			want:            scopes,
+		},
+		{
+			name:               "offline_access not supported",
+			scopes:             scopes,
+			revocationEndpoint: "https://idp.example.com/revoke",
+			scopesSupported:    []string{oidc.ScopeOpenID},
+			want:               scopes,
+		},
+		{
+			name:               "offline_access already configured",
+			scopes:             []string{oidc.ScopeOpenID, oidcOfflineAccessScope},
+			revocationEndpoint: "https://idp.example.com/revoke",
+			scopesSupported:    []string{oidc.ScopeOpenID, oidcOfflineAccessScope},
+			want:               []string{oidc.ScopeOpenID, oidcOfflineAccessScope},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := oidcScopes(tt.scopes, tt.revocationEndpoint, tt.scopesSupported)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("oidcScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getNamespaceNameFromGroups(t *testing.T) {
+	groupNamespaces := map[string]string{
+		"engineering": "engineering",
+		"contractors": "external",
+	}
+
+	tests := []struct {
+		name            string
+		groups          []string
+		groupNamespaces map[string]string
+		groupPriority   []string
+		want            string
+		wantOk          bool
+	}{
+		{
+			name:            "no mapping configured",
+			groups:          []string{"engineering"},
+			groupNamespaces: map[string]string{},
+			want:            "",
+			wantOk:          false,
+		},
+		{
+			name:            "no group matches",
+			groups:          []string{"sales"},
+			groupNamespaces: groupNamespaces,
+			want:            "",
+			wantOk:          false,
+		},
+		{
+			name:            "single group matches",
+			groups:          []string{"sales", "engineering"},
+			groupNamespaces: groupNamespaces,
+			want:            "engineering",
+			wantOk:          true,
+		},
+		{
+			name:            "mixed case group",
+			groups:          []string{"Engineering"},
+			groupNamespaces: groupNamespaces,
+			want:            "engineering",
+			wantOk:          true,
+		},
+		{
+			name:            "priority wins over claims order",
+			groups:          []string{"engineering", "contractors"},
+			groupNamespaces: groupNamespaces,
+			groupPriority:   []string{"contractors", "engineering"},
+			want:            "external",
+			wantOk:          true,
+		},
+		{
+			name:            "claims order without priority",
+			groups:          []string{"contractors", "engineering"},
+			groupNamespaces: groupNamespaces,
+			want:            "external",
+			wantOk:          true,
+		},
+		{
+			name:            "priority group the user is not in",
+			groups:          []string{"engineering"},
+			groupNamespaces: groupNamespaces,
+			groupPriority:   []string{"contractors"},
+			want:            "engineering",
+			wantOk:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := getNamespaceNameFromGroups(
+				tt.groups,
+				tt.groupNamespaces,
+				tt.groupPriority,
+			)
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf(
+					"getNamespaceNameFromGroups() = %v, %v, want %v, %v",
+					got,
+					gotOk,
+					tt.want,
+					tt.wantOk,
+				)
+			}
+		})
+	}
+}
+
+func Test_getNamespaceName(t *testing.T) {
+	claims := &IDTokenClaims{
+		Email:    "Alice.Smith@example.com",
+		Username: "asmith",
+		All: map[string]interface{}{
+			"email":              "Alice.Smith@example.com",
+			"preferred_username": "asmith",
+			"tailnet":            "Team Blue",
+			"empty":              " ",
+			"number":             42,
+		},
+	}
+
+	tests := []struct {
+		name             string
+		namespaceClaim   string
+		stripEmaildomain bool
+		want             string
+		wantStatus       int
+	}{
+		{
+			name:       "email by default",
+			want:       "alice.smith.example.com",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:             "email without its domain",
+			stripEmaildomain: true,
+			want:             "alice.smith",
+			wantStatus:       http.StatusOK,
+		},
+		{
+			name:           "preferred_username",
+			namespaceClaim: "preferred_username",
+			want:           "asmith",
+			wantStatus:     http.StatusOK,
+		},
+		{
+			name:           "custom claim is normalized",
+			namespaceClaim: "tailnet",
+			want:           "team-blue",
+			wantStatus:     http.StatusOK,
+		},
+		{
+			name:           "missing claim",
+			namespaceClaim: "department",
+			wantStatus:     http.StatusBadRequest,
+		},
+		{
+			name:           "empty claim",
+			namespaceClaim: "empty",
+			wantStatus:     http.StatusBadRequest,
+		},
+		{
+			name:           "claim which is not a string",
+			namespaceClaim: "number",
+			wantStatus:     http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			got, err := getNamespaceName(
+				recorder,
+				claims,
+				tt.namespaceClaim,
+				tt.stripEmaildomain,
+			)
+			if tt.wantStatus != http.StatusOK {
+				if !errors.Is(err, errOIDCNamespaceClaim) {
+					t.Errorf("getNamespaceName() error = %v, want %v", err, errOIDCNamespaceClaim)
+				}
+				if recorder.Code != tt.wantStatus {
+					t.Errorf("getNamespaceName() status = %d, want %d", recorder.Code, tt.wantStatus)
+				}
+
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("getNamespaceName() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -9,15 +9,23 @@ import (
 	"time"
 
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"gorm.io/gorm"
 )
 
 const (
-	ErrPreAuthKeyNotFound          = Error("AuthKey not found")
-	ErrPreAuthKeyExpired           = Error("AuthKey expired")
-	ErrSingleUseAuthKeyHasBeenUsed = Error("AuthKey has already been used")
-	ErrNamespaceMismatch           = Error("namespace mismatch")
+	ErrPreAuthKeyNotFound              = Error("AuthKey not found")
+	ErrPreAuthKeyExpired               = Error("AuthKey expired")
+	ErrSingleUseAuthKeyHasBeenUsed     = Error("AuthKey has already been used")
+	ErrNamespaceMismatch               = Error("namespace mismatch")
+	ErrPreAuthKeyLabelTooLong          = Error("AuthKey label is too long")
+	ErrPreAuthKeyTagNotOwned           = Error("tag is not owned by the namespace of the AuthKey")
+	ErrPreAuthKeyNegativeMachineExpiry = Error("AuthKey machine expiry cannot be negative")
+)
+
+const (
+	maxPreAuthKeyLabelLength = 255
 )
 
 // PreAuthKey describes a pre-authorization key usable in a particular namespace.
@@ -29,23 +37,59 @@ type PreAuthKey struct {
 	Reusable    bool
 	Ephemeral   bool `gorm:"default:false"`
 	Used        bool `gorm:"default:false"`
+	Label       string
+	// MaxUses limits the number of registrations made with the key,
+	// 0 means no limit.
+	MaxUses  uint
+	UseCount uint `gorm:"default:0"`
+	// ACLTags are forced on the machines registered with the key.
+	ACLTags StringList
+	// MachineExpiry overrides default_machine_expiry for the machines
+	// registered with the key, 0 means they never expire.
+	MachineExpiry *time.Duration
 
 	CreatedAt  *time.Time
 	Expiration *time.Time
 }
 
 // CreatePreAuthKey creates a new PreAuthKey in a namespace, and returns it.
+// The label is an optional free-text description of the purpose of the key.
+// A key with maxUses expires once it has been used that many times.
+// When an ACL policy is loaded, the aclTags must be owned by the namespace.
+// A non-nil machineExpiry overrides default_machine_expiry for the machines
+// registered with the key.
 func (h *Headscale) CreatePreAuthKey(
 	namespaceName string,
 	reusable bool,
 	ephemeral bool,
 	expiration *time.Time,
+	label string,
+	maxUses uint,
+	aclTags []string,
+	machineExpiry *time.Duration,
 ) (*PreAuthKey, error) {
+	if len(label) > maxPreAuthKeyLabelLength {
+		return nil, fmt.Errorf(
+			"%w: %d characters, at most %d are allowed",
+			ErrPreAuthKeyLabelTooLong,
+			len(label),
+			maxPreAuthKeyLabelLength,
+		)
+	}
+
+	if machineExpiry != nil && *machineExpiry < 0 {
+		return nil, ErrPreAuthKeyNegativeMachineExpiry
+	}
+
 	namespace, err := h.GetNamespace(namespaceName)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := h.checkPreAuthKeyTags(namespace, aclTags); err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UTC()
 	kstr, err := h.generateKey()
 	if err != nil {
@@ -53,13 +97,17 @@ func (h *Headscale) CreatePreAuthKey(
 	}
 
 	key := PreAuthKey{
-		Key:         kstr,
-		NamespaceID: namespace.ID,
-		Namespace:   *namespace,
-		Reusable:    reusable,
-		Ephemeral:   ephemeral,
-		CreatedAt:   &now,
-		Expiration:  expiration,
+		Key:           kstr,
+		NamespaceID:   namespace.ID,
+		Namespace:     *namespace,
+		Reusable:      reusable,
+		Ephemeral:     ephemeral,
+		CreatedAt:     &now,
+		Expiration:    expiration,
+		Label:         label,
+		MaxUses:       maxUses,
+		ACLTags:       aclTags,
+		MachineExpiry: machineExpiry,
 	}
 
 	if err := h.db.Save(&key).Error; err != nil {
@@ -69,6 +117,12 @@ func (h *Headscale) CreatePreAuthKey(
 	return &key, nil
 }
 
+// checkPreAuthKeyTags checks that the namespace is an owner of the tags in
+// the ACL policy.
+func (h *Headscale) checkPreAuthKeyTags(namespace *Namespace, aclTags []string) error {
+	return h.checkNamespaceOwnsTags(namespace, aclTags, ErrPreAuthKeyTagNotOwned)
+}
+
 // ListPreAuthKeys returns the list of PreAuthKeys for a namespace.
 func (h *Headscale) ListPreAuthKeys(namespaceName string) ([]PreAuthKey, error) {
 	namespace, err := h.GetNamespace(namespaceName)
@@ -84,6 +138,32 @@ func (h *Headscale) ListPreAuthKeys(namespaceName string) ([]PreAuthKey, error)
 	return keys, nil
 }
 
+// getPreAuthKeysMachines returns the machines registered with each of the
+// keys, by key ID.
+func (h *Headscale) getPreAuthKeysMachines(keys []PreAuthKey) (map[uint64][]Machine, error) {
+	machinesByKey := make(map[uint64][]Machine)
+	if len(keys) == 0 {
+		return machinesByKey, nil
+	}
+
+	keyIDs := make([]uint, len(keys))
+	for index, key := range keys {
+		keyIDs[index] = uint(key.ID)
+	}
+
+	machines := []Machine{}
+	if err := h.db.Where("auth_key_id IN ?", keyIDs).Order("id").Find(&machines).Error; err != nil {
+		return nil, err
+	}
+
+	for _, machine := range machines {
+		keyID := uint64(machine.AuthKeyID)
+		machinesByKey[keyID] = append(machinesByKey[keyID], machine)
+	}
+
+	return machinesByKey, nil
+}
+
 // GetPreAuthKey returns a PreAuthKey for a given key.
 func (h *Headscale) GetPreAuthKey(namespace string, key string) (*PreAuthKey, error) {
 	pak, err := h.checkKeyValidity(key)
@@ -117,16 +197,39 @@ func (h *Headscale) ExpirePreAuthKey(k *PreAuthKey) error {
 	return nil
 }
 
-// UsePreAuthKey marks a PreAuthKey as used.
+// UsePreAuthKey marks a PreAuthKey as used, and counts the use.
+// The counter is only incremented while it is below the limit of the key, so
+// that concurrent registrations cannot use the key more than allowed.
 func (h *Headscale) UsePreAuthKey(k *PreAuthKey) error {
-	k.Used = true
-	if err := h.db.Save(k).Error; err != nil {
-		return fmt.Errorf("failed to update key used status in the database: %w", err)
+	result := h.db.Model(k).
+		Where("max_uses = 0 OR use_count < max_uses").
+		Updates(map[string]interface{}{
+			"used":      true,
+			"use_count": gorm.Expr("use_count + 1"),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update key used status in the database: %w", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		return ErrPreAuthKeyExpired
+	}
+
+	k.Used = true
+	k.UseCount++
 
 	return nil
 }
 
+// remainingUses returns the number of registrations the key can still be
+// used for, when it has a limit.
+func (key *PreAuthKey) remainingUses() uint {
+	if key.UseCount >= key.MaxUses {
+		return 0
+	}
+
+	return key.MaxUses - key.UseCount
+}
+
 // checkKeyValidity does the heavy lifting for validation of the PreAuthKey coming from a node
 // If returns no error and a PreAuthKey, it can be used.
 func (h *Headscale) checkKeyValidity(k string) (*PreAuthKey, error) {
@@ -142,6 +245,15 @@ func (h *Headscale) checkKeyValidity(k string) (*PreAuthKey, error) {
 		return nil, ErrPreAuthKeyExpired
 	}
 
+	// a key with a usage limit is valid until the limit is reached
+	if pak.MaxUses > 0 {
+		if pak.remainingUses() == 0 {
+			return nil, ErrPreAuthKeyExpired
+		}
+
+		return &pak, nil
+	}
+
 	if pak.Reusable || pak.Ephemeral { // we don't need to check if has been used before
 		return &pak, nil
 	}
@@ -176,6 +288,14 @@ func (key *PreAuthKey) toProto() *v1.PreAuthKey {
 		Ephemeral: key.Ephemeral,
 		Reusable:  key.Reusable,
 		Used:      key.Used,
+		Label:     key.Label,
+		MaxUses:   uint32(key.MaxUses),
+		UseCount:  uint32(key.UseCount),
+		AclTags:   key.ACLTags,
+	}
+
+	if key.MaxUses > 0 {
+		protoKey.RemainingUses = uint32(key.remainingUses())
 	}
 
 	if key.Expiration != nil {
@@ -186,5 +306,9 @@ func (key *PreAuthKey) toProto() *v1.PreAuthKey {
 		protoKey.CreatedAt = timestamppb.New(*key.CreatedAt)
 	}
 
+	if key.MachineExpiry != nil {
+		protoKey.MachineExpiry = durationpb.New(*key.MachineExpiry)
+	}
+
 	return &protoKey
 }
@@ -1,20 +1,26 @@
 package headscale
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"time"
 
 	"gopkg.in/check.v1"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
 )
 
 func (*Suite) TestCreatePreAuthKey(c *check.C) {
-	_, err := app.CreatePreAuthKey("bogus", true, false, nil)
+	_, err := app.CreatePreAuthKey("bogus", true, false, nil, "", 0, nil, nil)
 
 	c.Assert(err, check.NotNil)
 
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	key, err := app.CreatePreAuthKey(namespace.Name, true, false, nil)
+	key, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	// Did we get a valid key?
@@ -40,7 +46,7 @@ func (*Suite) TestExpiredPreAuthKey(c *check.C) {
 	c.Assert(err, check.IsNil)
 
 	now := time.Now()
-	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, &now)
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, &now, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	key, err := app.checkKeyValidity(pak.Key)
@@ -58,7 +64,7 @@ func (*Suite) TestValidateKeyOk(c *check.C) {
 	namespace, err := app.CreateNamespace("test3")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	key, err := app.checkKeyValidity(pak.Key)
@@ -70,7 +76,7 @@ func (*Suite) TestAlreadyUsedKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test4")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	machine := Machine{
@@ -94,7 +100,7 @@ func (*Suite) TestReusableBeingUsedKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test5")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	machine := Machine{
@@ -118,7 +124,7 @@ func (*Suite) TestNotReusableNotBeingUsedKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test6")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	key, err := app.checkKeyValidity(pak.Key)
@@ -130,7 +136,7 @@ func (*Suite) TestEphemeralKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test7")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, true, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, true, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	now := time.Now()
@@ -165,7 +171,7 @@ func (*Suite) TestExpirePreauthKey(c *check.C) {
 	namespace, err := app.CreateNamespace("test3")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(pak.Expiration, check.IsNil)
 
@@ -182,7 +188,7 @@ func (*Suite) TestNotReusableMarkedAsUsed(c *check.C) {
 	namespace, err := app.CreateNamespace("test6")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 	pak.Used = true
 	app.db.Save(&pak)
@@ -190,3 +196,146 @@ func (*Suite) TestNotReusableMarkedAsUsed(c *check.C) {
 	_, err = app.checkKeyValidity(pak.Key)
 	c.Assert(err, check.Equals, ErrSingleUseAuthKeyHasBeenUsed)
 }
+
+func (*Suite) TestPreAuthKeyLabel(c *check.C) {
+	namespace, err := app.CreateNamespace("test7")
+	c.Assert(err, check.IsNil)
+
+	_, err = app.CreatePreAuthKey(
+		namespace.Name,
+		false,
+		false,
+		nil,
+		strings.Repeat("a", maxPreAuthKeyLabelLength+1),
+		0,
+		nil,
+		nil,
+	)
+	c.Assert(errors.Is(err, ErrPreAuthKeyLabelTooLong), check.Equals, true)
+
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "CI batch Jan", 0, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(pak.toProto().GetLabel(), check.Equals, "CI batch Jan")
+
+	keys, err := app.ListPreAuthKeys(namespace.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(keys, check.HasLen, 1)
+	c.Assert(keys[0].Label, check.Equals, "CI batch Jan")
+}
+
+func (*Suite) TestPreAuthKeyMaxUses(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 2, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(pak.toProto().GetRemainingUses(), check.Equals, uint32(2))
+
+	for use := 0; use < 2; use++ {
+		key, err := app.checkKeyValidity(pak.Key)
+		c.Assert(err, check.IsNil)
+		c.Assert(app.UsePreAuthKey(key), check.IsNil)
+	}
+
+	_, err = app.checkKeyValidity(pak.Key)
+	c.Assert(err, check.Equals, ErrPreAuthKeyExpired)
+
+	// the counter does not go over the limit
+	c.Assert(app.UsePreAuthKey(pak), check.Equals, ErrPreAuthKeyExpired)
+
+	keys, err := app.ListPreAuthKeys(namespace.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(keys, check.HasLen, 1)
+	c.Assert(keys[0].toProto().GetUseCount(), check.Equals, uint32(2))
+	c.Assert(keys[0].toProto().GetRemainingUses(), check.Equals, uint32(0))
+}
+
+func (*Suite) TestPreAuthKeyACLTags(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	app.aclPolicy = &ACLPolicy{
+		TagOwners: TagOwners{
+			"tag:ci":    []string{"test"},
+			"tag:other": []string{"other"},
+		},
+	}
+
+	_, err = app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, []string{"tag:unknown"}, nil)
+	c.Assert(errors.Is(err, ErrTagNotInTagOwners), check.Equals, true)
+
+	_, err = app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, []string{"tag:other"}, nil)
+	c.Assert(errors.Is(err, ErrPreAuthKeyTagNotOwned), check.Equals, true)
+
+	pak, err := app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, []string{"tag:ci"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(pak.toProto().GetAclTags(), check.DeepEquals, []string{"tag:ci"})
+
+	registerRequest := tailcfg.RegisterRequest{
+		NodeKey:  key.NewNode().Public(),
+		Hostinfo: &tailcfg.Hostinfo{Hostname: "tagged"},
+	}
+	registerRequest.Auth.AuthKey = pak.Key
+
+	writer := httptest.NewRecorder()
+	app.handleAuthKeyCommon(writer, registerRequest, key.MachinePublic{})
+	c.Assert(writer.Code, check.Equals, http.StatusOK)
+
+	machine, err := app.GetMachineByNodeKey(registerRequest.NodeKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.ForcedTags, check.DeepEquals, StringList{"tag:ci"})
+}
+
+func (*Suite) TestPreAuthKeyMachineExpiry(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	app.cfg.DefaultMachineExpiry = time.Hour
+
+	negative := -time.Hour
+	_, err = app.CreatePreAuthKey(namespace.Name, true, false, nil, "", 0, nil, &negative)
+	c.Assert(errors.Is(err, ErrPreAuthKeyNegativeMachineExpiry), check.Equals, true)
+
+	never := time.Duration(0)
+	day := 24 * time.Hour
+
+	register := func(machineExpiry *time.Duration, hostname string) *Machine {
+		pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, machineExpiry)
+		c.Assert(err, check.IsNil)
+
+		registerRequest := tailcfg.RegisterRequest{
+			NodeKey:  key.NewNode().Public(),
+			Hostinfo: &tailcfg.Hostinfo{Hostname: hostname},
+		}
+		registerRequest.Auth.AuthKey = pak.Key
+
+		writer := httptest.NewRecorder()
+		app.handleAuthKeyCommon(writer, registerRequest, key.MachinePublic{})
+		c.Assert(writer.Code, check.Equals, http.StatusOK)
+
+		machine, err := app.GetMachineByNodeKey(registerRequest.NodeKey)
+		c.Assert(err, check.IsNil)
+
+		return machine
+	}
+
+	before := time.Now()
+
+	machine := register(nil, "default")
+	c.Assert(machine.Expiry, check.NotNil)
+	c.Assert(machine.Expiry.After(before.Add(time.Hour-time.Minute)), check.Equals, true)
+	c.Assert(machine.Expiry.Before(before.Add(time.Hour+time.Minute)), check.Equals, true)
+
+	machine = register(&day, "override")
+	c.Assert(machine.Expiry, check.NotNil)
+	c.Assert(machine.Expiry.After(before.Add(day-time.Minute)), check.Equals, true)
+	c.Assert(machine.Expiry.Before(before.Add(day+time.Minute)), check.Equals, true)
+
+	machine = register(&never, "never")
+	c.Assert(machine.Expiry == nil || machine.Expiry.IsZero(), check.Equals, true)
+	c.Assert(machine.isExpired(), check.Equals, false)
+
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, &day)
+	c.Assert(err, check.IsNil)
+	c.Assert(pak.toProto().GetMachineExpiry().AsDuration(), check.Equals, day)
+}
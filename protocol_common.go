@@ -184,10 +184,9 @@ func (h *Headscale) handleRegisterCommon(
 			newMachine.Expiry = &registerRequest.Expiry
 		}
 
-		h.registrationCache.Set(
+		h.setRegistrationCache(
 			newMachine.NodeKey,
 			newMachine,
-			registerCacheExpiration,
 		)
 
 		h.handleNewMachineCommon(writer, registerRequest, machineKey)
@@ -197,6 +196,15 @@ func (h *Headscale) handleRegisterCommon(
 
 	// The machine is already registered, so we need to pass through reauth or key update.
 	if machine != nil {
+		// The node key is known to every peer, it does not prove the client
+		// is the machine when it comes with another machine key.
+		if !machineKey.IsZero() &&
+			machine.MachineKey != MachinePublicKeyStripPrefix(machineKey) {
+			h.handleMachineKeyChangedCommon(writer, *machine, machineKey)
+
+			return
+		}
+
 		// If the NodeKey stored in headscale is the same as the key presented in a registration
 		// request, then we have a node that is either:
 		// - Trying to log out (sending a expiry in the past)
@@ -238,16 +246,33 @@ func (h *Headscale) handleRegisterCommon(
 		h.handleMachineExpiredCommon(writer, registerRequest, *machine, machineKey)
 
 		machine.Expiry = &time.Time{}
-		h.registrationCache.Set(
+		h.setRegistrationCache(
 			NodePublicKeyStripPrefix(registerRequest.NodeKey),
 			*machine,
-			registerCacheExpiration,
 		)
 
 		return
 	}
 }
 
+// handleMachineKeyChangedCommon refuses a registration presenting the node
+// key of a machine along with another machine key. The machine key is only
+// rotated by an admin, with RotateMachineKey.
+func (h *Headscale) handleMachineKeyChangedCommon(
+	writer http.ResponseWriter,
+	machine Machine,
+	machineKey key.MachinePublic,
+) {
+	log.Warn().
+		Caller().
+		Str("machine", machine.Hostname).
+		Str("machine_key", machine.MachineKey).
+		Str("new_machine_key", MachinePublicKeyStripPrefix(machineKey)).
+		Msg("Refusing a registration with the node key of the machine and another machine key")
+
+	http.Error(writer, ErrMachineKeyChanged.Error(), http.StatusUnauthorized)
+}
+
 // handleAuthKeyCommon contains the logic to manage auth key client registration
 // It is used both by the legacy and the new Noise protocol.
 // When using Noise, the machineKey is Zero.
@@ -340,9 +365,40 @@ func (h *Headscale) handleAuthKeyCommon(
 			Str("machine", machine.Hostname).
 			Msg("machine was already registered before, refreshing with new auth key")
 
+		// the machine is only found by its public node key, known to every
+		// peer: the pre-auth key must be from the namespace of the machine,
+		// and the machine key cannot change
+		if pak.Namespace.ID != machine.NamespaceID {
+			log.Error().
+				Caller().
+				Str("machine", machine.Hostname).
+				Str("namespace", pak.Namespace.Name).
+				Err(ErrMachineKeyNamespace).
+				Msg("Refusing to refresh the machine with the auth key")
+			machineRegistrations.WithLabelValues("reauth", RegisterMethodAuthKey, "error", pak.Namespace.Name).
+				Inc()
+			http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+		if !machineKey.IsZero() &&
+			machine.MachineKey != MachinePublicKeyStripPrefix(machineKey) {
+			machineRegistrations.WithLabelValues("reauth", RegisterMethodAuthKey, "error", pak.Namespace.Name).
+				Inc()
+			h.handleMachineKeyChangedCommon(writer, *machine, machineKey)
+
+			return
+		}
+
 		machine.NodeKey = nodeKey
 		machine.AuthKeyID = uint(pak.ID)
-		err := h.RefreshMachine(machine, registerRequest.Expiry)
+		for _, tag := range pak.ACLTags {
+			if !contains(machine.ForcedTags, tag) {
+				machine.ForcedTags = append(machine.ForcedTags, tag)
+			}
+		}
+		expiry := h.registrationExpiry(&registerRequest.Expiry, pak)
+		err := h.RefreshMachine(machine, *expiry)
 		if err != nil {
 			log.Error().
 				Caller().
@@ -374,10 +430,11 @@ func (h *Headscale) handleAuthKeyCommon(
 			NamespaceID:    pak.Namespace.ID,
 			MachineKey:     MachinePublicKeyStripPrefix(machineKey),
 			RegisterMethod: RegisterMethodAuthKey,
-			Expiry:         &registerRequest.Expiry,
+			Expiry:         h.registrationExpiry(&registerRequest.Expiry, pak),
 			NodeKey:        nodeKey,
 			LastSeen:       &now,
 			AuthKeyID:      uint(pak.ID),
+			ForcedTags:     append(StringList{}, pak.ACLTags...),
 		}
 
 		machine, err = h.RegisterMachine(
@@ -391,12 +448,30 @@ func (h *Headscale) handleAuthKeyCommon(
 				Msg("could not register machine")
 			machineRegistrations.WithLabelValues("new", RegisterMethodAuthKey, "error", pak.Namespace.Name).
 				Inc()
+			// the clients show the body of the error to the user
+			if errors.Is(err, ErrNamespaceMachineQuotaExceeded) {
+				http.Error(writer, err.Error(), http.StatusForbidden)
+
+				return
+			}
 			http.Error(writer, "Internal server error", http.StatusInternalServerError)
 
 			return
 		}
 	}
 
+	// the tags of the key change the machines matched by the ACL rules
+	if len(pak.ACLTags) > 0 {
+		if err := h.UpdateACLRules(); err != nil && !errors.Is(err, errEmptyPolicy) {
+			log.Error().
+				Caller().
+				Bool("noise", machineKey.IsZero()).
+				Str("machine", machine.Hostname).
+				Err(err).
+				Msg("Failed to update the ACL rules with the tags of the pre-auth key")
+		}
+	}
+
 	err = h.UsePreAuthKey(pak)
 	if err != nil {
 		log.Error().
@@ -464,7 +539,7 @@ func (h *Headscale) handleNewMachineCommon(
 		Bool("noise", machineKey.IsZero()).
 		Str("machine", registerRequest.Hostinfo.Hostname).
 		Msg("The node seems to be new, sending auth url")
-	if h.cfg.OIDC.Issuer != "" {
+	if len(h.cfg.OIDCProviders) > 0 {
 		resp.AuthURL = fmt.Sprintf(
 			"%s/oidc/register/%s",
 			strings.TrimSuffix(h.cfg.ServerURL, "/"),
@@ -697,7 +772,7 @@ func (h *Headscale) handleMachineExpiredCommon(
 		return
 	}
 
-	if h.cfg.OIDC.Issuer != "" {
+	if len(h.cfg.OIDCProviders) > 0 {
 		resp.AuthURL = fmt.Sprintf("%s/oidc/register/%s",
 			strings.TrimSuffix(h.cfg.ServerURL, "/"),
 			NodePublicKeyStripPrefix(registerRequest.NodeKey))
@@ -2,22 +2,90 @@ package headscale
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
 	"time"
 
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/rs/zerolog/log"
 	"tailscale.com/tailcfg"
 )
 
 const (
-	keepAliveInterval = 60 * time.Second
+	// defaultKeepAliveInterval is how often the long poll streams send a
+	// keep alive, unless node_keepalive_interval says otherwise.
+	defaultKeepAliveInterval = 60 * time.Second
+	// clientPollTimeout is how long the Tailscale clients wait without
+	// hearing from a long poll before dropping it and reconnecting.
+	clientPollTimeout = 120 * time.Second
+
+	// defaultPollMaxRequestBodySize is large enough for the HostInfo and
+	// Endpoints clients send, while still bounding what a client can make us
+	// buffer in memory.
+	defaultPollMaxRequestBodySize = 1 << 20
 )
 
 type contextKey string
 
 const machineNameContextKey = contextKey("machineName")
 
+// keepAliveInterval returns the configured node_keepalive_interval.
+func (h *Headscale) keepAliveInterval() time.Duration {
+	interval := h.cfg.NodeKeepAliveInterval
+	if interval <= 0 {
+		interval = defaultKeepAliveInterval
+	}
+
+	return interval
+}
+
+// readPollRequestBody reads the body of a poll request, refusing to read more
+// than the configured poll_max_request_body_size. When the limit is exceeded,
+// a 413 has already been written to the client and ok is false.
+func (h *Headscale) readPollRequestBody(
+	writer http.ResponseWriter,
+	req *http.Request,
+	handler string,
+) ([]byte, bool) {
+	limit := h.cfg.PollMaxRequestBodySize
+	if limit <= 0 {
+		limit = defaultPollMaxRequestBodySize
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(writer, req.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Warn().
+				Str("handler", handler).
+				Int64("limit", limit).
+				Msg("Rejecting poll request, body too large")
+			http.Error(
+				writer,
+				"Request body too large",
+				http.StatusRequestEntityTooLarge,
+			)
+
+			return nil, false
+		}
+
+		log.Error().
+			Caller().
+			Str("handler", handler).
+			Err(err).
+			Msg("Cannot read request body")
+		http.Error(writer, "Cannot read request body", http.StatusBadRequest)
+
+		return nil, false
+	}
+
+	return body, true
+}
+
 // handlePollCommon is the common code for the legacy and Noise protocols to
 // managed the poll loop.
 func (h *Headscale) handlePollCommon(
@@ -27,20 +95,69 @@ func (h *Headscale) handlePollCommon(
 	mapRequest tailcfg.MapRequest,
 	isNoise bool,
 ) {
+	// The namespace is preloaded with the machine, a zero or different ID
+	// means the namespace row is missing (removed out-of-band, or corrupted).
+	// Map generation and ACL expansion cannot work without it.
+	if machine.Namespace.ID == 0 || machine.Namespace.ID != machine.NamespaceID {
+		log.Error().
+			Str("handler", "PollNetMap").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Uint("namespace_id", machine.NamespaceID).
+			Msg("Rejecting poll from machine without a valid namespace, it needs to reauthenticate")
+		http.Error(writer, "Machine has no valid namespace", http.StatusUnauthorized)
+
+		return
+	}
+
+	if machine.isExpired() {
+		log.Info().
+			Str("handler", "PollNetMap").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Time("expiry", *machine.Expiry).
+			Msg("Rejecting poll from expired machine, it needs to reauthenticate")
+		http.Error(writer, "Machine has expired", http.StatusUnauthorized)
+
+		return
+	}
+
+	if err := h.checkAdvertisedRoutes(mapRequest.Hostinfo); err != nil {
+		log.Warn().
+			Str("handler", "PollNetMap").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Err(err).
+			Msg("Rejecting poll from machine advertising too many routes")
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	// an ephemeral machine reconnecting within the grace period is kept
+	if h.cancelEphemeralMachineDeletion(machine.MachineKey) {
+		log.Debug().
+			Str("handler", "PollNetMap").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Msg("Ephemeral machine reconnected, cancelling its deletion")
+	}
+
 	machine.Hostname = mapRequest.Hostinfo.Hostname
 	machine.HostInfo = HostInfo(*mapRequest.Hostinfo)
 	machine.DiscoKey = DiscoPublicKeyStripPrefix(mapRequest.DiscoKey)
 	now := time.Now().UTC()
 
 	// update ACLRules with peer informations (to update server tags if necessary)
-	if h.aclPolicy != nil {
+	if h.getACLPolicy() != nil {
 		err := h.UpdateACLRules()
 		if err != nil {
 			log.Error().
 				Caller().
 				Bool("noise", isNoise).
 				Str("machine", machine.Hostname).
-				Err(err)
+				Err(err).
+				Msg("Could not update the ACL rules")
 		}
 	}
 	// From Tailscale client:
@@ -56,22 +173,34 @@ func (h *Headscale) handlePollCommon(
 		machine.LastSeen = &now
 	}
 
-	if err := h.db.Updates(machine).Error; err != nil {
-		if err != nil {
-			log.Error().
-				Str("handler", "PollNetMap").
-				Bool("noise", isNoise).
-				Str("node_key", machine.NodeKey).
-				Str("machine", machine.Hostname).
-				Err(err).
-				Msg("Failed to persist/update machine in the database")
-			http.Error(writer, "", http.StatusInternalServerError)
+	if err := h.savePollState(machine, !mapRequest.ReadOnly); err != nil {
+		log.Error().
+			Str("handler", "PollNetMap").
+			Bool("noise", isNoise).
+			Str("node_key", machine.NodeKey).
+			Str("machine", machine.Hostname).
+			Err(err).
+			Msg("Failed to persist/update machine in the database")
+		http.Error(writer, "", http.StatusInternalServerError)
 
-			return
-		}
+		return
 	}
 
-	mapResp, err := h.getMapResponseData(mapRequest, machine, isNoise)
+	// the advertised routes may have changed with the HostInfo
+	if err := h.enableAutoApprovedRoutes(machine); err != nil {
+		log.Error().
+			Str("handler", "PollNetMap").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Err(err).
+			Msg("Failed to enable the auto approved routes")
+	}
+
+	var mapResp []byte
+	mapJSON, err := h.getMapResponseJSON(mapRequest, machine)
+	if err == nil {
+		mapResp, err = h.encodeMapResponse(mapJSON, mapRequest, machine, isNoise)
+	}
 	if err != nil {
 		log.Error().
 			Str("handler", "PollNetMap").
@@ -166,18 +295,11 @@ func (h *Headscale) handlePollCommon(
 			Inc()
 		updateChan <- struct{}{}
 
-		return
-	} else if mapRequest.OmitPeers && mapRequest.Stream {
-		log.Warn().
-			Str("handler", "PollNetMap").
-			Bool("noise", isNoise).
-			Str("machine", machine.Hostname).
-			Msg("Ignoring request, don't know how to handle it")
-		http.Error(writer, "", http.StatusBadRequest)
-
 		return
 	}
 
+	// A client sending OmitPeers with Stream gets the regular stream, the
+	// map responses generated for it just have no peers.
 	log.Info().
 		Str("handler", "PollNetMap").
 		Bool("noise", isNoise).
@@ -188,6 +310,7 @@ func (h *Headscale) handlePollCommon(
 		Bool("noise", isNoise).
 		Str("machine", machine.Hostname).
 		Msg("Sending initial map")
+	h.mapResponseHashes.Store(machine.MachineKey, sha256.Sum256(mapJSON))
 	pollDataChan <- mapResp
 
 	log.Info().
@@ -232,19 +355,43 @@ func (h *Headscale) pollNetMapStream(
 	h.pollNetMapStreamWG.Add(1)
 	defer h.pollNetMapStreamWG.Done()
 
+	pollStreamsActive.Inc()
+	defer pollStreamsActive.Dec()
+
 	ctx := context.WithValue(ctxReq, machineNameContextKey, machine.Hostname)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	go h.scheduledPollWorker(
-		ctx,
-		updateChan,
-		keepAliveChan,
-		mapRequest,
-		machine,
-		isNoise,
-	)
+	// The update channel is never closed, as the notifier may still hold it
+	// for a last update after it has been unregistered.
+	h.registerUpdateChannel(machine.MachineKey, updateChan)
+	defer h.unregisterUpdateChannel(machine.MachineKey, updateChan)
+
+	h.publishMachineEvent(v1.MachineEventType_MACHINE_EVENT_TYPE_ONLINE, *machine)
+	defer h.publishMachineEvent(v1.MachineEventType_MACHINE_EVENT_TYPE_OFFLINE, *machine)
+
+	// The keep alive worker is stopped and waited for before keepAliveChan
+	// is closed, so it can never send on a closed channel.
+	// The worker gets its own copy of the machine, as the stream reloads
+	// it from the database while the worker is running.
+	keepAliveMachine := *machine
+	keepAliveDone := make(chan struct{})
+	go func() {
+		defer close(keepAliveDone)
+		h.scheduledPollWorker(
+			ctx,
+			keepAliveChan,
+			mapRequest,
+			&keepAliveMachine,
+			isNoise,
+		)
+	}()
+	defer func() {
+		cancel()
+		<-keepAliveDone
+		closeChanWithLog(keepAliveChan, machine.Hostname, "keepAliveChan")
+	}()
 
 	log.Trace().
 		Str("handler", "pollNetMapStream").
@@ -377,6 +524,7 @@ func (h *Headscale) pollNetMapStream(
 			} else {
 				flusher.Flush()
 			}
+			keepAlivesSent.Inc()
 
 			log.Trace().
 				Str("handler", "PollNetMapStream").
@@ -435,118 +583,119 @@ func (h *Headscale) pollNetMapStream(
 			updateRequestsReceivedOnChannel.WithLabelValues(machine.Namespace.Name, machine.Hostname).
 				Inc()
 
-			if h.isOutdated(machine) {
-				var lastUpdate time.Time
-				if machine.LastSuccessfulUpdate != nil {
-					lastUpdate = *machine.LastSuccessfulUpdate
-				}
-				log.Debug().
+			_, forced := h.forcedUpdates.LoadAndDelete(machine.MachineKey)
+			// the notifier only wakes up the outdated clients, see
+			// notifyOutdatedClients, the machine is only reloaded for its map
+			if err := h.UpdateMachineFromDatabase(machine); err != nil {
+				log.Error().
 					Str("handler", "PollNetMapStream").
 					Bool("noise", isNoise).
 					Str("machine", machine.Hostname).
-					Time("last_successful_update", lastUpdate).
-					Time("last_state_change", h.getLastStateChange(machine.Namespace.Name)).
-					Msgf("There has been updates since the last successful update to %s", machine.Hostname)
-				data, err := h.getMapResponseData(mapRequest, machine, false)
-				if err != nil {
-					log.Error().
-						Str("handler", "PollNetMapStream").
-						Bool("noise", isNoise).
-						Str("machine", machine.Hostname).
-						Str("channel", "update").
-						Err(err).
-						Msg("Could not get the map update")
+					Str("channel", "update").
+					Err(err).
+					Msg("Cannot update machine from database")
 
-					return
-				}
-				_, err = writer.Write(data)
-				if err != nil {
-					log.Error().
-						Str("handler", "PollNetMapStream").
-						Bool("noise", isNoise).
-						Str("machine", machine.Hostname).
-						Str("channel", "update").
-						Err(err).
-						Msg("Could not write the map response")
-					updateRequestsSentToNode.WithLabelValues(machine.Namespace.Name, machine.Hostname, "failed").
-						Inc()
+				return
+			}
 
-					return
-				}
+			var lastUpdate time.Time
+			if machine.LastSuccessfulUpdate != nil {
+				lastUpdate = *machine.LastSuccessfulUpdate
+			}
+			log.Debug().
+				Str("handler", "PollNetMapStream").
+				Bool("noise", isNoise).
+				Str("machine", machine.Hostname).
+				Time("last_successful_update", lastUpdate).
+				Time("last_state_change", h.getLastStateChange(machine.Namespace.Name)).
+				Bool("forced", forced).
+				Msgf("There has been updates since the last successful update to %s", machine.Hostname)
+			sendStart := time.Now()
+			mapJSON, err := h.getMapResponseJSON(mapRequest, machine)
+			if err != nil {
+				log.Error().
+					Str("handler", "PollNetMapStream").
+					Bool("noise", isNoise).
+					Str("machine", machine.Hostname).
+					Str("channel", "update").
+					Err(err).
+					Msg("Could not get the map update")
 
-				flusher, ok := writer.(http.Flusher)
-				if !ok {
-					log.Error().
-						Caller().
-						Str("handler", "PollNetMapStream").
-						Bool("noise", isNoise).
-						Str("machine", machine.Hostname).
-						Str("channel", "update").
-						Msg("Cannot cast writer to http.Flusher")
-				} else {
-					flusher.Flush()
-				}
+				return
+			}
 
+			// a state change in a namespace the machine cannot see gives
+			// the same map, the client does not need it again
+			mapHash := sha256.Sum256(mapJSON)
+			if lastHash, ok := h.mapResponseHashes.Load(machine.MachineKey); !forced && ok &&
+				lastHash == mapHash {
 				log.Trace().
 					Str("handler", "PollNetMapStream").
 					Bool("noise", isNoise).
 					Str("machine", machine.Hostname).
 					Str("channel", "update").
-					Msg("Updated Map has been sent")
-				updateRequestsSentToNode.WithLabelValues(machine.Namespace.Name, machine.Hostname, "success").
-					Inc()
+					Msg("Map is unchanged, not sending it again")
 
-				// Keep track of the last successful update,
-				// we sometimes end in a state were the update
-				// is not picked up by a client and we use this
-				// to determine if we should "force" an update.
-				// TODO(kradalby): Abstract away all the database calls, this can cause race conditions
-				// when an outdated machine object is kept alive, e.g. db is update from
-				// command line, but then overwritten.
-				err = h.UpdateMachineFromDatabase(machine)
-				if err != nil {
-					log.Error().
-						Str("handler", "PollNetMapStream").
-						Bool("noise", isNoise).
-						Str("machine", machine.Hostname).
-						Str("channel", "update").
-						Err(err).
-						Msg("Cannot update machine from database")
-
-					// client has been removed from database
-					// since the stream opened, terminate connection.
+				if !h.touchLastSuccessfulUpdate(machine, isNoise) {
 					return
 				}
-				now := time.Now().UTC()
-
-				lastStateUpdate.WithLabelValues(machine.Namespace.Name, machine.Hostname).
-					Set(float64(now.Unix()))
-				machine.LastSuccessfulUpdate = &now
-
-				err = h.TouchMachine(machine)
-				if err != nil {
-					log.Error().
-						Str("handler", "PollNetMapStream").
-						Bool("noise", isNoise).
-						Str("machine", machine.Hostname).
-						Str("channel", "update").
-						Err(err).
-						Msg("Cannot update machine LastSuccessfulUpdate")
 
-					return
-				}
-			} else {
-				var lastUpdate time.Time
-				if machine.LastSuccessfulUpdate != nil {
-					lastUpdate = *machine.LastSuccessfulUpdate
-				}
-				log.Trace().
+				continue
+			}
+
+			data, err := h.encodeMapResponse(mapJSON, mapRequest, machine, isNoise)
+			if err != nil {
+				log.Error().
+					Str("handler", "PollNetMapStream").
+					Bool("noise", isNoise).
+					Str("machine", machine.Hostname).
+					Str("channel", "update").
+					Err(err).
+					Msg("Could not encode the map update")
+
+				return
+			}
+			_, err = writer.Write(data)
+			if err != nil {
+				log.Error().
 					Str("handler", "PollNetMapStream").
 					Bool("noise", isNoise).
 					Str("machine", machine.Hostname).
-					Time("last_successful_update", lastUpdate).
-					Time("last_state_change", h.getLastStateChange(machine.Namespace.Name)).
-					Msgf("%s is up to date", machine.Hostname)
+					Str("channel", "update").
+					Err(err).
+					Msg("Could not write the map response")
+				updateRequestsSentToNode.WithLabelValues(machine.Namespace.Name, machine.Hostname, "failed").
+					Inc()
+
+				return
+			}
+
+			flusher, ok := writer.(http.Flusher)
+			if !ok {
+				log.Error().
+					Caller().
+					Str("handler", "PollNetMapStream").
+					Bool("noise", isNoise).
+					Str("machine", machine.Hostname).
+					Str("channel", "update").
+					Msg("Cannot cast writer to http.Flusher")
+			} else {
+				flusher.Flush()
+			}
+			mapUpdateSendDuration.Observe(time.Since(sendStart).Seconds())
+
+			log.Trace().
+				Str("handler", "PollNetMapStream").
+				Bool("noise", isNoise).
+				Str("machine", machine.Hostname).
+				Str("channel", "update").
+				Msg("Updated Map has been sent")
+			updateRequestsSentToNode.WithLabelValues(machine.Namespace.Name, machine.Hostname, "success").
+				Inc()
+			h.mapResponseHashes.Store(machine.MachineKey, mapHash)
+
+			if !h.touchLastSuccessfulUpdate(machine, isNoise) {
+				return
 			}
 
 		case <-ctx.Done():
@@ -584,6 +733,10 @@ func (h *Headscale) pollNetMapStream(
 					Msg("Cannot update machine LastSeen")
 			}
 
+			if h.isEphemeralMachine(machine) {
+				h.scheduleEphemeralMachineDeletion(*machine)
+			}
+
 			// The connection has been closed, so we can stop polling.
 			return
 
@@ -594,32 +747,133 @@ func (h *Headscale) pollNetMapStream(
 				Str("machine", machine.Hostname).
 				Msg("The long-poll handler is shutting down")
 
+			h.drainPollNetMapStream(ctx, writer, mapRequest, machine, isNoise)
+
+			return
+		}
+	}
+}
+
+// touchLastSuccessfulUpdate records that the machine has the latest state,
+// after an update was sent to it or found unchanged. It returns false when
+// the stream must end.
+func (h *Headscale) touchLastSuccessfulUpdate(machine *Machine, isNoise bool) bool {
+	// Keep track of the last successful update,
+	// we sometimes end in a state were the update
+	// is not picked up by a client and we use this
+	// to determine if we should "force" an update.
+	// TODO(kradalby): Abstract away all the database calls, this can cause race conditions
+	// when an outdated machine object is kept alive, e.g. db is update from
+	// command line, but then overwritten.
+	err := h.UpdateMachineFromDatabase(machine)
+	if err != nil {
+		log.Error().
+			Str("handler", "PollNetMapStream").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Str("channel", "update").
+			Err(err).
+			Msg("Cannot update machine from database")
+
+		// client has been removed from database
+		// since the stream opened, terminate connection.
+		return false
+	}
+	now := time.Now().UTC()
+
+	lastStateUpdate.WithLabelValues(machine.Namespace.Name, machine.Hostname).
+		Set(float64(now.Unix()))
+	machine.LastSuccessfulUpdate = &now
+
+	err = h.TouchMachine(machine)
+	if err != nil {
+		log.Error().
+			Str("handler", "PollNetMapStream").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Str("channel", "update").
+			Err(err).
+			Msg("Cannot update machine LastSuccessfulUpdate")
+
+		return false
+	}
+
+	return true
+}
+
+// drainPollNetMapStream closes a stream when headscale shuts down.
+// It waits for a random delay within the drain period, so that the clients
+// do not all reconnect at the same time, and sends a last keep alive so
+// the stream is ended cleanly rather than reset.
+func (h *Headscale) drainPollNetMapStream(
+	ctx context.Context,
+	writer http.ResponseWriter,
+	mapRequest tailcfg.MapRequest,
+	machine *Machine,
+	isNoise bool,
+) {
+	if h.cfg.PollShutdownDrainPeriod > 0 {
+		//nolint:gosec // only used to spread the reconnections
+		delay := time.Duration(rand.Int63n(int64(h.cfg.PollShutdownDrainPeriod)))
+
+		select {
+		case <-ctx.Done():
 			return
+		case <-time.After(delay):
 		}
 	}
+
+	data, err := h.getMapKeepAliveResponseData(mapRequest, machine, isNoise)
+	if err != nil {
+		log.Error().
+			Str("handler", "PollNetMapStream").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Str("channel", "shutdown").
+			Err(err).
+			Msg("Error generating the last keep alive msg")
+
+		return
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		log.Error().
+			Str("handler", "PollNetMapStream").
+			Bool("noise", isNoise).
+			Str("machine", machine.Hostname).
+			Str("channel", "shutdown").
+			Err(err).
+			Msg("Cannot write the last keep alive message")
+
+		return
+	}
+
+	if flusher, ok := writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	keepAlivesSent.Inc()
+
+	log.Trace().
+		Str("handler", "PollNetMapStream").
+		Bool("noise", isNoise).
+		Str("machine", machine.Hostname).
+		Str("channel", "shutdown").
+		Msg("Stream drained")
 }
 
+// scheduledPollWorker sends the keep alives of a stream.
+// The update requests are sent by the notifier, see runNotifier.
+// keepAliveChan is closed by pollNetMapStream once the worker has returned.
 func (h *Headscale) scheduledPollWorker(
 	ctx context.Context,
-	updateChan chan struct{},
 	keepAliveChan chan []byte,
 	mapRequest tailcfg.MapRequest,
 	machine *Machine,
 	isNoise bool,
 ) {
-	keepAliveTicker := time.NewTicker(keepAliveInterval)
-	updateCheckerTicker := time.NewTicker(h.cfg.NodeUpdateCheckInterval)
-
-	defer closeChanWithLog(
-		updateChan,
-		fmt.Sprint(ctx.Value(machineNameContextKey)),
-		"updateChan",
-	)
-	defer closeChanWithLog(
-		keepAliveChan,
-		fmt.Sprint(ctx.Value(machineNameContextKey)),
-		"updateChan",
-	)
+	keepAliveTicker := time.NewTicker(h.keepAliveInterval())
+	defer keepAliveTicker.Stop()
 
 	for {
 		select {
@@ -643,17 +897,11 @@ func (h *Headscale) scheduledPollWorker(
 				Str("machine", machine.Hostname).
 				Bool("noise", isNoise).
 				Msg("Sending keepalive")
-			keepAliveChan <- data
-
-		case <-updateCheckerTicker.C:
-			log.Debug().
-				Str("func", "scheduledPollWorker").
-				Str("machine", machine.Hostname).
-				Bool("noise", isNoise).
-				Msg("Sending update request")
-			updateRequestsFromNode.WithLabelValues(machine.Namespace.Name, machine.Hostname, "scheduled-update").
-				Inc()
-			updateChan <- struct{}{}
+			select {
+			case keepAliveChan <- data:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,548 @@
+package headscale
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopkg.in/check.v1"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+)
+
+func (s *Suite) TestPollRejectsOversizedBody(c *check.C) {
+	app.cfg.PollMaxRequestBodySize = 1024
+
+	body := bytes.Repeat([]byte("a"), 2048)
+	req := httptest.NewRequest(http.MethodPost, "/machine/map", bytes.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	app.NoisePollNetMapHandler(recorder, req)
+
+	c.Assert(recorder.Code, check.Equals, http.StatusRequestEntityTooLarge)
+}
+
+func (s *Suite) TestPollAcceptsBodyWithinLimit(c *check.C) {
+	app.cfg.PollMaxRequestBodySize = 1024
+
+	body := bytes.Repeat([]byte("a"), 512)
+	req := httptest.NewRequest(http.MethodPost, "/machine/map", bytes.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	app.NoisePollNetMapHandler(recorder, req)
+
+	// The body is not a valid MapRequest, but it must get past the size check.
+	c.Assert(recorder.Code, check.Not(check.Equals), http.StatusRequestEntityTooLarge)
+}
+
+func (s *Suite) TestPollFromExpiredMachine(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	expiry := time.Now().Add(-time.Hour)
+	expired, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "expired",
+		GivenName:      "expired",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		Expiry:         &expiry,
+	})
+	c.Assert(err, check.IsNil)
+
+	valid, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "valid",
+		GivenName:      "valid",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+
+	poll := func(machine *Machine) *httptest.ResponseRecorder {
+		// Load the machine with its namespace, like the poll handlers do.
+		machine, err := app.GetMachineByID(machine.ID)
+		c.Assert(err, check.IsNil)
+
+		mapRequest := tailcfg.MapRequest{
+			Hostinfo:  &tailcfg.Hostinfo{Hostname: machine.Hostname},
+			OmitPeers: true,
+		}
+		recorder := httptest.NewRecorder()
+		app.handlePollCommon(recorder, context.Background(), machine, mapRequest, true)
+
+		return recorder
+	}
+
+	c.Assert(poll(expired).Code, check.Equals, http.StatusUnauthorized)
+	c.Assert(poll(valid).Code, check.Equals, http.StatusOK)
+
+	// The expired machine must not have been marked as seen.
+	expired, err = app.GetMachineByID(expired.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(expired.LastSeen, check.IsNil)
+}
+
+func (s *Suite) TestPollWithTooManyRoutes(c *check.C) {
+	app.cfg.MaxRoutesPerMachine = 1
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "router",
+		GivenName:      "router",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+	machine, err = app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo: &tailcfg.Hostinfo{
+			Hostname: machine.Hostname,
+			RoutableIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/24"),
+				netip.MustParsePrefix("10.0.1.0/24"),
+			},
+		},
+		OmitPeers: true,
+	}
+	recorder := httptest.NewRecorder()
+	app.handlePollCommon(recorder, context.Background(), machine, mapRequest, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, "(?s).*max_routes_per_machine is 1.*")
+
+	// the routes were not stored
+	machine, err = app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.GetAdvertisedRoutes(), check.HasLen, 0)
+}
+
+func (s *Suite) TestPollKeepsConcurrentAdminChanges(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		ForcedTags:     StringList{"tag:old"},
+	})
+	c.Assert(err, check.IsNil)
+
+	// The poll session works on its own copy of the machine...
+	session, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+
+	// ...while an admin changes the machine.
+	admin, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.SetTags(admin, []string{"tag:new"}), check.IsNil)
+	c.Assert(app.RenameMachine(admin, "renamed"), check.IsNil)
+
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo:  &tailcfg.Hostinfo{Hostname: "new-hostname"},
+		OmitPeers: true,
+	}
+	recorder := httptest.NewRecorder()
+	app.handlePollCommon(recorder, context.Background(), session, mapRequest, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+
+	saved, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(saved.ForcedTags, check.DeepEquals, StringList{"tag:new"})
+	c.Assert(saved.GivenName, check.Equals, "renamed")
+	// The columns owned by the poll are still updated.
+	c.Assert(saved.Hostname, check.Equals, "new-hostname")
+	c.Assert(saved.LastSeen, check.NotNil)
+}
+
+func (s *Suite) TestPollFromMachineWithoutNamespace(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "orphan",
+		GivenName:      "orphan",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+
+	// Remove the namespace behind the back of headscale.
+	c.Assert(app.db.Unscoped().Delete(&Namespace{}, namespace.ID).Error, check.IsNil)
+
+	orphan, err := app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo:  &tailcfg.Hostinfo{Hostname: orphan.Hostname},
+		OmitPeers: true,
+	}
+	recorder := httptest.NewRecorder()
+	app.handlePollCommon(recorder, context.Background(), orphan, mapRequest, true)
+
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *Suite) TestDrainPollNetMapStream(c *check.C) {
+	machine := &Machine{
+		MachineKey: MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		Hostname:   "testmachine",
+	}
+	mapRequest := tailcfg.MapRequest{}
+
+	keepAlives := testutil.ToFloat64(keepAlivesSent)
+
+	app.cfg.PollShutdownDrainPeriod = 0
+	recorder := httptest.NewRecorder()
+	app.drainPollNetMapStream(context.Background(), recorder, mapRequest, machine, true)
+	c.Assert(recorder.Body.Len() > 0, check.Equals, true)
+	c.Assert(recorder.Flushed, check.Equals, true)
+	c.Assert(testutil.ToFloat64(keepAlivesSent), check.Equals, keepAlives+1)
+
+	// a client disconnecting during the drain delay gets nothing
+	app.cfg.PollShutdownDrainPeriod = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder = httptest.NewRecorder()
+	app.drainPollNetMapStream(ctx, recorder, mapRequest, machine, true)
+	c.Assert(recorder.Body.Len(), check.Equals, 0)
+	c.Assert(testutil.ToFloat64(keepAlivesSent), check.Equals, keepAlives+1)
+}
+
+const errBrokenConnection = Error("broken connection")
+
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *failingResponseWriter) Write(data []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errBrokenConnection
+	}
+
+	return w.ResponseRecorder.Write(data)
+}
+
+func (s *Suite) TestPollNetMapStreamStopsKeepAlive(c *check.C) {
+	app.cfg.NodeKeepAliveInterval = time.Millisecond
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	registered, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+
+	stream := func(writer http.ResponseWriter, ctx context.Context) chan []byte {
+		machine, err := app.GetMachineByID(registered.ID)
+		c.Assert(err, check.IsNil)
+
+		pollDataChan := make(chan []byte)
+		keepAliveChan := make(chan []byte)
+		updateChan := make(chan struct{}, 1)
+		app.pollNetMapStream(
+			writer,
+			ctx,
+			machine,
+			tailcfg.MapRequest{},
+			pollDataChan,
+			keepAliveChan,
+			updateChan,
+			true,
+		)
+
+		return keepAliveChan
+	}
+
+	for i := 0; i < 20; i++ {
+		// the client goes away while keep alives are being sent
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		keepAliveChan := stream(httptest.NewRecorder(), ctx)
+		cancel()
+		_, open := <-keepAliveChan
+		c.Assert(open, check.Equals, false)
+
+		// the stream ends on a failed keep alive write
+		writer := &failingResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+		keepAliveChan = stream(writer, context.Background())
+		_, open = <-keepAliveChan
+		c.Assert(open, check.Equals, false)
+	}
+}
+
+// cancellingResponseWriter cancels the request once cancelAfter writes
+// have been done, like a client going away.
+type cancellingResponseWriter struct {
+	*httptest.ResponseRecorder
+	writes      int
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (w *cancellingResponseWriter) Write(data []byte) (int, error) {
+	w.writes++
+	if w.writes == w.cancelAfter {
+		defer w.cancel()
+	}
+
+	return w.ResponseRecorder.Write(data)
+}
+
+func (s *Suite) TestPollStreamWithoutPeers(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	register := func(hostname string) *Machine {
+		machine, err := app.RegisterMachine(Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       hostname,
+			GivenName:      hostname,
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		})
+		c.Assert(err, check.IsNil)
+
+		// Load the machine with its namespace, like the poll handlers do.
+		machine, err = app.GetMachineByID(machine.ID)
+		c.Assert(err, check.IsNil)
+
+		return machine
+	}
+	machine := register("testmachine")
+	register("peer")
+
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo: &tailcfg.Hostinfo{Hostname: machine.Hostname},
+		Stream:   true,
+	}
+	mapResponse, err := app.generateMapResponse(mapRequest, machine)
+	c.Assert(err, check.IsNil)
+	c.Assert(mapResponse.Peers, check.HasLen, 1)
+
+	mapRequest.OmitPeers = true
+	mapResponse, err = app.generateMapResponse(mapRequest, machine)
+	c.Assert(err, check.IsNil)
+	c.Assert(mapResponse.Node, check.NotNil)
+	c.Assert(mapResponse.Peers, check.HasLen, 0)
+
+	// Make the stream send an update after the initial map.
+	app.forcedUpdates.Store(machine.MachineKey, struct{}{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writer := &cancellingResponseWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		cancelAfter:      2,
+		cancel:           cancel,
+	}
+	app.handlePollCommon(writer, ctx, machine, mapRequest, true)
+	recorder := writer.ResponseRecorder
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+
+	// Both the initial map and the update sent after it have no peers.
+	body := recorder.Body.Bytes()
+	mapResponses := 0
+	for len(body) > 0 {
+		c.Assert(len(body) > reservedResponseHeaderSize, check.Equals, true)
+		size := binary.LittleEndian.Uint32(body[:reservedResponseHeaderSize])
+		body = body[reservedResponseHeaderSize:]
+
+		streamed := tailcfg.MapResponse{}
+		c.Assert(json.Unmarshal(body[:size], &streamed), check.IsNil)
+		body = body[size:]
+		if streamed.KeepAlive {
+			continue
+		}
+
+		c.Assert(streamed.Node, check.NotNil)
+		c.Assert(streamed.Peers, check.HasLen, 0)
+		mapResponses++
+	}
+	c.Assert(mapResponses, check.Equals, 2)
+}
+
+func (s *Suite) TestPollNetMapStreamSkipsUnchangedMap(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	register := func(hostname string) *Machine {
+		machine, err := app.RegisterMachine(Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       hostname,
+			GivenName:      hostname,
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		})
+		c.Assert(err, check.IsNil)
+
+		machine, err = app.GetMachineByID(machine.ID)
+		c.Assert(err, check.IsNil)
+
+		return machine
+	}
+	machine := register("testmachine")
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo: &tailcfg.Hostinfo{Hostname: machine.Hostname},
+		Stream:   true,
+	}
+
+	// stream runs the stream of the machine for an update request, after
+	// the client got lastMap
+	stream := func(lastMap []byte) []byte {
+		app.mapResponseHashes.Store(machine.MachineKey, sha256.Sum256(lastMap))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		updateChan := make(chan struct{}, 1)
+		updateChan <- struct{}{}
+		recorder := httptest.NewRecorder()
+		app.pollNetMapStream(
+			recorder,
+			ctx,
+			machine,
+			mapRequest,
+			make(chan []byte),
+			make(chan []byte),
+			updateChan,
+			true,
+		)
+
+		return recorder.Body.Bytes()
+	}
+
+	lastMap, err := app.getMapResponseJSON(mapRequest, machine)
+	c.Assert(err, check.IsNil)
+
+	// the state changed, but not the map of the machine
+	time.Sleep(time.Millisecond)
+	app.setLastStateChangeToNow()
+	c.Assert(stream(lastMap), check.HasLen, 0)
+	c.Assert(app.isOutdated(machine), check.Equals, false)
+
+	// a new peer changes the map
+	register("peer")
+	time.Sleep(time.Millisecond)
+	app.setLastStateChangeToNow()
+	body := stream(lastMap)
+	c.Assert(len(body) > reservedResponseHeaderSize, check.Equals, true)
+
+	streamed := tailcfg.MapResponse{}
+	c.Assert(json.Unmarshal(body[reservedResponseHeaderSize:], &streamed), check.IsNil)
+	c.Assert(streamed.Peers, check.HasLen, 1)
+	c.Assert(app.isOutdated(machine), check.Equals, false)
+}
+
+func (s *Suite) TestEphemeralMachineDeletedOnDisconnect(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, true, nil, "", 0, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "ephemeral",
+		GivenName:      "ephemeral",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		AuthKeyID:      uint(pak.ID),
+	})
+	c.Assert(err, check.IsNil)
+
+	machine, err = app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.isEphemeralMachine(machine), check.Equals, true)
+
+	mapRequest := tailcfg.MapRequest{
+		Hostinfo: &tailcfg.Hostinfo{Hostname: machine.Hostname},
+		Stream:   true,
+	}
+
+	// disconnect runs the stream of the machine until the client closes it
+	disconnect := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		app.pollNetMapStream(
+			httptest.NewRecorder(),
+			ctx,
+			machine,
+			mapRequest,
+			make(chan []byte),
+			make(chan []byte),
+			make(chan struct{}, 1),
+			true,
+		)
+	}
+
+	// a quick reconnect cancels the deletion
+	app.cfg.EphemeralNodeDisconnectGracePeriod = time.Hour
+	disconnect()
+
+	readOnly := tailcfg.MapRequest{
+		Hostinfo: &tailcfg.Hostinfo{Hostname: machine.Hostname},
+		ReadOnly: true,
+	}
+	recorder := httptest.NewRecorder()
+	app.handlePollCommon(recorder, context.Background(), machine, readOnly, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(app.cancelEphemeralMachineDeletion(machine.MachineKey), check.Equals, false)
+
+	// otherwise the machine is deleted once the grace period is over
+	app.cfg.EphemeralNodeDisconnectGracePeriod = 10 * time.Millisecond
+	disconnect()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = app.GetMachineByID(machine.ID)
+		if err != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(err, check.NotNil)
+
+	// a machine registered without an ephemeral key is kept
+	c.Assert(app.isEphemeralMachine(&Machine{}), check.Equals, false)
+}
@@ -10,22 +10,63 @@ import (
 	"tailscale.com/types/key"
 )
 
+// zstdEncoder compresses the map responses of the clients asking for zstd.
+// EncodeAll is safe for concurrent use, and creating an encoder for every
+// response is far more expensive than the compression itself.
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
 func (h *Headscale) getMapResponseData(
 	mapRequest tailcfg.MapRequest,
 	machine *Machine,
 	isNoise bool,
+) ([]byte, error) {
+	jsonBody, err := h.getMapResponseJSON(mapRequest, machine)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.encodeMapResponse(jsonBody, mapRequest, machine, isNoise)
+}
+
+// getMapResponseJSON returns the map response of the machine as JSON, before
+// it is compressed and sealed, so that it can be compared with the last one
+// sent to the machine.
+func (h *Headscale) getMapResponseJSON(
+	mapRequest tailcfg.MapRequest,
+	machine *Machine,
 ) ([]byte, error) {
 	mapResponse, err := h.generateMapResponse(mapRequest, machine)
 	if err != nil {
 		return nil, err
 	}
 
+	jsonBody, err := json.Marshal(mapResponse)
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("Cannot marshal map response")
+
+		return nil, err
+	}
+
+	return jsonBody, nil
+}
+
+// encodeMapResponse compresses and seals the JSON map response for the
+// machine, as asked in its map request.
+func (h *Headscale) encodeMapResponse(
+	jsonBody []byte,
+	mapRequest tailcfg.MapRequest,
+	machine *Machine,
+	isNoise bool,
+) ([]byte, error) {
 	if isNoise {
-		return h.marshalMapResponse(mapResponse, key.MachinePublic{}, mapRequest.Compress)
+		return h.encodeMapResponseBody(jsonBody, key.MachinePublic{}, mapRequest.Compress), nil
 	}
 
 	var machineKey key.MachinePublic
-	err = machineKey.UnmarshalText([]byte(MachinePublicKeyEnsurePrefix(machine.MachineKey)))
+	err := machineKey.UnmarshalText([]byte(MachinePublicKeyEnsurePrefix(machine.MachineKey)))
 	if err != nil {
 		log.Error().
 			Caller().
@@ -35,7 +76,7 @@ func (h *Headscale) getMapResponseData(
 		return nil, err
 	}
 
-	return h.marshalMapResponse(mapResponse, machineKey, mapRequest.Compress)
+	return h.encodeMapResponseBody(jsonBody, machineKey, mapRequest.Compress), nil
 }
 
 func (h *Headscale) getMapKeepAliveResponseData(
@@ -97,12 +138,23 @@ func (h *Headscale) marshalMapResponse(
 			Caller().
 			Err(err).
 			Msg("Cannot marshal map response")
+
+		return nil, err
 	}
 
+	return h.encodeMapResponseBody(jsonBody, machineKey, compression), nil
+}
+
+// encodeMapResponseBody compresses the JSON body if asked to, seals it for
+// the legacy protocol, and prefixes it with its size.
+func (h *Headscale) encodeMapResponseBody(
+	jsonBody []byte,
+	machineKey key.MachinePublic,
+	compression string,
+) []byte {
 	var respBody []byte
 	if compression == ZstdCompression {
-		encoder, _ := zstd.NewWriter(nil)
-		respBody = encoder.EncodeAll(jsonBody, nil)
+		respBody = zstdEncoder.EncodeAll(jsonBody, nil)
 		if !machineKey.IsZero() { // if legacy protocol
 			respBody = h.privateKey.SealTo(machineKey, respBody)
 		}
@@ -118,5 +170,5 @@ func (h *Headscale) marshalMapResponse(
 	binary.LittleEndian.PutUint32(data, uint32(len(respBody)))
 	data = append(data, respBody...)
 
-	return data, nil
+	return data
 }
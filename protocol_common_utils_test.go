@@ -0,0 +1,96 @@
+package headscale
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/check.v1"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+)
+
+func (s *Suite) TestMarshalMapResponseZstd(c *check.C) {
+	mapResponse := tailcfg.MapResponse{Domain: "example.com"}
+
+	data, err := app.marshalMapResponse(mapResponse, key.MachinePublic{}, ZstdCompression)
+	c.Assert(err, check.IsNil)
+	c.Assert(
+		binary.LittleEndian.Uint32(data),
+		check.Equals,
+		uint32(len(data)-reservedResponseHeaderSize),
+	)
+
+	decoder, err := zstd.NewReader(nil)
+	c.Assert(err, check.IsNil)
+	defer decoder.Close()
+
+	jsonBody, err := decoder.DecodeAll(data[reservedResponseHeaderSize:], nil)
+	c.Assert(err, check.IsNil)
+
+	var decoded tailcfg.MapResponse
+	c.Assert(json.Unmarshal(jsonBody, &decoded), check.IsNil)
+	c.Assert(decoded.Domain, check.Equals, "example.com")
+}
+
+// BenchmarkMarshalMapResponse measures the size on the wire of the map
+// of a 200 peers tailnet, with and without zstd compression.
+func BenchmarkMarshalMapResponse(b *testing.B) {
+	const peers = 200
+
+	now := time.Now()
+	mapResponse := tailcfg.MapResponse{
+		Peers: make([]*tailcfg.Node, 0, peers),
+	}
+	for i := 0; i < peers; i++ {
+		hostname := fmt.Sprintf("machine-%d", i)
+		machine := Machine{
+			ID:          uint64(i),
+			MachineKey:  MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:     NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:    DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:    hostname,
+			GivenName:   hostname,
+			Namespace:   Namespace{Name: "bench"},
+			IPAddresses: MachineAddresses{netip.AddrFrom4([4]byte{100, 64, byte(i / 256), byte(i % 256)})},
+			Endpoints:   StringList{fmt.Sprintf("192.0.2.%d:41641", i%256)},
+			LastSeen:    &now,
+			HostInfo: HostInfo(tailcfg.Hostinfo{
+				Hostname:   hostname,
+				OS:         "linux",
+				IPNVersion: "1.30.0",
+			}),
+		}
+
+		node, err := machine.toNode("", nil, true, defaultKeepAliveInterval)
+		if err != nil {
+			b.Fatal(err)
+		}
+		mapResponse.Peers = append(mapResponse.Peers, node)
+	}
+
+	h := Headscale{}
+
+	for _, compression := range []string{"", ZstdCompression} {
+		name := compression
+		if name == "" {
+			name = "none"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			var size int
+			for n := 0; n < b.N; n++ {
+				data, err := h.marshalMapResponse(mapResponse, key.MachinePublic{}, compression)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "wire-bytes/op")
+		})
+	}
+}
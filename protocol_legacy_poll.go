@@ -2,7 +2,6 @@ package headscale
 
 import (
 	"errors"
-	"io"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -39,7 +38,10 @@ func (h *Headscale) PollNetMapHandler(
 		Str("handler", "PollNetMap").
 		Str("id", machineKeyStr).
 		Msg("PollNetMapHandler called")
-	body, _ := io.ReadAll(req.Body)
+	body, ok := h.readPollRequestBody(writer, req, "PollNetMap")
+	if !ok {
+		return
+	}
 
 	var machineKey key.MachinePublic
 	err := machineKey.UnmarshalText([]byte(MachinePublicKeyEnsurePrefix(machineKeyStr)))
@@ -84,6 +86,8 @@ func (h *Headscale) PollNetMapHandler(
 		return
 	}
 
+	setAccessLogMachine(writer, machine)
+
 	log.Trace().
 		Str("handler", "PollNetMap").
 		Str("id", machineKeyStr).
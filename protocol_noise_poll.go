@@ -3,7 +3,6 @@ package headscale
 import (
 	"encoding/json"
 	"errors"
-	"io"
 	"net/http"
 
 	"github.com/rs/zerolog/log"
@@ -28,7 +27,10 @@ func (h *Headscale) NoisePollNetMapHandler(
 	log.Trace().
 		Str("handler", "NoisePollNetMap").
 		Msg("PollNetMapHandler called")
-	body, _ := io.ReadAll(req.Body)
+	body, ok := h.readPollRequestBody(writer, req, "NoisePollNetMap")
+	if !ok {
+		return
+	}
 
 	mapRequest := tailcfg.MapRequest{}
 	if err := json.Unmarshal(body, &mapRequest); err != nil {
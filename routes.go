@@ -3,12 +3,21 @@ package headscale
 import (
 	"fmt"
 	"net/netip"
+	"sort"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/rs/zerolog/log"
 )
 
 const (
-	ErrRouteIsNotAvailable = Error("route is not available")
+	ErrRouteIsNotAvailable            = Error("route is not available")
+	ErrMachineExitRoutesNotAdvertised = Error("machine does not advertise the exit routes")
 )
 
+// exitRoutes are the default routes of an exit node. The clients only offer
+// an exit node when it has both of them.
+var exitRoutes = []netip.Prefix{ExitRouteV4, ExitRouteV6}
+
 // Deprecated: use machine function instead
 // GetAdvertisedNodeRoutes returns the subnet routes advertised by a node (identified by
 // namespace and node name).
@@ -116,3 +125,227 @@ func (h *Headscale) EnableNodeRoute(
 
 	return nil
 }
+
+// advertisesExitRoutes reports whether the machine offers to be an exit node.
+func (machine *Machine) advertisesExitRoutes() bool {
+	advertisedRoutes := machine.GetAdvertisedRoutes()
+	for _, route := range exitRoutes {
+		if !contains(advertisedRoutes, route) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isExitNode reports whether the exit routes have been enabled for the
+// machine, i.e. whether it has been approved as an exit node.
+func (machine *Machine) isExitNode() bool {
+	for _, route := range exitRoutes {
+		if !contains(machine.GetEnabledRoutes(), route) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withExitRoutes adds the exit routes to the routes to enable on the
+// machine, approving it as an exit node.
+func (machine *Machine) withExitRoutes(routeStrs []string) ([]string, error) {
+	if !machine.advertisesExitRoutes() {
+		return nil, fmt.Errorf("%w: %s", ErrMachineExitRoutesNotAdvertised, machine.Hostname)
+	}
+
+	newRoutes := append([]string{}, routeStrs...)
+	for _, route := range exitRoutes {
+		if !contains(newRoutes, route.String()) {
+			newRoutes = append(newRoutes, route.String())
+		}
+	}
+
+	return newRoutes, nil
+}
+
+// enableAutoApprovedRoutes enables the routes advertised by the machine that
+// the autoApprovers of the ACL policy approve for it. The routes already
+// enabled are left as they are.
+func (h *Headscale) enableAutoApprovedRoutes(machine *Machine) error {
+	policy := h.getACLPolicy()
+	if policy == nil || policy.AutoApprovers.IsZero() || len(machine.IPAddresses) == 0 {
+		return nil
+	}
+
+	approvedRoutes := []netip.Prefix{}
+	for _, route := range machine.GetAdvertisedRoutes() {
+		if contains(machine.GetEnabledRoutes(), route) {
+			continue
+		}
+
+		approvers, err := policy.AutoApprovers.routeApprovers(route)
+		if err != nil {
+			return err
+		}
+
+		for _, alias := range approvers {
+			// the wildcard expands to itself rather than to addresses
+			if alias == "*" || alias == machine.Namespace.Name {
+				approvedRoutes = append(approvedRoutes, route)
+
+				break
+			}
+
+			// the machine matches the alias if its addresses are part
+			// of its expansion
+			ips, err := expandAlias(
+				[]Machine{*machine},
+				*policy,
+				alias,
+				h.cfg.OIDC.StripEmaildomain,
+			)
+			if err != nil {
+				return err
+			}
+			if contains(ips, machine.IPAddresses[0].String()) {
+				approvedRoutes = append(approvedRoutes, route)
+
+				break
+			}
+		}
+	}
+
+	if len(approvedRoutes) == 0 {
+		return nil
+	}
+
+	log.Info().
+		Str("machine", machine.Hostname).
+		Strs("routes", ipPrefixToString(approvedRoutes)).
+		Msg("Enabling auto approved routes")
+
+	routes := append(append([]netip.Prefix{}, machine.GetEnabledRoutes()...), approvedRoutes...)
+
+	return h.EnableRoutes(machine, ipPrefixToString(routes)...)
+}
+
+// canUseExitNodes reports whether the ACLs let the machine reach the internet,
+// either through autogroup:internet, an exit route or a wildcard destination.
+// Without a policy, every machine can use every exit node.
+func (h *Headscale) canUseExitNodes(machine *Machine) bool {
+	aclPolicy, aclRules, _ := h.getACL()
+	if aclPolicy == nil {
+		return true
+	}
+
+	internet := append(
+		[]string{"*", ExitRouteV4.String(), ExitRouteV6.String()},
+		internetPrefixes...,
+	)
+	machineIPs := append([]string{"*"}, machine.IPAddresses.ToStringSlice()...)
+	for _, rule := range aclRules {
+		if !containsAddresses(rule.SrcIPs, machineIPs) {
+			continue
+		}
+
+		for _, dst := range rule.DstPorts {
+			if contains(internet, dst.IP) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getAvailableExitNodes returns the exit nodes the machine is allowed to use,
+// sorted by ID.
+func (h *Headscale) getAvailableExitNodes(machine *Machine) (Machines, error) {
+	exitNodes := Machines{}
+	if !h.canUseExitNodes(machine) {
+		return exitNodes, nil
+	}
+
+	machines, err := h.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range machines {
+		if candidate.ID == machine.ID || candidate.isExpired() || !candidate.isExitNode() {
+			continue
+		}
+
+		exitNodes = append(exitNodes, candidate)
+	}
+
+	sort.Slice(exitNodes, func(i, j int) bool { return exitNodes[i].ID < exitNodes[j].ID })
+
+	return exitNodes, nil
+}
+
+// routeMatchType tells how the route of a machine overlaps the prefix, it is
+// ROUTE_MATCH_TYPE_UNSPECIFIED when they do not overlap.
+func routeMatchType(route netip.Prefix, prefix netip.Prefix) v1.RouteMatchType {
+	route, prefix = route.Masked(), prefix.Masked()
+
+	switch {
+	case route == prefix:
+		return v1.RouteMatchType_ROUTE_MATCH_TYPE_EQUAL
+	case route.Bits() < prefix.Bits() && route.Contains(prefix.Addr()):
+		return v1.RouteMatchType_ROUTE_MATCH_TYPE_ROUTE_CONTAINS_PREFIX
+	case prefix.Bits() < route.Bits() && prefix.Contains(route.Addr()):
+		return v1.RouteMatchType_ROUTE_MATCH_TYPE_PREFIX_CONTAINS_ROUTE
+	}
+
+	return v1.RouteMatchType_ROUTE_MATCH_TYPE_UNSPECIFIED
+}
+
+// listMachinesByRoute returns the machines advertising or having enabled a
+// route overlapping the prefix, with the matching routes. The exit routes
+// contain every prefix, so they only match when an exit route is searched.
+func (h *Headscale) listMachinesByRoute(
+	prefix netip.Prefix,
+) ([]*v1.MachineRouteMatches, error) {
+	machines, err := h.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	searchesExitRoute := contains(exitRoutes, prefix.Masked())
+
+	matches := []*v1.MachineRouteMatches{}
+	for _, machine := range machines {
+		routes := []*v1.RouteMatch{}
+		for _, status := range machine.routeStatusesToProto() {
+			if status.GetExitRoute() && !searchesExitRoute {
+				continue
+			}
+
+			route, err := netip.ParsePrefix(status.GetPrefix())
+			if err != nil {
+				return nil, err
+			}
+
+			matchType := routeMatchType(route, prefix)
+			if matchType == v1.RouteMatchType_ROUTE_MATCH_TYPE_UNSPECIFIED {
+				continue
+			}
+
+			routes = append(routes, &v1.RouteMatch{
+				Route:      status.GetPrefix(),
+				Advertised: status.GetAdvertised(),
+				Enabled:    status.GetEnabled(),
+				MatchType:  matchType,
+			})
+		}
+
+		if len(routes) > 0 {
+			matches = append(matches, &v1.MachineRouteMatches{
+				Machine: machine.toProto(),
+				Routes:  routes,
+			})
+		}
+	}
+
+	return matches, nil
+}
@@ -1,17 +1,27 @@
 package headscale
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/netip"
+	"time"
 
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gopkg.in/check.v1"
 	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
 )
 
 func (s *Suite) TestGetRoutes(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "test_get_route_machine")
@@ -55,7 +65,7 @@ func (s *Suite) TestGetEnableRoutes(c *check.C) {
 	namespace, err := app.CreateNamespace("test")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "test_enable_route_machine")
@@ -133,3 +143,438 @@ func (s *Suite) TestGetEnableRoutes(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(len(enabledRoutesWithAdditionalRoute), check.Equals, 2)
 }
+
+func (s *Suite) TestEnableRoutesOverLimit(c *check.C) {
+	app.cfg.MaxRoutesPerMachine = 2
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	routes := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("10.0.1.0/24"),
+		netip.MustParsePrefix("10.0.2.0/24"),
+	}
+
+	machine := Machine{
+		ID:             0,
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "test_route_limit_machine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		HostInfo:       HostInfo(tailcfg.Hostinfo{RoutableIPs: routes}),
+	}
+	app.db.Save(&machine)
+
+	err = app.EnableRoutes(&machine, "10.0.0.0/24", "10.0.1.0/24")
+	c.Assert(err, check.IsNil)
+
+	err = app.EnableRoutes(&machine, "10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24")
+	c.Assert(errors.Is(err, ErrMachineTooManyRoutes), check.Equals, true)
+	c.Assert(machine.GetEnabledRoutes(), check.HasLen, 2)
+
+	hostinfo := tailcfg.Hostinfo{RoutableIPs: routes}
+	err = app.checkAdvertisedRoutes(&hostinfo)
+	c.Assert(errors.Is(err, ErrMachineTooManyRoutes), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, ".*max_routes_per_machine is 2.*")
+
+	hostinfo.RoutableIPs = routes[:2]
+	c.Assert(app.checkAdvertisedRoutes(&hostinfo), check.IsNil)
+}
+
+func (s *Suite) TestGetAvailableExitNodes(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	expiry := time.Now().Add(-time.Hour)
+	machines := make([]Machine, 4)
+	for index := range machines {
+		machines[index] = Machine{
+			ID:          uint64(index + 1),
+			MachineKey:  fmt.Sprintf("foo%d", index),
+			NodeKey:     fmt.Sprintf("bar%d", index),
+			DiscoKey:    fmt.Sprintf("faa%d", index),
+			Hostname:    fmt.Sprintf("machine%d", index),
+			NamespaceID: namespace.ID,
+			IPAddresses: MachineAddresses{
+				netip.MustParseAddr(fmt.Sprintf("100.64.0.%d", index+1)),
+			},
+		}
+	}
+	// machine1 and machine3 are exit nodes, but machine3 has expired.
+	machines[1].EnabledRoutes = IPPrefixes{ExitRouteV4, ExitRouteV6}
+	machines[2].EnabledRoutes = IPPrefixes{netip.MustParsePrefix("10.0.0.0/24")}
+	machines[3].EnabledRoutes = IPPrefixes{ExitRouteV4}
+	machines[3].Expiry = &expiry
+	for index := range machines {
+		app.db.Save(&machines[index])
+	}
+
+	ids := func(machines Machines) []uint64 {
+		result := []uint64{}
+		for _, machine := range machines {
+			result = append(result, machine.ID)
+		}
+
+		return result
+	}
+
+	exitNodes, err := app.getAvailableExitNodes(&machines[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(ids(exitNodes), check.DeepEquals, []uint64{2})
+
+	// The exit node cannot use itself.
+	exitNodes, err = app.getAvailableExitNodes(&machines[1])
+	c.Assert(err, check.IsNil)
+	c.Assert(ids(exitNodes), check.DeepEquals, []uint64{})
+
+	app.aclPolicy = &ACLPolicy{
+		ACLs: []ACL{
+			{
+				Action:       "accept",
+				Sources:      []string{"100.64.0.1"},
+				Destinations: []string{"autogroup:internet:*"},
+			},
+			{
+				Action:       "accept",
+				Sources:      []string{"100.64.0.3"},
+				Destinations: []string{"100.64.0.1:22"},
+			},
+		},
+	}
+	c.Assert(app.UpdateACLRules(), check.IsNil)
+
+	exitNodes, err = app.getAvailableExitNodes(&machines[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(ids(exitNodes), check.DeepEquals, []uint64{2})
+
+	exitNodes, err = app.getAvailableExitNodes(&machines[2])
+	c.Assert(err, check.IsNil)
+	c.Assert(ids(exitNodes), check.DeepEquals, []uint64{})
+}
+
+func (s *Suite) TestEnableRoutesBatch(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machines := make([]Machine, 2)
+	for index := range machines {
+		route := netip.MustParsePrefix(fmt.Sprintf("10.0.%d.0/24", index))
+		machines[index] = Machine{
+			ID:             0,
+			MachineKey:     fmt.Sprintf("foo%d", index),
+			NodeKey:        fmt.Sprintf("bar%d", index),
+			DiscoKey:       fmt.Sprintf("faa%d", index),
+			Hostname:       fmt.Sprintf("test_batch_machine_%d", index),
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+			HostInfo:       HostInfo(tailcfg.Hostinfo{RoutableIPs: []netip.Prefix{route}}),
+		}
+		app.db.Save(&machines[index])
+	}
+
+	// the second entry fails, the first one must be rolled back
+	_, err = app.EnableRoutesBatch([]MachineRoutes{
+		{MachineID: machines[0].ID, Routes: []string{"10.0.0.0/24"}},
+		{MachineID: machines[1].ID, Routes: []string{"192.168.0.0/24"}},
+	})
+	var batchErr RoutesBatchError
+	c.Assert(errors.As(err, &batchErr), check.Equals, true)
+	c.Assert(batchErr.Index, check.Equals, 1)
+	c.Assert(batchErr.MachineID, check.Equals, machines[1].ID)
+	c.Assert(errors.Is(err, ErrMachineRouteIsNotAvailable), check.Equals, true)
+
+	machine, err := app.GetMachineByID(machines[0].ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.GetEnabledRoutes(), check.HasLen, 0)
+
+	_, err = app.EnableRoutesBatch([]MachineRoutes{
+		{MachineID: 1000, Routes: []string{"10.0.0.0/24"}},
+	})
+	c.Assert(errors.Is(err, ErrMachineNotFound), check.Equals, true)
+
+	updated, err := app.EnableRoutesBatch([]MachineRoutes{
+		{MachineID: machines[0].ID, Routes: []string{"10.0.0.0/24"}},
+		{MachineID: machines[1].ID, Routes: []string{"10.0.1.0/24"}},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(updated, check.HasLen, 2)
+	c.Assert(app.getLastStateChange(namespace.Name).IsZero(), check.Equals, false)
+
+	for index := range machines {
+		machine, err := app.GetMachineByID(machines[index].ID)
+		c.Assert(err, check.IsNil)
+		c.Assert(machine.GetEnabledRoutes(), check.DeepEquals, machines[index].GetAdvertisedRoutes())
+	}
+}
+
+func (s *Suite) TestExitNodeApproval(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	subnet := netip.MustParsePrefix("10.0.0.0/24")
+	machine := Machine{
+		ID:             0,
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "test_exit_machine",
+		GivenName:      "test_exit_machine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		IPAddresses:    MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		HostInfo: HostInfo(tailcfg.Hostinfo{
+			RoutableIPs: []netip.Prefix{subnet, ExitRouteV4, ExitRouteV6},
+		}),
+	}
+	app.db.Save(&machine)
+
+	c.Assert(machine.advertisesExitRoutes(), check.Equals, true)
+	c.Assert(machine.isExitNode(), check.Equals, false)
+
+	// the exit routes are advertised, but not approved
+	err = app.EnableRoutes(&machine, subnet.String())
+	c.Assert(err, check.IsNil)
+
+	node, err := machine.toNode("", nil, true, defaultKeepAliveInterval)
+	c.Assert(err, check.IsNil)
+	c.Assert(contains(node.AllowedIPs, ExitRouteV4), check.Equals, false)
+	c.Assert(contains(node.AllowedIPs, ExitRouteV6), check.Equals, false)
+	c.Assert(contains(node.AllowedIPs, subnet), check.Equals, true)
+
+	// a single default route does not make an exit node
+	err = app.EnableRoutes(&machine, ExitRouteV4.String())
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.isExitNode(), check.Equals, false)
+
+	node, err = machine.toNode("", nil, true, defaultKeepAliveInterval)
+	c.Assert(err, check.IsNil)
+	c.Assert(contains(node.AllowedIPs, ExitRouteV4), check.Equals, false)
+
+	routes, err := machine.withExitRoutes([]string{subnet.String()})
+	c.Assert(err, check.IsNil)
+	err = app.EnableRoutes(&machine, routes...)
+	c.Assert(err, check.IsNil)
+	c.Assert(machine.isExitNode(), check.Equals, true)
+	c.Assert(machine.RoutesToProto().GetExitNode(), check.Equals, true)
+
+	node, err = machine.toNode("", nil, true, defaultKeepAliveInterval)
+	c.Assert(err, check.IsNil)
+	c.Assert(contains(node.AllowedIPs, ExitRouteV4), check.Equals, true)
+	c.Assert(contains(node.AllowedIPs, ExitRouteV6), check.Equals, true)
+	c.Assert(node.PrimaryRoutes, check.DeepEquals, []netip.Prefix{subnet})
+
+	machine.HostInfo = HostInfo(tailcfg.Hostinfo{RoutableIPs: []netip.Prefix{subnet}})
+	_, err = machine.withExitRoutes([]string{subnet.String()})
+	c.Assert(errors.Is(err, ErrMachineExitRoutesNotAdvertised), check.Equals, true)
+}
+
+func (s *Suite) TestRouteStatusesToProto(c *check.C) {
+	subnet := netip.MustParsePrefix("10.0.0.0/24")
+	withdrawn := netip.MustParsePrefix("192.168.0.0/24")
+	machine := Machine{
+		HostInfo: HostInfo(tailcfg.Hostinfo{
+			RoutableIPs: []netip.Prefix{subnet, ExitRouteV4, ExitRouteV6},
+		}),
+		// the machine stopped advertising the second route once enabled
+		EnabledRoutes: IPPrefixes{subnet, withdrawn},
+	}
+
+	routes := machine.RoutesToProto()
+	c.Assert(routes.GetRouteStatuses(), check.HasLen, 4)
+
+	statuses := make([][]interface{}, 0, len(routes.GetRouteStatuses()))
+	for _, route := range routes.GetRouteStatuses() {
+		statuses = append(statuses, []interface{}{
+			route.GetPrefix(),
+			route.GetAdvertised(),
+			route.GetEnabled(),
+			route.GetExitRoute(),
+		})
+	}
+	c.Assert(statuses, check.DeepEquals, [][]interface{}{
+		{"10.0.0.0/24", true, true, false},
+		{"0.0.0.0/0", true, false, true},
+		{"::/0", true, false, true},
+		{"192.168.0.0/24", false, true, false},
+	})
+}
+
+func (s *Suite) TestAutoApproveRoutes(c *check.C) {
+	policy, err := parseACLPolicy([]byte(`{
+		"tagOwners": {"tag:router": ["test"]},
+		"acls": [{"action": "accept", "src": ["*"], "dst": ["*:*"]}],
+		"autoApprovers": {
+			"routes": {"10.10.0.0/24": ["tag:router"]},
+			"exitNode": ["tag:router"],
+		},
+	}`), "")
+	c.Assert(err, check.IsNil)
+	app.aclPolicy = &policy
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	subnet := netip.MustParsePrefix("10.10.0.0/24")
+	wider := netip.MustParsePrefix("10.10.0.0/16")
+	hostInfo := tailcfg.Hostinfo{
+		Hostname:    "router",
+		RoutableIPs: []netip.Prefix{subnet, wider, ExitRouteV4, ExitRouteV6},
+	}
+
+	machineIDs := []uint64{}
+	for index, tags := range []StringList{{"tag:router"}, nil} {
+		machine, err := app.RegisterMachine(Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       "router",
+			GivenName:      fmt.Sprintf("router-%d", index),
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+			IPAddresses:    MachineAddresses{netip.AddrFrom4([4]byte{100, 64, 0, byte(index + 1)})},
+			ForcedTags:     tags,
+		})
+		c.Assert(err, check.IsNil)
+		machineIDs = append(machineIDs, machine.ID)
+
+		session, err := app.GetMachineByID(machine.ID)
+		c.Assert(err, check.IsNil)
+
+		mapRequest := tailcfg.MapRequest{Hostinfo: &hostInfo, OmitPeers: true}
+		recorder := httptest.NewRecorder()
+		app.handlePollCommon(recorder, context.Background(), session, mapRequest, true)
+		c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	}
+
+	router, err := app.GetMachineByID(machineIDs[0])
+	c.Assert(err, check.IsNil)
+	// the /24 is approved, the wider /16 is left to an admin
+	c.Assert(
+		[]netip.Prefix(router.EnabledRoutes),
+		check.DeepEquals,
+		[]netip.Prefix{subnet, ExitRouteV4, ExitRouteV6},
+	)
+	c.Assert(router.isExitNode(), check.Equals, true)
+
+	untagged, err := app.GetMachineByID(machineIDs[1])
+	c.Assert(err, check.IsNil)
+	c.Assert(untagged.EnabledRoutes, check.HasLen, 0)
+
+	_, _, err = app.CheckACLPolicy([]byte(`{
+		"acls": [{"action": "accept", "src": ["*"], "dst": ["*:*"]}],
+		"autoApprovers": {"routes": {"10.10.0.0": ["test"]}},
+	}`))
+	c.Assert(errors.Is(err, errInvalidAutoApprover), check.Equals, true)
+}
+
+func (s *Suite) TestAutoApproveRoutesWildcard(c *check.C) {
+	// a policy with only auto approvers is not empty
+	policy, err := parseACLPolicy([]byte(`{
+		"autoApprovers": {"routes": {"10.20.0.0/24": ["*"]}},
+	}`), "")
+	c.Assert(err, check.IsNil)
+	c.Assert(policy.IsZero(), check.Equals, false)
+	app.aclPolicy = &policy
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	subnet := netip.MustParsePrefix("10.20.0.0/24")
+	registered, err := app.RegisterMachine(Machine{
+		MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+		NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+		DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+		Hostname:       "router",
+		GivenName:      "router",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+		IPAddresses:    MachineAddresses{netip.MustParseAddr("100.64.0.1")},
+		HostInfo: HostInfo{
+			RoutableIPs: []netip.Prefix{subnet},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	router, err := app.GetMachineByID(registered.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.enableAutoApprovedRoutes(router), check.IsNil)
+
+	router, err = app.GetMachineByID(registered.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert([]netip.Prefix(router.EnabledRoutes), check.DeepEquals, []netip.Prefix{subnet})
+}
+
+func (s *Suite) TestListMachinesByRoute(c *check.C) {
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	routes := [][]netip.Prefix{
+		{netip.MustParsePrefix("10.0.0.0/8")},
+		{netip.MustParsePrefix("10.1.0.0/16")},
+		{netip.MustParsePrefix("10.1.2.0/24"), netip.MustParsePrefix("192.168.0.0/24")},
+		{ExitRouteV4, ExitRouteV6},
+	}
+	for index, machineRoutes := range routes {
+		machine := Machine{
+			ID:          uint64(index + 1),
+			MachineKey:  fmt.Sprintf("foo%d", index),
+			NodeKey:     fmt.Sprintf("bar%d", index),
+			DiscoKey:    fmt.Sprintf("faa%d", index),
+			Hostname:    fmt.Sprintf("machine%d", index),
+			NamespaceID: namespace.ID,
+			HostInfo:    HostInfo(tailcfg.Hostinfo{RoutableIPs: machineRoutes}),
+		}
+		// the /16 is enabled but no longer advertised
+		if index == 1 {
+			machine.HostInfo = HostInfo{}
+			machine.EnabledRoutes = machineRoutes
+		}
+		app.db.Save(&machine)
+	}
+
+	api := newHeadscaleV1APIServer(&app)
+	matches := func(prefix string) [][]interface{} {
+		response, err := api.ListMachinesByRoute(
+			context.Background(),
+			&v1.ListMachinesByRouteRequest{Prefix: prefix},
+		)
+		c.Assert(err, check.IsNil)
+
+		result := [][]interface{}{}
+		for _, machine := range response.GetMachines() {
+			for _, route := range machine.GetRoutes() {
+				result = append(result, []interface{}{
+					machine.GetMachine().GetId(),
+					route.GetRoute(),
+					route.GetAdvertised(),
+					route.GetEnabled(),
+					route.GetMatchType(),
+				})
+			}
+		}
+
+		return result
+	}
+
+	c.Assert(matches("10.1.0.0/16"), check.DeepEquals, [][]interface{}{
+		{uint64(1), "10.0.0.0/8", true, false, v1.RouteMatchType_ROUTE_MATCH_TYPE_ROUTE_CONTAINS_PREFIX},
+		{uint64(2), "10.1.0.0/16", false, true, v1.RouteMatchType_ROUTE_MATCH_TYPE_EQUAL},
+		{uint64(3), "10.1.2.0/24", true, false, v1.RouteMatchType_ROUTE_MATCH_TYPE_PREFIX_CONTAINS_ROUTE},
+	})
+	c.Assert(matches("192.168.0.128/25"), check.DeepEquals, [][]interface{}{
+		{uint64(3), "192.168.0.0/24", true, false, v1.RouteMatchType_ROUTE_MATCH_TYPE_ROUTE_CONTAINS_PREFIX},
+	})
+	c.Assert(matches("172.16.0.0/12"), check.HasLen, 0)
+	c.Assert(matches("::/0"), check.DeepEquals, [][]interface{}{
+		{uint64(4), "::/0", true, false, v1.RouteMatchType_ROUTE_MATCH_TYPE_EQUAL},
+	})
+
+	_, err = api.ListMachinesByRoute(
+		context.Background(),
+		&v1.ListMachinesByRouteRequest{Prefix: "10.1.0.0"},
+	)
+	c.Assert(status.Code(err), check.Equals, codes.InvalidArgument)
+}
@@ -17,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -30,6 +31,7 @@ import (
 const (
 	ErrCannotDecryptResponse = Error("cannot decrypt response")
 	ErrCouldNotAllocateIP    = Error("could not find any suitable IP")
+	ErrDuplicateMachineIPs   = Error("IP addresses allocated to several machines")
 
 	// These constants are copied from the upstream tailscale.com/types/key
 	// library, because they are not exported.
@@ -198,7 +200,9 @@ func (h *Headscale) getUsedIPs() (*netipx.IPSet, error) {
 	// but this was quick to get running and it should be enough
 	// to begin experimenting with a dual stack tailnet.
 	var addressesSlices []string
-	h.db.Model(&Machine{}).Pluck("ip_addresses", &addressesSlices)
+	// The soft deleted machines keep their addresses until they are purged,
+	// so that they can be restored.
+	h.db.Unscoped().Model(&Machine{}).Pluck("ip_addresses", &addressesSlices)
 
 	var ips netipx.IPSetBuilder
 	for _, slice := range addressesSlices {
@@ -227,6 +231,78 @@ func (h *Headscale) getUsedIPs() (*netipx.IPSet, error) {
 	return ipSet, nil
 }
 
+// machineIPConflict is an IP address allocated to several machines.
+type machineIPConflict struct {
+	IP       netip.Addr
+	Machines []Machine
+}
+
+// findDuplicateMachineIPs returns the IP addresses allocated to several
+// machines, sorted. The soft deleted machines are included, they keep their
+// addresses until they are purged.
+func (h *Headscale) findDuplicateMachineIPs() ([]machineIPConflict, error) {
+	machines := []Machine{}
+	if err := h.db.Unscoped().Preload("Namespace").Find(&machines).Error; err != nil {
+		return nil, fmt.Errorf("failed to list the machines: %w", err)
+	}
+
+	owners := make(map[netip.Addr][]Machine)
+	for _, machine := range machines {
+		for _, ip := range machine.IPAddresses {
+			if count := len(owners[ip]); count > 0 && owners[ip][count-1].ID == machine.ID {
+				continue
+			}
+			owners[ip] = append(owners[ip], machine)
+		}
+	}
+
+	conflicts := []machineIPConflict{}
+	for ip, machines := range owners {
+		if len(machines) > 1 {
+			conflicts = append(conflicts, machineIPConflict{IP: ip, Machines: machines})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].IP.Less(conflicts[j].IP)
+	})
+
+	return conflicts, nil
+}
+
+// CheckDuplicateMachineIPs logs every IP address allocated to several
+// machines, and returns ErrDuplicateMachineIPs if there is any. The
+// conflicts are resolved by giving new addresses to all but one of the
+// machines with ReassignMachineIP.
+func (h *Headscale) CheckDuplicateMachineIPs() error {
+	conflicts, err := h.findDuplicateMachineIPs()
+	if err != nil {
+		return err
+	}
+
+	for _, conflict := range conflicts {
+		machines := make([]string, len(conflict.Machines))
+		for index, machine := range conflict.Machines {
+			machines[index] = fmt.Sprintf(
+				"%s/%s (%d)",
+				machine.Namespace.Name,
+				machine.Hostname,
+				machine.ID,
+			)
+		}
+
+		log.Error().
+			Str("ip", conflict.IP.String()).
+			Strs("machines", machines).
+			Msg("IP address allocated to several machines, use 'headscale nodes reassign-ip' to give them new ones")
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: %d addresses", ErrDuplicateMachineIPs, len(conflicts))
+	}
+
+	return nil
+}
+
 func tailNodesToString(nodes []*tailcfg.Node) string {
 	temp := make([]string, len(nodes))
 
@@ -1,10 +1,12 @@
 package headscale
 
 import (
+	"errors"
 	"net/netip"
 
 	"go4.org/netipx"
 	"gopkg.in/check.v1"
+	"tailscale.com/types/key"
 )
 
 func (s *Suite) TestGetAvailableIp(c *check.C) {
@@ -25,7 +27,7 @@ func (s *Suite) TestGetUsedIps(c *check.C) {
 	namespace, err := app.CreateNamespace("test-ip")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "testmachine")
@@ -73,7 +75,7 @@ func (s *Suite) TestGetMultiIp(c *check.C) {
 		ips, err := app.getAvailableIPs()
 		c.Assert(err, check.IsNil)
 
-		pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+		pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 		c.Assert(err, check.IsNil)
 
 		_, err = app.GetMachine("test", "testmachine")
@@ -163,7 +165,7 @@ func (s *Suite) TestGetAvailableIpMachineWithoutIP(c *check.C) {
 	namespace, err := app.CreateNamespace("test-ip")
 	c.Assert(err, check.IsNil)
 
-	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil)
+	pak, err := app.CreatePreAuthKey(namespace.Name, false, false, nil, "", 0, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	_, err = app.GetMachine("test", "testmachine")
@@ -199,3 +201,103 @@ func (s *Suite) TestGenerateRandomStringDNSSafe(c *check.C) {
 		}
 	}
 }
+
+func (s *Suite) TestGetAvailableIPsExhausted(c *check.C) {
+	app.cfg.IPPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("fd7a:115c:a1e0::/126"),
+		netip.MustParsePrefix("100.64.0.0/30"),
+	}
+
+	namespace, err := app.CreateNamespace("test-ip-exhausted")
+	c.Assert(err, check.IsNil)
+
+	newMachine := func(hostname string) Machine {
+		return Machine{
+			MachineKey:     MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:        NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:       DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:       hostname,
+			GivenName:      hostname,
+			NamespaceID:    namespace.ID,
+			RegisterMethod: RegisterMethodAuthKey,
+		}
+	}
+
+	// the network and broadcast addresses are never allocated
+	first, err := app.RegisterMachine(newMachine("first"))
+	c.Assert(err, check.IsNil)
+	c.Assert(first.IPAddresses.ToStringSlice(), check.DeepEquals, []string{
+		"fd7a:115c:a1e0::1",
+		"100.64.0.1",
+	})
+
+	second, err := app.RegisterMachine(newMachine("second"))
+	c.Assert(err, check.IsNil)
+	c.Assert(second.IPAddresses.ToStringSlice(), check.DeepEquals, []string{
+		"fd7a:115c:a1e0::2",
+		"100.64.0.2",
+	})
+
+	_, err = app.RegisterMachine(newMachine("third"))
+	c.Assert(err, check.Equals, ErrCouldNotAllocateIP)
+
+	machines, err := app.ListMachines()
+	c.Assert(err, check.IsNil)
+	c.Assert(len(machines), check.Equals, 2)
+
+	// the addresses of a purged machine are given to the next one
+	c.Assert(app.db.Unscoped().Delete(first).Error, check.IsNil)
+
+	third, err := app.RegisterMachine(newMachine("third"))
+	c.Assert(err, check.IsNil)
+	c.Assert(third.IPAddresses.ToStringSlice(), check.DeepEquals, []string{
+		"fd7a:115c:a1e0::1",
+		"100.64.0.1",
+	})
+}
+
+func (s *Suite) TestDuplicateMachineIPs(c *check.C) {
+	namespace, err := app.CreateNamespace("test-ip-duplicate")
+	c.Assert(err, check.IsNil)
+
+	shared := netip.MustParseAddr("10.27.0.1")
+	for index, hostname := range []string{"first", "second", "third"} {
+		ips := MachineAddresses{shared}
+		if hostname == "third" {
+			ips = MachineAddresses{netip.MustParseAddr("10.27.0.2")}
+		}
+		machine := Machine{
+			ID:          uint64(index + 1),
+			MachineKey:  MachinePublicKeyStripPrefix(key.NewMachine().Public()),
+			NodeKey:     NodePublicKeyStripPrefix(key.NewNode().Public()),
+			DiscoKey:    DiscoPublicKeyStripPrefix(key.NewDisco().Public()),
+			Hostname:    hostname,
+			GivenName:   hostname,
+			NamespaceID: namespace.ID,
+			IPAddresses: ips,
+		}
+		c.Assert(app.db.Save(&machine).Error, check.IsNil)
+	}
+
+	conflicts, err := app.findDuplicateMachineIPs()
+	c.Assert(err, check.IsNil)
+	c.Assert(len(conflicts), check.Equals, 1)
+	c.Assert(conflicts[0].IP, check.Equals, shared)
+	c.Assert(len(conflicts[0].Machines), check.Equals, 2)
+	c.Assert(conflicts[0].Machines[0].Hostname, check.Equals, "first")
+	c.Assert(conflicts[0].Machines[1].Hostname, check.Equals, "second")
+
+	err = app.CheckDuplicateMachineIPs()
+	c.Assert(errors.Is(err, ErrDuplicateMachineIPs), check.Equals, true)
+
+	second, err := app.GetMachineByID(2)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.ReassignMachineIP(second), check.IsNil)
+	c.Assert(second.IPAddresses.ToStringSlice(), check.DeepEquals, []string{"10.27.0.3"})
+
+	second, err = app.GetMachineByID(2)
+	c.Assert(err, check.IsNil)
+	c.Assert(second.IPAddresses.ToStringSlice(), check.DeepEquals, []string{"10.27.0.3"})
+
+	c.Assert(app.CheckDuplicateMachineIPs(), check.IsNil)
+}
@@ -0,0 +1,167 @@
+package headscale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	errWebhookUnexpectedStatus = Error("webhook answered with an unexpected status")
+
+	WebhookEventMachineRegistered = "machine.registered"
+	WebhookEventMachineDeleted    = "machine.deleted"
+	WebhookEventMachineExpired    = "machine.expired"
+
+	// webhookQueueSize is the number of events waiting to be delivered
+	// before new events are dropped.
+	webhookQueueSize = 256
+
+	// webhookRetryInterval is the delay before the first retry of a failed
+	// delivery, doubled for every following retry.
+	webhookRetryInterval = time.Second
+)
+
+// webhookEvent is the JSON body POSTed to the webhook.
+type webhookEvent struct {
+	Type      string    `json:"type"`
+	MachineID uint64    `json:"machine_id"`
+	Machine   string    `json:"machine"`
+	Namespace string    `json:"namespace"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// webhookNotifier delivers the machine events to the webhook in the
+// background, so a slow endpoint never holds up the registrations. The
+// events are delivered one at a time, in order.
+type webhookNotifier struct {
+	cfg           WebhookConfig
+	client        *http.Client
+	retryInterval time.Duration
+	events        chan webhookEvent
+}
+
+func newWebhookNotifier(cfg WebhookConfig) *webhookNotifier {
+	return &webhookNotifier{
+		cfg:           cfg,
+		client:        &http.Client{Timeout: cfg.Timeout},
+		retryInterval: webhookRetryInterval,
+		events:        make(chan webhookEvent, webhookQueueSize),
+	}
+}
+
+// notify queues the event for delivery. It never blocks: when the queue is
+// full, the event is dropped.
+func (notifier *webhookNotifier) notify(event webhookEvent) {
+	select {
+	case notifier.events <- event:
+	default:
+		log.Warn().
+			Str("event", event.Type).
+			Str("machine", event.Machine).
+			Msg("Webhook queue is full, dropping event")
+	}
+}
+
+// run delivers the queued events until ctx is done.
+func (notifier *webhookNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-notifier.events:
+			notifier.deliver(ctx, event)
+		}
+	}
+}
+
+// deliver POSTs the event, retrying up to max_retries times with an
+// exponential backoff.
+func (notifier *webhookNotifier) deliver(ctx context.Context, event webhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error().
+			Caller().
+			Err(err).
+			Msg("Could not marshal webhook event")
+
+		return
+	}
+
+	retryInterval := notifier.retryInterval
+	for attempt := 0; ; attempt++ {
+		err = notifier.post(ctx, body)
+		if err == nil {
+			return
+		}
+
+		if attempt >= notifier.cfg.MaxRetries {
+			break
+		}
+
+		log.Debug().
+			Err(err).
+			Str("event", event.Type).
+			Int("attempt", attempt+1).
+			Msg("Could not deliver webhook event, retrying")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+		retryInterval *= 2
+	}
+
+	log.Error().
+		Err(err).
+		Str("event", event.Type).
+		Str("machine", event.Machine).
+		Msg("Could not deliver webhook event, giving up")
+}
+
+func (notifier *webhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		notifier.cfg.URL,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := notifier.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", errWebhookUnexpectedStatus, resp.Status)
+	}
+
+	return nil
+}
+
+// notifyWebhook queues an event about the machine for the webhook, if one
+// is configured.
+func (h *Headscale) notifyWebhook(eventType string, machine Machine) {
+	if h.webhook == nil {
+		return
+	}
+
+	h.webhook.notify(webhookEvent{
+		Type:      eventType,
+		MachineID: machine.ID,
+		Machine:   machine.GivenName,
+		Namespace: machine.Namespace.Name,
+		Timestamp: time.Now().UTC(),
+	})
+}
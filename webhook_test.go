@@ -0,0 +1,129 @@
+package headscale
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func newTestWebhookServer(
+	c *check.C,
+	failures int32,
+) (*httptest.Server, chan webhookEvent, *int32) {
+	events := make(chan webhookEvent, webhookQueueSize)
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, req *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= failures {
+				writer.WriteHeader(http.StatusInternalServerError)
+
+				return
+			}
+
+			var event webhookEvent
+			c.Check(json.NewDecoder(req.Body).Decode(&event), check.IsNil)
+			events <- event
+		},
+	))
+
+	return server, events, &requests
+}
+
+func receiveWebhookEvent(c *check.C, events chan webhookEvent) webhookEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		c.Fatal("no webhook event received")
+	}
+
+	return webhookEvent{}
+}
+
+func (s *Suite) TestWebhookNotifiesMachineLifecycle(c *check.C) {
+	server, events, _ := newTestWebhookServer(c, 0)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app.webhook = newWebhookNotifier(WebhookConfig{URL: server.URL, Timeout: time.Second})
+	defer func() { app.webhook = nil }()
+	go app.webhook.run(ctx)
+
+	namespace, err := app.CreateNamespace("test")
+	c.Assert(err, check.IsNil)
+
+	machine, err := app.RegisterMachine(Machine{
+		MachineKey:     "foo",
+		NodeKey:        "bar",
+		DiscoKey:       "faa",
+		Hostname:       "testmachine",
+		GivenName:      "testmachine",
+		NamespaceID:    namespace.ID,
+		RegisterMethod: RegisterMethodAuthKey,
+	})
+	c.Assert(err, check.IsNil)
+
+	event := receiveWebhookEvent(c, events)
+	c.Assert(event.Type, check.Equals, WebhookEventMachineRegistered)
+	c.Assert(event.MachineID, check.Equals, machine.ID)
+	c.Assert(event.Machine, check.Equals, "testmachine")
+	c.Assert(event.Namespace, check.Equals, "test")
+	c.Assert(event.Timestamp.IsZero(), check.Equals, false)
+
+	machine, err = app.GetMachineByID(machine.ID)
+	c.Assert(err, check.IsNil)
+
+	err = app.ExpireMachine(machine)
+	c.Assert(err, check.IsNil)
+	c.Assert(receiveWebhookEvent(c, events).Type, check.Equals, WebhookEventMachineExpired)
+
+	err = app.DeleteMachine(machine)
+	c.Assert(err, check.IsNil)
+
+	event = receiveWebhookEvent(c, events)
+	c.Assert(event.Type, check.Equals, WebhookEventMachineDeleted)
+	c.Assert(event.Namespace, check.Equals, "test")
+}
+
+func (s *Suite) TestWebhookRetries(c *check.C) {
+	server, events, requests := newTestWebhookServer(c, 2)
+	defer server.Close()
+
+	notifier := newWebhookNotifier(WebhookConfig{
+		URL:        server.URL,
+		Timeout:    time.Second,
+		MaxRetries: 2,
+	})
+	notifier.retryInterval = time.Millisecond
+
+	notifier.deliver(context.Background(), webhookEvent{Type: WebhookEventMachineExpired})
+	c.Assert(receiveWebhookEvent(c, events).Type, check.Equals, WebhookEventMachineExpired)
+	c.Assert(atomic.LoadInt32(requests), check.Equals, int32(3))
+
+	// the retries are bounded
+	failingServer, failingEvents, failingRequests := newTestWebhookServer(c, 10)
+	defer failingServer.Close()
+
+	notifier.cfg.URL = failingServer.URL
+	notifier.deliver(context.Background(), webhookEvent{Type: WebhookEventMachineExpired})
+	c.Assert(atomic.LoadInt32(failingRequests), check.Equals, int32(3))
+	c.Assert(failingEvents, check.HasLen, 0)
+}
+
+func (s *Suite) TestWebhookNotifyDoesNotBlock(c *check.C) {
+	notifier := newWebhookNotifier(WebhookConfig{URL: "http://127.0.0.1:0", Timeout: time.Second})
+
+	// nothing delivers the events, the ones over the queue size are dropped
+	for i := 0; i <= webhookQueueSize; i++ {
+		notifier.notify(webhookEvent{Type: WebhookEventMachineRegistered})
+	}
+	c.Assert(notifier.events, check.HasLen, webhookQueueSize)
+}